@@ -2,9 +2,8 @@ module github.com/sw5005-sus/ceramicraft-payment-mservice/client
 
 go 1.25.7
 
-
 require (
-	github.com/sw5005-sus/ceramicraft-payment-mservice/common v0.0.1
+	github.com/sw5005-sus/ceramicraft-payment-mservice/common v0.0.3
 	google.golang.org/grpc v1.75.1
 )
 
@@ -1,9 +1,11 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -21,6 +23,7 @@ func GetPaymentClient(config *GRpcClientConfig) (paymentpb.PaymentServiceClient,
 			grpc.WithTransportCredentials(insecure.NewCredentials()),
 			grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(1024 * 1024)),
 			grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(1024 * 1024)),
+			grpc.WithUnaryInterceptor(bizErrorInterceptor),
 		}
 		conn, err := grpc.NewClient(fmt.Sprintf("%s:%d", config.Host, config.Port), opts...)
 		if err != nil {
@@ -31,6 +34,19 @@ func GetPaymentClient(config *GRpcClientConfig) (paymentpb.PaymentServiceClient,
 	return client, nil
 }
 
+// bizErrorInterceptor turns the status error of a failed call back into the BizError it carries, so callers
+// branch with bizerror.RespCodeOf. Errors without a business code, such as UNAVAILABLE, are returned as they are.
+func bizErrorInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err == nil {
+		return nil
+	}
+	if bizErr := bizerror.FromGRPCError(err); bizErr.Err == nil {
+		return bizErr
+	}
+	return err
+}
+
 func Destroy() {
 	if conn != nil {
 		err := conn.Close()
@@ -1,5 +1,7 @@
 package biz_error
 
+import "google.golang.org/grpc/status"
+
 // BizError represents a business error with a code, message, and an optional underlying error.
 type BizError struct {
 	Code    int
@@ -13,3 +15,18 @@ func (e *BizError) Error() string {
 	}
 	return e.Message
 }
+
+func (e *BizError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is a BizError with the same code, so callers can branch with errors.Is.
+func (e *BizError) Is(target error) bool {
+	t, ok := target.(*BizError)
+	return ok && t.Code == e.Code
+}
+
+// GRPCStatus lets status.FromError and status.Code understand BizErrors.
+func (e *BizError) GRPCStatus() *status.Status {
+	return ToGRPCStatus(e)
+}
@@ -0,0 +1,109 @@
+package biz_error
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const errorDomain = "payment-ms"
+
+// ErrorMapping describes how a business code is surfaced to gRPC and HTTP callers.
+type ErrorMapping struct {
+	GrpcCode   codes.Code
+	HttpStatus int
+}
+
+var errorMappings = map[paymentpb.RespCode]ErrorMapping{
+	paymentpb.RespCode_SUCCESS:                        {codes.OK, http.StatusOK},
+	paymentpb.RespCode_INSUFFICIENT_BALANCE:           {codes.FailedPrecondition, http.StatusPaymentRequired},
+	paymentpb.RespCode_ACCOUNT_NOT_EXIST:              {codes.NotFound, http.StatusNotFound},
+	paymentpb.RespCode_DUPLICATE_REQUEST:              {codes.AlreadyExists, http.StatusConflict},
+	paymentpb.RespCode_PAY_ORDER_NOT_EXIST:            {codes.NotFound, http.StatusNotFound},
+	paymentpb.RespCode_REFUND_AMOUNT_EXCEEDED:         {codes.FailedPrecondition, http.StatusUnprocessableEntity},
+	paymentpb.RespCode_HOLD_NOT_EXIST:                 {codes.NotFound, http.StatusNotFound},
+	paymentpb.RespCode_HOLD_NOT_ACTIVE:                {codes.FailedPrecondition, http.StatusConflict},
+	paymentpb.RespCode_CAPTURE_AMOUNT_EXCEEDED:        {codes.FailedPrecondition, http.StatusUnprocessableEntity},
+	paymentpb.RespCode_IDEMPOTENCY_KEY_CONFLICT:       {codes.AlreadyExists, http.StatusConflict},
+	paymentpb.RespCode_BALANCE_UPDATE_CONFLICT:        {codes.Aborted, http.StatusConflict},
+	paymentpb.RespCode_TRANSFER_LIMIT_EXCEEDED:        {codes.ResourceExhausted, http.StatusUnprocessableEntity},
+	paymentpb.RespCode_RECIPIENT_NOT_EXIST:            {codes.NotFound, http.StatusNotFound},
+	paymentpb.RespCode_ACCOUNT_NOT_ACTIVE:             {codes.FailedPrecondition, http.StatusForbidden},
+	paymentpb.RespCode_REDEEM_CODE_INVALID:            {codes.InvalidArgument, http.StatusBadRequest},
+	paymentpb.RespCode_REDEEM_CODE_USED:               {codes.FailedPrecondition, http.StatusConflict},
+	paymentpb.RespCode_REDEEM_CODE_EXPIRED:            {codes.FailedPrecondition, http.StatusGone},
+	paymentpb.RespCode_REDEEM_CODE_NOT_YET_VALID:      {codes.FailedPrecondition, http.StatusUnprocessableEntity},
+	paymentpb.RespCode_REDEEM_CODE_REVOKED:            {codes.FailedPrecondition, http.StatusGone},
+	paymentpb.RespCode_REDEEM_CODE_BATCH_NOT_EXIST:    {codes.NotFound, http.StatusNotFound},
+	paymentpb.RespCode_REDEEM_CODE_EXHAUSTED:          {codes.FailedPrecondition, http.StatusGone},
+	paymentpb.RespCode_REDEEM_CODE_USER_LIMIT_REACHED: {codes.ResourceExhausted, http.StatusUnprocessableEntity},
+	paymentpb.RespCode_REDEEM_CODE_MALFORMED:          {codes.InvalidArgument, http.StatusBadRequest},
+	paymentpb.RespCode_REDEEM_CODE_LOCKED:             {codes.ResourceExhausted, http.StatusTooManyRequests},
+	paymentpb.RespCode_BAD_REQUEST:                    {codes.InvalidArgument, http.StatusBadRequest},
+	paymentpb.RespCode_PERMISSION_DENIED:              {codes.PermissionDenied, http.StatusForbidden},
+	paymentpb.RespCode_UNKNOWN_ERROR:                  {codes.Internal, http.StatusInternalServerError},
+}
+
+// MappingOf returns the gRPC and HTTP mapping of a business code, unknown codes map like UNKNOWN_ERROR.
+func MappingOf(code paymentpb.RespCode) ErrorMapping {
+	if m, ok := errorMappings[code]; ok {
+		return m
+	}
+	return errorMappings[paymentpb.RespCode_UNKNOWN_ERROR]
+}
+
+// RespCodeOf returns the business code carried by err: SUCCESS for nil, UNKNOWN_ERROR for non-BizErrors.
+func RespCodeOf(err error) paymentpb.RespCode {
+	if err == nil {
+		return paymentpb.RespCode_SUCCESS
+	}
+	var bizErr *BizError
+	if errors.As(err, &bizErr) {
+		return paymentpb.RespCode(bizErr.Code)
+	}
+	return paymentpb.RespCode_UNKNOWN_ERROR
+}
+
+// HTTPStatusOf returns the HTTP status an error should be answered with.
+func HTTPStatusOf(err error) int {
+	return MappingOf(RespCodeOf(err)).HttpStatus
+}
+
+// ToGRPCStatus converts err into a gRPC status carrying an ErrorInfo detail with the business code.
+func ToGRPCStatus(err error) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+	code := RespCodeOf(err)
+	st := status.New(MappingOf(code).GrpcCode, err.Error())
+	detailed, detailErr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   code.String(),
+		Domain:   errorDomain,
+		Metadata: map[string]string{"code": strconv.Itoa(int(code))},
+	})
+	if detailErr != nil {
+		return st
+	}
+	return detailed
+}
+
+// FromGRPCError restores the BizError carried by a status error created with ToGRPCStatus.
+func FromGRPCError(err error) *BizError {
+	st, ok := status.FromError(err)
+	if !ok {
+		return &BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: err.Error(), Err: err}
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == errorDomain {
+			if code, convErr := strconv.Atoi(info.Metadata["code"]); convErr == nil {
+				return &BizError{Code: code, Message: st.Message()}
+			}
+		}
+	}
+	return &BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: st.Message(), Err: err}
+}
@@ -24,12 +24,33 @@ const (
 type RespCode int32
 
 const (
-	RespCode_SUCCESS              RespCode = 0
-	RespCode_INSUFFICIENT_BALANCE RespCode = 1001
-	RespCode_ACCOUNT_NOT_EXIST    RespCode = 1002
-	RespCode_DUPLICATE_REQUEST    RespCode = 1003
-	RespCode_BAD_REQUEST          RespCode = 4000
-	RespCode_UNKNOWN_ERROR        RespCode = 5000
+	RespCode_SUCCESS                        RespCode = 0
+	RespCode_INSUFFICIENT_BALANCE           RespCode = 1001
+	RespCode_ACCOUNT_NOT_EXIST              RespCode = 1002
+	RespCode_DUPLICATE_REQUEST              RespCode = 1003
+	RespCode_PAY_ORDER_NOT_EXIST            RespCode = 1004
+	RespCode_REFUND_AMOUNT_EXCEEDED         RespCode = 1005
+	RespCode_HOLD_NOT_EXIST                 RespCode = 1006
+	RespCode_HOLD_NOT_ACTIVE                RespCode = 1007
+	RespCode_CAPTURE_AMOUNT_EXCEEDED        RespCode = 1008
+	RespCode_IDEMPOTENCY_KEY_CONFLICT       RespCode = 1009
+	RespCode_BALANCE_UPDATE_CONFLICT        RespCode = 1010
+	RespCode_TRANSFER_LIMIT_EXCEEDED        RespCode = 1011
+	RespCode_RECIPIENT_NOT_EXIST            RespCode = 1012
+	RespCode_ACCOUNT_NOT_ACTIVE             RespCode = 1013
+	RespCode_REDEEM_CODE_INVALID            RespCode = 2001
+	RespCode_REDEEM_CODE_USED               RespCode = 2002
+	RespCode_REDEEM_CODE_EXPIRED            RespCode = 2003
+	RespCode_REDEEM_CODE_NOT_YET_VALID      RespCode = 2004
+	RespCode_REDEEM_CODE_REVOKED            RespCode = 2005
+	RespCode_REDEEM_CODE_BATCH_NOT_EXIST    RespCode = 2006
+	RespCode_REDEEM_CODE_EXHAUSTED          RespCode = 2007
+	RespCode_REDEEM_CODE_USER_LIMIT_REACHED RespCode = 2008
+	RespCode_REDEEM_CODE_MALFORMED          RespCode = 2009
+	RespCode_REDEEM_CODE_LOCKED             RespCode = 2010
+	RespCode_BAD_REQUEST                    RespCode = 4000
+	RespCode_PERMISSION_DENIED              RespCode = 4003
+	RespCode_UNKNOWN_ERROR                  RespCode = 5000
 )
 
 // Enum value maps for RespCode.
@@ -39,16 +60,58 @@ var (
 		1001: "INSUFFICIENT_BALANCE",
 		1002: "ACCOUNT_NOT_EXIST",
 		1003: "DUPLICATE_REQUEST",
+		1004: "PAY_ORDER_NOT_EXIST",
+		1005: "REFUND_AMOUNT_EXCEEDED",
+		1006: "HOLD_NOT_EXIST",
+		1007: "HOLD_NOT_ACTIVE",
+		1008: "CAPTURE_AMOUNT_EXCEEDED",
+		1009: "IDEMPOTENCY_KEY_CONFLICT",
+		1010: "BALANCE_UPDATE_CONFLICT",
+		1011: "TRANSFER_LIMIT_EXCEEDED",
+		1012: "RECIPIENT_NOT_EXIST",
+		1013: "ACCOUNT_NOT_ACTIVE",
+		2001: "REDEEM_CODE_INVALID",
+		2002: "REDEEM_CODE_USED",
+		2003: "REDEEM_CODE_EXPIRED",
+		2004: "REDEEM_CODE_NOT_YET_VALID",
+		2005: "REDEEM_CODE_REVOKED",
+		2006: "REDEEM_CODE_BATCH_NOT_EXIST",
+		2007: "REDEEM_CODE_EXHAUSTED",
+		2008: "REDEEM_CODE_USER_LIMIT_REACHED",
+		2009: "REDEEM_CODE_MALFORMED",
+		2010: "REDEEM_CODE_LOCKED",
 		4000: "BAD_REQUEST",
+		4003: "PERMISSION_DENIED",
 		5000: "UNKNOWN_ERROR",
 	}
 	RespCode_value = map[string]int32{
-		"SUCCESS":              0,
-		"INSUFFICIENT_BALANCE": 1001,
-		"ACCOUNT_NOT_EXIST":    1002,
-		"DUPLICATE_REQUEST":    1003,
-		"BAD_REQUEST":          4000,
-		"UNKNOWN_ERROR":        5000,
+		"SUCCESS":                        0,
+		"INSUFFICIENT_BALANCE":           1001,
+		"ACCOUNT_NOT_EXIST":              1002,
+		"DUPLICATE_REQUEST":              1003,
+		"PAY_ORDER_NOT_EXIST":            1004,
+		"REFUND_AMOUNT_EXCEEDED":         1005,
+		"HOLD_NOT_EXIST":                 1006,
+		"HOLD_NOT_ACTIVE":                1007,
+		"CAPTURE_AMOUNT_EXCEEDED":        1008,
+		"IDEMPOTENCY_KEY_CONFLICT":       1009,
+		"BALANCE_UPDATE_CONFLICT":        1010,
+		"TRANSFER_LIMIT_EXCEEDED":        1011,
+		"RECIPIENT_NOT_EXIST":            1012,
+		"ACCOUNT_NOT_ACTIVE":             1013,
+		"REDEEM_CODE_INVALID":            2001,
+		"REDEEM_CODE_USED":               2002,
+		"REDEEM_CODE_EXPIRED":            2003,
+		"REDEEM_CODE_NOT_YET_VALID":      2004,
+		"REDEEM_CODE_REVOKED":            2005,
+		"REDEEM_CODE_BATCH_NOT_EXIST":    2006,
+		"REDEEM_CODE_EXHAUSTED":          2007,
+		"REDEEM_CODE_USER_LIMIT_REACHED": 2008,
+		"REDEEM_CODE_MALFORMED":          2009,
+		"REDEEM_CODE_LOCKED":             2010,
+		"BAD_REQUEST":                    4000,
+		"PERMISSION_DENIED":              4003,
+		"UNKNOWN_ERROR":                  5000,
 	}
 )
 
@@ -79,6 +142,61 @@ func (RespCode) EnumDescriptor() ([]byte, []int) {
 	return file_proto_payment_proto_rawDescGZIP(), []int{0}
 }
 
+type HoldStatus int32
+
+const (
+	HoldStatus_HOLD_STATUS_UNKNOWN HoldStatus = 0
+	HoldStatus_AUTHORIZED          HoldStatus = 1
+	HoldStatus_CAPTURED            HoldStatus = 2
+	HoldStatus_VOIDED              HoldStatus = 3
+	HoldStatus_EXPIRED             HoldStatus = 4
+)
+
+// Enum value maps for HoldStatus.
+var (
+	HoldStatus_name = map[int32]string{
+		0: "HOLD_STATUS_UNKNOWN",
+		1: "AUTHORIZED",
+		2: "CAPTURED",
+		3: "VOIDED",
+		4: "EXPIRED",
+	}
+	HoldStatus_value = map[string]int32{
+		"HOLD_STATUS_UNKNOWN": 0,
+		"AUTHORIZED":          1,
+		"CAPTURED":            2,
+		"VOIDED":              3,
+		"EXPIRED":             4,
+	}
+)
+
+func (x HoldStatus) Enum() *HoldStatus {
+	p := new(HoldStatus)
+	*p = x
+	return p
+}
+
+func (x HoldStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (HoldStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_payment_proto_enumTypes[1].Descriptor()
+}
+
+func (HoldStatus) Type() protoreflect.EnumType {
+	return &file_proto_payment_proto_enumTypes[1]
+}
+
+func (x HoldStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use HoldStatus.Descriptor instead.
+func (HoldStatus) EnumDescriptor() ([]byte, []int) {
+	return file_proto_payment_proto_rawDescGZIP(), []int{1}
+}
+
 type PayOrderRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UserId        int32                  `protobuf:"varint,1,opt,name=userId,proto3" json:"userId,omitempty"`
@@ -140,13 +258,16 @@ func (x *PayOrderRequest) GetBizId() string {
 }
 
 type PayOrderInfo struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	PayOrderId    string                 `protobuf:"bytes,1,opt,name=payOrderId,proto3" json:"payOrderId,omitempty"`
-	Amount        int32                  `protobuf:"varint,2,opt,name=amount,proto3" json:"amount,omitempty"`
-	UserId        int32                  `protobuf:"varint,3,opt,name=userId,proto3" json:"userId,omitempty"`
-	CreatedTime   int64                  `protobuf:"varint,4,opt,name=createdTime,proto3" json:"createdTime,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	PayOrderId     string                 `protobuf:"bytes,1,opt,name=payOrderId,proto3" json:"payOrderId,omitempty"`
+	Amount         int32                  `protobuf:"varint,2,opt,name=amount,proto3" json:"amount,omitempty"`
+	UserId         int32                  `protobuf:"varint,3,opt,name=userId,proto3" json:"userId,omitempty"`
+	CreatedTime    int64                  `protobuf:"varint,4,opt,name=createdTime,proto3" json:"createdTime,omitempty"`
+	RefundedAmount int32                  `protobuf:"varint,5,opt,name=refundedAmount,proto3" json:"refundedAmount,omitempty"`
+	BalanceBefore  int32                  `protobuf:"varint,6,opt,name=balanceBefore,proto3" json:"balanceBefore,omitempty"`
+	BalanceAfter   int32                  `protobuf:"varint,7,opt,name=balanceAfter,proto3" json:"balanceAfter,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *PayOrderInfo) Reset() {
@@ -207,6 +328,27 @@ func (x *PayOrderInfo) GetCreatedTime() int64 {
 	return 0
 }
 
+func (x *PayOrderInfo) GetRefundedAmount() int32 {
+	if x != nil {
+		return x.RefundedAmount
+	}
+	return 0
+}
+
+func (x *PayOrderInfo) GetBalanceBefore() int32 {
+	if x != nil {
+		return x.BalanceBefore
+	}
+	return 0
+}
+
+func (x *PayOrderInfo) GetBalanceAfter() int32 {
+	if x != nil {
+		return x.BalanceAfter
+	}
+	return 0
+}
+
 type PayOrderResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Code          int32                  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
@@ -272,6 +414,9 @@ type PayOrderQueryRequest struct {
 	UserId        int32                  `protobuf:"varint,1,opt,name=userId,proto3" json:"userId,omitempty"`
 	BizId         *string                `protobuf:"bytes,2,opt,name=bizId,proto3,oneof" json:"bizId,omitempty"`
 	QuerySize     *int32                 `protobuf:"varint,3,opt,name=querySize,proto3,oneof" json:"querySize,omitempty"`
+	PageToken     *string                `protobuf:"bytes,4,opt,name=pageToken,proto3,oneof" json:"pageToken,omitempty"`
+	StartTime     *int64                 `protobuf:"varint,5,opt,name=startTime,proto3,oneof" json:"startTime,omitempty"`
+	EndTime       *int64                 `protobuf:"varint,6,opt,name=endTime,proto3,oneof" json:"endTime,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -327,11 +472,33 @@ func (x *PayOrderQueryRequest) GetQuerySize() int32 {
 	return 0
 }
 
+func (x *PayOrderQueryRequest) GetPageToken() string {
+	if x != nil && x.PageToken != nil {
+		return *x.PageToken
+	}
+	return ""
+}
+
+func (x *PayOrderQueryRequest) GetStartTime() int64 {
+	if x != nil && x.StartTime != nil {
+		return *x.StartTime
+	}
+	return 0
+}
+
+func (x *PayOrderQueryRequest) GetEndTime() int64 {
+	if x != nil && x.EndTime != nil {
+		return *x.EndTime
+	}
+	return 0
+}
+
 type PayOrderQueryResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Code          int32                  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
 	ErrorMsg      *string                `protobuf:"bytes,2,opt,name=errorMsg,proto3,oneof" json:"errorMsg,omitempty"`
 	PayOrderInfos []*PayOrderInfo        `protobuf:"bytes,3,rep,name=payOrderInfos,proto3" json:"payOrderInfos,omitempty"`
+	NextPageToken *string                `protobuf:"bytes,4,opt,name=nextPageToken,proto3,oneof" json:"nextPageToken,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -387,50 +554,927 @@ func (x *PayOrderQueryResponse) GetPayOrderInfos() []*PayOrderInfo {
 	return nil
 }
 
-var File_proto_payment_proto protoreflect.FileDescriptor
+func (x *PayOrderQueryResponse) GetNextPageToken() string {
+	if x != nil && x.NextPageToken != nil {
+		return *x.NextPageToken
+	}
+	return ""
+}
 
-const file_proto_payment_proto_rawDesc = "" +
-	"\n" +
-	"\x13proto/payment.proto\x12\tpaymentpb\"W\n" +
-	"\x0fPayOrderRequest\x12\x16\n" +
-	"\x06userId\x18\x01 \x01(\x05R\x06userId\x12\x16\n" +
-	"\x06amount\x18\x02 \x01(\x05R\x06amount\x12\x14\n" +
-	"\x05bizId\x18\x03 \x01(\tR\x05bizId\"\x80\x01\n" +
-	"\fPayOrderInfo\x12\x1e\n" +
-	"\n" +
-	"payOrderId\x18\x01 \x01(\tR\n" +
-	"payOrderId\x12\x16\n" +
-	"\x06amount\x18\x02 \x01(\x05R\x06amount\x12\x16\n" +
-	"\x06userId\x18\x03 \x01(\x05R\x06userId\x12 \n" +
-	"\vcreatedTime\x18\x04 \x01(\x03R\vcreatedTime\"\xa7\x01\n" +
-	"\x10PayOrderResponse\x12\x12\n" +
-	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x1f\n" +
-	"\berrorMsg\x18\x02 \x01(\tH\x00R\berrorMsg\x88\x01\x01\x12@\n" +
-	"\fpayOrderInfo\x18\x03 \x01(\v2\x17.paymentpb.PayOrderInfoH\x01R\fpayOrderInfo\x88\x01\x01B\v\n" +
-	"\t_errorMsgB\x0f\n" +
-	"\r_payOrderInfo\"\x84\x01\n" +
-	"\x14PayOrderQueryRequest\x12\x16\n" +
-	"\x06userId\x18\x01 \x01(\x05R\x06userId\x12\x19\n" +
-	"\x05bizId\x18\x02 \x01(\tH\x00R\x05bizId\x88\x01\x01\x12!\n" +
-	"\tquerySize\x18\x03 \x01(\x05H\x01R\tquerySize\x88\x01\x01B\b\n" +
-	"\x06_bizIdB\f\n" +
-	"\n" +
-	"_querySize\"\x98\x01\n" +
-	"\x15PayOrderQueryResponse\x12\x12\n" +
-	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x1f\n" +
-	"\berrorMsg\x18\x02 \x01(\tH\x00R\berrorMsg\x88\x01\x01\x12=\n" +
-	"\rpayOrderInfos\x18\x03 \x03(\v2\x17.paymentpb.PayOrderInfoR\rpayOrderInfosB\v\n" +
-	"\t_errorMsg*\x88\x01\n" +
-	"\bRespCode\x12\v\n" +
-	"\aSUCCESS\x10\x00\x12\x19\n" +
-	"\x14INSUFFICIENT_BALANCE\x10\xe9\a\x12\x16\n" +
-	"\x11ACCOUNT_NOT_EXIST\x10\xea\a\x12\x16\n" +
-	"\x11DUPLICATE_REQUEST\x10\xeb\a\x12\x10\n" +
-	"\vBAD_REQUEST\x10\xa0\x1f\x12\x12\n" +
-	"\rUNKNOWN_ERROR\x10\x88'2\xa9\x01\n" +
+type RefundOrderRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BizId         *string                `protobuf:"bytes,1,opt,name=bizId,proto3,oneof" json:"bizId,omitempty"`
+	PayOrderId    *string                `protobuf:"bytes,2,opt,name=payOrderId,proto3,oneof" json:"payOrderId,omitempty"`
+	Amount        int32                  `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	RefundId      string                 `protobuf:"bytes,4,opt,name=refundId,proto3" json:"refundId,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefundOrderRequest) Reset() {
+	*x = RefundOrderRequest{}
+	mi := &file_proto_payment_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefundOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefundOrderRequest) ProtoMessage() {}
+
+func (x *RefundOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_payment_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefundOrderRequest.ProtoReflect.Descriptor instead.
+func (*RefundOrderRequest) Descriptor() ([]byte, []int) {
+	return file_proto_payment_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RefundOrderRequest) GetBizId() string {
+	if x != nil && x.BizId != nil {
+		return *x.BizId
+	}
+	return ""
+}
+
+func (x *RefundOrderRequest) GetPayOrderId() string {
+	if x != nil && x.PayOrderId != nil {
+		return *x.PayOrderId
+	}
+	return ""
+}
+
+func (x *RefundOrderRequest) GetAmount() int32 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *RefundOrderRequest) GetRefundId() string {
+	if x != nil {
+		return x.RefundId
+	}
+	return ""
+}
+
+type RefundOrderInfo struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	RefundOrderId  string                 `protobuf:"bytes,1,opt,name=refundOrderId,proto3" json:"refundOrderId,omitempty"`
+	PayOrderId     string                 `protobuf:"bytes,2,opt,name=payOrderId,proto3" json:"payOrderId,omitempty"`
+	Amount         int32                  `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	RefundedAmount int32                  `protobuf:"varint,4,opt,name=refundedAmount,proto3" json:"refundedAmount,omitempty"`
+	CreatedTime    int64                  `protobuf:"varint,5,opt,name=createdTime,proto3" json:"createdTime,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RefundOrderInfo) Reset() {
+	*x = RefundOrderInfo{}
+	mi := &file_proto_payment_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefundOrderInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefundOrderInfo) ProtoMessage() {}
+
+func (x *RefundOrderInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_payment_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefundOrderInfo.ProtoReflect.Descriptor instead.
+func (*RefundOrderInfo) Descriptor() ([]byte, []int) {
+	return file_proto_payment_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RefundOrderInfo) GetRefundOrderId() string {
+	if x != nil {
+		return x.RefundOrderId
+	}
+	return ""
+}
+
+func (x *RefundOrderInfo) GetPayOrderId() string {
+	if x != nil {
+		return x.PayOrderId
+	}
+	return ""
+}
+
+func (x *RefundOrderInfo) GetAmount() int32 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *RefundOrderInfo) GetRefundedAmount() int32 {
+	if x != nil {
+		return x.RefundedAmount
+	}
+	return 0
+}
+
+func (x *RefundOrderInfo) GetCreatedTime() int64 {
+	if x != nil {
+		return x.CreatedTime
+	}
+	return 0
+}
+
+type RefundOrderResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Code            int32                  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	ErrorMsg        *string                `protobuf:"bytes,2,opt,name=errorMsg,proto3,oneof" json:"errorMsg,omitempty"`
+	RefundOrderInfo *RefundOrderInfo       `protobuf:"bytes,3,opt,name=refundOrderInfo,proto3,oneof" json:"refundOrderInfo,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *RefundOrderResponse) Reset() {
+	*x = RefundOrderResponse{}
+	mi := &file_proto_payment_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefundOrderResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefundOrderResponse) ProtoMessage() {}
+
+func (x *RefundOrderResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_payment_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefundOrderResponse.ProtoReflect.Descriptor instead.
+func (*RefundOrderResponse) Descriptor() ([]byte, []int) {
+	return file_proto_payment_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RefundOrderResponse) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *RefundOrderResponse) GetErrorMsg() string {
+	if x != nil && x.ErrorMsg != nil {
+		return *x.ErrorMsg
+	}
+	return ""
+}
+
+func (x *RefundOrderResponse) GetRefundOrderInfo() *RefundOrderInfo {
+	if x != nil {
+		return x.RefundOrderInfo
+	}
+	return nil
+}
+
+type AuthorizePaymentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        int32                  `protobuf:"varint,1,opt,name=userId,proto3" json:"userId,omitempty"`
+	Amount        int32                  `protobuf:"varint,2,opt,name=amount,proto3" json:"amount,omitempty"`
+	BizId         string                 `protobuf:"bytes,3,opt,name=bizId,proto3" json:"bizId,omitempty"`
+	ExpireSeconds *int32                 `protobuf:"varint,4,opt,name=expireSeconds,proto3,oneof" json:"expireSeconds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuthorizePaymentRequest) Reset() {
+	*x = AuthorizePaymentRequest{}
+	mi := &file_proto_payment_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuthorizePaymentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthorizePaymentRequest) ProtoMessage() {}
+
+func (x *AuthorizePaymentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_payment_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthorizePaymentRequest.ProtoReflect.Descriptor instead.
+func (*AuthorizePaymentRequest) Descriptor() ([]byte, []int) {
+	return file_proto_payment_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *AuthorizePaymentRequest) GetUserId() int32 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *AuthorizePaymentRequest) GetAmount() int32 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *AuthorizePaymentRequest) GetBizId() string {
+	if x != nil {
+		return x.BizId
+	}
+	return ""
+}
+
+func (x *AuthorizePaymentRequest) GetExpireSeconds() int32 {
+	if x != nil && x.ExpireSeconds != nil {
+		return *x.ExpireSeconds
+	}
+	return 0
+}
+
+type CapturePaymentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BizId         string                 `protobuf:"bytes,1,opt,name=bizId,proto3" json:"bizId,omitempty"`
+	Amount        *int32                 `protobuf:"varint,2,opt,name=amount,proto3,oneof" json:"amount,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CapturePaymentRequest) Reset() {
+	*x = CapturePaymentRequest{}
+	mi := &file_proto_payment_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CapturePaymentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CapturePaymentRequest) ProtoMessage() {}
+
+func (x *CapturePaymentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_payment_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CapturePaymentRequest.ProtoReflect.Descriptor instead.
+func (*CapturePaymentRequest) Descriptor() ([]byte, []int) {
+	return file_proto_payment_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CapturePaymentRequest) GetBizId() string {
+	if x != nil {
+		return x.BizId
+	}
+	return ""
+}
+
+func (x *CapturePaymentRequest) GetAmount() int32 {
+	if x != nil && x.Amount != nil {
+		return *x.Amount
+	}
+	return 0
+}
+
+type VoidPaymentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BizId         string                 `protobuf:"bytes,1,opt,name=bizId,proto3" json:"bizId,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VoidPaymentRequest) Reset() {
+	*x = VoidPaymentRequest{}
+	mi := &file_proto_payment_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VoidPaymentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VoidPaymentRequest) ProtoMessage() {}
+
+func (x *VoidPaymentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_payment_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VoidPaymentRequest.ProtoReflect.Descriptor instead.
+func (*VoidPaymentRequest) Descriptor() ([]byte, []int) {
+	return file_proto_payment_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *VoidPaymentRequest) GetBizId() string {
+	if x != nil {
+		return x.BizId
+	}
+	return ""
+}
+
+type PaymentHoldInfo struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	BizId          string                 `protobuf:"bytes,1,opt,name=bizId,proto3" json:"bizId,omitempty"`
+	UserId         int32                  `protobuf:"varint,2,opt,name=userId,proto3" json:"userId,omitempty"`
+	Amount         int32                  `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	CapturedAmount int32                  `protobuf:"varint,4,opt,name=capturedAmount,proto3" json:"capturedAmount,omitempty"`
+	Status         HoldStatus             `protobuf:"varint,5,opt,name=status,proto3,enum=paymentpb.HoldStatus" json:"status,omitempty"`
+	ExpireTime     int64                  `protobuf:"varint,6,opt,name=expireTime,proto3" json:"expireTime,omitempty"`
+	CreatedTime    int64                  `protobuf:"varint,7,opt,name=createdTime,proto3" json:"createdTime,omitempty"`
+	PayOrderId     *string                `protobuf:"bytes,8,opt,name=payOrderId,proto3,oneof" json:"payOrderId,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *PaymentHoldInfo) Reset() {
+	*x = PaymentHoldInfo{}
+	mi := &file_proto_payment_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaymentHoldInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaymentHoldInfo) ProtoMessage() {}
+
+func (x *PaymentHoldInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_payment_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaymentHoldInfo.ProtoReflect.Descriptor instead.
+func (*PaymentHoldInfo) Descriptor() ([]byte, []int) {
+	return file_proto_payment_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *PaymentHoldInfo) GetBizId() string {
+	if x != nil {
+		return x.BizId
+	}
+	return ""
+}
+
+func (x *PaymentHoldInfo) GetUserId() int32 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *PaymentHoldInfo) GetAmount() int32 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *PaymentHoldInfo) GetCapturedAmount() int32 {
+	if x != nil {
+		return x.CapturedAmount
+	}
+	return 0
+}
+
+func (x *PaymentHoldInfo) GetStatus() HoldStatus {
+	if x != nil {
+		return x.Status
+	}
+	return HoldStatus_HOLD_STATUS_UNKNOWN
+}
+
+func (x *PaymentHoldInfo) GetExpireTime() int64 {
+	if x != nil {
+		return x.ExpireTime
+	}
+	return 0
+}
+
+func (x *PaymentHoldInfo) GetCreatedTime() int64 {
+	if x != nil {
+		return x.CreatedTime
+	}
+	return 0
+}
+
+func (x *PaymentHoldInfo) GetPayOrderId() string {
+	if x != nil && x.PayOrderId != nil {
+		return *x.PayOrderId
+	}
+	return ""
+}
+
+type PaymentHoldResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Code            int32                  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	ErrorMsg        *string                `protobuf:"bytes,2,opt,name=errorMsg,proto3,oneof" json:"errorMsg,omitempty"`
+	PaymentHoldInfo *PaymentHoldInfo       `protobuf:"bytes,3,opt,name=paymentHoldInfo,proto3,oneof" json:"paymentHoldInfo,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *PaymentHoldResponse) Reset() {
+	*x = PaymentHoldResponse{}
+	mi := &file_proto_payment_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaymentHoldResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaymentHoldResponse) ProtoMessage() {}
+
+func (x *PaymentHoldResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_payment_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaymentHoldResponse.ProtoReflect.Descriptor instead.
+func (*PaymentHoldResponse) Descriptor() ([]byte, []int) {
+	return file_proto_payment_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PaymentHoldResponse) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *PaymentHoldResponse) GetErrorMsg() string {
+	if x != nil && x.ErrorMsg != nil {
+		return *x.ErrorMsg
+	}
+	return ""
+}
+
+func (x *PaymentHoldResponse) GetPaymentHoldInfo() *PaymentHoldInfo {
+	if x != nil {
+		return x.PaymentHoldInfo
+	}
+	return nil
+}
+
+type TransferRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FromUserId    int32                  `protobuf:"varint,1,opt,name=fromUserId,proto3" json:"fromUserId,omitempty"`
+	ToAccountNo   string                 `protobuf:"bytes,2,opt,name=toAccountNo,proto3" json:"toAccountNo,omitempty"`
+	Amount        int32                  `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	TransferId    string                 `protobuf:"bytes,4,opt,name=transferId,proto3" json:"transferId,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferRequest) Reset() {
+	*x = TransferRequest{}
+	mi := &file_proto_payment_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferRequest) ProtoMessage() {}
+
+func (x *TransferRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_payment_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransferRequest.ProtoReflect.Descriptor instead.
+func (*TransferRequest) Descriptor() ([]byte, []int) {
+	return file_proto_payment_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *TransferRequest) GetFromUserId() int32 {
+	if x != nil {
+		return x.FromUserId
+	}
+	return 0
+}
+
+func (x *TransferRequest) GetToAccountNo() string {
+	if x != nil {
+		return x.ToAccountNo
+	}
+	return ""
+}
+
+func (x *TransferRequest) GetAmount() int32 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *TransferRequest) GetTransferId() string {
+	if x != nil {
+		return x.TransferId
+	}
+	return ""
+}
+
+type TransferInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransferId    string                 `protobuf:"bytes,1,opt,name=transferId,proto3" json:"transferId,omitempty"`
+	FromUserId    int32                  `protobuf:"varint,2,opt,name=fromUserId,proto3" json:"fromUserId,omitempty"`
+	ToAccountNo   string                 `protobuf:"bytes,3,opt,name=toAccountNo,proto3" json:"toAccountNo,omitempty"`
+	Amount        int32                  `protobuf:"varint,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	BalanceAfter  int32                  `protobuf:"varint,5,opt,name=balanceAfter,proto3" json:"balanceAfter,omitempty"`
+	CreatedTime   int64                  `protobuf:"varint,6,opt,name=createdTime,proto3" json:"createdTime,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferInfo) Reset() {
+	*x = TransferInfo{}
+	mi := &file_proto_payment_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferInfo) ProtoMessage() {}
+
+func (x *TransferInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_payment_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransferInfo.ProtoReflect.Descriptor instead.
+func (*TransferInfo) Descriptor() ([]byte, []int) {
+	return file_proto_payment_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *TransferInfo) GetTransferId() string {
+	if x != nil {
+		return x.TransferId
+	}
+	return ""
+}
+
+func (x *TransferInfo) GetFromUserId() int32 {
+	if x != nil {
+		return x.FromUserId
+	}
+	return 0
+}
+
+func (x *TransferInfo) GetToAccountNo() string {
+	if x != nil {
+		return x.ToAccountNo
+	}
+	return ""
+}
+
+func (x *TransferInfo) GetAmount() int32 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *TransferInfo) GetBalanceAfter() int32 {
+	if x != nil {
+		return x.BalanceAfter
+	}
+	return 0
+}
+
+func (x *TransferInfo) GetCreatedTime() int64 {
+	if x != nil {
+		return x.CreatedTime
+	}
+	return 0
+}
+
+type TransferResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          int32                  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	ErrorMsg      *string                `protobuf:"bytes,2,opt,name=errorMsg,proto3,oneof" json:"errorMsg,omitempty"`
+	TransferInfo  *TransferInfo          `protobuf:"bytes,3,opt,name=transferInfo,proto3,oneof" json:"transferInfo,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferResponse) Reset() {
+	*x = TransferResponse{}
+	mi := &file_proto_payment_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferResponse) ProtoMessage() {}
+
+func (x *TransferResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_payment_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransferResponse.ProtoReflect.Descriptor instead.
+func (*TransferResponse) Descriptor() ([]byte, []int) {
+	return file_proto_payment_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *TransferResponse) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *TransferResponse) GetErrorMsg() string {
+	if x != nil && x.ErrorMsg != nil {
+		return *x.ErrorMsg
+	}
+	return ""
+}
+
+func (x *TransferResponse) GetTransferInfo() *TransferInfo {
+	if x != nil {
+		return x.TransferInfo
+	}
+	return nil
+}
+
+var File_proto_payment_proto protoreflect.FileDescriptor
+
+const file_proto_payment_proto_rawDesc = "" +
+	"\n" +
+	"\x13proto/payment.proto\x12\tpaymentpb\"W\n" +
+	"\x0fPayOrderRequest\x12\x16\n" +
+	"\x06userId\x18\x01 \x01(\x05R\x06userId\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x05R\x06amount\x12\x14\n" +
+	"\x05bizId\x18\x03 \x01(\tR\x05bizId\"\xf2\x01\n" +
+	"\fPayOrderInfo\x12\x1e\n" +
+	"\n" +
+	"payOrderId\x18\x01 \x01(\tR\n" +
+	"payOrderId\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x05R\x06amount\x12\x16\n" +
+	"\x06userId\x18\x03 \x01(\x05R\x06userId\x12 \n" +
+	"\vcreatedTime\x18\x04 \x01(\x03R\vcreatedTime\x12&\n" +
+	"\x0erefundedAmount\x18\x05 \x01(\x05R\x0erefundedAmount\x12$\n" +
+	"\rbalanceBefore\x18\x06 \x01(\x05R\rbalanceBefore\x12\"\n" +
+	"\fbalanceAfter\x18\a \x01(\x05R\fbalanceAfter\"\xa7\x01\n" +
+	"\x10PayOrderResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x1f\n" +
+	"\berrorMsg\x18\x02 \x01(\tH\x00R\berrorMsg\x88\x01\x01\x12@\n" +
+	"\fpayOrderInfo\x18\x03 \x01(\v2\x17.paymentpb.PayOrderInfoH\x01R\fpayOrderInfo\x88\x01\x01B\v\n" +
+	"\t_errorMsgB\x0f\n" +
+	"\r_payOrderInfo\"\x91\x02\n" +
+	"\x14PayOrderQueryRequest\x12\x16\n" +
+	"\x06userId\x18\x01 \x01(\x05R\x06userId\x12\x19\n" +
+	"\x05bizId\x18\x02 \x01(\tH\x00R\x05bizId\x88\x01\x01\x12!\n" +
+	"\tquerySize\x18\x03 \x01(\x05H\x01R\tquerySize\x88\x01\x01\x12!\n" +
+	"\tpageToken\x18\x04 \x01(\tH\x02R\tpageToken\x88\x01\x01\x12!\n" +
+	"\tstartTime\x18\x05 \x01(\x03H\x03R\tstartTime\x88\x01\x01\x12\x1d\n" +
+	"\aendTime\x18\x06 \x01(\x03H\x04R\aendTime\x88\x01\x01B\b\n" +
+	"\x06_bizIdB\f\n" +
+	"\n" +
+	"_querySizeB\f\n" +
+	"\n" +
+	"_pageTokenB\f\n" +
+	"\n" +
+	"_startTimeB\n" +
+	"\n" +
+	"\b_endTime\"\xd5\x01\n" +
+	"\x15PayOrderQueryResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x1f\n" +
+	"\berrorMsg\x18\x02 \x01(\tH\x00R\berrorMsg\x88\x01\x01\x12=\n" +
+	"\rpayOrderInfos\x18\x03 \x03(\v2\x17.paymentpb.PayOrderInfoR\rpayOrderInfos\x12)\n" +
+	"\rnextPageToken\x18\x04 \x01(\tH\x01R\rnextPageToken\x88\x01\x01B\v\n" +
+	"\t_errorMsgB\x10\n" +
+	"\x0e_nextPageToken\"\xa1\x01\n" +
+	"\x12RefundOrderRequest\x12\x19\n" +
+	"\x05bizId\x18\x01 \x01(\tH\x00R\x05bizId\x88\x01\x01\x12#\n" +
+	"\n" +
+	"payOrderId\x18\x02 \x01(\tH\x01R\n" +
+	"payOrderId\x88\x01\x01\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x05R\x06amount\x12\x1a\n" +
+	"\brefundId\x18\x04 \x01(\tR\brefundIdB\b\n" +
+	"\x06_bizIdB\r\n" +
+	"\v_payOrderId\"\xb9\x01\n" +
+	"\x0fRefundOrderInfo\x12$\n" +
+	"\rrefundOrderId\x18\x01 \x01(\tR\rrefundOrderId\x12\x1e\n" +
+	"\n" +
+	"payOrderId\x18\x02 \x01(\tR\n" +
+	"payOrderId\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x05R\x06amount\x12&\n" +
+	"\x0erefundedAmount\x18\x04 \x01(\x05R\x0erefundedAmount\x12 \n" +
+	"\vcreatedTime\x18\x05 \x01(\x03R\vcreatedTime\"\xb6\x01\n" +
+	"\x13RefundOrderResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x1f\n" +
+	"\berrorMsg\x18\x02 \x01(\tH\x00R\berrorMsg\x88\x01\x01\x12I\n" +
+	"\x0frefundOrderInfo\x18\x03 \x01(\v2\x1a.paymentpb.RefundOrderInfoH\x01R\x0frefundOrderInfo\x88\x01\x01B\v\n" +
+	"\t_errorMsgB\x12\n" +
+	"\x10_refundOrderInfo\"\x9c\x01\n" +
+	"\x17AuthorizePaymentRequest\x12\x16\n" +
+	"\x06userId\x18\x01 \x01(\x05R\x06userId\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x05R\x06amount\x12\x14\n" +
+	"\x05bizId\x18\x03 \x01(\tR\x05bizId\x12)\n" +
+	"\rexpireSeconds\x18\x04 \x01(\x05H\x00R\rexpireSeconds\x88\x01\x01B\x10\n" +
+	"\x0e_expireSeconds\"U\n" +
+	"\x15CapturePaymentRequest\x12\x14\n" +
+	"\x05bizId\x18\x01 \x01(\tR\x05bizId\x12\x1b\n" +
+	"\x06amount\x18\x02 \x01(\x05H\x00R\x06amount\x88\x01\x01B\t\n" +
+	"\a_amount\"*\n" +
+	"\x12VoidPaymentRequest\x12\x14\n" +
+	"\x05bizId\x18\x01 \x01(\tR\x05bizId\"\xa4\x02\n" +
+	"\x0fPaymentHoldInfo\x12\x14\n" +
+	"\x05bizId\x18\x01 \x01(\tR\x05bizId\x12\x16\n" +
+	"\x06userId\x18\x02 \x01(\x05R\x06userId\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x05R\x06amount\x12&\n" +
+	"\x0ecapturedAmount\x18\x04 \x01(\x05R\x0ecapturedAmount\x12-\n" +
+	"\x06status\x18\x05 \x01(\x0e2\x15.paymentpb.HoldStatusR\x06status\x12\x1e\n" +
+	"\n" +
+	"expireTime\x18\x06 \x01(\x03R\n" +
+	"expireTime\x12 \n" +
+	"\vcreatedTime\x18\a \x01(\x03R\vcreatedTime\x12#\n" +
+	"\n" +
+	"payOrderId\x18\b \x01(\tH\x00R\n" +
+	"payOrderId\x88\x01\x01B\r\n" +
+	"\v_payOrderId\"\xb6\x01\n" +
+	"\x13PaymentHoldResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x1f\n" +
+	"\berrorMsg\x18\x02 \x01(\tH\x00R\berrorMsg\x88\x01\x01\x12I\n" +
+	"\x0fpaymentHoldInfo\x18\x03 \x01(\v2\x1a.paymentpb.PaymentHoldInfoH\x01R\x0fpaymentHoldInfo\x88\x01\x01B\v\n" +
+	"\t_errorMsgB\x12\n" +
+	"\x10_paymentHoldInfo\"\x8b\x01\n" +
+	"\x0fTransferRequest\x12\x1e\n" +
+	"\n" +
+	"fromUserId\x18\x01 \x01(\x05R\n" +
+	"fromUserId\x12 \n" +
+	"\vtoAccountNo\x18\x02 \x01(\tR\vtoAccountNo\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x05R\x06amount\x12\x1e\n" +
+	"\n" +
+	"transferId\x18\x04 \x01(\tR\n" +
+	"transferId\"\xce\x01\n" +
+	"\fTransferInfo\x12\x1e\n" +
+	"\n" +
+	"transferId\x18\x01 \x01(\tR\n" +
+	"transferId\x12\x1e\n" +
+	"\n" +
+	"fromUserId\x18\x02 \x01(\x05R\n" +
+	"fromUserId\x12 \n" +
+	"\vtoAccountNo\x18\x03 \x01(\tR\vtoAccountNo\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\x05R\x06amount\x12\"\n" +
+	"\fbalanceAfter\x18\x05 \x01(\x05R\fbalanceAfter\x12 \n" +
+	"\vcreatedTime\x18\x06 \x01(\x03R\vcreatedTime\"\xa7\x01\n" +
+	"\x10TransferResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x1f\n" +
+	"\berrorMsg\x18\x02 \x01(\tH\x00R\berrorMsg\x88\x01\x01\x12@\n" +
+	"\ftransferInfo\x18\x03 \x01(\v2\x17.paymentpb.TransferInfoH\x01R\ftransferInfo\x88\x01\x01B\v\n" +
+	"\t_errorMsgB\x0f\n" +
+	"\r_transferInfo*\xcb\x05\n" +
+	"\bRespCode\x12\v\n" +
+	"\aSUCCESS\x10\x00\x12\x19\n" +
+	"\x14INSUFFICIENT_BALANCE\x10\xe9\a\x12\x16\n" +
+	"\x11ACCOUNT_NOT_EXIST\x10\xea\a\x12\x16\n" +
+	"\x11DUPLICATE_REQUEST\x10\xeb\a\x12\x18\n" +
+	"\x13PAY_ORDER_NOT_EXIST\x10\xec\a\x12\x1b\n" +
+	"\x16REFUND_AMOUNT_EXCEEDED\x10\xed\a\x12\x13\n" +
+	"\x0eHOLD_NOT_EXIST\x10\xee\a\x12\x14\n" +
+	"\x0fHOLD_NOT_ACTIVE\x10\xef\a\x12\x1c\n" +
+	"\x17CAPTURE_AMOUNT_EXCEEDED\x10\xf0\a\x12\x1d\n" +
+	"\x18IDEMPOTENCY_KEY_CONFLICT\x10\xf1\a\x12\x1c\n" +
+	"\x17BALANCE_UPDATE_CONFLICT\x10\xf2\a\x12\x1c\n" +
+	"\x17TRANSFER_LIMIT_EXCEEDED\x10\xf3\a\x12\x18\n" +
+	"\x13RECIPIENT_NOT_EXIST\x10\xf4\a\x12\x17\n" +
+	"\x12ACCOUNT_NOT_ACTIVE\x10\xf5\a\x12\x18\n" +
+	"\x13REDEEM_CODE_INVALID\x10\xd1\x0f\x12\x15\n" +
+	"\x10REDEEM_CODE_USED\x10\xd2\x0f\x12\x18\n" +
+	"\x13REDEEM_CODE_EXPIRED\x10\xd3\x0f\x12\x1e\n" +
+	"\x19REDEEM_CODE_NOT_YET_VALID\x10\xd4\x0f\x12\x18\n" +
+	"\x13REDEEM_CODE_REVOKED\x10\xd5\x0f\x12 \n" +
+	"\x1bREDEEM_CODE_BATCH_NOT_EXIST\x10\xd6\x0f\x12\x1a\n" +
+	"\x15REDEEM_CODE_EXHAUSTED\x10\xd7\x0f\x12#\n" +
+	"\x1eREDEEM_CODE_USER_LIMIT_REACHED\x10\xd8\x0f\x12\x1a\n" +
+	"\x15REDEEM_CODE_MALFORMED\x10\xd9\x0f\x12\x17\n" +
+	"\x12REDEEM_CODE_LOCKED\x10\xda\x0f\x12\x10\n" +
+	"\vBAD_REQUEST\x10\xa0\x1f\x12\x16\n" +
+	"\x11PERMISSION_DENIED\x10\xa3\x1f\x12\x12\n" +
+	"\rUNKNOWN_ERROR\x10\x88'*\\\n" +
+	"\n" +
+	"HoldStatus\x12\x17\n" +
+	"\x13HOLD_STATUS_UNKNOWN\x10\x00\x12\x0e\n" +
+	"\n" +
+	"AUTHORIZED\x10\x01\x12\f\n" +
+	"\bCAPTURED\x10\x02\x12\n" +
+	"\n" +
+	"\x06VOIDED\x10\x03\x12\v\n" +
+	"\aEXPIRED\x10\x042\xb6\x04\n" +
 	"\x0ePaymentService\x12C\n" +
 	"\bPayOrder\x12\x1a.paymentpb.PayOrderRequest\x1a\x1b.paymentpb.PayOrderResponse\x12R\n" +
-	"\rQueryPayOrder\x12\x1f.paymentpb.PayOrderQueryRequest\x1a .paymentpb.PayOrderQueryResponseB\x16Z\x14/paymentpb;paymentpbb\x06proto3"
+	"\rQueryPayOrder\x12\x1f.paymentpb.PayOrderQueryRequest\x1a .paymentpb.PayOrderQueryResponse\x12L\n" +
+	"\vRefundOrder\x12\x1d.paymentpb.RefundOrderRequest\x1a\x1e.paymentpb.RefundOrderResponse\x12V\n" +
+	"\x10AuthorizePayment\x12\".paymentpb.AuthorizePaymentRequest\x1a\x1e.paymentpb.PaymentHoldResponse\x12R\n" +
+	"\x0eCapturePayment\x12 .paymentpb.CapturePaymentRequest\x1a\x1e.paymentpb.PaymentHoldResponse\x12L\n" +
+	"\vVoidPayment\x12\x1d.paymentpb.VoidPaymentRequest\x1a\x1e.paymentpb.PaymentHoldResponse\x12C\n" +
+	"\bTransfer\x12\x1a.paymentpb.TransferRequest\x1a\x1b.paymentpb.TransferResponseB\x16Z\x14/paymentpb;paymentpbb\x06proto3"
 
 var (
 	file_proto_payment_proto_rawDescOnce sync.Once
@@ -444,28 +1488,54 @@ func file_proto_payment_proto_rawDescGZIP() []byte {
 	return file_proto_payment_proto_rawDescData
 }
 
-var file_proto_payment_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_proto_payment_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_proto_payment_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_proto_payment_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
 var file_proto_payment_proto_goTypes = []any{
-	(RespCode)(0),                 // 0: paymentpb.RespCode
-	(*PayOrderRequest)(nil),       // 1: paymentpb.PayOrderRequest
-	(*PayOrderInfo)(nil),          // 2: paymentpb.PayOrderInfo
-	(*PayOrderResponse)(nil),      // 3: paymentpb.PayOrderResponse
-	(*PayOrderQueryRequest)(nil),  // 4: paymentpb.PayOrderQueryRequest
-	(*PayOrderQueryResponse)(nil), // 5: paymentpb.PayOrderQueryResponse
+	(RespCode)(0),                   // 0: paymentpb.RespCode
+	(HoldStatus)(0),                 // 1: paymentpb.HoldStatus
+	(*PayOrderRequest)(nil),         // 2: paymentpb.PayOrderRequest
+	(*PayOrderInfo)(nil),            // 3: paymentpb.PayOrderInfo
+	(*PayOrderResponse)(nil),        // 4: paymentpb.PayOrderResponse
+	(*PayOrderQueryRequest)(nil),    // 5: paymentpb.PayOrderQueryRequest
+	(*PayOrderQueryResponse)(nil),   // 6: paymentpb.PayOrderQueryResponse
+	(*RefundOrderRequest)(nil),      // 7: paymentpb.RefundOrderRequest
+	(*RefundOrderInfo)(nil),         // 8: paymentpb.RefundOrderInfo
+	(*RefundOrderResponse)(nil),     // 9: paymentpb.RefundOrderResponse
+	(*AuthorizePaymentRequest)(nil), // 10: paymentpb.AuthorizePaymentRequest
+	(*CapturePaymentRequest)(nil),   // 11: paymentpb.CapturePaymentRequest
+	(*VoidPaymentRequest)(nil),      // 12: paymentpb.VoidPaymentRequest
+	(*PaymentHoldInfo)(nil),         // 13: paymentpb.PaymentHoldInfo
+	(*PaymentHoldResponse)(nil),     // 14: paymentpb.PaymentHoldResponse
+	(*TransferRequest)(nil),         // 15: paymentpb.TransferRequest
+	(*TransferInfo)(nil),            // 16: paymentpb.TransferInfo
+	(*TransferResponse)(nil),        // 17: paymentpb.TransferResponse
 }
 var file_proto_payment_proto_depIdxs = []int32{
-	2, // 0: paymentpb.PayOrderResponse.payOrderInfo:type_name -> paymentpb.PayOrderInfo
-	2, // 1: paymentpb.PayOrderQueryResponse.payOrderInfos:type_name -> paymentpb.PayOrderInfo
-	1, // 2: paymentpb.PaymentService.PayOrder:input_type -> paymentpb.PayOrderRequest
-	4, // 3: paymentpb.PaymentService.QueryPayOrder:input_type -> paymentpb.PayOrderQueryRequest
-	3, // 4: paymentpb.PaymentService.PayOrder:output_type -> paymentpb.PayOrderResponse
-	5, // 5: paymentpb.PaymentService.QueryPayOrder:output_type -> paymentpb.PayOrderQueryResponse
-	4, // [4:6] is the sub-list for method output_type
-	2, // [2:4] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	3,  // 0: paymentpb.PayOrderResponse.payOrderInfo:type_name -> paymentpb.PayOrderInfo
+	3,  // 1: paymentpb.PayOrderQueryResponse.payOrderInfos:type_name -> paymentpb.PayOrderInfo
+	8,  // 2: paymentpb.RefundOrderResponse.refundOrderInfo:type_name -> paymentpb.RefundOrderInfo
+	1,  // 3: paymentpb.PaymentHoldInfo.status:type_name -> paymentpb.HoldStatus
+	13, // 4: paymentpb.PaymentHoldResponse.paymentHoldInfo:type_name -> paymentpb.PaymentHoldInfo
+	16, // 5: paymentpb.TransferResponse.transferInfo:type_name -> paymentpb.TransferInfo
+	2,  // 6: paymentpb.PaymentService.PayOrder:input_type -> paymentpb.PayOrderRequest
+	5,  // 7: paymentpb.PaymentService.QueryPayOrder:input_type -> paymentpb.PayOrderQueryRequest
+	7,  // 8: paymentpb.PaymentService.RefundOrder:input_type -> paymentpb.RefundOrderRequest
+	10, // 9: paymentpb.PaymentService.AuthorizePayment:input_type -> paymentpb.AuthorizePaymentRequest
+	11, // 10: paymentpb.PaymentService.CapturePayment:input_type -> paymentpb.CapturePaymentRequest
+	12, // 11: paymentpb.PaymentService.VoidPayment:input_type -> paymentpb.VoidPaymentRequest
+	15, // 12: paymentpb.PaymentService.Transfer:input_type -> paymentpb.TransferRequest
+	4,  // 13: paymentpb.PaymentService.PayOrder:output_type -> paymentpb.PayOrderResponse
+	6,  // 14: paymentpb.PaymentService.QueryPayOrder:output_type -> paymentpb.PayOrderQueryResponse
+	9,  // 15: paymentpb.PaymentService.RefundOrder:output_type -> paymentpb.RefundOrderResponse
+	14, // 16: paymentpb.PaymentService.AuthorizePayment:output_type -> paymentpb.PaymentHoldResponse
+	14, // 17: paymentpb.PaymentService.CapturePayment:output_type -> paymentpb.PaymentHoldResponse
+	14, // 18: paymentpb.PaymentService.VoidPayment:output_type -> paymentpb.PaymentHoldResponse
+	17, // 19: paymentpb.PaymentService.Transfer:output_type -> paymentpb.TransferResponse
+	13, // [13:20] is the sub-list for method output_type
+	6,  // [6:13] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_proto_payment_proto_init() }
@@ -476,13 +1546,20 @@ func file_proto_payment_proto_init() {
 	file_proto_payment_proto_msgTypes[2].OneofWrappers = []any{}
 	file_proto_payment_proto_msgTypes[3].OneofWrappers = []any{}
 	file_proto_payment_proto_msgTypes[4].OneofWrappers = []any{}
+	file_proto_payment_proto_msgTypes[5].OneofWrappers = []any{}
+	file_proto_payment_proto_msgTypes[7].OneofWrappers = []any{}
+	file_proto_payment_proto_msgTypes[8].OneofWrappers = []any{}
+	file_proto_payment_proto_msgTypes[9].OneofWrappers = []any{}
+	file_proto_payment_proto_msgTypes[11].OneofWrappers = []any{}
+	file_proto_payment_proto_msgTypes[12].OneofWrappers = []any{}
+	file_proto_payment_proto_msgTypes[15].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_payment_proto_rawDesc), len(file_proto_payment_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   5,
+			NumEnums:      2,
+			NumMessages:   16,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -0,0 +1,18 @@
+package command
+
+import (
+	"context"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/service"
+)
+
+// backfillBalance fills balance_before and balance_after of change logs written before they existed.
+func backfillBalance(ctx context.Context, args []string) error {
+	updated, err := service.GetUserAccountService().BackfillBalanceSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	log.Logger.Infof("backfill-balance done, %d change logs updated", updated)
+	return nil
+}
@@ -0,0 +1,32 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CommandFunc is a one-shot maintenance task run from the command line instead of starting the servers.
+type CommandFunc func(ctx context.Context, args []string) error
+
+var commands = map[string]CommandFunc{
+	"backfill-balance":    backfillBalance,
+	"hash-redeem-codes":   hashRedeemCodes,
+	"reconcile":           reconcile,
+	"replay-dead-letters": replayDeadLetters,
+}
+
+// Run executes the command registered under name.
+func Run(ctx context.Context, name string, args []string) error {
+	fn, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown command %q, available commands: %s", name, strings.Join(names, ", "))
+	}
+	return fn(ctx, args)
+}
@@ -0,0 +1,20 @@
+package command
+
+import (
+	"context"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/service"
+)
+
+// hashRedeemCodes replaces the plaintext of redeem codes stored before codes were hashed with their keyed hash,
+// and encrypts the plaintext left on job generated codes that were not exported yet. Run it right after deploying,
+// those codes cannot be redeemed until it has. It can be run again safely.
+func hashRedeemCodes(ctx context.Context, args []string) error {
+	hashed, err := service.GetRedeemCodeService().HashPlaintextRedeemCodes(ctx)
+	if err != nil {
+		return err
+	}
+	log.Logger.Infof("hash-redeem-codes done, %d redeem codes hashed or encrypted", hashed)
+	return nil
+}
@@ -0,0 +1,22 @@
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/service"
+)
+
+// reconcile runs one reconciliation and fails if it found discrepancies, so a scheduler can alert on the exit code.
+func reconcile(ctx context.Context, args []string) error {
+	report, err := service.GetReconciliationService().Reconcile(ctx)
+	if err != nil {
+		return err
+	}
+	if len(report.Discrepancies) > 0 {
+		return fmt.Errorf("reconciliation %s found %d discrepancies", report.RunId, len(report.Discrepancies))
+	}
+	log.Logger.Infof("reconcile done, %d accounts and %d redeem codes checked", report.AccountsChecked, report.RedeemCodesChecked)
+	return nil
+}
@@ -0,0 +1,31 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/mq"
+)
+
+// replayDeadLetters publishes the dead-lettered messages of a topic back onto it once the cause of the failures
+// is fixed: `replay-dead-letters <topic> [limit]`.
+func replayDeadLetters(ctx context.Context, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: replay-dead-letters <topic> [limit]")
+	}
+	limit := 0
+	if len(args) == 2 {
+		var err error
+		if limit, err = strconv.Atoi(args[1]); err != nil || limit < 0 {
+			return fmt.Errorf("invalid limit %q", args[1])
+		}
+	}
+	replayed, err := mq.ReplayDeadLetters(ctx, args[0], limit)
+	if err != nil {
+		return fmt.Errorf("replayed %d messages of %s before failing: %w", replayed, mq.DeadLetterTopic(args[0]), err)
+	}
+	log.Logger.Infof("replay-dead-letters done, %d messages replayed from %s", replayed, mq.DeadLetterTopic(args[0]))
+	return nil
+}
@@ -11,11 +11,56 @@ var (
 )
 
 type Conf struct {
-	GrpcConfig  *GrpcConfig          `mapstructure:"grpc"`
-	LogConfig   *LogConfig           `mapstructure:"log"`
-	HttpConfig  *HttpConfig          `mapstructure:"http"`
-	MySQLConfig *MySQL               `mapstructure:"mysql"`
-	KafkaConfig *KafkaConsumerConfig `mapstructure:"kafka"`
+	GrpcConfig    *GrpcConfig          `mapstructure:"grpc"`
+	LogConfig     *LogConfig           `mapstructure:"log"`
+	HttpConfig    *HttpConfig          `mapstructure:"http"`
+	MySQLConfig   *MySQL               `mapstructure:"mysql"`
+	KafkaConfig   *KafkaConsumerConfig `mapstructure:"kafka"`
+	PaymentConfig *PaymentConfig       `mapstructure:"payment"`
+}
+
+type PaymentConfig struct {
+	HoldExpireSeconds      int `mapstructure:"hold_expire_seconds"`
+	HoldExpiryScanInterval int `mapstructure:"hold_expiry_scan_interval"`
+	// BalanceUpdateMode is "optimistic" (compare-and-swap with retries) or "pessimistic" (SELECT ... FOR UPDATE)
+	BalanceUpdateMode           string `mapstructure:"balance_update_mode"`
+	BalanceUpdateMaxRetries     int    `mapstructure:"balance_update_max_retries"`
+	BalanceUpdateRetryBackoffMs int    `mapstructure:"balance_update_retry_backoff_ms"`
+	// per-user limits on transfers sent within a calendar day, 0 means unlimited
+	TransferDailyAmountLimit int `mapstructure:"transfer_daily_amount_limit"`
+	TransferDailyCountLimit  int `mapstructure:"transfer_daily_count_limit"`
+	// seconds between reconciliation runs, 0 disables the job
+	ReconciliationInterval int `mapstructure:"reconciliation_interval"`
+	// redeem code generation jobs insert chunk_size codes per transaction and take up to max_count codes
+	RedeemCodeGenChunkSize int `mapstructure:"redeem_code_gen_chunk_size"`
+	RedeemCodeGenMaxCount  int `mapstructure:"redeem_code_gen_max_count"`
+	// seconds between scans resuming unfinished generation jobs, 0 disables the job
+	RedeemCodeGenResumeInterval int `mapstructure:"redeem_code_gen_resume_interval"`
+	// format of new redeem codes, defaults to 15 characters without 0, O, 1, I and L, a check character and
+	// groups of 4, a negative group size shows codes without dashes
+	RedeemCodeAlphabet  string `mapstructure:"redeem_code_alphabet"`
+	RedeemCodeLength    int    `mapstructure:"redeem_code_length"`
+	RedeemCodeGroupSize int    `mapstructure:"redeem_code_group_size"`
+	// prefix of the codes of a campaign, keyed by campaign in lower case
+	RedeemCodeCampaignPrefixes map[string]string `mapstructure:"redeem_code_campaign_prefixes"`
+	// failed redeem code top-ups allowed per user and per ip before a lockout, 0 disables the lockout of the subject
+	RedeemCodeLockoutUserMaxFailures int `mapstructure:"redeem_code_lockout_user_max_failures"`
+	RedeemCodeLockoutIPMaxFailures   int `mapstructure:"redeem_code_lockout_ip_max_failures"`
+	// seconds of the first lockout, doubled for each further lockout up to max seconds
+	RedeemCodeLockoutBaseSeconds int `mapstructure:"redeem_code_lockout_base_seconds"`
+	RedeemCodeLockoutMaxSeconds  int `mapstructure:"redeem_code_lockout_max_seconds"`
+	// seconds without failures after which failures and past lockouts are forgotten
+	RedeemCodeLockoutResetSeconds int `mapstructure:"redeem_code_lockout_reset_seconds"`
+	// user ids of the merchants and admins allowed to clear lockouts, auth tokens carry no role
+	RedeemCodeLockoutOperatorIds []int `mapstructure:"redeem_code_lockout_operator_ids"`
+	// key of the HMAC redeem codes are stored under, set through REDEEM_CODE_HASH_SECRET
+	RedeemCodeHashSecret string `mapstructure:"redeem_code_hash_secret"`
+	// key job generated codes are encrypted under until their first export, set through REDEEM_CODE_ENCRYPTION_KEY
+	RedeemCodeEncryptionKey string `mapstructure:"redeem_code_encryption_key"`
+	// milliseconds between outbox relays publishing pending events, 0 disables the relay
+	OutboxRelayIntervalMs int `mapstructure:"outbox_relay_interval_ms"`
+	// events published per Kafka write
+	OutboxRelayBatchSize int `mapstructure:"outbox_relay_batch_size"`
 }
 
 type KafkaConsumerConfig struct {
@@ -23,6 +68,11 @@ type KafkaConsumerConfig struct {
 	GroupID        string   `mapstructure:"group_id"`
 	MaxBytes       int      `mapstructure:"max_bytes"`
 	CommitInterval int      `mapstructure:"commit_interval"`
+	// attempts at processing a message before it is sent to the dead-letter topic of its topic
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// milliseconds before the first retry, doubled for each further retry up to max
+	RetryBackoffMs    int `mapstructure:"retry_backoff_ms"`
+	RetryMaxBackoffMs int `mapstructure:"retry_max_backoff_ms"`
 }
 
 type HttpConfig struct {
@@ -71,4 +121,23 @@ func Init() {
 	} else {
 		panic("MYSQL_PASSWORD environment variable is not set")
 	}
+	if Config.PaymentConfig == nil {
+		Config.PaymentConfig = &PaymentConfig{}
+	}
+	// changing the secret makes every stored redeem code unusable
+	redeemCodeHashSecret := os.Getenv("REDEEM_CODE_HASH_SECRET")
+	if redeemCodeHashSecret != "" {
+		Config.PaymentConfig.RedeemCodeHashSecret = redeemCodeHashSecret
+	} else {
+		panic("REDEEM_CODE_HASH_SECRET environment variable is not set")
+	}
+	// changing the key makes the codes of jobs that were not exported yet unrecoverable
+	redeemCodeEncryptionKey := os.Getenv("REDEEM_CODE_ENCRYPTION_KEY")
+	if redeemCodeEncryptionKey == "" {
+		panic("REDEEM_CODE_ENCRYPTION_KEY environment variable is not set")
+	}
+	if redeemCodeEncryptionKey == redeemCodeHashSecret {
+		panic("REDEEM_CODE_ENCRYPTION_KEY must differ from REDEEM_CODE_HASH_SECRET")
+	}
+	Config.PaymentConfig.RedeemCodeEncryptionKey = redeemCodeEncryptionKey
 }
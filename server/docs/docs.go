@@ -17,7 +17,7 @@ const docTemplate = `{
     "paths": {
         "/payment-ms/v1/customer/pay-accounts/self": {
             "get": {
-                "description": "Get user pay account info",
+                "description": "Get user pay account info, with the account number in full as other users need it to transfer to the account",
                 "consumes": [
                     "application/json"
                 ],
@@ -52,13 +52,25 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/data.BaseResponse"
                         }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
                     }
                 }
             }
         },
         "/payment-ms/v1/customer/pay-accounts/self/top-ups": {
             "post": {
-                "description": "Top up user pay account using a redeem code",
+                "description": "Top up user pay account using a redeem code. Case, dashes and spaces in the code are ignored, a code failing its check character is rejected as malformed. Too many invalid or malformed codes lock the user and the ip out for a while, longer each time, answered with 429 REDEEM_CODE_LOCKED",
                 "consumes": [
                     "application/json"
                 ],
@@ -104,13 +116,43 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/data.BaseResponse"
                         }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
                     }
                 }
             }
         },
-        "/payment-ms/v1/merchant/redeem-codes": {
+        "/payment-ms/v1/customer/pay-accounts/self/transactions": {
             "get": {
-                "description": "Query redeem codes",
+                "description": "List the balance changes of the user pay account newest first, pass next_cursor back as cursor to get the next page",
                 "consumes": [
                     "application/json"
                 ],
@@ -118,39 +160,236 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "RedeemCodes"
+                    "PayAccount"
                 ],
-                "summary": "Query redeem codes",
+                "summary": "List user pay account transactions",
                 "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Op type, 1: top-up, 2: payment, 3: refund, 4: authorize, 5: capture, 6: void, 7: transfer-out, 8: transfer-in",
+                        "name": "op_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Start of the time range in unix seconds, inclusive",
+                        "name": "start_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "End of the time range in unix seconds, exclusive",
+                        "name": "end_time",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
-                        "name": "code",
+                        "description": "Cursor returned by the previous page",
+                        "name": "cursor",
                         "in": "query"
                     },
                     {
                         "type": "integer",
+                        "description": "Page size, 1 to 100, default 20",
                         "name": "limit",
                         "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/data.BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/data.UserPayTransactionPage"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
                     },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment-ms/v1/customer/pay-accounts/self/transfers": {
+            "post": {
+                "description": "Send balance to the pay account numbered to_account_no; retrying with the same transfer_id returns the original transfer with code DUPLICATE_REQUEST",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PayAccount"
+                ],
+                "summary": "Transfer balance to another user",
+                "parameters": [
                     {
-                        "type": "boolean",
-                        "name": "used",
-                        "in": "query"
+                        "description": "Transfer request",
+                        "name": "transfer",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/data.UserPayTransferRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/data.BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/data.UserPayTransferResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "402": {
+                        "description": "Payment Required",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment-ms/v1/customer/redeem-codes/validate": {
+            "post": {
+                "description": "Tell what a redeem code is worth and whether a top-up with it would go through, without redeeming it. When it would not, reason carries the code the top-up would fail with. Invalid or malformed codes count towards the lockout of top-ups",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PayAccount"
+                ],
+                "summary": "Validate a redeem code",
+                "parameters": [
+                    {
+                        "description": "Redeem code to validate",
+                        "name": "validate",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/data.RedeemCodeValidateRequest"
+                        }
                     }
                 ],
                 "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/data.BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/data.RedeemCodeValidation"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/data.BaseResponse"
                         }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
                     }
                 }
             }
         },
-        "/payment-ms/v1/merchant/redeem-codes/generate": {
+        "/payment-ms/v1/merchant/promo-codes": {
             "post": {
-                "description": "Generate redeem codes",
+                "description": "Create a code that many users can redeem, up to max_redemptions in total and per_user_limit times each. The code is generated unless given, it is stored hashed and only returned by this call",
                 "consumes": [
                     "application/json"
                 ],
@@ -160,21 +399,57 @@ const docTemplate = `{
                 "tags": [
                     "RedeemCodes"
                 ],
-                "summary": "Generate redeem codes",
+                "summary": "Create a promo code",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Code to hand out, letters and digits only, generated if omitted",
+                        "name": "code",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
-                        "description": "Amount for each redeem code",
+                        "description": "Amount of each redemption",
                         "name": "amount",
                         "in": "query",
                         "required": true
                     },
                     {
                         "type": "integer",
-                        "description": "Number of redeem codes to generate",
-                        "name": "count",
+                        "description": "Redemptions allowed across all users",
+                        "name": "max_redemptions",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Redemptions allowed per user, default 1, 0 for no limit",
+                        "name": "per_user_limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Unix time the code becomes valid, right away if omitted",
+                        "name": "valid_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Unix time the code expires, never if omitted",
+                        "name": "valid_until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the batch holding the code",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Campaign the code belongs to",
+                        "name": "campaign",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -189,7 +464,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/data.RedeemCodeGenResult"
+                                            "$ref": "#/definitions/data.RedeemCodeVO"
                                         }
                                     }
                                 }
@@ -204,57 +479,1017 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "data.BaseResponse": {
-            "type": "object",
-            "properties": {
-                "code": {
-                    "type": "integer"
-                },
-                "data": {},
-                "err_msg": {
-                    "type": "string"
-                }
-            }
-        },
-        "data.RedeemCodeGenResult": {
-            "type": "object",
-            "properties": {
-                "gen_count": {
-                    "type": "integer"
-                }
-            }
         },
-        "data.UserPayAccount": {
-            "type": "object",
-            "properties": {
-                "account_no": {
-                    "type": "string"
-                },
-                "balance": {
-                    "type": "integer"
-                },
-                "created_at": {
+        "/payment-ms/v1/merchant/redeem-code-batches": {
+            "get": {
+                "description": "List redeem code batches newest first with how many of their codes were used and revoked",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RedeemCodes"
+                ],
+                "summary": "Query redeem code batches",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only batches of this campaign",
+                        "name": "campaign",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only batches generated by this merchant",
+                        "name": "creator_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor returned by the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 1 to 100, default 20",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/data.BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/data.RedeemCodeBatchPage"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment-ms/v1/merchant/redeem-code-batches/{batch_id}/revoke": {
+            "post": {
+                "description": "Revoke every unused code of the batch at once, codes already used keep their top-ups",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RedeemCodes"
+                ],
+                "summary": "Revoke a redeem code batch",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Redeem code batch ID",
+                        "name": "batch_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/data.BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/data.RedeemCodeBatchRevokeResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment-ms/v1/merchant/redeem-code-jobs": {
+            "post": {
+                "description": "Generate a large batch of redeem codes in the background, poll the job for its progress. The id of the job is the id of the batch",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RedeemCodes"
+                ],
+                "summary": "Start a redeem code generation job",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Amount for each redeem code",
+                        "name": "amount",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of redeem codes to generate, up to the configured maximum",
+                        "name": "count",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Unix time the codes become valid, right away if omitted",
+                        "name": "valid_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Unix time the codes expire, never if omitted",
+                        "name": "valid_until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the batch, e.g. the print run",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Campaign the batch belongs to",
+                        "name": "campaign",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/data.BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/data.RedeemCodeGenJobVO"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment-ms/v1/merchant/redeem-code-jobs/{job_id}": {
+            "get": {
+                "description": "Get the status and progress of a generation job",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RedeemCodes"
+                ],
+                "summary": "Get a redeem code generation job",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Generation job ID",
+                        "name": "job_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/data.BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/data.RedeemCodeGenJobVO"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment-ms/v1/merchant/redeem-code-lockouts/clear": {
+            "post": {
+                "description": "Forget the failed top-ups and lockouts of a user or an ip, so that it can redeem codes again right away",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RedeemCodes"
+                ],
+                "summary": "Clear a redeem code lockout",
+                "parameters": [
+                    {
+                        "description": "User id or ip address to clear",
+                        "name": "clear",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/data.RedeemCodeLockoutClearRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/data.BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/data.RedeemCodeLockoutClearResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment-ms/v1/merchant/redeem-codes": {
+            "get": {
+                "description": "Query redeem codes",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RedeemCodes"
+                ],
+                "summary": "Query redeem codes",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "batch_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "name": "code",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "active",
+                            "expired"
+                        ],
+                        "type": "string",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "name": "used",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment-ms/v1/merchant/redeem-codes/details": {
+            "post": {
+                "description": "Look a redeem code up for support: its status, its batch, the user who used it and when, or the latest 20 redemptions of a promo code. The code goes in the body to keep it out of access logs",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RedeemCodes"
+                ],
+                "summary": "Get the details of a redeem code",
+                "parameters": [
+                    {
+                        "description": "Redeem code to look up",
+                        "name": "lookup",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/data.RedeemCodeDetailRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/data.BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/data.RedeemCodeDetailVO"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment-ms/v1/merchant/redeem-codes/export": {
+            "get": {
+                "description": "Stream every redeem code of a batch or matching a filter as CSV or JSON Lines, with code suffix, amount, status and timestamps. CSV timestamps are RFC 3339, JSON Lines timestamps are unix seconds. Codes generated by a job carry their plaintext code in the first export only",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "RedeemCodes"
+                ],
+                "summary": "Export redeem codes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv (default) or jsonl",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only codes of this batch",
+                        "name": "batch_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only used or only unused codes",
+                        "name": "used",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "active or expired",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment-ms/v1/merchant/redeem-codes/generate": {
+            "post": {
+                "description": "Generate a batch of redeem codes, the result carries the id of the batch and the codes. The codes are stored hashed and shown only in this response",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RedeemCodes"
+                ],
+                "summary": "Generate redeem codes",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Amount for each redeem code",
+                        "name": "amount",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of redeem codes to generate",
+                        "name": "count",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Unix time the codes become valid, right away if omitted",
+                        "name": "valid_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Unix time the codes expire, never if omitted",
+                        "name": "valid_until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the batch, e.g. the print run",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Campaign the batch belongs to",
+                        "name": "campaign",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/data.BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/data.RedeemCodeGenResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/data.BaseResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "data.BaseResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer"
+                },
+                "data": {},
+                "err_msg": {
+                    "type": "string"
+                }
+            }
+        },
+        "data.RedeemCodeBatchPage": {
+            "type": "object",
+            "properties": {
+                "batches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/data.RedeemCodeBatchVO"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "data.RedeemCodeBatchRevokeResult": {
+            "type": "object",
+            "properties": {
+                "batch_id": {
+                    "type": "integer"
+                },
+                "revoked_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "data.RedeemCodeBatchVO": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "campaign": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "integer"
+                },
+                "creator_id": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "redemptions": {
+                    "description": "redemptions of the promo codes of the batch",
+                    "type": "integer"
+                },
+                "revoked": {
+                    "type": "integer"
+                },
+                "revoked_at": {
+                    "type": "integer"
+                },
+                "unused": {
+                    "description": "codes still redeemable, neither used nor revoked",
+                    "type": "integer"
+                },
+                "used": {
+                    "type": "integer"
+                },
+                "valid_from": {
+                    "type": "integer"
+                },
+                "valid_until": {
+                    "type": "integer"
+                }
+            }
+        },
+        "data.RedeemCodeDetailRequest": {
+            "type": "object",
+            "required": [
+                "redeem_code"
+            ],
+            "properties": {
+                "redeem_code": {
+                    "type": "string",
+                    "maxLength": 64
+                }
+            }
+        },
+        "data.RedeemCodeDetailVO": {
+            "type": "object",
+            "required": [
+                "amount"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "batch": {
+                    "$ref": "#/definitions/data.RedeemCodeBatchVO"
+                },
+                "batch_id": {
+                    "type": "integer"
+                },
+                "code": {
+                    "description": "only returned when the code is created",
+                    "type": "string"
+                },
+                "code_suffix": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "max_redemptions": {
+                    "type": "integer"
+                },
+                "per_user_limit": {
+                    "type": "integer"
+                },
+                "redeemed_count": {
+                    "type": "integer"
+                },
+                "redemptions": {
+                    "description": "promo codes, the latest 20 newest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/data.RedeemCodeRedemptionVO"
+                    }
+                },
+                "revoked": {
+                    "type": "boolean"
+                },
+                "revoked_at": {
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "active, pending, expired, used or revoked",
+                    "type": "string"
+                },
+                "top_up_change_log_id": {
+                    "type": "integer"
+                },
+                "type": {
+                    "description": "1: single-use, 2: promo",
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "integer"
+                },
+                "used": {
+                    "type": "integer"
+                },
+                "used_at": {
+                    "description": "single-use codes, time of the top-up",
+                    "type": "integer"
+                },
+                "valid_from": {
+                    "type": "integer"
+                },
+                "valid_until": {
+                    "type": "integer"
+                }
+            }
+        },
+        "data.RedeemCodeGenJobVO": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "integer"
+                },
+                "generated": {
+                    "type": "integer"
+                },
+                "job_id": {
+                    "description": "also the id of the batch the codes go into",
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "progress": {
+                    "description": "generated / quantity, 0 to 1",
+                    "type": "number"
+                },
+                "quantity": {
                     "type": "integer"
                 },
+                "status": {
+                    "description": "generating, completed or failed",
+                    "type": "string"
+                },
                 "updated_at": {
                     "type": "integer"
+                }
+            }
+        },
+        "data.RedeemCodeGenResult": {
+            "type": "object",
+            "properties": {
+                "batch_id": {
+                    "type": "integer"
+                },
+                "codes": {
+                    "description": "shown only once, the codes are stored hashed",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "gen_success_cnt": {
+                    "type": "integer"
+                }
+            }
+        },
+        "data.RedeemCodeLockoutClearRequest": {
+            "type": "object",
+            "required": [
+                "key",
+                "subject"
+            ],
+            "properties": {
+                "key": {
+                    "description": "user id or ip address",
+                    "type": "string",
+                    "maxLength": 64
+                },
+                "subject": {
+                    "type": "string",
+                    "enum": [
+                        "user",
+                        "ip"
+                    ]
+                }
+            }
+        },
+        "data.RedeemCodeLockoutClearResult": {
+            "type": "object",
+            "properties": {
+                "cleared": {
+                    "description": "false if the subject had no failures or lockouts to clear",
+                    "type": "boolean"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "subject": {
+                    "type": "string"
+                }
+            }
+        },
+        "data.RedeemCodeRedemptionVO": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "change_log_id": {
+                    "type": "integer"
+                },
+                "redeemed_at": {
+                    "type": "integer"
+                },
+                "seq": {
+                    "description": "numbers the redemptions of the code by the user from 1",
+                    "type": "integer"
                 },
                 "user_id": {
                     "type": "integer"
                 }
             }
         },
-        "data.UserPayAccountTopUpRequest": {
+        "data.RedeemCodeVO": {
+            "type": "object",
+            "required": [
+                "amount"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "batch_id": {
+                    "type": "integer"
+                },
+                "code": {
+                    "description": "only returned when the code is created",
+                    "type": "string"
+                },
+                "code_suffix": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "max_redemptions": {
+                    "type": "integer"
+                },
+                "per_user_limit": {
+                    "type": "integer"
+                },
+                "redeemed_count": {
+                    "type": "integer"
+                },
+                "revoked": {
+                    "type": "boolean"
+                },
+                "type": {
+                    "description": "1: single-use, 2: promo",
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "integer"
+                },
+                "used": {
+                    "type": "integer"
+                },
+                "valid_from": {
+                    "type": "integer"
+                },
+                "valid_until": {
+                    "type": "integer"
+                }
+            }
+        },
+        "data.RedeemCodeValidateRequest": {
             "type": "object",
             "required": [
                 "redeem_code"
             ],
             "properties": {
                 "redeem_code": {
+                    "type": "string",
+                    "maxLength": 64
+                }
+            }
+        },
+        "data.RedeemCodeValidation": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "code_suffix": {
                     "type": "string"
+                },
+                "reason": {
+                    "description": "code a top-up would fail with, e.g. REDEEM_CODE_EXPIRED",
+                    "type": "string"
+                },
+                "redeemable": {
+                    "type": "boolean"
+                },
+                "status": {
+                    "description": "active, pending, expired, used or revoked",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "1: single-use, 2: promo",
+                    "type": "integer"
+                },
+                "valid_from": {
+                    "type": "integer"
+                },
+                "valid_until": {
+                    "type": "integer"
+                }
+            }
+        },
+        "data.UserPayAccount": {
+            "type": "object",
+            "properties": {
+                "account_no": {
+                    "description": "in full, only ever shown to the owner of the account",
+                    "type": "string"
+                },
+                "balance": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "integer"
+                },
+                "frozen_balance": {
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "active, frozen or closed",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "data.UserPayAccountTopUpRequest": {
+            "type": "object",
+            "required": [
+                "redeem_code"
+            ],
+            "properties": {
+                "redeem_code": {
+                    "type": "string",
+                    "maxLength": 64
                 }
             }
         },
@@ -268,6 +1503,84 @@ const docTemplate = `{
                     "type": "integer"
                 }
             }
+        },
+        "data.UserPayTransaction": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "balance_after": {
+                    "type": "integer"
+                },
+                "balance_before": {
+                    "type": "integer"
+                },
+                "biz_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "integer"
+                },
+                "op_type": {
+                    "type": "integer"
+                },
+                "pay_order_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "data.UserPayTransactionPage": {
+            "type": "object",
+            "properties": {
+                "next_cursor": {
+                    "type": "string"
+                },
+                "transactions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/data.UserPayTransaction"
+                    }
+                }
+            }
+        },
+        "data.UserPayTransferRequest": {
+            "type": "object",
+            "required": [
+                "amount",
+                "to_account_no",
+                "transfer_id"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "to_account_no": {
+                    "type": "string"
+                },
+                "transfer_id": {
+                    "type": "string",
+                    "maxLength": 32
+                }
+            }
+        },
+        "data.UserPayTransferResult": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "integer"
+                },
+                "current_balance": {
+                    "type": "integer"
+                },
+                "transfer_id": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
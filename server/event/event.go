@@ -0,0 +1,124 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+)
+
+// Event types, each published to the Kafka topic of the same name.
+const (
+	TypePaymentSucceeded = "payment-succeeded"
+	TypeAccountToppedUp  = "account-topped-up"
+	TypeRefundCompleted  = "refund-completed"
+)
+
+// Current versions of the event data, bumped on changes consumers cannot ignore.
+const (
+	PaymentSucceededVersion = 1
+	AccountToppedUpVersion  = 1
+	RefundCompletedVersion  = 1
+)
+
+// Envelope is the value of every published event. Delivery is at-least-once, consumers must skip an EventId
+// they have seen before.
+type Envelope struct {
+	EventId    string          `json:"event_id"`
+	EventType  string          `json:"event_type"`
+	Version    int             `json:"version"`
+	OccurredAt int64           `json:"occurred_at"` // unix seconds
+	Data       json.RawMessage `json:"data"`
+}
+
+// PaymentSucceeded is the data of payment-succeeded version 1, an order paid from a pay account.
+type PaymentSucceeded struct {
+	PayOrderId   string `json:"pay_order_id"`
+	BizId        string `json:"biz_id"`
+	UserId       int    `json:"user_id"`
+	AccountId    int    `json:"account_id"`
+	Amount       int    `json:"amount"`
+	BalanceAfter int    `json:"balance_after"`
+	PaidAt       int64  `json:"paid_at"`
+}
+
+// AccountToppedUp is the data of account-topped-up version 1, a pay account topped up with a redeem code.
+type AccountToppedUp struct {
+	UserId       int   `json:"user_id"`
+	AccountId    int   `json:"account_id"`
+	ChangeLogId  int   `json:"change_log_id"`
+	RedeemCodeId uint  `json:"redeem_code_id"`
+	Amount       int   `json:"amount"`
+	BalanceAfter int   `json:"balance_after"`
+	ToppedUpAt   int64 `json:"topped_up_at"`
+}
+
+// RefundCompleted is the data of refund-completed version 1, a payment refunded to its pay account, in part or
+// in full.
+type RefundCompleted struct {
+	RefundId     string `json:"refund_id"`
+	PayOrderId   string `json:"pay_order_id"`
+	BizId        string `json:"biz_id"`
+	UserId       int    `json:"user_id"`
+	AccountId    int    `json:"account_id"`
+	ChangeLogId  int    `json:"change_log_id"`
+	Amount       int    `json:"amount"`
+	BalanceAfter int    `json:"balance_after"`
+	RefundedAt   int64  `json:"refunded_at"`
+}
+
+type Message struct {
+	Topic string
+	Key   string // messages with the same key keep their order
+	Value []byte
+}
+
+type Publisher interface {
+	// Publish returns once every message is acknowledged, or with an error when any of them may not have been.
+	Publish(ctx context.Context, msgs ...Message) error
+}
+
+var (
+	publisherInstance Publisher
+	publisherOnce     sync.Once
+)
+
+func GetPublisher() Publisher {
+	publisherOnce.Do(func() {
+		publisherInstance = NewKafkaPublisher(config.Config.KafkaConfig.Brokers)
+	})
+	return publisherInstance
+}
+
+var _ Publisher = (*KafkaPublisher)(nil) // Compile-time interface check
+
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaPublisher returns a publisher waiting for every in-sync replica, so that an acknowledged message
+// survives the loss of the partition leader.
+func NewKafkaPublisher(brokers []string) *KafkaPublisher {
+	return &KafkaPublisher{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+	}}
+}
+
+// Publish implements Publisher.
+func (k *KafkaPublisher) Publish(ctx context.Context, msgs ...Message) error {
+	kafkaMsgs := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		kafkaMsgs[i] = kafka.Message{Topic: msg.Topic, Key: []byte(msg.Key), Value: msg.Value}
+	}
+	return k.writer.WriteMessages(ctx, kafkaMsgs...)
+}
+
+func (k *KafkaPublisher) Close() error {
+	return k.writer.Close()
+}
@@ -92,3 +92,5 @@ require (
 )
 
 replace gopkg.in/yaml.v3 => gopkg.in/yaml.v3 v3.0.1
+
+replace github.com/sw5005-sus/ceramicraft-payment-mservice/common => ../common
@@ -26,6 +26,7 @@ func Init(exitSig chan os.Signal) {
 		grpc.MaxConcurrentStreams(uint32(config.Config.GrpcConfig.MaxPoolSize)),                      // Set maximum concurrent streams
 		grpc.MaxRecvMsgSize(1024 * 1024), // Set maximum receive message size (1MB here)
 		grpc.MaxSendMsgSize(1024 * 1024), // Set maximum send message size (1MB here)
+		grpc.UnaryInterceptor(bizErrorInterceptor),
 	}
 	grpcServer := grpc.NewServer(opts...)
 	paymentpb.RegisterPaymentServiceServer(grpcServer, &PaymentService{})
@@ -0,0 +1,34 @@
+package grpc
+
+import (
+	"context"
+
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"google.golang.org/grpc"
+)
+
+// codedResponse is implemented by every response of PaymentService.
+type codedResponse interface {
+	GetCode() int32
+	GetErrorMsg() string
+}
+
+// bizErrorInterceptor answers a call whose response carries a business error code with the gRPC status of that
+// code, the code itself travelling as an ErrorInfo detail, see bizerror.ToGRPCStatus. A replayed request keeps
+// its response, which carries the original result along with DUPLICATE_REQUEST.
+func bizErrorInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	coded, ok := resp.(codedResponse)
+	if !ok {
+		return resp, nil
+	}
+	code := paymentpb.RespCode(coded.GetCode())
+	if code == paymentpb.RespCode_SUCCESS || code == paymentpb.RespCode_DUPLICATE_REQUEST {
+		return resp, nil
+	}
+	return nil, bizerror.ToGRPCStatus(&bizerror.BizError{Code: int(code), Message: coded.GetErrorMsg()}).Err()
+}
@@ -0,0 +1,52 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBizErrorInterceptor(t *testing.T) {
+	ctx := context.Background()
+	respond := func(resp any) func(ctx context.Context, req any) (any, error) {
+		return func(ctx context.Context, req any) (any, error) {
+			return resp, nil
+		}
+	}
+
+	t.Run("should answer a business error with its status and code", func(t *testing.T) {
+		errmsg := "insufficient balance"
+		resp := &paymentpb.PayOrderResponse{Code: int32(paymentpb.RespCode_INSUFFICIENT_BALANCE), ErrorMsg: &errmsg}
+
+		ret, err := bizErrorInterceptor(ctx, nil, nil, respond(resp))
+		assert.Nil(t, ret)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+		bizErr := bizerror.FromGRPCError(err)
+		assert.Equal(t, paymentpb.RespCode_INSUFFICIENT_BALANCE, bizerror.RespCodeOf(bizErr))
+		assert.Equal(t, errmsg, bizErr.Message)
+	})
+
+	t.Run("should keep successful and replayed responses", func(t *testing.T) {
+		for _, code := range []paymentpb.RespCode{paymentpb.RespCode_SUCCESS, paymentpb.RespCode_DUPLICATE_REQUEST} {
+			resp := &paymentpb.PayOrderResponse{Code: int32(code), PayOrderInfo: &paymentpb.PayOrderInfo{PayOrderId: "1_biz_7"}}
+
+			ret, err := bizErrorInterceptor(ctx, nil, nil, respond(resp))
+			assert.NoError(t, err, code.String())
+			assert.Equal(t, resp, ret, code.String())
+		}
+	})
+
+	t.Run("should pass handler errors through", func(t *testing.T) {
+		handlerErr := status.Error(codes.Unimplemented, "not implemented")
+
+		_, err := bizErrorInterceptor(ctx, nil, nil, func(ctx context.Context, req any) (any, error) {
+			return nil, handlerErr
+		})
+		assert.Equal(t, handlerErr, err)
+	})
+}
@@ -2,14 +2,19 @@ package grpc
 
 import (
 	"context"
-	"fmt"
+	"time"
 
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/service"
 )
 
+// maxIdempotentKeyLength is the size of the idempotent_key column of user_account_change_logs.
+const maxIdempotentKeyLength = 32
+
 type PaymentService struct {
 	paymentpb.UnimplementedPaymentServiceServer
 }
@@ -26,19 +31,28 @@ func (s *PaymentService) PayOrder(ctx context.Context, req *paymentpb.PayOrderRe
 		return resp, nil
 	}
 	changeLog, err := service.GetUserAccountService().PayOrder(ctx, int(req.UserId), req.BizId, int(req.Amount))
-	if err != nil {
-		resp.Code = int32(paymentpb.RespCode_UNKNOWN_ERROR)
+	if bizErr, ok := err.(*bizerror.BizError); ok && bizErr.Code == int(paymentpb.RespCode_DUPLICATE_REQUEST) && changeLog != nil {
+		log.Logger.Infof("Duplicate payment, original change log: %+v", changeLog)
+		resp.Code = int32(paymentpb.RespCode_DUPLICATE_REQUEST)
+		errmsg = bizErr.Error()
+		resp.ErrorMsg = &errmsg
+	} else if err != nil {
+		resp.Code = int32(bizerror.RespCodeOf(err))
 		errmsg = err.Error()
 		resp.ErrorMsg = &errmsg
 		return resp, nil
+	} else {
+		log.Logger.Infof("Payment successful, change log: %+v", changeLog)
+		resp.Code = int32(paymentpb.RespCode_SUCCESS)
 	}
-	log.Logger.Infof("Payment successful, change log: %+v", changeLog)
-	resp.Code = int32(paymentpb.RespCode_SUCCESS)
 	resp.PayOrderInfo = &paymentpb.PayOrderInfo{
-		PayOrderId:  genPayOrderId(changeLog),
-		Amount:      int32(changeLog.Amount),
-		UserId:      req.UserId,
-		CreatedTime: changeLog.CreatedAt.Unix(),
+		PayOrderId:     changeLog.GetPayOrderId(),
+		Amount:         int32(changeLog.Amount),
+		UserId:         req.UserId,
+		CreatedTime:    changeLog.CreatedAt.Unix(),
+		RefundedAmount: int32(changeLog.RefundedAmount),
+		BalanceBefore:  int32(changeLog.BalanceBefore),
+		BalanceAfter:   int32(changeLog.BalanceAfter),
 	}
 	return resp, nil
 }
@@ -54,9 +68,16 @@ func (s *PaymentService) QueryPayOrder(ctx context.Context, req *paymentpb.PayOr
 		resp.ErrorMsg = &errmsg
 		return resp, nil
 	}
-	changeLogs, err := service.GetUserAccountService().GetUserPayHistory(ctx, req)
+	if req.StartTime != nil && req.EndTime != nil && *req.StartTime >= *req.EndTime {
+		log.Logger.Warnf("Invalid time range: %v", req)
+		resp.Code = int32(paymentpb.RespCode_BAD_REQUEST)
+		errmsg = "StartTime must be before EndTime"
+		resp.ErrorMsg = &errmsg
+		return resp, nil
+	}
+	changeLogs, nextPageToken, err := service.GetUserAccountService().GetUserPayHistory(ctx, req)
 	if err != nil {
-		resp.Code = int32(paymentpb.RespCode_UNKNOWN_ERROR)
+		resp.Code = int32(bizerror.RespCodeOf(err))
 		errmsg = err.Error()
 		resp.ErrorMsg = &errmsg
 		return resp, nil
@@ -64,17 +85,193 @@ func (s *PaymentService) QueryPayOrder(ctx context.Context, req *paymentpb.PayOr
 	ret := make([]*paymentpb.PayOrderInfo, 0)
 	for _, cLog := range changeLogs {
 		ret = append(ret, &paymentpb.PayOrderInfo{
-			PayOrderId:  genPayOrderId(cLog),
-			Amount:      int32(cLog.Amount),
-			UserId:      req.UserId,
-			CreatedTime: cLog.CreatedAt.Unix(),
+			PayOrderId:     cLog.GetPayOrderId(),
+			Amount:         int32(cLog.Amount),
+			UserId:         req.UserId,
+			CreatedTime:    cLog.CreatedAt.Unix(),
+			RefundedAmount: int32(cLog.RefundedAmount),
+			BalanceBefore:  int32(cLog.BalanceBefore),
+			BalanceAfter:   int32(cLog.BalanceAfter),
 		})
 	}
 	resp.Code = int32(paymentpb.RespCode_SUCCESS)
 	resp.PayOrderInfos = ret
+	if nextPageToken != "" {
+		resp.NextPageToken = &nextPageToken
+	}
+	return resp, nil
+}
+
+func (s *PaymentService) Transfer(ctx context.Context, req *paymentpb.TransferRequest) (*paymentpb.TransferResponse, error) {
+	log.Logger.Infof("[grpc-svr] method=Transfer, req=%v", req)
+	resp := &paymentpb.TransferResponse{}
+	errmsg := ""
+	if req.FromUserId == 0 || req.ToAccountNo == "" || req.Amount <= 0 || req.TransferId == "" || len(req.TransferId) > maxIdempotentKeyLength {
+		log.Logger.Warnf("Invalid request: %v", req)
+		resp.Code = int32(paymentpb.RespCode_BAD_REQUEST)
+		errmsg = "FromUserId, ToAccountNo, Amount and TransferId must be provided and valid"
+		resp.ErrorMsg = &errmsg
+		return resp, nil
+	}
+	outLog, err := service.GetUserAccountService().Transfer(ctx, int(req.FromUserId), req.ToAccountNo, int(req.Amount), req.TransferId)
+	if bizerror.RespCodeOf(err) == paymentpb.RespCode_DUPLICATE_REQUEST && outLog != nil {
+		log.Logger.Infof("Duplicate transfer, original change log: %+v", outLog)
+		resp.Code = int32(paymentpb.RespCode_DUPLICATE_REQUEST)
+		errmsg = err.Error()
+		resp.ErrorMsg = &errmsg
+	} else if err != nil {
+		resp.Code = int32(bizerror.RespCodeOf(err))
+		errmsg = err.Error()
+		resp.ErrorMsg = &errmsg
+		return resp, nil
+	} else {
+		log.Logger.Infof("Transfer successful, change log: %+v", outLog)
+		resp.Code = int32(paymentpb.RespCode_SUCCESS)
+	}
+	resp.TransferInfo = &paymentpb.TransferInfo{
+		TransferId:   outLog.IdempotentKey,
+		FromUserId:   req.FromUserId,
+		ToAccountNo:  req.ToAccountNo,
+		Amount:       int32(outLog.Amount),
+		BalanceAfter: int32(outLog.BalanceAfter),
+		CreatedTime:  outLog.CreatedAt.Unix(),
+	}
 	return resp, nil
 }
 
-func genPayOrderId(changeLog *model.UserAccountChangeLog) string {
-	return fmt.Sprintf("%d_%s_%d", changeLog.AccountId, changeLog.IdempotentKey, changeLog.ID)
+func (s *PaymentService) RefundOrder(ctx context.Context, req *paymentpb.RefundOrderRequest) (*paymentpb.RefundOrderResponse, error) {
+	log.Logger.Infof("[grpc-svr] method=RefundOrder, req=%v", req)
+	resp := &paymentpb.RefundOrderResponse{}
+	errmsg := ""
+	if (req.GetBizId() == "" && req.GetPayOrderId() == "") || req.Amount <= 0 || req.RefundId == "" || len(req.RefundId) > maxIdempotentKeyLength {
+		log.Logger.Warnf("Invalid request: %v", req)
+		resp.Code = int32(paymentpb.RespCode_BAD_REQUEST)
+		errmsg = "BizId or PayOrderId, Amount and RefundId must be provided and valid"
+		resp.ErrorMsg = &errmsg
+		return resp, nil
+	}
+	refundLog, payLog, err := service.GetUserAccountService().RefundOrder(ctx, req)
+	if bizerror.RespCodeOf(err) == paymentpb.RespCode_DUPLICATE_REQUEST && refundLog != nil {
+		log.Logger.Infof("Duplicate refund, original change log: %+v", refundLog)
+		resp.Code = int32(paymentpb.RespCode_DUPLICATE_REQUEST)
+		errmsg = err.Error()
+		resp.ErrorMsg = &errmsg
+	} else if err != nil {
+		resp.Code = int32(bizerror.RespCodeOf(err))
+		errmsg = err.Error()
+		resp.ErrorMsg = &errmsg
+		return resp, nil
+	} else {
+		log.Logger.Infof("Refund successful, change log: %+v", refundLog)
+		resp.Code = int32(paymentpb.RespCode_SUCCESS)
+	}
+	resp.RefundOrderInfo = &paymentpb.RefundOrderInfo{
+		RefundOrderId:  refundLog.GetPayOrderId(),
+		PayOrderId:     payLog.GetPayOrderId(),
+		Amount:         int32(refundLog.Amount),
+		RefundedAmount: int32(payLog.RefundedAmount),
+		CreatedTime:    refundLog.CreatedAt.Unix(),
+	}
+	return resp, nil
+}
+
+func (s *PaymentService) AuthorizePayment(ctx context.Context, req *paymentpb.AuthorizePaymentRequest) (*paymentpb.PaymentHoldResponse, error) {
+	log.Logger.Infof("[grpc-svr] method=AuthorizePayment, req=%v", req)
+	resp := &paymentpb.PaymentHoldResponse{}
+	errmsg := ""
+	if req.UserId == 0 || req.Amount <= 0 || req.BizId == "" || (req.ExpireSeconds != nil && *req.ExpireSeconds <= 0) {
+		log.Logger.Warnf("Invalid request: %v", req)
+		resp.Code = int32(paymentpb.RespCode_BAD_REQUEST)
+		errmsg = "UserId, Amount and BizId must be provided and valid"
+		resp.ErrorMsg = &errmsg
+		return resp, nil
+	}
+	expireSeconds := config.Config.PaymentConfig.HoldExpireSeconds
+	if req.ExpireSeconds != nil {
+		expireSeconds = int(*req.ExpireSeconds)
+	}
+	hold, err := service.GetPaymentHoldService().AuthorizePayment(ctx, int(req.UserId), req.BizId, int(req.Amount), time.Duration(expireSeconds)*time.Second)
+	if bizerror.RespCodeOf(err) == paymentpb.RespCode_DUPLICATE_REQUEST && hold != nil {
+		log.Logger.Infof("Duplicate authorization, original payment hold: %+v", hold)
+		resp.Code = int32(paymentpb.RespCode_DUPLICATE_REQUEST)
+		errmsg = err.Error()
+		resp.ErrorMsg = &errmsg
+	} else if err != nil {
+		resp.Code = int32(bizerror.RespCodeOf(err))
+		errmsg = err.Error()
+		resp.ErrorMsg = &errmsg
+		return resp, nil
+	} else {
+		resp.Code = int32(paymentpb.RespCode_SUCCESS)
+	}
+	resp.PaymentHoldInfo = toPaymentHoldInfo(hold, nil)
+	return resp, nil
+}
+
+func (s *PaymentService) CapturePayment(ctx context.Context, req *paymentpb.CapturePaymentRequest) (*paymentpb.PaymentHoldResponse, error) {
+	log.Logger.Infof("[grpc-svr] method=CapturePayment, req=%v", req)
+	resp := &paymentpb.PaymentHoldResponse{}
+	errmsg := ""
+	if req.BizId == "" || (req.Amount != nil && *req.Amount <= 0) {
+		log.Logger.Warnf("Invalid request: %v", req)
+		resp.Code = int32(paymentpb.RespCode_BAD_REQUEST)
+		errmsg = "BizId must be provided and Amount must be positive"
+		resp.ErrorMsg = &errmsg
+		return resp, nil
+	}
+	var amount *int
+	if req.Amount != nil {
+		captureAmount := int(*req.Amount)
+		amount = &captureAmount
+	}
+	hold, changeLog, err := service.GetPaymentHoldService().CapturePayment(ctx, req.BizId, amount)
+	if err != nil {
+		resp.Code = int32(bizerror.RespCodeOf(err))
+		errmsg = err.Error()
+		resp.ErrorMsg = &errmsg
+		return resp, nil
+	}
+	resp.Code = int32(paymentpb.RespCode_SUCCESS)
+	resp.PaymentHoldInfo = toPaymentHoldInfo(hold, changeLog)
+	return resp, nil
+}
+
+func (s *PaymentService) VoidPayment(ctx context.Context, req *paymentpb.VoidPaymentRequest) (*paymentpb.PaymentHoldResponse, error) {
+	log.Logger.Infof("[grpc-svr] method=VoidPayment, req=%v", req)
+	resp := &paymentpb.PaymentHoldResponse{}
+	errmsg := ""
+	if req.BizId == "" {
+		log.Logger.Warnf("Invalid request: %v", req)
+		resp.Code = int32(paymentpb.RespCode_BAD_REQUEST)
+		errmsg = "BizId must be provided"
+		resp.ErrorMsg = &errmsg
+		return resp, nil
+	}
+	hold, err := service.GetPaymentHoldService().VoidPayment(ctx, req.BizId)
+	if err != nil {
+		resp.Code = int32(bizerror.RespCodeOf(err))
+		errmsg = err.Error()
+		resp.ErrorMsg = &errmsg
+		return resp, nil
+	}
+	resp.Code = int32(paymentpb.RespCode_SUCCESS)
+	resp.PaymentHoldInfo = toPaymentHoldInfo(hold, nil)
+	return resp, nil
+}
+
+func toPaymentHoldInfo(hold *model.PaymentHold, captureLog *model.UserAccountChangeLog) *paymentpb.PaymentHoldInfo {
+	info := &paymentpb.PaymentHoldInfo{
+		BizId:          hold.BizId,
+		UserId:         int32(hold.UserId),
+		Amount:         int32(hold.Amount),
+		CapturedAmount: int32(hold.CapturedAmount),
+		Status:         paymentpb.HoldStatus(hold.Status),
+		ExpireTime:     hold.ExpiresAt.Unix(),
+		CreatedTime:    hold.CreatedAt.Unix(),
+	}
+	if captureLog != nil {
+		payOrderId := captureLog.GetPayOrderId()
+		info.PayOrderId = &payOrderId
+	}
+	return info
 }
@@ -0,0 +1,26 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+)
+
+func TestRefundOrderValidation(t *testing.T) {
+	config.Config = &config.Conf{LogConfig: &config.LogConfig{Level: "debug"}}
+	log.InitLogger()
+	bizId := "test-biz-id"
+
+	t.Run("should reject a refund id longer than an idempotent key", func(t *testing.T) {
+		req := &paymentpb.RefundOrderRequest{BizId: &bizId, Amount: 10, RefundId: strings.Repeat("r", maxIdempotentKeyLength+1)}
+
+		resp, err := (&PaymentService{}).RefundOrder(context.Background(), req)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(paymentpb.RespCode_BAD_REQUEST), resp.Code)
+	})
+}
@@ -1,7 +1,12 @@
 package api
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/http/data"
 )
 
 const (
@@ -62,3 +67,13 @@ func RespError(ctx *gin.Context, err error, code ...int) *Response {
 
 	return r
 }
+
+// RespBizError 按业务错误码返回对应的HTTP状态码和BaseResponse.Code
+func RespBizError(ctx *gin.Context, err error) {
+	ctx.JSON(bizerror.HTTPStatusOf(err), data.BaseResponse{Code: int(bizerror.RespCodeOf(err)), ErrMsg: err.Error()})
+}
+
+// RespBadRequest 参数错误返回
+func RespBadRequest(ctx *gin.Context, errMsg string) {
+	ctx.JSON(http.StatusBadRequest, data.BaseResponse{Code: int(paymentpb.RespCode_BAD_REQUEST), ErrMsg: errMsg})
+}
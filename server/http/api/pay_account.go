@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/http/data"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/service"
@@ -11,7 +13,7 @@ import (
 
 // GetUserPayAccountInfo godoc
 // @Summary Get user pay account info
-// @Description Get user pay account info
+// @Description Get user pay account info, with the account number in full as other users need it to transfer to the account
 // @Tags PayAccount
 // @Accept json
 // @Produce json
@@ -25,59 +27,197 @@ func GetUserPayAccountInfo(c *gin.Context) {
 	if userIdInterface, exists := c.Get("userID"); exists {
 		userId = userIdInterface.(int)
 	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+		RespBadRequest(c, "User ID not found in context")
 		return
 	}
 	userAccount, err := service.GetUserAccountService().GetUserAccountByUserID(c.Request.Context(), userId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, data.BaseResponse{ErrMsg: err.Error()})
+		RespBizError(c, err)
 		return
 	}
 	if userAccount == nil {
-		c.JSON(http.StatusNotFound, data.BaseResponse{ErrMsg: "User pay account not found"})
+		c.JSON(http.StatusNotFound, data.BaseResponse{Code: int(paymentpb.RespCode_ACCOUNT_NOT_EXIST), ErrMsg: "User pay account not found"})
 		return
 	}
 
 	c.JSON(http.StatusOK, data.BaseResponse{
 		Data: &data.UserPayAccount{
-			UserId:    userAccount.UserId,
-			Balance:   userAccount.Balance,
-			AccountNo: userAccount.GetHiddenAccountNo(),
-			CreatedAt: userAccount.CreatedAt.Unix(),
-			UpdatedAt: userAccount.UpdatedAt.Unix(),
+			UserId:        userAccount.UserId,
+			Balance:       userAccount.Balance,
+			FrozenBalance: userAccount.FrozenBalance,
+			Status:        userAccount.StatusName(),
+			AccountNo:     userAccount.AccountNo,
+			CreatedAt:     userAccount.CreatedAt.Unix(),
+			UpdatedAt:     userAccount.UpdatedAt.Unix(),
 		}})
 }
 
 // TopUpUserPayAccount godoc
 // @Summary Top up user pay account
-// @Description Top up user pay account using a redeem code
+// @Description Top up user pay account using a redeem code. Case, dashes and spaces in the code are ignored, a code failing its check character is rejected as malformed. Too many invalid or malformed codes lock the user and the ip out for a while, longer each time, answered with 429 REDEEM_CODE_LOCKED
 // @Tags PayAccount
 // @Accept json
 // @Produce json
 // @Param topup body data.UserPayAccountTopUpRequest true "Top up request"
 // @Success 200 {object} data.BaseResponse{data=data.UserPayAccountTopUpResult}
 // @Failure 400 {object} data.BaseResponse
+// @Failure 403 {object} data.BaseResponse
+// @Failure 404 {object} data.BaseResponse
+// @Failure 409 {object} data.BaseResponse
+// @Failure 429 {object} data.BaseResponse
+// @Failure 500 {object} data.BaseResponse
 // @Router /payment-ms/v1/customer/pay-accounts/self/top-ups [post]
 func TopUpUserPayAccount(c *gin.Context) {
 	var userId int
 	if userIdInterface, exists := c.Get("userID"); exists {
 		userId = userIdInterface.(int)
 	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+		RespBadRequest(c, "User ID not found in context")
 		return
 	}
 	log.Logger.Infof("TopUpUserPayAccount called for user ID: %d", userId)
 	var req data.UserPayAccountTopUpRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, data.BaseResponse{ErrMsg: err.Error()})
+		RespBadRequest(c, err.Error())
 		return
 	}
 
+	lockoutService := service.GetRedeemCodeLockoutService()
+	if err := lockoutService.CheckAttempt(c.Request.Context(), userId, c.ClientIP()); err != nil {
+		RespBizError(c, err)
+		return
+	}
 	account, redeemCode, err := service.GetUserAccountService().UserAccountTopUp(c.Request.Context(), userId, req.RedeemCode)
+	lockoutService.RecordTopUp(c.Request.Context(), userId, c.ClientIP(), err)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, data.BaseResponse{ErrMsg: err.Error()})
+		RespBizError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, data.BaseResponse{Data: &data.UserPayAccountTopUpResult{TopUpAmount: redeemCode.Amount, CurrentBalance: account.Balance}})
 }
+
+// ValidateRedeemCode godoc
+// @Summary Validate a redeem code
+// @Description Tell what a redeem code is worth and whether a top-up with it would go through, without redeeming it. When it would not, reason carries the code the top-up would fail with. Invalid or malformed codes count towards the lockout of top-ups
+// @Tags PayAccount
+// @Accept json
+// @Produce json
+// @Param validate body data.RedeemCodeValidateRequest true "Redeem code to validate"
+// @Success 200 {object} data.BaseResponse{data=data.RedeemCodeValidation}
+// @Failure 400 {object} data.BaseResponse
+// @Failure 429 {object} data.BaseResponse
+// @Failure 500 {object} data.BaseResponse
+// @Router /payment-ms/v1/customer/redeem-codes/validate [post]
+func ValidateRedeemCode(c *gin.Context) {
+	var userId int
+	if userIdInterface, exists := c.Get("userID"); exists {
+		userId = userIdInterface.(int)
+	} else {
+		RespBadRequest(c, "User ID not found in context")
+		return
+	}
+	var req data.RedeemCodeValidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespBadRequest(c, err.Error())
+		return
+	}
+
+	lockoutService := service.GetRedeemCodeLockoutService()
+	if err := lockoutService.CheckAttempt(c.Request.Context(), userId, c.ClientIP()); err != nil {
+		RespBizError(c, err)
+		return
+	}
+	validation, err := service.GetUserAccountService().ValidateRedeemCode(c.Request.Context(), userId, req.RedeemCode)
+	lockoutService.RecordValidation(c.Request.Context(), userId, c.ClientIP(), err)
+	if err != nil {
+		RespBizError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, data.BaseResponse{Data: validation})
+}
+
+// GetUserPayTransactions godoc
+// @Summary List user pay account transactions
+// @Description List the balance changes of the user pay account newest first, pass next_cursor back as cursor to get the next page
+// @Tags PayAccount
+// @Accept json
+// @Produce json
+// @Param op_type query int false "Op type, 1: top-up, 2: payment, 3: refund, 4: authorize, 5: capture, 6: void, 7: transfer-out, 8: transfer-in"
+// @Param start_time query int false "Start of the time range in unix seconds, inclusive"
+// @Param end_time query int false "End of the time range in unix seconds, exclusive"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param limit query int false "Page size, 1 to 100, default 20"
+// @Success 200 {object} data.BaseResponse{data=data.UserPayTransactionPage}
+// @Failure 400 {object} data.BaseResponse
+// @Failure 404 {object} data.BaseResponse
+// @Failure 500 {object} data.BaseResponse
+// @Router /payment-ms/v1/customer/pay-accounts/self/transactions [get]
+func GetUserPayTransactions(c *gin.Context) {
+	var userId int
+	if userIdInterface, exists := c.Get("userID"); exists {
+		userId = userIdInterface.(int)
+	} else {
+		RespBadRequest(c, "User ID not found in context")
+		return
+	}
+	var req data.UserPayTransactionQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		RespBadRequest(c, err.Error())
+		return
+	}
+	if req.StartTime > 0 && req.EndTime > 0 && req.StartTime >= req.EndTime {
+		RespBadRequest(c, "start_time must be before end_time")
+		return
+	}
+	page, err := service.GetUserAccountService().GetUserTransactions(c.Request.Context(), userId, &req)
+	if err != nil {
+		RespBizError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, data.BaseResponse{Data: page})
+}
+
+// TransferFromUserPayAccount godoc
+// @Summary Transfer balance to another user
+// @Description Send balance to the pay account numbered to_account_no; retrying with the same transfer_id returns the original transfer with code DUPLICATE_REQUEST
+// @Tags PayAccount
+// @Accept json
+// @Produce json
+// @Param transfer body data.UserPayTransferRequest true "Transfer request"
+// @Success 200 {object} data.BaseResponse{data=data.UserPayTransferResult}
+// @Failure 400 {object} data.BaseResponse
+// @Failure 402 {object} data.BaseResponse
+// @Failure 403 {object} data.BaseResponse
+// @Failure 404 {object} data.BaseResponse
+// @Failure 409 {object} data.BaseResponse
+// @Failure 422 {object} data.BaseResponse
+// @Failure 500 {object} data.BaseResponse
+// @Router /payment-ms/v1/customer/pay-accounts/self/transfers [post]
+func TransferFromUserPayAccount(c *gin.Context) {
+	var userId int
+	if userIdInterface, exists := c.Get("userID"); exists {
+		userId = userIdInterface.(int)
+	} else {
+		RespBadRequest(c, "User ID not found in context")
+		return
+	}
+	var req data.UserPayTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespBadRequest(c, err.Error())
+		return
+	}
+	log.Logger.Infof("TransferFromUserPayAccount called for user ID: %d, transfer ID: %s", userId, req.TransferId)
+	outLog, err := service.GetUserAccountService().Transfer(c.Request.Context(), userId, req.ToAccountNo, req.Amount, req.TransferId)
+	code := bizerror.RespCodeOf(err)
+	if err != nil && !(code == paymentpb.RespCode_DUPLICATE_REQUEST && outLog != nil) {
+		RespBizError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, data.BaseResponse{Code: int(code), Data: &data.UserPayTransferResult{
+		TransferId:     outLog.IdempotentKey,
+		Amount:         outLog.Amount,
+		CurrentBalance: outLog.BalanceAfter,
+		CreatedAt:      outLog.CreatedAt.Unix(),
+	}})
+}
@@ -2,9 +2,12 @@ package api
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/http/data"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/service"
 
 	"github.com/gin-gonic/gin"
@@ -16,34 +19,237 @@ const (
 
 // GenerateRedeemCodes godoc
 // @Summary Generate redeem codes
-// @Description Generate redeem codes
+// @Description Generate a batch of redeem codes, the result carries the id of the batch and the codes. The codes are stored hashed and shown only in this response
 // @Tags RedeemCodes
 // @Accept json
 // @Produce json
 // @Param amount query int true "Amount for each redeem code"
 // @Param count query int true "Number of redeem codes to generate"
+// @Param valid_from query int false "Unix time the codes become valid, right away if omitted"
+// @Param valid_until query int false "Unix time the codes expire, never if omitted"
+// @Param name query string false "Name of the batch, e.g. the print run"
+// @Param campaign query string false "Campaign the batch belongs to"
 // @Success 200 {object} data.BaseResponse{data=data.RedeemCodeGenResult}
 // @Failure 400 {object} data.BaseResponse
 // @Router /payment-ms/v1/merchant/redeem-codes/generate [post]
 func GenerateRedeemCodes(c *gin.Context) {
+	var userId int
+	if userIdInterface, exists := c.Get("userID"); exists {
+		userId = userIdInterface.(int)
+	} else {
+		RespBadRequest(c, "User ID not found in context")
+		return
+	}
 	var req data.RedeemCodeGenRequest
 	if err := c.ShouldBindQuery(&req); err != nil {
 		log.Logger.Errorf("GenerateRedeemCodes bind error: %v", err)
-		c.JSON(http.StatusBadRequest, data.BaseResponse{ErrMsg: err.Error()})
+		RespBadRequest(c, err.Error())
 		return
 	}
 	if req.Amount <= 0 || req.Count <= 0 || req.Count > maxGenCodeSize {
 		log.Logger.Error("GenerateRedeemCodes error: invalid amount or count")
-		c.JSON(http.StatusBadRequest, data.BaseResponse{ErrMsg: "Amount must be positive and count must be between 1 and 100"})
+		RespBadRequest(c, "Amount must be positive and count must be between 1 and 100")
+		return
+	}
+	validFrom, validUntil, ok := parseValidityWindow(req.ValidFrom, req.ValidUntil)
+	if !ok {
+		log.Logger.Error("GenerateRedeemCodes error: invalid validity window")
+		RespBadRequest(c, "valid_until must be in the future and after valid_from")
 		return
 	}
-	err := service.GetRedeemCodeService().GenerateRedeemCodes(c.Request.Context(), req.Amount, req.Count)
+	result, err := service.GetRedeemCodeService().GenerateRedeemCodes(c.Request.Context(), &model.RedeemCodeBatch{
+		Name:       req.Name,
+		Campaign:   req.Campaign,
+		CreatorId:  userId,
+		Amount:     req.Amount,
+		Quantity:   req.Count,
+		ValidFrom:  validFrom,
+		ValidUntil: validUntil,
+	})
 	if err != nil {
 		log.Logger.Errorf("GenerateRedeemCodes service error: %v", err)
-		c.JSON(http.StatusInternalServerError, data.BaseResponse{ErrMsg: err.Error()})
+		RespBizError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, data.BaseResponse{Data: result})
+}
+
+// StartRedeemCodeGenJob godoc
+// @Summary Start a redeem code generation job
+// @Description Generate a large batch of redeem codes in the background, poll the job for its progress. The id of the job is the id of the batch
+// @Tags RedeemCodes
+// @Accept json
+// @Produce json
+// @Param amount query int true "Amount for each redeem code"
+// @Param count query int true "Number of redeem codes to generate, up to the configured maximum"
+// @Param valid_from query int false "Unix time the codes become valid, right away if omitted"
+// @Param valid_until query int false "Unix time the codes expire, never if omitted"
+// @Param name query string false "Name of the batch, e.g. the print run"
+// @Param campaign query string false "Campaign the batch belongs to"
+// @Success 200 {object} data.BaseResponse{data=data.RedeemCodeGenJobVO}
+// @Failure 400 {object} data.BaseResponse
+// @Router /payment-ms/v1/merchant/redeem-code-jobs [post]
+func StartRedeemCodeGenJob(c *gin.Context) {
+	var userId int
+	if userIdInterface, exists := c.Get("userID"); exists {
+		userId = userIdInterface.(int)
+	} else {
+		RespBadRequest(c, "User ID not found in context")
+		return
+	}
+	var req data.RedeemCodeGenRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.Logger.Errorf("StartRedeemCodeGenJob bind error: %v", err)
+		RespBadRequest(c, err.Error())
+		return
+	}
+	if req.Amount <= 0 || req.Count <= 0 {
+		RespBadRequest(c, "Amount and count must be positive")
+		return
+	}
+	validFrom, validUntil, ok := parseValidityWindow(req.ValidFrom, req.ValidUntil)
+	if !ok {
+		RespBadRequest(c, "valid_until must be in the future and after valid_from")
+		return
+	}
+	job, err := service.GetRedeemCodeService().StartGenerationJob(c.Request.Context(), &model.RedeemCodeBatch{
+		Name:       req.Name,
+		Campaign:   req.Campaign,
+		CreatorId:  userId,
+		Amount:     req.Amount,
+		Quantity:   req.Count,
+		ValidFrom:  validFrom,
+		ValidUntil: validUntil,
+	})
+	if err != nil {
+		log.Logger.Errorf("StartRedeemCodeGenJob service error: %v", err)
+		RespBizError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, data.BaseResponse{Data: job})
+}
+
+// GetRedeemCodeGenJob godoc
+// @Summary Get a redeem code generation job
+// @Description Get the status and progress of a generation job
+// @Tags RedeemCodes
+// @Accept json
+// @Produce json
+// @Param job_id path int true "Generation job ID"
+// @Success 200 {object} data.BaseResponse{data=data.RedeemCodeGenJobVO}
+// @Failure 400 {object} data.BaseResponse
+// @Failure 404 {object} data.BaseResponse
+// @Router /payment-ms/v1/merchant/redeem-code-jobs/{job_id} [get]
+func GetRedeemCodeGenJob(c *gin.Context) {
+	jobId, err := strconv.ParseUint(c.Param("job_id"), 10, 64)
+	if err != nil || jobId == 0 {
+		RespBadRequest(c, "job_id must be a positive integer")
+		return
+	}
+	job, err := service.GetRedeemCodeService().GetGenerationJob(c.Request.Context(), uint(jobId))
+	if err != nil {
+		RespBizError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, data.BaseResponse{Data: map[string]interface{}{"gen_success_cnt": req.Count}})
+	c.JSON(http.StatusOK, data.BaseResponse{Data: job})
+}
+
+// GetRedeemCodeDetails godoc
+// @Summary Get the details of a redeem code
+// @Description Look a redeem code up for support: its status, its batch, the user who used it and when, or the latest 20 redemptions of a promo code. The code goes in the body to keep it out of access logs
+// @Tags RedeemCodes
+// @Accept json
+// @Produce json
+// @Param lookup body data.RedeemCodeDetailRequest true "Redeem code to look up"
+// @Success 200 {object} data.BaseResponse{data=data.RedeemCodeDetailVO}
+// @Failure 400 {object} data.BaseResponse
+// @Failure 500 {object} data.BaseResponse
+// @Router /payment-ms/v1/merchant/redeem-codes/details [post]
+func GetRedeemCodeDetails(c *gin.Context) {
+	var req data.RedeemCodeDetailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Logger.Errorf("GetRedeemCodeDetails bind error: %v", err)
+		RespBadRequest(c, err.Error())
+		return
+	}
+	details, err := service.GetRedeemCodeService().GetRedeemCodeDetails(c.Request.Context(), req.RedeemCode)
+	if err != nil {
+		log.Logger.Errorf("GetRedeemCodeDetails service error: %v", err)
+		RespBizError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, data.BaseResponse{Data: details})
+}
+
+// CreatePromoCode godoc
+// @Summary Create a promo code
+// @Description Create a code that many users can redeem, up to max_redemptions in total and per_user_limit times each. The code is generated unless given, it is stored hashed and only returned by this call
+// @Tags RedeemCodes
+// @Accept json
+// @Produce json
+// @Param code query string false "Code to hand out, letters and digits only, generated if omitted"
+// @Param amount query int true "Amount of each redemption"
+// @Param max_redemptions query int true "Redemptions allowed across all users"
+// @Param per_user_limit query int false "Redemptions allowed per user, default 1, 0 for no limit"
+// @Param valid_from query int false "Unix time the code becomes valid, right away if omitted"
+// @Param valid_until query int false "Unix time the code expires, never if omitted"
+// @Param name query string false "Name of the batch holding the code"
+// @Param campaign query string false "Campaign the code belongs to"
+// @Success 200 {object} data.BaseResponse{data=data.RedeemCodeVO}
+// @Failure 400 {object} data.BaseResponse
+// @Router /payment-ms/v1/merchant/promo-codes [post]
+func CreatePromoCode(c *gin.Context) {
+	var userId int
+	if userIdInterface, exists := c.Get("userID"); exists {
+		userId = userIdInterface.(int)
+	} else {
+		RespBadRequest(c, "User ID not found in context")
+		return
+	}
+	var req data.PromoCodeCreateRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.Logger.Errorf("CreatePromoCode bind error: %v", err)
+		RespBadRequest(c, err.Error())
+		return
+	}
+	validFrom, validUntil, ok := parseValidityWindow(req.ValidFrom, req.ValidUntil)
+	if !ok {
+		RespBadRequest(c, "valid_until must be in the future and after valid_from")
+		return
+	}
+	promoCode, err := service.GetRedeemCodeService().CreatePromoCode(c.Request.Context(), &model.RedeemCodeBatch{
+		Name:       req.Name,
+		Campaign:   req.Campaign,
+		CreatorId:  userId,
+		Amount:     req.Amount,
+		Quantity:   1,
+		ValidFrom:  validFrom,
+		ValidUntil: validUntil,
+	}, req.Code, req.MaxRedemptions, req.PerUserLimit)
+	if err != nil {
+		log.Logger.Errorf("CreatePromoCode service error: %v", err)
+		RespBizError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, data.BaseResponse{Data: promoCode})
+}
+
+// parseValidityWindow turns optional unix seconds into a validity window, valid until must be in the future
+// and after valid from.
+func parseValidityWindow(from int64, until int64) (*time.Time, *time.Time, bool) {
+	var validFrom, validUntil *time.Time
+	if from > 0 {
+		t := time.Unix(from, 0)
+		validFrom = &t
+	}
+	if until > 0 {
+		t := time.Unix(until, 0)
+		if !t.After(time.Now()) || (validFrom != nil && !t.After(*validFrom)) {
+			return nil, nil, false
+		}
+		validUntil = &t
+	}
+	return validFrom, validUntil, true
 }
 
 // QueryRedeemCodes godoc
@@ -60,19 +266,160 @@ func QueryRedeemCodes(c *gin.Context) {
 	var query data.RedeemCodeQuery
 	if err := c.ShouldBindQuery(&query); err != nil {
 		log.Logger.Errorf("QueryRedeemCodes bind error:", err)
-		c.JSON(http.StatusBadRequest, data.BaseResponse{ErrMsg: err.Error()})
+		RespBadRequest(c, err.Error())
 		return
 	}
-	if query.Code == nil && query.Used == nil {
+	if query.Code == nil && query.BatchId == nil && query.Used == nil && query.Status == "" {
 		log.Logger.Error("QueryRedeemCodes error: at least one query parameter must be provided")
-		c.JSON(http.StatusBadRequest, data.BaseResponse{ErrMsg: "At least one query parameter must be provided"})
+		RespBadRequest(c, "At least one query parameter must be provided")
 		return
 	}
 	ret, err := service.GetRedeemCodeService().QueryRedeemCodes(c.Request.Context(), &query)
 	if err != nil {
 		log.Logger.Errorf("QueryRedeemCodes service error: $v", err)
-		c.JSON(http.StatusInternalServerError, data.BaseResponse{ErrMsg: err.Error()})
+		RespBizError(c, err)
 		return
 	}
 	c.JSON(200, data.BaseResponse{Data: ret})
 }
+
+// ExportRedeemCodes godoc
+// @Summary Export redeem codes
+// @Description Stream every redeem code of a batch or matching a filter as CSV or JSON Lines, with code suffix, amount, status and timestamps. CSV timestamps are RFC 3339, JSON Lines timestamps are unix seconds. Codes generated by a job carry their plaintext code in the first export only
+// @Tags RedeemCodes
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string false "csv (default) or jsonl"
+// @Param batch_id query int false "Only codes of this batch"
+// @Param used query bool false "Only used or only unused codes"
+// @Param status query string false "active or expired"
+// @Success 200 {file} file
+// @Failure 400 {object} data.BaseResponse
+// @Failure 500 {object} data.BaseResponse
+// @Router /payment-ms/v1/merchant/redeem-codes/export [get]
+func ExportRedeemCodes(c *gin.Context) {
+	var query data.RedeemCodeExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		log.Logger.Errorf("ExportRedeemCodes bind error: %v", err)
+		RespBadRequest(c, err.Error())
+		return
+	}
+	if query.BatchId == nil && query.Used == nil && query.Status == "" {
+		RespBadRequest(c, "At least one of batch_id, used and status must be provided")
+		return
+	}
+	filename := "redeem-codes"
+	if query.BatchId != nil {
+		filename += "-batch-" + strconv.FormatUint(uint64(*query.BatchId), 10)
+	}
+	if query.Format == service.RedeemCodeExportFormatJSONL {
+		c.Header("Content-Type", "application/x-ndjson")
+		filename += ".jsonl"
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		filename += ".csv"
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	exported, err := service.GetRedeemCodeService().ExportRedeemCodes(c.Request.Context(), &query, c.Writer)
+	if err != nil {
+		// once rows are out the status is sent, cutting the stream short is all that is left
+		if c.Writer.Written() {
+			log.Logger.Errorf("ExportRedeemCodes aborted after %d rows: %v", exported, err)
+			return
+		}
+		c.Writer.Header().Del("Content-Disposition")
+		RespBizError(c, err)
+	}
+}
+
+// QueryRedeemCodeBatches godoc
+// @Summary Query redeem code batches
+// @Description List redeem code batches newest first with how many of their codes were used and revoked
+// @Tags RedeemCodes
+// @Accept json
+// @Produce json
+// @Param campaign query string false "Only batches of this campaign"
+// @Param creator_id query int false "Only batches generated by this merchant"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param limit query int false "Page size, 1 to 100, default 20"
+// @Success 200 {object} data.BaseResponse{data=data.RedeemCodeBatchPage}
+// @Failure 400 {object} data.BaseResponse
+// @Failure 500 {object} data.BaseResponse
+// @Router /payment-ms/v1/merchant/redeem-code-batches [get]
+func QueryRedeemCodeBatches(c *gin.Context) {
+	var query data.RedeemCodeBatchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		log.Logger.Errorf("QueryRedeemCodeBatches bind error: %v", err)
+		RespBadRequest(c, err.Error())
+		return
+	}
+	page, err := service.GetRedeemCodeService().QueryRedeemCodeBatches(c.Request.Context(), &query)
+	if err != nil {
+		log.Logger.Errorf("QueryRedeemCodeBatches service error: %v", err)
+		RespBizError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, data.BaseResponse{Data: page})
+}
+
+// RevokeRedeemCodeBatch godoc
+// @Summary Revoke a redeem code batch
+// @Description Revoke every unused code of the batch at once, codes already used keep their top-ups
+// @Tags RedeemCodes
+// @Accept json
+// @Produce json
+// @Param batch_id path int true "Redeem code batch ID"
+// @Success 200 {object} data.BaseResponse{data=data.RedeemCodeBatchRevokeResult}
+// @Failure 400 {object} data.BaseResponse
+// @Failure 404 {object} data.BaseResponse
+// @Failure 500 {object} data.BaseResponse
+// @Router /payment-ms/v1/merchant/redeem-code-batches/{batch_id}/revoke [post]
+func RevokeRedeemCodeBatch(c *gin.Context) {
+	batchId, err := strconv.ParseUint(c.Param("batch_id"), 10, 64)
+	if err != nil || batchId == 0 {
+		RespBadRequest(c, "batch_id must be a positive integer")
+		return
+	}
+	revoked, err := service.GetRedeemCodeService().RevokeRedeemCodeBatch(c.Request.Context(), uint(batchId))
+	if err != nil {
+		log.Logger.Errorf("RevokeRedeemCodeBatch service error: %v", err)
+		RespBizError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, data.BaseResponse{Data: data.RedeemCodeBatchRevokeResult{BatchId: uint(batchId), RevokedCount: revoked}})
+}
+
+// ClearRedeemCodeLockout godoc
+// @Summary Clear a redeem code lockout
+// @Description Forget the failed top-ups and lockouts of a user or an ip, so that it can redeem codes again right away
+// @Tags RedeemCodes
+// @Accept json
+// @Produce json
+// @Param clear body data.RedeemCodeLockoutClearRequest true "User id or ip address to clear"
+// @Success 200 {object} data.BaseResponse{data=data.RedeemCodeLockoutClearResult}
+// @Failure 400 {object} data.BaseResponse
+// @Failure 403 {object} data.BaseResponse
+// @Failure 500 {object} data.BaseResponse
+// @Router /payment-ms/v1/merchant/redeem-code-lockouts/clear [post]
+func ClearRedeemCodeLockout(c *gin.Context) {
+	var userId int
+	if userIdInterface, exists := c.Get("userID"); exists {
+		userId = userIdInterface.(int)
+	} else {
+		RespBadRequest(c, "User ID not found in context")
+		return
+	}
+	var req data.RedeemCodeLockoutClearRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Logger.Errorf("ClearRedeemCodeLockout bind error: %v", err)
+		RespBadRequest(c, err.Error())
+		return
+	}
+	cleared, err := service.GetRedeemCodeLockoutService().ClearLockout(c.Request.Context(), req.Subject, req.Key, userId)
+	if err != nil {
+		log.Logger.Errorf("ClearRedeemCodeLockout service error: %v", err)
+		RespBizError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, data.BaseResponse{Data: data.RedeemCodeLockoutClearResult{Subject: req.Subject, Key: req.Key, Cleared: cleared}})
+}
@@ -1,18 +1,71 @@
 package data
 
 type UserPayAccount struct {
-	UserId    int    `json:"user_id"`
-	AccountNo string `json:"account_no"`
-	Balance   int    `json:"balance"`
-	CreatedAt int64  `json:"created_at"`
-	UpdatedAt int64  `json:"updated_at"`
+	UserId        int    `json:"user_id"`
+	AccountNo     string `json:"account_no"` // in full, only ever shown to the owner of the account
+	Balance       int    `json:"balance"`
+	FrozenBalance int    `json:"frozen_balance"`
+	Status        string `json:"status"` // active, frozen or closed
+	CreatedAt     int64  `json:"created_at"`
+	UpdatedAt     int64  `json:"updated_at"`
 }
 
 type UserPayAccountTopUpRequest struct {
-	RedeemCode string `json:"redeem_code" binding:"required"`
+	RedeemCode string `json:"redeem_code" binding:"required,max=64"`
 }
 
 type UserPayAccountTopUpResult struct {
 	TopUpAmount    int `json:"top_up_amount"`
 	CurrentBalance int `json:"current_balance"`
 }
+
+type UserPayTransaction struct {
+	PayOrderId    string `json:"pay_order_id"`
+	OpType        int    `json:"op_type"`
+	Amount        int    `json:"amount"`
+	BalanceBefore int    `json:"balance_before"`
+	BalanceAfter  int    `json:"balance_after"`
+	BizId         string `json:"biz_id"`
+	CreatedAt     int64  `json:"created_at"`
+}
+
+type UserPayTransactionQuery struct {
+	OpType    int    `form:"op_type" binding:"omitempty,min=1,max=8"`
+	StartTime int64  `form:"start_time"` // unix seconds, inclusive
+	EndTime   int64  `form:"end_time"`   // unix seconds, exclusive
+	Cursor    string `form:"cursor"`
+	Limit     int    `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+type UserPayTransactionPage struct {
+	Transactions []*UserPayTransaction `json:"transactions"`
+	NextCursor   string                `json:"next_cursor,omitempty"`
+}
+
+type UserPayTransferRequest struct {
+	ToAccountNo string `json:"to_account_no" binding:"required"`
+	Amount      int    `json:"amount" binding:"required,min=1"`
+	TransferId  string `json:"transfer_id" binding:"required,max=32"`
+}
+
+type UserPayTransferResult struct {
+	TransferId     string `json:"transfer_id"`
+	Amount         int    `json:"amount"`
+	CurrentBalance int    `json:"current_balance"`
+	CreatedAt      int64  `json:"created_at"`
+}
+
+type RedeemCodeValidateRequest struct {
+	RedeemCode string `json:"redeem_code" binding:"required,max=64"`
+}
+
+type RedeemCodeValidation struct {
+	CodeSuffix string `json:"code_suffix"`
+	Type       int    `json:"type"` // 1: single-use, 2: promo
+	Amount     int    `json:"amount"`
+	Status     string `json:"status"` // active, pending, expired, used or revoked
+	Redeemable bool   `json:"redeemable"`
+	Reason     string `json:"reason,omitempty"` // code a top-up would fail with, e.g. REDEEM_CODE_EXPIRED
+	ValidFrom  int64  `json:"valid_from,omitempty"`
+	ValidUntil int64  `json:"valid_until,omitempty"`
+}
@@ -1,25 +1,154 @@
 package data
 
 type RedeemCodeVO struct {
-	Id         int    `json:"id"`
-	Code       string `json:"code" binding:"required"`
-	Amount     int    `json:"amount" binding:"required"`
-	UsedUserId int    `json:"used"`
-	CreatedAt  int64  `json:"created_at"`
-	UpdatedAt  int64  `json:"updated_at"`
+	Id             int    `json:"id"`
+	Code           string `json:"code,omitempty"` // only returned when the code is created
+	CodeSuffix     string `json:"code_suffix"`
+	Type           int    `json:"type"` // 1: single-use, 2: promo
+	Amount         int    `json:"amount" binding:"required"`
+	UsedUserId     int    `json:"used"`
+	MaxRedemptions int    `json:"max_redemptions,omitempty"`
+	PerUserLimit   int    `json:"per_user_limit,omitempty"`
+	RedeemedCount  int    `json:"redeemed_count,omitempty"`
+	BatchId        uint   `json:"batch_id,omitempty"`
+	Revoked        bool   `json:"revoked,omitempty"`
+	ValidFrom      int64  `json:"valid_from,omitempty"`
+	ValidUntil     int64  `json:"valid_until,omitempty"`
+	CreatedAt      int64  `json:"created_at"`
+	UpdatedAt      int64  `json:"updated_at"`
 }
 
 type RedeemCodeGenRequest struct {
-	Amount int `form:"amount" binding:"required"`
-	Count  int `form:"count" binding:"required"`
+	Amount     int    `form:"amount" binding:"required"`
+	Count      int    `form:"count" binding:"required"`
+	ValidFrom  int64  `form:"valid_from"`  // unix seconds, the codes are valid right away if omitted
+	ValidUntil int64  `form:"valid_until"` // unix seconds, exclusive, the codes never expire if omitted
+	Name       string `form:"name" binding:"max=64"`
+	Campaign   string `form:"campaign" binding:"max=64"`
+}
+
+type PromoCodeCreateRequest struct {
+	Code           string `form:"code" binding:"omitempty,alphanum,max=16"`
+	Amount         int    `form:"amount" binding:"required,min=1"`
+	MaxRedemptions int    `form:"max_redemptions" binding:"required,min=1"`
+	PerUserLimit   int    `form:"per_user_limit,default=1" binding:"min=0"`
+	ValidFrom      int64  `form:"valid_from"`
+	ValidUntil     int64  `form:"valid_until"`
+	Name           string `form:"name" binding:"max=64"`
+	Campaign       string `form:"campaign" binding:"max=64"`
+}
+
+type RedeemCodeGenJobVO struct {
+	JobId     uint    `json:"job_id"` // also the id of the batch the codes go into
+	Status    string  `json:"status"` // generating, completed or failed
+	Quantity  int     `json:"quantity"`
+	Generated int     `json:"generated"`
+	Progress  float64 `json:"progress"` // generated / quantity, 0 to 1
+	LastError string  `json:"last_error,omitempty"`
+	CreatedAt int64   `json:"created_at"`
+	UpdatedAt int64   `json:"updated_at"`
 }
 
 type RedeemCodeGenResult struct {
-	GenCount int `json:"gen_count"`
+	GenCount int      `json:"gen_success_cnt"`
+	BatchId  uint     `json:"batch_id"`
+	Codes    []string `json:"codes"` // shown only once, the codes are stored hashed
 }
 
 type RedeemCodeQuery struct {
-	Code  *string `form:"code"`
-	Used  *bool   `form:"used"`
-	Limit int     `form:"limit,default=10"`
+	Code    *string `form:"code"`
+	BatchId *uint   `form:"batch_id"`
+	Used    *bool   `form:"used"`
+	Status  string  `form:"status" binding:"omitempty,oneof=active expired"`
+	Limit   int     `form:"limit,default=10"`
+}
+
+type RedeemCodeExportQuery struct {
+	Format  string `form:"format,default=csv" binding:"oneof=csv jsonl"`
+	BatchId *uint  `form:"batch_id"`
+	Used    *bool  `form:"used"`
+	Status  string `form:"status" binding:"omitempty,oneof=active expired"`
+}
+
+// RedeemCodeExportRow is one line of a JSON Lines export, CSV exports carry the same columns.
+type RedeemCodeExportRow struct {
+	Id         uint   `json:"id"`
+	Code       string `json:"code,omitempty"` // plaintext, only in the first export of a job generated code
+	CodeSuffix string `json:"code_suffix"`
+	Amount     int    `json:"amount"`
+	Status     string `json:"status"` // active, pending, expired, used or revoked
+	BatchId    uint   `json:"batch_id"`
+	UsedUserId int    `json:"used"`
+	ValidFrom  int64  `json:"valid_from,omitempty"`
+	ValidUntil int64  `json:"valid_until,omitempty"`
+	RevokedAt  int64  `json:"revoked_at,omitempty"`
+	CreatedAt  int64  `json:"created_at"`
+	UpdatedAt  int64  `json:"updated_at"`
+}
+
+type RedeemCodeBatchVO struct {
+	Id          uint   `json:"id"`
+	Name        string `json:"name"`
+	Campaign    string `json:"campaign"`
+	CreatorId   int    `json:"creator_id"`
+	Amount      int    `json:"amount"`
+	Quantity    int    `json:"quantity"`
+	Used        int    `json:"used"`
+	Revoked     int    `json:"revoked"`
+	Redemptions int    `json:"redemptions"` // redemptions of the promo codes of the batch
+	Unused      int    `json:"unused"`      // codes still redeemable, neither used nor revoked
+	ValidFrom   int64  `json:"valid_from,omitempty"`
+	ValidUntil  int64  `json:"valid_until,omitempty"`
+	RevokedAt   int64  `json:"revoked_at,omitempty"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+type RedeemCodeBatchQuery struct {
+	Campaign  string `form:"campaign"`
+	CreatorId int    `form:"creator_id"`
+	Cursor    string `form:"cursor"`
+	Limit     int    `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+type RedeemCodeBatchPage struct {
+	Batches    []*RedeemCodeBatchVO `json:"batches"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+type RedeemCodeBatchRevokeResult struct {
+	BatchId      uint `json:"batch_id"`
+	RevokedCount int  `json:"revoked_count"`
+}
+
+type RedeemCodeLockoutClearRequest struct {
+	Subject string `json:"subject" binding:"required,oneof=user ip"`
+	Key     string `json:"key" binding:"required,max=64"` // user id or ip address
+}
+
+type RedeemCodeLockoutClearResult struct {
+	Subject string `json:"subject"`
+	Key     string `json:"key"`
+	Cleared bool   `json:"cleared"` // false if the subject had no failures or lockouts to clear
+}
+
+type RedeemCodeDetailRequest struct {
+	RedeemCode string `json:"redeem_code" binding:"required,max=64"`
+}
+
+type RedeemCodeDetailVO struct {
+	RedeemCodeVO
+	Status           string                    `json:"status"` // active, pending, expired, used or revoked
+	RevokedAt        int64                     `json:"revoked_at,omitempty"`
+	UsedAt           int64                     `json:"used_at,omitempty"` // single-use codes, time of the top-up
+	TopUpChangeLogId int                       `json:"top_up_change_log_id,omitempty"`
+	Batch            *RedeemCodeBatchVO        `json:"batch,omitempty"`
+	Redemptions      []*RedeemCodeRedemptionVO `json:"redemptions,omitempty"` // promo codes, the latest 20 newest first
+}
+
+type RedeemCodeRedemptionVO struct {
+	UserId      int   `json:"user_id"`
+	Seq         int   `json:"seq"` // numbers the redemptions of the code by the user from 1
+	Amount      int   `json:"amount"`
+	ChangeLogId int   `json:"change_log_id"`
+	RedeemedAt  int64 `json:"redeemed_at"`
 }
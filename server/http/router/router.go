@@ -39,8 +39,19 @@ func NewRouter() *gin.Engine {
 		v1Authed.Use(middleware.AuthMiddleware())
 		v1Authed.GET("/merchant/redeem-codes", api.QueryRedeemCodes)
 		v1Authed.POST("/merchant/redeem-codes/generate", api.GenerateRedeemCodes)
+		v1Authed.GET("/merchant/redeem-codes/export", api.ExportRedeemCodes)
+		v1Authed.POST("/merchant/redeem-codes/details", api.GetRedeemCodeDetails)
+		v1Authed.POST("/merchant/promo-codes", api.CreatePromoCode)
+		v1Authed.POST("/merchant/redeem-code-jobs", api.StartRedeemCodeGenJob)
+		v1Authed.GET("/merchant/redeem-code-jobs/:job_id", api.GetRedeemCodeGenJob)
+		v1Authed.GET("/merchant/redeem-code-batches", api.QueryRedeemCodeBatches)
+		v1Authed.POST("/merchant/redeem-code-batches/:batch_id/revoke", api.RevokeRedeemCodeBatch)
+		v1Authed.POST("/merchant/redeem-code-lockouts/clear", api.ClearRedeemCodeLockout)
 		v1Authed.POST("/customer/pay-accounts/self/top-ups", api.TopUpUserPayAccount)
+		v1Authed.POST("/customer/redeem-codes/validate", api.ValidateRedeemCode)
 		v1Authed.GET("/customer/pay-accounts/self", api.GetUserPayAccountInfo)
+		v1Authed.GET("/customer/pay-accounts/self/transactions", api.GetUserPayTransactions)
+		v1Authed.POST("/customer/pay-accounts/self/transfers", api.TransferFromUserPayAccount)
 	}
 	return r
 }
@@ -0,0 +1,36 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+)
+
+type JobFunc func(ctx context.Context) error
+
+func Init() {
+	startJob("release-expired-holds", time.Duration(config.Config.PaymentConfig.HoldExpiryScanInterval)*time.Second, releaseExpiredHolds)
+	startJob("reconcile-balances", time.Duration(config.Config.PaymentConfig.ReconciliationInterval)*time.Second, reconcileBalances)
+	startJob("resume-redeem-code-generation", time.Duration(config.Config.PaymentConfig.RedeemCodeGenResumeInterval)*time.Second, resumeRedeemCodeGeneration)
+	startJob("relay-outbox", time.Duration(config.Config.PaymentConfig.OutboxRelayIntervalMs)*time.Millisecond, relayOutbox)
+}
+
+// startJob runs fn every interval in the background; a non-positive interval disables the job.
+func startJob(name string, interval time.Duration, fn JobFunc) {
+	if interval <= 0 {
+		log.Logger.Warnf("Job %s is disabled", name)
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := fn(context.Background()); err != nil {
+				log.Logger.Errorf("Job %s failed: %v", name, err)
+			}
+		}
+	}()
+	log.Logger.Infof("Job %s started, interval %v", name, interval)
+}
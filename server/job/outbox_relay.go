@@ -0,0 +1,12 @@
+package job
+
+import (
+	"context"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/service"
+)
+
+func relayOutbox(ctx context.Context) error {
+	_, err := service.GetOutboxService().RelayOutbox(ctx)
+	return err
+}
@@ -0,0 +1,178 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEmptyEntry      = errors.New("journal entry has no lines")
+	ErrInvalidLine     = errors.New("journal line must either debit or credit a positive amount")
+	ErrUnbalancedEntry = errors.New("journal entry debits and credits do not balance")
+)
+
+// Account names a ledger account, ledger accounts are created the first time an entry touches them.
+type Account struct {
+	Code          string
+	Type          int
+	UserAccountId int
+	// balance of the user account before the ledger saw it, posted as an opening balance with the wallet
+	openingBalance int
+}
+
+// System accounts.
+var (
+	// RedeemCodeLiability is debited when a redeem code is used, codes are issued outside the ledger.
+	RedeemCodeLiability = Account{Code: "redeem_code_liability", Type: model.LedgerAccountTypeLiability}
+	MerchantRevenue     = Account{Code: "merchant_revenue", Type: model.LedgerAccountTypeRevenue}
+	Refunds             = Account{Code: "refunds", Type: model.LedgerAccountTypeExpense}
+	// PaymentHolds carries the money of authorized payments until they are captured or voided.
+	PaymentHolds = Account{Code: "payment_holds", Type: model.LedgerAccountTypeLiability}
+	// OpeningBalance is the other side of the balances user accounts had before they joined the ledger.
+	OpeningBalance = Account{Code: "opening_balance", Type: model.LedgerAccountTypeEquity}
+)
+
+// Wallet returns the ledger account holding the available balance of a user account.
+// userAccount must be the snapshot the posted change is applied to, user_accounts.balance is a cache of the wallet.
+func Wallet(userAccount *model.UserAccount) Account {
+	return Account{
+		Code:           WalletCode(userAccount.ID),
+		Type:           model.LedgerAccountTypeLiability,
+		UserAccountId:  userAccount.ID,
+		openingBalance: userAccount.Balance,
+	}
+}
+
+func WalletCode(userAccountId int) string {
+	return fmt.Sprintf("wallet:%d", userAccountId)
+}
+
+type Line struct {
+	Account Account
+	Debit   int
+	Credit  int
+}
+
+// Entry is a journal entry to post, built with Debit and Credit.
+type Entry struct {
+	OpType      int
+	ChangeLogId int
+	Lines       []Line
+}
+
+func NewEntry(opType int, changeLogId int) *Entry {
+	return &Entry{OpType: opType, ChangeLogId: changeLogId}
+}
+
+func (e *Entry) Debit(account Account, amount int) *Entry {
+	e.Lines = append(e.Lines, Line{Account: account, Debit: amount})
+	return e
+}
+
+func (e *Entry) Credit(account Account, amount int) *Entry {
+	e.Lines = append(e.Lines, Line{Account: account, Credit: amount})
+	return e
+}
+
+// Validate checks that every line moves a positive amount on one side and that debits equal credits.
+func (e *Entry) Validate() error {
+	if len(e.Lines) == 0 {
+		return ErrEmptyEntry
+	}
+	debits, credits := 0, 0
+	for _, line := range e.Lines {
+		if line.Debit < 0 || line.Credit < 0 || (line.Debit == 0) == (line.Credit == 0) {
+			return ErrInvalidLine
+		}
+		debits += line.Debit
+		credits += line.Credit
+	}
+	if debits != credits {
+		return ErrUnbalancedEntry
+	}
+	return nil
+}
+
+type Ledger interface {
+	// PostInTransaction records entry inside tx. A lost race creating a ledger account is returned as
+	// gorm.ErrCheckConstraintViolated, like a balance compare-and-swap conflict, so the caller retries.
+	PostInTransaction(ctx context.Context, entry *Entry, tx *gorm.DB) (*model.JournalEntry, error)
+}
+
+var (
+	ledgerInstance Ledger
+	ledgerOnce     sync.Once
+)
+
+func GetLedger() Ledger {
+	ledgerOnce.Do(func() {
+		ledgerInstance = &LedgerImpl{
+			ledgerDao: dao.GetLedgerDao(),
+		}
+	})
+	return ledgerInstance
+}
+
+type LedgerImpl struct {
+	ledgerDao dao.LedgerDao
+}
+
+// PostInTransaction implements Ledger.
+func (l *LedgerImpl) PostInTransaction(ctx context.Context, entry *Entry, tx *gorm.DB) (*model.JournalEntry, error) {
+	if err := entry.Validate(); err != nil {
+		log.Logger.Errorf("Invalid journal entry for change log %d: %v", entry.ChangeLogId, err)
+		return nil, err
+	}
+	lines := make([]*model.JournalLine, len(entry.Lines))
+	for i, line := range entry.Lines {
+		account, err := l.getOrCreateAccount(ctx, line.Account, tx)
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = &model.JournalLine{LedgerAccountId: account.ID, Debit: line.Debit, Credit: line.Credit}
+	}
+	journalEntry := &model.JournalEntry{OpType: entry.OpType, ChangeLogId: entry.ChangeLogId}
+	if err := l.ledgerDao.CreateEntryInTransaction(ctx, journalEntry, lines, tx); err != nil {
+		return nil, err
+	}
+	return journalEntry, nil
+}
+
+// getOrCreateAccount resolves a ledger account by code. A new wallet gets an opening balance entry
+// in the same transaction, so it starts from what the user account held before.
+func (l *LedgerImpl) getOrCreateAccount(ctx context.Context, account Account, tx *gorm.DB) (*model.LedgerAccount, error) {
+	ledgerAccount, err := l.ledgerDao.GetAccountByCodeInTransaction(ctx, account.Code, tx)
+	if err != nil || ledgerAccount != nil {
+		return ledgerAccount, err
+	}
+	ledgerAccount = &model.LedgerAccount{Code: account.Code, Type: account.Type, UserAccountId: account.UserAccountId}
+	err = l.ledgerDao.CreateAccountInTransaction(ctx, ledgerAccount, tx)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, gorm.ErrCheckConstraintViolated
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Logger.Infof("Created ledger account %s", account.Code)
+	if account.openingBalance == 0 {
+		return ledgerAccount, nil
+	}
+	opening := NewEntry(0, 0)
+	if account.openingBalance > 0 {
+		opening.Debit(OpeningBalance, account.openingBalance).Credit(account, account.openingBalance)
+	} else {
+		opening.Debit(account, -account.openingBalance).Credit(OpeningBalance, -account.openingBalance)
+	}
+	// the wallet exists now, posting the opening entry does not come back here for it
+	if _, err = l.PostInTransaction(ctx, opening, tx); err != nil {
+		return nil, err
+	}
+	return ledgerAccount, nil
+}
@@ -0,0 +1,108 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao/mocks"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"gorm.io/gorm"
+)
+
+func initEnv() {
+	config.Config = &config.Conf{
+		LogConfig: &config.LogConfig{
+			Level:    "debug",
+			FilePath: "",
+		},
+	}
+	log.InitLogger()
+}
+
+func TestEntryValidate(t *testing.T) {
+	wallet := Wallet(&model.UserAccount{ID: 1})
+	assert.ErrorIs(t, NewEntry(model.OpTypePayment, 1).Validate(), ErrEmptyEntry)
+	assert.ErrorIs(t, NewEntry(model.OpTypePayment, 1).Debit(wallet, 0).Credit(MerchantRevenue, 0).Validate(), ErrInvalidLine)
+	assert.ErrorIs(t, NewEntry(model.OpTypePayment, 1).Debit(wallet, -5).Credit(MerchantRevenue, -5).Validate(), ErrInvalidLine)
+	assert.ErrorIs(t, NewEntry(model.OpTypePayment, 1).Debit(wallet, 10).Credit(MerchantRevenue, 9).Validate(), ErrUnbalancedEntry)
+	assert.NoError(t, NewEntry(model.OpTypeCapture, 1).Debit(PaymentHolds, 10).Credit(MerchantRevenue, 6).Credit(wallet, 4).Validate())
+}
+
+func TestPostInTransaction(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+
+	t.Run("should post lines against existing accounts", func(t *testing.T) {
+		ledgerDao := new(mocks.LedgerDao)
+		ledger := &LedgerImpl{ledgerDao: ledgerDao}
+
+		ledgerDao.On("GetAccountByCodeInTransaction", ctx, "wallet:1", mock.Anything).Return(&model.LedgerAccount{ID: 11}, nil).Once()
+		ledgerDao.On("GetAccountByCodeInTransaction", ctx, MerchantRevenue.Code, mock.Anything).Return(&model.LedgerAccount{ID: 2}, nil).Once()
+		ledgerDao.On("CreateEntryInTransaction", ctx, mock.MatchedBy(func(e *model.JournalEntry) bool {
+			return e.OpType == model.OpTypePayment && e.ChangeLogId == 7
+		}), mock.MatchedBy(func(lines []*model.JournalLine) bool {
+			return len(lines) == 2 && lines[0].LedgerAccountId == 11 && lines[0].Debit == 30 &&
+				lines[1].LedgerAccountId == 2 && lines[1].Credit == 30
+		}), mock.Anything).Return(nil).Once()
+
+		entry := NewEntry(model.OpTypePayment, 7).Debit(Wallet(&model.UserAccount{ID: 1, Balance: 100}), 30).Credit(MerchantRevenue, 30)
+		_, err := ledger.PostInTransaction(ctx, entry, nil)
+		assert.NoError(t, err)
+		ledgerDao.AssertExpectations(t)
+	})
+
+	t.Run("should open a new wallet with the cached balance", func(t *testing.T) {
+		ledgerDao := new(mocks.LedgerDao)
+		ledger := &LedgerImpl{ledgerDao: ledgerDao}
+
+		ledgerDao.On("GetAccountByCodeInTransaction", ctx, "wallet:1", mock.Anything).Return(nil, nil).Once()
+		ledgerDao.On("CreateAccountInTransaction", ctx, mock.MatchedBy(func(a *model.LedgerAccount) bool {
+			return a.Code == "wallet:1" && a.UserAccountId == 1 && a.Type == model.LedgerAccountTypeLiability
+		}), mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(*model.LedgerAccount).ID = 11
+		}).Once()
+		ledgerDao.On("GetAccountByCodeInTransaction", ctx, OpeningBalance.Code, mock.Anything).Return(&model.LedgerAccount{ID: 5}, nil).Once()
+		ledgerDao.On("GetAccountByCodeInTransaction", ctx, "wallet:1", mock.Anything).Return(&model.LedgerAccount{ID: 11}, nil).Once()
+		opening := ledgerDao.On("CreateEntryInTransaction", ctx, mock.MatchedBy(func(e *model.JournalEntry) bool {
+			return e.OpType == 0
+		}), mock.MatchedBy(func(lines []*model.JournalLine) bool {
+			return len(lines) == 2 && lines[0].LedgerAccountId == 5 && lines[0].Debit == 100 &&
+				lines[1].LedgerAccountId == 11 && lines[1].Credit == 100
+		}), mock.Anything).Return(nil).Once()
+		ledgerDao.On("GetAccountByCodeInTransaction", ctx, RedeemCodeLiability.Code, mock.Anything).Return(&model.LedgerAccount{ID: 3}, nil).Once()
+		ledgerDao.On("CreateEntryInTransaction", ctx, mock.MatchedBy(func(e *model.JournalEntry) bool {
+			return e.OpType == model.OpTypeTopUp
+		}), mock.Anything, mock.Anything).Return(nil).Once().NotBefore(opening)
+
+		entry := NewEntry(model.OpTypeTopUp, 7).Credit(Wallet(&model.UserAccount{ID: 1, Balance: 100}), 50).Debit(RedeemCodeLiability, 50)
+		_, err := ledger.PostInTransaction(ctx, entry, nil)
+		assert.NoError(t, err)
+		ledgerDao.AssertExpectations(t)
+	})
+
+	t.Run("should report a lost account creation race as a conflict", func(t *testing.T) {
+		ledgerDao := new(mocks.LedgerDao)
+		ledger := &LedgerImpl{ledgerDao: ledgerDao}
+
+		ledgerDao.On("GetAccountByCodeInTransaction", ctx, "wallet:1", mock.Anything).Return(nil, nil).Once()
+		ledgerDao.On("CreateAccountInTransaction", ctx, mock.Anything, mock.Anything).Return(gorm.ErrDuplicatedKey).Once()
+
+		entry := NewEntry(model.OpTypePayment, 7).Debit(Wallet(&model.UserAccount{ID: 1}), 30).Credit(MerchantRevenue, 30)
+		_, err := ledger.PostInTransaction(ctx, entry, nil)
+		assert.ErrorIs(t, err, gorm.ErrCheckConstraintViolated)
+	})
+
+	t.Run("should reject an unbalanced entry", func(t *testing.T) {
+		ledgerDao := new(mocks.LedgerDao)
+		ledger := &LedgerImpl{ledgerDao: ledgerDao}
+
+		entry := NewEntry(model.OpTypePayment, 7).Debit(Wallet(&model.UserAccount{ID: 1}), 30).Credit(MerchantRevenue, 20)
+		_, err := ledger.PostInTransaction(ctx, entry, nil)
+		assert.ErrorIs(t, err, ErrUnbalancedEntry)
+		ledgerDao.AssertNotCalled(t, "CreateEntryInTransaction", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
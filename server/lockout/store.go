@@ -0,0 +1,107 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// State is the failed attempts of one subject, such as a user or an ip.
+type State struct {
+	// failures since the last lockout, success or reset
+	Failures int
+	// lockouts so far, each one longer than the one before
+	Lockouts    int
+	LockedUntil time.Time
+	// the store may forget the state from ExpiresAt on, a zero ExpiresAt keeps it
+	ExpiresAt time.Time
+}
+
+// LockedAt reports whether the subject is locked out at now.
+func (s State) LockedAt(now time.Time) bool {
+	return now.Before(s.LockedUntil)
+}
+
+// Store keeps the states of subjects by key. The in-process MemoryStore suits a single instance, instances
+// behind a load balancer must share a store, e.g. one backed by Redis, or an attacker gets the attempts of
+// every instance.
+type Store interface {
+	// Get returns the state of key, the zero State if there is none or it expired.
+	Get(ctx context.Context, key string) (State, error)
+	// Update applies fn to the state of key and saves the result as one atomic step, concurrent updates of a key
+	// must not be lost. It returns the saved state.
+	Update(ctx context.Context, key string, fn func(state *State)) (State, error)
+	// Delete forgets the state of key and reports whether there was one.
+	Delete(ctx context.Context, key string) (bool, error)
+}
+
+var _ Store = (*MemoryStore)(nil) // Compile-time interface check
+
+const memoryStoreSweepInterval = time.Minute
+
+// MemoryStore is a Store in the memory of the process, expired states are dropped as it is used.
+type MemoryStore struct {
+	mu        sync.Mutex
+	states    map[string]State
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]State)}
+}
+
+// Get implements Store.
+func (m *MemoryStore) Get(ctx context.Context, key string) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.states[key]
+	if !ok || expired(state, time.Now()) {
+		return State{}, nil
+	}
+	return state, nil
+}
+
+// Update implements Store.
+func (m *MemoryStore) Update(ctx context.Context, key string, fn func(state *State)) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.sweep(now)
+	state, ok := m.states[key]
+	if !ok || expired(state, now) {
+		state = State{}
+	}
+	fn(&state)
+	if state == (State{}) {
+		delete(m.states, key)
+	} else {
+		m.states[key] = state
+	}
+	return state, nil
+}
+
+// Delete implements Store.
+func (m *MemoryStore) Delete(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.states[key]
+	delete(m.states, key)
+	return ok && !expired(state, time.Now()), nil
+}
+
+// sweep drops the expired states at most once per sweep interval, so that keys never seen again are freed.
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < memoryStoreSweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, state := range m.states {
+		if expired(state, now) {
+			delete(m.states, key)
+		}
+	}
+}
+
+func expired(state State, now time.Time) bool {
+	return !state.ExpiresAt.IsZero() && !now.Before(state.ExpiresAt)
+}
@@ -0,0 +1,85 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should return the zero state of an unknown key", func(t *testing.T) {
+		store := NewMemoryStore()
+		state, err := store.Get(ctx, "user:1")
+		assert.NoError(t, err)
+		assert.Equal(t, State{}, state)
+	})
+
+	t.Run("should save updates per key", func(t *testing.T) {
+		store := NewMemoryStore()
+		expiresAt := time.Now().Add(time.Hour)
+		saved, err := store.Update(ctx, "user:1", func(state *State) {
+			state.Failures++
+			state.ExpiresAt = expiresAt
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, saved.Failures)
+
+		state, _ := store.Get(ctx, "user:1")
+		assert.Equal(t, saved, state)
+		other, _ := store.Get(ctx, "ip:10.0.0.1")
+		assert.Equal(t, State{}, other)
+	})
+
+	t.Run("should forget expired states", func(t *testing.T) {
+		store := NewMemoryStore()
+		_, _ = store.Update(ctx, "user:1", func(state *State) {
+			state.Failures = 3
+			state.ExpiresAt = time.Now().Add(-time.Second)
+		})
+
+		state, _ := store.Get(ctx, "user:1")
+		assert.Equal(t, State{}, state)
+		saved, _ := store.Update(ctx, "user:1", func(state *State) {
+			state.Failures++
+		})
+		assert.Equal(t, 1, saved.Failures)
+	})
+
+	t.Run("should drop a state updated back to zero", func(t *testing.T) {
+		store := NewMemoryStore()
+		_, _ = store.Update(ctx, "user:1", func(state *State) { state.Failures = 2 })
+		_, _ = store.Update(ctx, "user:1", func(state *State) { *state = State{} })
+		assert.Empty(t, store.states)
+	})
+
+	t.Run("should report whether delete found a state", func(t *testing.T) {
+		store := NewMemoryStore()
+		_, _ = store.Update(ctx, "user:1", func(state *State) { state.Lockouts = 1 })
+
+		deleted, err := store.Delete(ctx, "user:1")
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+		deleted, _ = store.Delete(ctx, "user:1")
+		assert.False(t, deleted)
+	})
+
+	t.Run("should not lose concurrent updates", func(t *testing.T) {
+		store := NewMemoryStore()
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = store.Update(ctx, "ip:10.0.0.1", func(state *State) { state.Failures++ })
+			}()
+		}
+		wg.Wait()
+		state, _ := store.Get(ctx, "ip:10.0.0.1")
+		assert.Equal(t, 50, state.Failures)
+	})
+}
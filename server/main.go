@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/command"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/grpc"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/http"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/job"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/metrics"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/mq"
@@ -23,8 +27,16 @@ func main() {
 	config.Init()
 	log.InitLogger()
 	repository.Init()
+	// `server <command> [args...]` runs a one-shot command instead of the servers
+	if len(os.Args) > 1 {
+		if err := command.Run(context.Background(), os.Args[1], os.Args[2:]); err != nil {
+			log.Logger.Fatalf("Command %s failed: %v", os.Args[1], err)
+		}
+		return
+	}
 	utils.InitJwtSecret()
 	mq.Init()
+	job.Init()
 	metrics.RegisterMetrics()
 	go grpc.Init(sigCh)
 	go http.Init(sigCh)
@@ -32,4 +44,10 @@ func main() {
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	sig := <-sigCh // Block until signal is received
 	log.Logger.Infof("Received signal: %v, shutting down...", sig)
+	// consumers get a grace period to finish and commit the messages they are processing
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	if err := mq.Shutdown(ctx); err != nil {
+		log.Logger.Errorf("Failed to shut down Kafka consumers: %v", err)
+	}
 }
@@ -30,8 +30,84 @@ var (
 		},
 		[]string{"method", "path", "status"},
 	)
+
+	// 余额乐观锁冲突次数
+	BalanceUpdateConflictsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_balance_update_conflicts_total",
+			Help: "Total number of balance compare-and-swap conflicts.",
+		},
+		[]string{"op"},
+	)
+
+	// 余额更新重试次数
+	BalanceUpdateRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_balance_update_retries_total",
+			Help: "Total number of balance update transactions retried after a conflict.",
+		},
+		[]string{"op"},
+	)
+
+	// 重试耗尽仍失败的次数
+	BalanceUpdateRetryExhaustedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_balance_update_retry_exhausted_total",
+			Help: "Total number of balance updates that still conflicted after all retries.",
+		},
+		[]string{"op"},
+	)
+
+	// 最近一次对账发现的差异数
+	ReconciliationDiscrepancies = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "payment_reconciliation_discrepancies",
+			Help: "Number of discrepancies found by the latest reconciliation run.",
+		},
+		[]string{"kind"},
+	)
+
+	// 兑换码充值失败过多被锁定的次数
+	RedeemCodeLockoutsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_redeem_code_lockouts_total",
+			Help: "Total number of users and ips locked out of redeem code top-ups after too many failed attempts.",
+		},
+		[]string{"subject"},
+	)
+
+	// 待发布的 outbox 事件数
+	OutboxBacklog = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "payment_outbox_backlog",
+			Help: "Number of outbox events waiting to be published to Kafka.",
+		},
+	)
+
+	// Kafka 消息处理失败后重试的次数
+	KafkaConsumerRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_kafka_consumer_retries_total",
+			Help: "Total number of Kafka messages processed again after a failed attempt.",
+		},
+		[]string{"topic"},
+	)
+
+	// 重试耗尽后转入死信主题的消息数
+	KafkaDeadLettersTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_kafka_dead_letters_total",
+			Help: "Total number of Kafka messages sent to a dead-letter topic after all attempts failed.",
+		},
+		[]string{"topic"},
+	)
 )
 
 func RegisterMetrics() {
 	prometheus.MustRegister(HttpRequestsTotal, HttpRequestDuration, HttpRequestsErrors)
+	prometheus.MustRegister(BalanceUpdateConflictsTotal, BalanceUpdateRetriesTotal, BalanceUpdateRetryExhaustedTotal)
+	prometheus.MustRegister(ReconciliationDiscrepancies)
+	prometheus.MustRegister(RedeemCodeLockoutsTotal)
+	prometheus.MustRegister(OutboxBacklog)
+	prometheus.MustRegister(KafkaConsumerRetriesTotal, KafkaDeadLettersTotal)
 }
@@ -0,0 +1,216 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/event"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/metrics"
+)
+
+// commitTimeout bounds a single commit, which also runs after the shutdown deadline has passed.
+const commitTimeout = 10 * time.Second
+
+// consumer processes the messages of one topic on a pool of workers. Messages are handed to workers by key, so
+// messages of one key are processed in order, and a message is committed once it and every message fetched
+// before it from its partition are processed. A failing message is retried with backoff and, once out of
+// attempts, sent to the dead-letter topic so that it no longer holds up the messages behind it.
+type consumer struct {
+	spec      consumerSpec
+	reader    messageReader
+	publisher event.Publisher
+	retry     retryPolicy
+	offsets   *offsetTracker
+
+	workers   []chan kafka.Message
+	processed chan kafka.Message
+
+	fetchCtx     context.Context
+	stopFetching context.CancelFunc
+	handleCtx    context.Context
+	stopHandling context.CancelFunc
+
+	fetchDone   chan struct{}
+	workerGroup sync.WaitGroup
+	commitDone  chan struct{}
+}
+
+func newConsumer(spec consumerSpec, reader messageReader, publisher event.Publisher, retry retryPolicy) *consumer {
+	c := &consumer{
+		spec:       spec,
+		reader:     reader,
+		publisher:  publisher,
+		retry:      retry,
+		offsets:    newOffsetTracker(),
+		workers:    make([]chan kafka.Message, spec.concurrency),
+		processed:  make(chan kafka.Message, spec.concurrency),
+		fetchDone:  make(chan struct{}),
+		commitDone: make(chan struct{}),
+	}
+	c.fetchCtx, c.stopFetching = context.WithCancel(context.Background())
+	c.handleCtx, c.stopHandling = context.WithCancel(context.Background())
+	for i := range c.workers {
+		c.workers[i] = make(chan kafka.Message, 1)
+	}
+	return c
+}
+
+func (c *consumer) start() {
+	go c.fetch()
+	for _, messages := range c.workers {
+		c.workerGroup.Add(1)
+		go c.work(messages)
+	}
+	go c.commit()
+}
+
+// shutdown stops fetching, waits for the workers to process what they were handed, or cancels them once ctx is
+// done, commits what was processed and closes the reader.
+func (c *consumer) shutdown(ctx context.Context) error {
+	c.stopFetching()
+	<-c.fetchDone
+	workersDone := make(chan struct{})
+	go func() {
+		c.workerGroup.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-ctx.Done():
+		log.Logger.Warnf("Kafka consumer for topic '%s' cancelling in-flight messages: %v", c.spec.topic, ctx.Err())
+		c.stopHandling()
+		<-workersDone
+	}
+	c.stopHandling()
+	close(c.processed)
+	<-c.commitDone
+	if err := c.reader.Close(); err != nil {
+		return fmt.Errorf("close reader of topic %s: %w", c.spec.topic, err)
+	}
+	log.Logger.Infof("Kafka consumer for topic '%s' stopped", c.spec.topic)
+	return nil
+}
+
+func (c *consumer) fetch() {
+	defer close(c.fetchDone)
+	defer func() {
+		for _, messages := range c.workers {
+			close(messages)
+		}
+	}()
+	readFailures := 0
+	for c.fetchCtx.Err() == nil {
+		m, err := c.reader.FetchMessage(c.fetchCtx)
+		if err != nil {
+			if c.fetchCtx.Err() != nil {
+				return
+			}
+			readFailures++
+			log.Logger.Errorf("Error reading message from topic %s: %v", c.spec.topic, err)
+			_ = sleepContext(c.fetchCtx, c.retry.backoff(readFailures))
+			continue
+		}
+		readFailures = 0
+		log.Logger.Infof("Message received: Topic=%s, Key=%s, Value=%s", m.Topic, m.Key, string(m.Value))
+		c.offsets.fetched(m)
+		select {
+		case c.workers[c.workerOf(m)] <- m:
+		case <-c.fetchCtx.Done():
+			// left uncommitted, it is delivered again after the restart
+			return
+		}
+	}
+}
+
+// workerOf picks the worker of m by key, or by partition for messages without one.
+func (c *consumer) workerOf(m kafka.Message) int {
+	if len(m.Key) == 0 {
+		return m.Partition % len(c.workers)
+	}
+	h := fnv.New32a()
+	_, _ = h.Write(m.Key)
+	return int(h.Sum32() % uint32(len(c.workers)))
+}
+
+func (c *consumer) work(messages <-chan kafka.Message) {
+	defer c.workerGroup.Done()
+	for m := range messages {
+		// past the shutdown deadline what is left is delivered again after the restart
+		if c.handleCtx.Err() == nil && c.handle(c.handleCtx, m) {
+			c.processed <- m
+		}
+	}
+}
+
+// commit commits processed messages as the offset tracker allows. It is the only one committing, so commits
+// of a partition never go backwards.
+func (c *consumer) commit() {
+	defer close(c.commitDone)
+	for m := range c.processed {
+		committable, ok := c.offsets.processed(m)
+		if !ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), commitTimeout)
+		err := c.reader.CommitMessages(ctx, committable)
+		cancel()
+		if err != nil {
+			log.Logger.Errorf("Failed to commit message at offset %d of topic %s: %v", committable.Offset, c.spec.topic, err)
+			continue
+		}
+		log.Logger.Infof("Topic: %s, Key: %s, Message at offset %d processed and committed", committable.Topic, committable.Key, committable.Offset)
+	}
+}
+
+// handle processes m until it succeeds or is dead-lettered and reports whether it may be committed, which is
+// not the case when ctx is done first. A message failing to decode is dead-lettered at once.
+func (c *consumer) handle(ctx context.Context, m kafka.Message) bool {
+	msg, err := c.spec.decode(m.Value)
+	if err != nil {
+		log.Logger.Errorf("Message at offset %d of topic %s failed to decode, dead-lettering: %v", m.Offset, m.Topic, err)
+		return c.deadLetter(ctx, m, 1, fmt.Errorf("decode: %w", err))
+	}
+	for attempt := 1; ; attempt++ {
+		err = c.spec.handle(ctx, msg)
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		if attempt >= c.retry.maxAttempts {
+			log.Logger.Errorf("Message at offset %d of topic %s failed %d attempts, dead-lettering: %v", m.Offset, m.Topic, attempt, err)
+			return c.deadLetter(ctx, m, attempt, err)
+		}
+		log.Logger.Warnf("Message at offset %d of topic %s failed attempt %d, retrying: %v", m.Offset, m.Topic, attempt, err)
+		metrics.KafkaConsumerRetriesTotal.WithLabelValues(c.spec.topic).Inc()
+		if sleepContext(ctx, c.retry.backoff(attempt)) != nil {
+			return false
+		}
+	}
+}
+
+// deadLetter publishes m to the dead-letter topic, retrying until it is acknowledged, as committing a message
+// that reached neither its handler nor the dead-letter topic would lose it.
+func (c *consumer) deadLetter(ctx context.Context, m kafka.Message, attempts int, processErr error) bool {
+	msg, err := newDeadLetterMessage(m, attempts, processErr)
+	if err != nil {
+		log.Logger.Errorf("Failed to build dead letter for offset %d of topic %s: %v", m.Offset, m.Topic, err)
+		return false
+	}
+	for failures := 1; ; failures++ {
+		if err = c.publisher.Publish(ctx, msg); err == nil {
+			metrics.KafkaDeadLettersTotal.WithLabelValues(c.spec.topic).Inc()
+			return true
+		}
+		log.Logger.Errorf("Failed to publish dead letter for offset %d of topic %s: %v", m.Offset, m.Topic, err)
+		if sleepContext(ctx, c.retry.backoff(failures)) != nil {
+			return false
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/event"
+	eventmocks "github.com/sw5005-sus/ceramicraft-payment-mservice/server/event/mocks"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+)
+
+// fakeReader hands out msgs in order, then blocks until ctx is done.
+type fakeReader struct {
+	mu        sync.Mutex
+	msgs      []kafka.Message
+	committed []int64
+	closed    bool
+}
+
+func (f *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	f.mu.Lock()
+	if len(f.msgs) > 0 {
+		m := f.msgs[0]
+		f.msgs = f.msgs[1:]
+		f.mu.Unlock()
+		return m, nil
+	}
+	f.mu.Unlock()
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (f *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, m := range msgs {
+		f.committed = append(f.committed, m.Offset)
+	}
+	return nil
+}
+
+func (f *fakeReader) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func initEnv() {
+	config.Config = &config.Conf{
+		LogConfig: &config.LogConfig{
+			Level:    "debug",
+			FilePath: "",
+		},
+	}
+	log.InitLogger()
+}
+
+func TestRetryPolicy(t *testing.T) {
+	t.Run("should fall back to defaults without config", func(t *testing.T) {
+		policy := newRetryPolicy(nil)
+		assert.Equal(t, defaultMaxAttempts, policy.maxAttempts)
+		assert.Equal(t, defaultRetryBackoff, policy.backoff(1))
+	})
+
+	t.Run("should double the backoff up to the max", func(t *testing.T) {
+		policy := newRetryPolicy(&config.KafkaConsumerConfig{MaxAttempts: 3, RetryBackoffMs: 100, RetryMaxBackoffMs: 500})
+		assert.Equal(t, 3, policy.maxAttempts)
+		assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+		assert.Equal(t, 200*time.Millisecond, policy.backoff(2))
+		assert.Equal(t, 400*time.Millisecond, policy.backoff(3))
+		assert.Equal(t, 500*time.Millisecond, policy.backoff(4))
+		assert.Equal(t, 500*time.Millisecond, policy.backoff(100))
+	})
+}
+
+// newTestConsumer builds the consumer of user-activated messages registered with handle.
+func newTestConsumer(reader messageReader, publisher event.Publisher, retry retryPolicy, concurrency int,
+	handle Handler[*UserActivationMessage]) *consumer {
+	r := &Registry{}
+	Register(r, "user-activated", concurrency, JSONDecoder[UserActivationMessage], handle)
+	return newConsumer(r.specs[0], reader, publisher, retry)
+}
+
+func TestConsumerHandle(t *testing.T) {
+	ctx := context.Background()
+	retry := retryPolicy{maxAttempts: 3, baseBackoff: time.Millisecond, maxBackoff: time.Millisecond}
+	initEnv()
+
+	t.Run("should retry a failing message and commit it once processed", func(t *testing.T) {
+		attempts := 0
+		c := newTestConsumer(nil, nil, retry, 1, func(ctx context.Context, msg *UserActivationMessage) error {
+			attempts++
+			if attempts < 3 {
+				return assert.AnError
+			}
+			return nil
+		})
+
+		assert.True(t, c.handle(ctx, kafka.Message{Topic: "user-activated", Offset: 7, Value: []byte(`{}`)}))
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("should dead-letter a message out of attempts", func(t *testing.T) {
+		publisher := new(eventmocks.Publisher)
+		attempts := 0
+		c := newTestConsumer(nil, publisher, retry, 1, func(ctx context.Context, msg *UserActivationMessage) error {
+			attempts++
+			return assert.AnError
+		})
+		publisher.On("Publish", ctx, mock.MatchedBy(func(msgs []event.Message) bool {
+			var deadLetter DeadLetter
+			return len(msgs) == 1 && msgs[0].Topic == "user-activated-dlq" && msgs[0].Key == "1" &&
+				json.Unmarshal(msgs[0].Value, &deadLetter) == nil && deadLetter.SourceTopic == "user-activated" &&
+				deadLetter.Partition == 2 && deadLetter.Offset == 7 && string(deadLetter.Payload) == `{"user_id":1}` &&
+				deadLetter.Error == assert.AnError.Error() && deadLetter.Attempts == 3
+		})).Return(nil).Once()
+
+		assert.True(t, c.handle(ctx, kafka.Message{Topic: "user-activated", Partition: 2, Offset: 7, Key: []byte("1"), Value: []byte(`{"user_id":1}`)}))
+		assert.Equal(t, 3, attempts)
+		publisher.AssertExpectations(t)
+	})
+
+	t.Run("should dead-letter a message failing to decode at once", func(t *testing.T) {
+		publisher := new(eventmocks.Publisher)
+		c := newTestConsumer(nil, publisher, retry, 1, func(ctx context.Context, msg *UserActivationMessage) error {
+			t.Fatal("handler called with a malformed message")
+			return nil
+		})
+		publisher.On("Publish", ctx, mock.MatchedBy(func(msgs []event.Message) bool {
+			var deadLetter DeadLetter
+			return len(msgs) == 1 && json.Unmarshal(msgs[0].Value, &deadLetter) == nil &&
+				string(deadLetter.Payload) == "not json" && strings.HasPrefix(deadLetter.Error, "decode: ") && deadLetter.Attempts == 1
+		})).Return(nil).Once()
+
+		assert.True(t, c.handle(ctx, kafka.Message{Topic: "user-activated", Offset: 7, Value: []byte("not json")}))
+		publisher.AssertExpectations(t)
+	})
+
+	t.Run("should keep publishing the dead letter until acknowledged", func(t *testing.T) {
+		publisher := new(eventmocks.Publisher)
+		c := newTestConsumer(nil, publisher, retry, 1, func(ctx context.Context, msg *UserActivationMessage) error {
+			return assert.AnError
+		})
+		publisher.On("Publish", ctx, mock.Anything).Return(assert.AnError).Twice()
+		publisher.On("Publish", ctx, mock.Anything).Return(nil).Once()
+
+		assert.True(t, c.handle(ctx, kafka.Message{Topic: "user-activated", Offset: 7, Value: []byte(`{}`)}))
+		publisher.AssertExpectations(t)
+	})
+
+	t.Run("should not commit a message when stopped while retrying", func(t *testing.T) {
+		cancelCtx, cancel := context.WithCancel(ctx)
+		slowRetry := retryPolicy{maxAttempts: 3, baseBackoff: time.Hour, maxBackoff: time.Hour}
+		c := newTestConsumer(nil, nil, slowRetry, 1, func(ctx context.Context, msg *UserActivationMessage) error {
+			cancel()
+			return assert.AnError
+		})
+
+		assert.False(t, c.handle(cancelCtx, kafka.Message{Topic: "user-activated", Offset: 7, Value: []byte(`{}`)}))
+	})
+}
+
+func TestConsumerRun(t *testing.T) {
+	ctx := context.Background()
+	retry := retryPolicy{maxAttempts: 3, baseBackoff: time.Millisecond, maxBackoff: time.Millisecond}
+	initEnv()
+	userMessage := func(offset int64, userId int) kafka.Message {
+		return kafka.Message{Topic: "user-activated", Offset: offset, Key: []byte(strconv.Itoa(userId)),
+			Value: []byte(fmt.Sprintf(`{"user_id":%d,"activate_time":%d}`, userId, offset))}
+	}
+
+	t.Run("should process messages of a key in order and commit them all", func(t *testing.T) {
+		reader := &fakeReader{}
+		for offset := int64(1); offset <= 12; offset++ {
+			reader.msgs = append(reader.msgs, userMessage(offset, int(offset%3)))
+		}
+		var mu sync.Mutex
+		var processing sync.WaitGroup
+		processing.Add(12)
+		byUser := make(map[int][]int64)
+		c := newTestConsumer(reader, nil, retry, 3, func(ctx context.Context, msg *UserActivationMessage) error {
+			defer processing.Done()
+			time.Sleep(time.Duration(12-msg.ActivateTime) * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			byUser[msg.UserID] = append(byUser[msg.UserID], msg.ActivateTime)
+			return nil
+		})
+
+		c.start()
+		processing.Wait()
+		assert.NoError(t, c.shutdown(ctx))
+		assert.Equal(t, map[int][]int64{0: {3, 6, 9, 12}, 1: {1, 4, 7, 10}, 2: {2, 5, 8, 11}}, byUser)
+		assert.IsIncreasing(t, reader.committed)
+		assert.Equal(t, int64(12), reader.committed[len(reader.committed)-1])
+		assert.True(t, reader.closed)
+	})
+
+	t.Run("should finish in-flight messages before closing on shutdown", func(t *testing.T) {
+		reader := &fakeReader{msgs: []kafka.Message{userMessage(1, 1)}}
+		started, release := make(chan struct{}), make(chan struct{})
+		c := newTestConsumer(reader, nil, retry, 1, func(ctx context.Context, msg *UserActivationMessage) error {
+			close(started)
+			<-release
+			return ctx.Err()
+		})
+
+		c.start()
+		<-started
+		stopped := make(chan error)
+		go func() {
+			stopped <- c.shutdown(ctx)
+		}()
+		select {
+		case <-stopped:
+			t.Fatal("shutdown returned with a message in flight")
+		case <-time.After(20 * time.Millisecond):
+		}
+		close(release)
+		assert.NoError(t, <-stopped)
+		assert.Equal(t, []int64{1}, reader.committed)
+		assert.True(t, reader.closed)
+	})
+
+	t.Run("should cancel in-flight messages at the deadline and leave them uncommitted", func(t *testing.T) {
+		reader := &fakeReader{msgs: []kafka.Message{userMessage(1, 1), userMessage(2, 2)}}
+		started := make(chan struct{})
+		c := newTestConsumer(reader, nil, retry, 1, func(ctx context.Context, msg *UserActivationMessage) error {
+			if msg.ActivateTime == 1 {
+				close(started)
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		c.start()
+		<-started
+		deadline, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		assert.NoError(t, c.shutdown(deadline))
+		assert.Empty(t, reader.committed)
+		assert.True(t, reader.closed)
+	})
+
+	t.Run("should run and stop registered consumers", func(t *testing.T) {
+		readers := map[string]*fakeReader{
+			"user-activated":  {msgs: []kafka.Message{userMessage(1, 1)}},
+			"order-cancelled": {msgs: []kafka.Message{{Topic: "order-cancelled", Offset: 5, Value: []byte(`{"biz_id":"b1"}`)}}},
+		}
+		var processing sync.WaitGroup
+		processing.Add(2)
+		r := &Registry{}
+		Register(r, "user-activated", 0, JSONDecoder[UserActivationMessage], func(ctx context.Context, msg *UserActivationMessage) error {
+			processing.Done()
+			return nil
+		})
+		Register(r, "order-cancelled", 2, JSONDecoder[OrderCancelledMessage], func(ctx context.Context, msg *OrderCancelledMessage) error {
+			assert.Equal(t, "b1", msg.BizId)
+			processing.Done()
+			return nil
+		})
+
+		r.Start(func(topic string) messageReader {
+			return readers[topic]
+		}, nil, retry)
+		processing.Wait()
+		assert.NoError(t, r.Shutdown(ctx))
+		for topic, reader := range readers {
+			assert.Len(t, reader.committed, 1, topic)
+			assert.True(t, reader.closed, topic)
+		}
+	})
+}
+
+func TestOffsetTracker(t *testing.T) {
+	message := func(partition int, offset int64) kafka.Message {
+		return kafka.Message{Partition: partition, Offset: offset}
+	}
+
+	t.Run("should commit only once older messages of the partition are processed", func(t *testing.T) {
+		tracker := newOffsetTracker()
+		for _, m := range []kafka.Message{message(0, 1), message(0, 2), message(1, 1), message(0, 3)} {
+			tracker.fetched(m)
+		}
+
+		_, ok := tracker.processed(message(0, 2))
+		assert.False(t, ok)
+		committable, ok := tracker.processed(message(1, 1))
+		assert.True(t, ok)
+		assert.Equal(t, message(1, 1), committable)
+		committable, ok = tracker.processed(message(0, 1))
+		assert.True(t, ok)
+		assert.Equal(t, message(0, 2), committable)
+		committable, ok = tracker.processed(message(0, 3))
+		assert.True(t, ok)
+		assert.Equal(t, message(0, 3), committable)
+	})
+
+	t.Run("should start over a partition fetched again from an earlier offset", func(t *testing.T) {
+		tracker := newOffsetTracker()
+		tracker.fetched(message(0, 1))
+		tracker.fetched(message(0, 2))
+		tracker.fetched(message(0, 2))
+
+		committable, ok := tracker.processed(message(0, 2))
+		assert.True(t, ok)
+		assert.Equal(t, message(0, 2), committable)
+		_, ok = tracker.processed(message(0, 1))
+		assert.False(t, ok)
+	})
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+	deadLetterMessage := func(offset int64, value string) kafka.Message {
+		return kafka.Message{Topic: "user-activated-dlq", Offset: offset, Value: []byte(value)}
+	}
+
+	t.Run("should publish dead letters back onto their topic", func(t *testing.T) {
+		publisher := new(eventmocks.Publisher)
+		reader := &fakeReader{msgs: []kafka.Message{
+			deadLetterMessage(1, `{"source_topic":"user-activated","key":"MQ==","payload":"eyJ1c2VyX2lkIjoxfQ==","error":"boom"}`),
+			deadLetterMessage(2, `garbage`),
+		}}
+		publisher.On("Publish", ctx, []event.Message{{Topic: "user-activated", Key: "1", Value: []byte(`{"user_id":1}`)}}).Return(nil).Once()
+
+		replayed, err := replayDeadLetters(ctx, reader, publisher, 0, 10*time.Millisecond)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, replayed)
+		// the malformed dead letter is skipped rather than blocking the rest
+		assert.Equal(t, []int64{1, 2}, reader.committed)
+		publisher.AssertExpectations(t)
+	})
+
+	t.Run("should stop at the limit", func(t *testing.T) {
+		publisher := new(eventmocks.Publisher)
+		reader := &fakeReader{msgs: []kafka.Message{
+			deadLetterMessage(1, `{"source_topic":"user-activated","payload":"e30="}`),
+			deadLetterMessage(2, `{"source_topic":"user-activated","payload":"e30="}`),
+		}}
+		publisher.On("Publish", ctx, mock.Anything).Return(nil).Once()
+
+		replayed, err := replayDeadLetters(ctx, reader, publisher, 1, 10*time.Millisecond)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, replayed)
+		assert.Equal(t, []int64{1}, reader.committed)
+	})
+
+	t.Run("should leave a dead letter uncommitted when publishing fails", func(t *testing.T) {
+		publisher := new(eventmocks.Publisher)
+		reader := &fakeReader{msgs: []kafka.Message{deadLetterMessage(1, `{"source_topic":"user-activated","payload":"e30="}`)}}
+		publisher.On("Publish", ctx, mock.Anything).Return(assert.AnError).Once()
+
+		replayed, err := replayDeadLetters(ctx, reader, publisher, 0, 10*time.Millisecond)
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.Equal(t, 0, replayed)
+		assert.Empty(t, reader.committed)
+	})
+}
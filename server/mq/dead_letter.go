@@ -0,0 +1,102 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/event"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+)
+
+const (
+	deadLetterTopicSuffix = "-dlq"
+	// consumer group of the replay, apart from the consumers so that a message is replayed only once
+	deadLetterReplayGroupSuffix = "-dlq-replay"
+	// the replay stops once the dead-letter topic stays quiet this long
+	deadLetterReplayIdle = 10 * time.Second
+)
+
+// DeadLetterTopic returns the topic messages of topic go to once every attempt at processing them failed.
+func DeadLetterTopic(topic string) string {
+	return topic + deadLetterTopicSuffix
+}
+
+// DeadLetter is the value of a message on a dead-letter topic: the failed message, verbatim, with why and
+// where it failed. Key and Payload are base64 in JSON, a poison message need not be valid text.
+type DeadLetter struct {
+	SourceTopic string `json:"source_topic"`
+	Partition   int    `json:"partition"`
+	Offset      int64  `json:"offset"`
+	Key         []byte `json:"key"`
+	Payload     []byte `json:"payload"`
+	Error       string `json:"error"`
+	Attempts    int    `json:"attempts"`
+	FailedAt    int64  `json:"failed_at"` // unix seconds
+}
+
+func newDeadLetterMessage(m kafka.Message, attempts int, processErr error) (event.Message, error) {
+	value, err := json.Marshal(&DeadLetter{
+		SourceTopic: m.Topic,
+		Partition:   m.Partition,
+		Offset:      m.Offset,
+		Key:         m.Key,
+		Payload:     m.Value,
+		Error:       processErr.Error(),
+		Attempts:    attempts,
+		FailedAt:    time.Now().Unix(),
+	})
+	if err != nil {
+		return event.Message{}, err
+	}
+	return event.Message{Topic: DeadLetterTopic(m.Topic), Key: string(m.Key), Value: value}, nil
+}
+
+// ReplayDeadLetters publishes the dead-lettered messages of topic back onto it, up to limit messages or all of
+// them when limit is 0, and returns how many were replayed. A replayed message failing again is dead-lettered
+// again, it is not lost.
+func ReplayDeadLetters(ctx context.Context, topic string, limit int) (int, error) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  config.Config.KafkaConfig.Brokers,
+		Topic:    DeadLetterTopic(topic),
+		GroupID:  config.Config.KafkaConfig.GroupID + deadLetterReplayGroupSuffix,
+		MaxBytes: config.Config.KafkaConfig.MaxBytes,
+	})
+	defer func() {
+		_ = reader.Close()
+	}()
+	return replayDeadLetters(ctx, reader, event.GetPublisher(), limit, deadLetterReplayIdle)
+}
+
+func replayDeadLetters(ctx context.Context, reader messageReader, publisher event.Publisher, limit int, idle time.Duration) (int, error) {
+	replayed := 0
+	for limit <= 0 || replayed < limit {
+		fetchCtx, cancel := context.WithTimeout(ctx, idle)
+		m, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			break
+		}
+		if err != nil {
+			return replayed, err
+		}
+		var deadLetter DeadLetter
+		if err = json.Unmarshal(m.Value, &deadLetter); err != nil || deadLetter.SourceTopic == "" {
+			log.Logger.Warnf("Skipping malformed dead letter at offset %d of %s: %s", m.Offset, m.Topic, string(m.Value))
+		} else {
+			err = publisher.Publish(ctx, event.Message{Topic: deadLetter.SourceTopic, Key: string(deadLetter.Key), Value: deadLetter.Payload})
+			if err != nil {
+				return replayed, err
+			}
+			replayed++
+			log.Logger.Infof("Replayed dead letter from offset %d of %s, failed with: %s", deadLetter.Offset, deadLetter.SourceTopic, deadLetter.Error)
+		}
+		if err = reader.CommitMessages(ctx, m); err != nil {
+			return replayed, err
+		}
+	}
+	return replayed, nil
+}
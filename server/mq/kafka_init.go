@@ -6,41 +6,37 @@ import (
 
 	"github.com/segmentio/kafka-go"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
-	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/event"
 )
 
-type KafkaMsgProcessor func(msg []byte) error
+// messageReader is the part of kafka.Reader the consumers use.
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+var registry = &Registry{}
 
 func Init() {
-	startKafkaConsumer("user-activated", userActivationProcess)
-	log.Logger.Infof("Kafka consumer for topic 'user-activated' started")
+	Register(registry, "user-activated", 4, JSONDecoder[UserActivationMessage], userActivationProcess)
+	Register(registry, "order-cancelled", 4, JSONDecoder[OrderCancelledMessage], orderCancellationProcess)
+	Register(registry, "user-deactivated", 4, JSONDecoder[UserDeactivationMessage], userDeactivationProcess)
+	Register(registry, "user-reactivated", 4, JSONDecoder[UserReactivationMessage], userReactivationProcess)
+	registry.Start(newKafkaReader, event.GetPublisher(), newRetryPolicy(config.Config.KafkaConfig))
+}
+
+// Shutdown stops the consumers started by Init, see Registry.Shutdown.
+func Shutdown(ctx context.Context) error {
+	return registry.Shutdown(ctx)
 }
 
-func startKafkaConsumer(topic string, processor KafkaMsgProcessor) {
-	reader := kafka.NewReader(kafka.ReaderConfig{
+func newKafkaReader(topic string) messageReader {
+	return kafka.NewReader(kafka.ReaderConfig{
 		Brokers:        config.Config.KafkaConfig.Brokers,
 		Topic:          topic,
 		GroupID:        config.Config.KafkaConfig.GroupID,
 		MaxBytes:       config.Config.KafkaConfig.MaxBytes,                      // 10MB
 		CommitInterval: time.Duration(config.Config.KafkaConfig.CommitInterval), // disable auto-commit
 	})
-	go func() {
-		for {
-			ctx := context.Background()
-			m, err := reader.ReadMessage(ctx)
-			if err != nil {
-				log.Logger.Errorf("Error reading message: %v", err)
-				continue
-			}
-			log.Logger.Infof("Message received: Topic=%s, Key=%s, Value=%s", m.Topic, m.Key, string(m.Value))
-			err = processor(m.Value)
-			if err == nil {
-				cmitErr := reader.CommitMessages(ctx, m)
-				if cmitErr != nil {
-					log.Logger.Errorf("Failed to commit message at offset %d: %v", m.Offset, cmitErr)
-				}
-				log.Logger.Infof("Topic: %s, Key: %s, Message at offset %d processed and committed", m.Topic, m.Key, m.Offset)
-			}
-		}
-	}()
 }
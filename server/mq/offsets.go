@@ -0,0 +1,60 @@
+package mq
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// offsetTracker tells which message of a partition may be committed when messages fetched in order are
+// processed out of order: the last one of the longest run of processed messages from the oldest uncommitted.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[int]*partitionOffsets
+}
+
+type partitionOffsets struct {
+	pending   []int64 // uncommitted offsets in fetch order
+	processed map[int64]kafka.Message
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[int]*partitionOffsets)}
+}
+
+// fetched records m as in flight. A partition fetched from an earlier offset again, as happens after a consumer
+// group rebalance, starts over, the messages it had in flight are delivered again.
+func (t *offsetTracker) fetched(m kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.partitions[m.Partition]
+	if !ok || (len(p.pending) > 0 && m.Offset <= p.pending[len(p.pending)-1]) {
+		p = &partitionOffsets{processed: make(map[int64]kafka.Message)}
+		t.partitions[m.Partition] = p
+	}
+	p.pending = append(p.pending, m.Offset)
+}
+
+// processed records m as done and returns the message to commit, false if an older message is still in flight.
+func (t *offsetTracker) processed(m kafka.Message) (kafka.Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.partitions[m.Partition]
+	// a message from before the partition started over is delivered again
+	if !ok || len(p.pending) == 0 || m.Offset < p.pending[0] {
+		return kafka.Message{}, false
+	}
+	p.processed[m.Offset] = m
+	var last kafka.Message
+	committable := false
+	for len(p.pending) > 0 {
+		done, ok := p.processed[p.pending[0]]
+		if !ok {
+			break
+		}
+		delete(p.processed, p.pending[0])
+		p.pending = p.pending[1:]
+		last, committable = done, true
+	}
+	return last, committable
+}
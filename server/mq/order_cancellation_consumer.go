@@ -0,0 +1,37 @@
+package mq
+
+import (
+	"context"
+
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/service"
+)
+
+// OrderCancelledMessage is sent on order-cancelled by the order service when an order is cancelled, paid or not.
+type OrderCancelledMessage struct {
+	BizId      string `json:"biz_id"` // the bizId the order was paid with
+	UserID     int    `json:"user_id"`
+	CancelTime int64  `json:"cancel_time"`
+}
+
+func orderCancellationProcess(ctx context.Context, cancelledMsg *OrderCancelledMessage) error {
+	if cancelledMsg.BizId == "" {
+		log.Logger.Warnf("Order cancelled message of user ID %d has no biz_id, skipped", cancelledMsg.UserID)
+		return nil
+	}
+	refundLog, err := service.GetUserAccountService().RefundCancelledOrder(ctx, cancelledMsg.BizId)
+	if bizerror.RespCodeOf(err) == paymentpb.RespCode_PAY_ORDER_NOT_EXIST {
+		log.Logger.Infof("Cancelled order %s of user ID %d was not paid from a pay account, nothing to refund", cancelledMsg.BizId, cancelledMsg.UserID)
+		return nil
+	}
+	if err != nil {
+		log.Logger.Errorf("Failed to refund cancelled order %s of user ID %d: %v", cancelledMsg.BizId, cancelledMsg.UserID, err)
+		return err
+	}
+	if refundLog != nil {
+		log.Logger.Infof("Cancelled order %s of user ID %d refunded, amount %d", cancelledMsg.BizId, cancelledMsg.UserID, refundLog.Amount)
+	}
+	return nil
+}
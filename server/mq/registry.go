@@ -0,0 +1,91 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/event"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+)
+
+// Decoder turns the value of a message into what its Handler takes. A message failing to decode is
+// dead-lettered without being retried.
+type Decoder[T any] func(value []byte) (T, error)
+
+// Handler processes a decoded message. An error has the message retried, then dead-lettered.
+type Handler[T any] func(ctx context.Context, msg T) error
+
+// JSONDecoder decodes a JSON message into a new T.
+func JSONDecoder[T any](value []byte) (*T, error) {
+	msg := new(T)
+	if err := json.Unmarshal(value, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+type consumerSpec struct {
+	topic string
+	// messages of different keys are processed by up to concurrency workers at once, those of one key in order
+	concurrency int
+	decode      func(value []byte) (any, error)
+	handle      func(ctx context.Context, msg any) error
+}
+
+// Registry holds the consumers of the service and runs them between Start and Shutdown.
+type Registry struct {
+	mu        sync.Mutex
+	specs     []consumerSpec
+	consumers []*consumer
+}
+
+// Register adds the consumer of topic. Consumers registered after Start are not run.
+func Register[T any](r *Registry, topic string, concurrency int, decode Decoder[T], handle Handler[T]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.specs = append(r.specs, consumerSpec{
+		topic:       topic,
+		concurrency: max(concurrency, 1),
+		decode: func(value []byte) (any, error) {
+			return decode(value)
+		},
+		handle: func(ctx context.Context, msg any) error {
+			return handle(ctx, msg.(T))
+		},
+	})
+}
+
+// Start runs every registered consumer with a reader of its own.
+func (r *Registry) Start(newReader func(topic string) messageReader, publisher event.Publisher, retry retryPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, spec := range r.specs {
+		c := newConsumer(spec, newReader(spec.topic), publisher, retry)
+		c.start()
+		r.consumers = append(r.consumers, c)
+		log.Logger.Infof("Kafka consumer for topic '%s' started, concurrency %d", spec.topic, spec.concurrency)
+	}
+}
+
+// Shutdown stops every consumer: fetching stops, messages in flight are processed and committed, then the
+// readers are closed. Handlers still running when ctx is done have their context cancelled, their messages are
+// left uncommitted and delivered again after the restart.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	consumers := r.consumers
+	r.consumers = nil
+	r.mu.Unlock()
+	var wg sync.WaitGroup
+	errs := make([]error, len(consumers))
+	for i, c := range consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = c.shutdown(ctx)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,63 @@
+package mq
+
+import (
+	"context"
+	"time"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+)
+
+const (
+	defaultMaxAttempts     = 5
+	defaultRetryBackoff    = 200 * time.Millisecond
+	defaultRetryMaxBackoff = 10 * time.Second
+)
+
+// retryPolicy decides how often a failing message is processed and how long to wait in between.
+type retryPolicy struct {
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+}
+
+func newRetryPolicy(conf *config.KafkaConsumerConfig) retryPolicy {
+	policy := retryPolicy{
+		maxAttempts: defaultMaxAttempts,
+		baseBackoff: defaultRetryBackoff,
+		maxBackoff:  defaultRetryMaxBackoff,
+	}
+	if conf == nil {
+		return policy
+	}
+	if conf.MaxAttempts > 0 {
+		policy.maxAttempts = conf.MaxAttempts
+	}
+	if conf.RetryBackoffMs > 0 {
+		policy.baseBackoff = time.Duration(conf.RetryBackoffMs) * time.Millisecond
+	}
+	if conf.RetryMaxBackoffMs > 0 {
+		policy.maxBackoff = time.Duration(conf.RetryMaxBackoffMs) * time.Millisecond
+	}
+	return policy
+}
+
+// backoff returns the wait after the given number of consecutive failures, doubling from the base up to the max.
+func (p retryPolicy) backoff(failures int) time.Duration {
+	d := p.baseBackoff
+	for i := 1; i < failures && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, p.maxBackoff)
+}
+
+// sleepContext waits for d, returning the error of ctx if it is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
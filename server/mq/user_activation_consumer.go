@@ -2,7 +2,6 @@ package mq
 
 import (
 	"context"
-	"encoding/json"
 
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/service"
@@ -13,14 +12,8 @@ type UserActivationMessage struct {
 	ActivateTime int64 `json:"activate_time"`
 }
 
-func userActivationProcess(msg []byte) error {
-	var activationMsg UserActivationMessage
-	err := json.Unmarshal(msg, &activationMsg)
-	if err != nil {
-		log.Logger.Warnf("Failed to unmarshal user activation message: %s", string(msg))
-		return nil
-	}
-	userAccount, err := service.GetUserAccountService().CreateUserAccount(context.Background(), activationMsg.UserID)
+func userActivationProcess(ctx context.Context, activationMsg *UserActivationMessage) error {
+	userAccount, err := service.GetUserAccountService().CreateUserAccount(ctx, activationMsg.UserID)
 	if err != nil {
 		log.Logger.Errorf("Failed to create user account for user ID %d: %v", activationMsg.UserID, err)
 		return err
@@ -0,0 +1,62 @@
+package mq
+
+import (
+	"context"
+	"time"
+
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/service"
+)
+
+// UserDeactivationReasonDeleted closes the pay account for good, any other reason, such as "banned", freezes it.
+const UserDeactivationReasonDeleted = "deleted"
+
+// UserDeactivationMessage is sent on user-deactivated by the user service when a user is banned or deleted.
+type UserDeactivationMessage struct {
+	UserID         int    `json:"user_id"`
+	Reason         string `json:"reason"`
+	DeactivateTime int64  `json:"deactivate_time"`
+}
+
+// UserReactivationMessage is sent on user-reactivated by the user service when a ban is lifted.
+type UserReactivationMessage struct {
+	UserID         int   `json:"user_id"`
+	ReactivateTime int64 `json:"reactivate_time"`
+}
+
+func userDeactivationProcess(ctx context.Context, deactivationMsg *UserDeactivationMessage) error {
+	status := model.UserAccountStatusFrozen
+	if deactivationMsg.Reason == UserDeactivationReasonDeleted {
+		status = model.UserAccountStatusClosed
+	}
+	return updateUserAccountStatus(ctx, deactivationMsg.UserID, status, deactivationMsg.DeactivateTime)
+}
+
+func userReactivationProcess(ctx context.Context, reactivationMsg *UserReactivationMessage) error {
+	return updateUserAccountStatus(ctx, reactivationMsg.UserID, model.UserAccountStatusActive, reactivationMsg.ReactivateTime)
+}
+
+// updateUserAccountStatus moves the account as of eventTime, in unix seconds. The two topics are not ordered with
+// each other, the time of the event decides which change wins. A message without a time counts as happening now.
+// It returns an error only when the update may succeed if retried.
+func updateUserAccountStatus(ctx context.Context, userId int, status int, eventTime int64) error {
+	changedAt := time.Now()
+	if eventTime > 0 {
+		changedAt = time.Unix(eventTime, 0)
+	}
+	account, err := service.GetUserAccountService().UpdateUserAccountStatus(ctx, userId, status, changedAt)
+	switch bizerror.RespCodeOf(err) {
+	case paymentpb.RespCode_SUCCESS:
+		log.Logger.Infof("User account of user ID %d is %s", userId, account.StatusName())
+		return nil
+	case paymentpb.RespCode_ACCOUNT_NOT_EXIST, paymentpb.RespCode_ACCOUNT_NOT_ACTIVE:
+		log.Logger.Warnf("User account status of user ID %d not updated: %v", userId, err)
+		return nil
+	default:
+		log.Logger.Errorf("Failed to update user account status of user ID %d: %v", userId, err)
+		return err
+	}
+}
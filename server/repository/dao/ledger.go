@@ -0,0 +1,101 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"gorm.io/gorm"
+)
+
+type LedgerDao interface {
+	GetAccountByCodeInTransaction(ctx context.Context, code string, tx *gorm.DB) (*model.LedgerAccount, error)
+	CreateAccountInTransaction(ctx context.Context, account *model.LedgerAccount, tx *gorm.DB) error
+	CreateEntryInTransaction(ctx context.Context, entry *model.JournalEntry, lines []*model.JournalLine, tx *gorm.DB) error
+	SumWalletLinesInTransaction(ctx context.Context, userAccountIds []int, tx *gorm.DB) ([]*model.LedgerAccountSum, error)
+}
+
+var (
+	ledgerDaoImpl     LedgerDao
+	ledgerDaoSyncOnce sync.Once
+)
+
+func GetLedgerDao() LedgerDao {
+	ledgerDaoSyncOnce.Do(func() {
+		ledgerDaoImpl = &LedgerDaoImpl{
+			db: repository.DB,
+		}
+	})
+	return ledgerDaoImpl
+}
+
+type LedgerDaoImpl struct {
+	db *gorm.DB
+}
+
+// GetAccountByCodeInTransaction implements LedgerDao.
+func (l *LedgerDaoImpl) GetAccountByCodeInTransaction(ctx context.Context, code string, tx *gorm.DB) (*model.LedgerAccount, error) {
+	var account model.LedgerAccount
+	ret := tx.WithContext(ctx).Where("code = ?", code).First(&account)
+	if ret.Error != nil {
+		if errors.Is(ret.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		log.Logger.Errorf("Failed to get ledger account %s: %v", code, ret.Error)
+		return nil, ret.Error
+	}
+	return &account, nil
+}
+
+// CreateAccountInTransaction implements LedgerDao.
+func (l *LedgerDaoImpl) CreateAccountInTransaction(ctx context.Context, account *model.LedgerAccount, tx *gorm.DB) error {
+	ret := tx.WithContext(ctx).Create(account)
+	if ret.Error != nil {
+		if errors.Is(ret.Error, gorm.ErrDuplicatedKey) {
+			log.Logger.Warnf("Ledger account %s already exists", account.Code)
+		} else {
+			log.Logger.Errorf("Failed to create ledger account %s: %v", account.Code, ret.Error)
+		}
+		return ret.Error
+	}
+	return nil
+}
+
+// CreateEntryInTransaction implements LedgerDao.
+// It creates the entry first and points every line at it.
+func (l *LedgerDaoImpl) CreateEntryInTransaction(ctx context.Context, entry *model.JournalEntry, lines []*model.JournalLine, tx *gorm.DB) error {
+	ret := tx.WithContext(ctx).Create(entry)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to create journal entry for change log %d: %v", entry.ChangeLogId, ret.Error)
+		return ret.Error
+	}
+	for _, line := range lines {
+		line.EntryId = entry.ID
+	}
+	ret = tx.WithContext(ctx).Create(lines)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to create journal lines for entry %d: %v", entry.ID, ret.Error)
+		return ret.Error
+	}
+	return nil
+}
+
+// SumWalletLinesInTransaction implements LedgerDao.
+// It totals the debits and credits of the wallets of the user accounts, accounts without a wallet are left out.
+func (l *LedgerDaoImpl) SumWalletLinesInTransaction(ctx context.Context, userAccountIds []int, tx *gorm.DB) ([]*model.LedgerAccountSum, error) {
+	var sums []*model.LedgerAccountSum
+	ret := tx.WithContext(ctx).Table("ledger_accounts").
+		Select("ledger_accounts.user_account_id, coalesce(sum(journal_lines.debit), 0) as debit, coalesce(sum(journal_lines.credit), 0) as credit").
+		Joins("left join journal_lines on journal_lines.ledger_account_id = ledger_accounts.id").
+		Where("ledger_accounts.user_account_id in ?", userAccountIds).
+		Group("ledger_accounts.user_account_id").
+		Scan(&sums)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to sum wallet lines of %d accounts: %v", len(userAccountIds), ret.Error)
+		return nil, ret.Error
+	}
+	return sums, nil
+}
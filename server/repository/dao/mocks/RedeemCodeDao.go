@@ -10,6 +10,8 @@ import (
 	mock "github.com/stretchr/testify/mock"
 
 	model "github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+
+	time "time"
 )
 
 // RedeemCodeDao is an autogenerated mock type for the RedeemCodeDao type
@@ -17,17 +19,139 @@ type RedeemCodeDao struct {
 	mock.Mock
 }
 
-// BatchInsert provides a mock function with given fields: ctx, redeemCodes
-func (_m *RedeemCodeDao) BatchInsert(ctx context.Context, redeemCodes []*model.RedeemCode) error {
-	ret := _m.Called(ctx, redeemCodes)
+// ClaimSealedCodes provides a mock function with given fields: ctx, ids
+func (_m *RedeemCodeDao) ClaimSealedCodes(ctx context.Context, ids []uint) ([]uint, error) {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimSealedCodes")
+	}
+
+	var r0 []uint
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []uint) ([]uint, error)); ok {
+		return rf(ctx, ids)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []uint) []uint); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]uint)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []uint) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountRedemptions provides a mock function with given fields: ctx, redeemCodeId, userId
+func (_m *RedeemCodeDao) CountRedemptions(ctx context.Context, redeemCodeId uint, userId int) (int, error) {
+	ret := _m.Called(ctx, redeemCodeId, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountRedemptions")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int) (int, error)); ok {
+		return rf(ctx, redeemCodeId, userId)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int) int); ok {
+		r0 = rf(ctx, redeemCodeId, userId)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, int) error); ok {
+		r1 = rf(ctx, redeemCodeId, userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountRedemptionsInTransaction provides a mock function with given fields: ctx, redeemCodeId, userId, tx
+func (_m *RedeemCodeDao) CountRedemptionsInTransaction(ctx context.Context, redeemCodeId uint, userId int, tx *gorm.DB) (int, error) {
+	ret := _m.Called(ctx, redeemCodeId, userId, tx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountRedemptionsInTransaction")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int, *gorm.DB) (int, error)); ok {
+		return rf(ctx, redeemCodeId, userId, tx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int, *gorm.DB) int); ok {
+		r0 = rf(ctx, redeemCodeId, userId, tx)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, int, *gorm.DB) error); ok {
+		r1 = rf(ctx, redeemCodeId, userId, tx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateBatch provides a mock function with given fields: ctx, batch, redeemCodes
+func (_m *RedeemCodeDao) CreateBatch(ctx context.Context, batch *model.RedeemCodeBatch, redeemCodes []*model.RedeemCode) error {
+	ret := _m.Called(ctx, batch, redeemCodes)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateBatch")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.RedeemCodeBatch, []*model.RedeemCode) error); ok {
+		r0 = rf(ctx, batch, redeemCodes)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateRedemptionInTransaction provides a mock function with given fields: ctx, redemption, tx
+func (_m *RedeemCodeDao) CreateRedemptionInTransaction(ctx context.Context, redemption *model.RedeemCodeRedemption, tx *gorm.DB) error {
+	ret := _m.Called(ctx, redemption, tx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateRedemptionInTransaction")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.RedeemCodeRedemption, *gorm.DB) error); ok {
+		r0 = rf(ctx, redemption, tx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FindRedeemCodesInBatches provides a mock function with given fields: ctx, query, batchSize, fn
+func (_m *RedeemCodeDao) FindRedeemCodesInBatches(ctx context.Context, query *model.RedeemCodeQuery, batchSize int, fn func(redeemCodes []*model.RedeemCode) error) error {
+	ret := _m.Called(ctx, query, batchSize, fn)
 
 	if len(ret) == 0 {
-		panic("no return value specified for BatchInsert")
+		panic("no return value specified for FindRedeemCodesInBatches")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, []*model.RedeemCode) error); ok {
-		r0 = rf(ctx, redeemCodes)
+	if rf, ok := ret.Get(0).(func(context.Context, *model.RedeemCodeQuery, int, func(redeemCodes []*model.RedeemCode) error) error); ok {
+		r0 = rf(ctx, query, batchSize, fn)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -35,9 +159,39 @@ func (_m *RedeemCodeDao) BatchInsert(ctx context.Context, redeemCodes []*model.R
 	return r0
 }
 
-// GetByCode provides a mock function with given fields: ctx, code
-func (_m *RedeemCodeDao) GetByCode(ctx context.Context, code string) (*model.RedeemCode, error) {
-	ret := _m.Called(ctx, code)
+// GetBatch provides a mock function with given fields: ctx, batchId
+func (_m *RedeemCodeDao) GetBatch(ctx context.Context, batchId uint) (*model.RedeemCodeBatch, error) {
+	ret := _m.Called(ctx, batchId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBatch")
+	}
+
+	var r0 *model.RedeemCodeBatch
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) (*model.RedeemCodeBatch, error)); ok {
+		return rf(ctx, batchId)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) *model.RedeemCodeBatch); ok {
+		r0 = rf(ctx, batchId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.RedeemCodeBatch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, batchId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetByCode provides a mock function with given fields: ctx, codeHash
+func (_m *RedeemCodeDao) GetByCode(ctx context.Context, codeHash string) (*model.RedeemCode, error) {
+	ret := _m.Called(ctx, codeHash)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetByCode")
@@ -46,10 +200,10 @@ func (_m *RedeemCodeDao) GetByCode(ctx context.Context, code string) (*model.Red
 	var r0 *model.RedeemCode
 	var r1 error
 	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.RedeemCode, error)); ok {
-		return rf(ctx, code)
+		return rf(ctx, codeHash)
 	}
 	if rf, ok := ret.Get(0).(func(context.Context, string) *model.RedeemCode); ok {
-		r0 = rf(ctx, code)
+		r0 = rf(ctx, codeHash)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.RedeemCode)
@@ -57,7 +211,183 @@ func (_m *RedeemCodeDao) GetByCode(ctx context.Context, code string) (*model.Red
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, code)
+		r1 = rf(ctx, codeHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HashRedeemCode provides a mock function with given fields: ctx, redeemCode
+func (_m *RedeemCodeDao) HashRedeemCode(ctx context.Context, redeemCode *model.RedeemCode) (bool, error) {
+	ret := _m.Called(ctx, redeemCode)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HashRedeemCode")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.RedeemCode) (bool, error)); ok {
+		return rf(ctx, redeemCode)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *model.RedeemCode) bool); ok {
+		r0 = rf(ctx, redeemCode)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *model.RedeemCode) error); ok {
+		r1 = rf(ctx, redeemCode)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InsertBatchChunk provides a mock function with given fields: ctx, batch, redeemCodes
+func (_m *RedeemCodeDao) InsertBatchChunk(ctx context.Context, batch *model.RedeemCodeBatch, redeemCodes []*model.RedeemCode) (int, error) {
+	ret := _m.Called(ctx, batch, redeemCodes)
+
+	if len(ret) == 0 {
+		panic("no return value specified for InsertBatchChunk")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.RedeemCodeBatch, []*model.RedeemCode) (int, error)); ok {
+		return rf(ctx, batch, redeemCodes)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *model.RedeemCodeBatch, []*model.RedeemCode) int); ok {
+		r0 = rf(ctx, batch, redeemCodes)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *model.RedeemCodeBatch, []*model.RedeemCode) error); ok {
+		r1 = rf(ctx, batch, redeemCodes)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryBatchProgress provides a mock function with given fields: ctx, batchIds
+func (_m *RedeemCodeDao) QueryBatchProgress(ctx context.Context, batchIds []uint) ([]*model.RedeemCodeBatchProgress, error) {
+	ret := _m.Called(ctx, batchIds)
+
+	if len(ret) == 0 {
+		panic("no return value specified for QueryBatchProgress")
+	}
+
+	var r0 []*model.RedeemCodeBatchProgress
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []uint) ([]*model.RedeemCodeBatchProgress, error)); ok {
+		return rf(ctx, batchIds)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []uint) []*model.RedeemCodeBatchProgress); ok {
+		r0 = rf(ctx, batchIds)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.RedeemCodeBatchProgress)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []uint) error); ok {
+		r1 = rf(ctx, batchIds)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryBatches provides a mock function with given fields: ctx, query
+func (_m *RedeemCodeDao) QueryBatches(ctx context.Context, query *model.RedeemCodeBatchQuery) ([]*model.RedeemCodeBatch, error) {
+	ret := _m.Called(ctx, query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for QueryBatches")
+	}
+
+	var r0 []*model.RedeemCodeBatch
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.RedeemCodeBatchQuery) ([]*model.RedeemCodeBatch, error)); ok {
+		return rf(ctx, query)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *model.RedeemCodeBatchQuery) []*model.RedeemCodeBatch); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.RedeemCodeBatch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *model.RedeemCodeBatchQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryBatchesByStatus provides a mock function with given fields: ctx, status, limit
+func (_m *RedeemCodeDao) QueryBatchesByStatus(ctx context.Context, status int, limit int) ([]*model.RedeemCodeBatch, error) {
+	ret := _m.Called(ctx, status, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for QueryBatchesByStatus")
+	}
+
+	var r0 []*model.RedeemCodeBatch
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) ([]*model.RedeemCodeBatch, error)); ok {
+		return rf(ctx, status, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []*model.RedeemCodeBatch); ok {
+		r0 = rf(ctx, status, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.RedeemCodeBatch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) error); ok {
+		r1 = rf(ctx, status, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryPlaintextRedeemCodes provides a mock function with given fields: ctx, afterID, limit
+func (_m *RedeemCodeDao) QueryPlaintextRedeemCodes(ctx context.Context, afterID uint, limit int) ([]*model.RedeemCode, error) {
+	ret := _m.Called(ctx, afterID, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for QueryPlaintextRedeemCodes")
+	}
+
+	var r0 []*model.RedeemCode
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int) ([]*model.RedeemCode, error)); ok {
+		return rf(ctx, afterID, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int) []*model.RedeemCode); ok {
+		r0 = rf(ctx, afterID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.RedeemCode)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, int) error); ok {
+		r1 = rf(ctx, afterID, limit)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -95,6 +425,168 @@ func (_m *RedeemCodeDao) QueryRedeemCodes(ctx context.Context, query *model.Rede
 	return r0, r1
 }
 
+// QueryRedemptions provides a mock function with given fields: ctx, redeemCodeId, limit
+func (_m *RedeemCodeDao) QueryRedemptions(ctx context.Context, redeemCodeId uint, limit int) ([]*model.RedeemCodeRedemption, error) {
+	ret := _m.Called(ctx, redeemCodeId, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for QueryRedemptions")
+	}
+
+	var r0 []*model.RedeemCodeRedemption
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int) ([]*model.RedeemCodeRedemption, error)); ok {
+		return rf(ctx, redeemCodeId, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int) []*model.RedeemCodeRedemption); ok {
+		r0 = rf(ctx, redeemCodeId, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.RedeemCodeRedemption)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, int) error); ok {
+		r1 = rf(ctx, redeemCodeId, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryUsedRedeemCodes provides a mock function with given fields: ctx, afterID, limit
+func (_m *RedeemCodeDao) QueryUsedRedeemCodes(ctx context.Context, afterID uint, limit int) ([]*model.RedeemCode, error) {
+	ret := _m.Called(ctx, afterID, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for QueryUsedRedeemCodes")
+	}
+
+	var r0 []*model.RedeemCode
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int) ([]*model.RedeemCode, error)); ok {
+		return rf(ctx, afterID, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int) []*model.RedeemCode); ok {
+		r0 = rf(ctx, afterID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.RedeemCode)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, int) error); ok {
+		r1 = rf(ctx, afterID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RedeemPromoCodeInTransaction provides a mock function with given fields: ctx, redeemCode, tx
+func (_m *RedeemCodeDao) RedeemPromoCodeInTransaction(ctx context.Context, redeemCode *model.RedeemCode, tx *gorm.DB) (int, error) {
+	ret := _m.Called(ctx, redeemCode, tx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RedeemPromoCodeInTransaction")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.RedeemCode, *gorm.DB) (int, error)); ok {
+		return rf(ctx, redeemCode, tx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *model.RedeemCode, *gorm.DB) int); ok {
+		r0 = rf(ctx, redeemCode, tx)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *model.RedeemCode, *gorm.DB) error); ok {
+		r1 = rf(ctx, redeemCode, tx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RevokeBatch provides a mock function with given fields: ctx, batchId, revokedAt
+func (_m *RedeemCodeDao) RevokeBatch(ctx context.Context, batchId uint, revokedAt time.Time) (int, error) {
+	ret := _m.Called(ctx, batchId, revokedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeBatch")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, time.Time) (int, error)); ok {
+		return rf(ctx, batchId, revokedAt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, time.Time) int); ok {
+		r0 = rf(ctx, batchId, revokedAt)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, time.Time) error); ok {
+		r1 = rf(ctx, batchId, revokedAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SealPlaintextCode provides a mock function with given fields: ctx, redeemCodeId, sealedCode
+func (_m *RedeemCodeDao) SealPlaintextCode(ctx context.Context, redeemCodeId uint, sealedCode []byte) (bool, error) {
+	ret := _m.Called(ctx, redeemCodeId, sealedCode)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SealPlaintextCode")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, []byte) (bool, error)); ok {
+		return rf(ctx, redeemCodeId, sealedCode)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, []byte) bool); ok {
+		r0 = rf(ctx, redeemCodeId, sealedCode)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, []byte) error); ok {
+		r1 = rf(ctx, redeemCodeId, sealedCode)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateBatchStatus provides a mock function with given fields: ctx, batchId, status, lastError
+func (_m *RedeemCodeDao) UpdateBatchStatus(ctx context.Context, batchId uint, status int, lastError string) error {
+	ret := _m.Called(ctx, batchId, status, lastError)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateBatchStatus")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int, string) error); ok {
+		r0 = rf(ctx, batchId, status, lastError)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UseRedeemCodeInTransaction provides a mock function with given fields: ctx, redeemCode, tx
 func (_m *RedeemCodeDao) UseRedeemCodeInTransaction(ctx context.Context, redeemCode *model.RedeemCode, tx *gorm.DB) (int, error) {
 	ret := _m.Called(ctx, redeemCode, tx)
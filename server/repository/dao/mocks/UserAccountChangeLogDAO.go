@@ -10,6 +10,8 @@ import (
 	mock "github.com/stretchr/testify/mock"
 
 	model "github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+
+	time "time"
 )
 
 // UserAccountChangeLogDAO is an autogenerated mock type for the UserAccountChangeLogDAO type
@@ -17,6 +19,34 @@ type UserAccountChangeLogDAO struct {
 	mock.Mock
 }
 
+// AddRefundedAmountInTransaction provides a mock function with given fields: ctx, id, amount, tx
+func (_m *UserAccountChangeLogDAO) AddRefundedAmountInTransaction(ctx context.Context, id int, amount int, tx *gorm.DB) (int, error) {
+	ret := _m.Called(ctx, id, amount, tx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddRefundedAmountInTransaction")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, *gorm.DB) (int, error)); ok {
+		return rf(ctx, id, amount, tx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, *gorm.DB) int); ok {
+		r0 = rf(ctx, id, amount, tx)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int, *gorm.DB) error); ok {
+		r1 = rf(ctx, id, amount, tx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateChangeLogInTransaction provides a mock function with given fields: ctx, changeLog, tx
 func (_m *UserAccountChangeLogDAO) CreateChangeLogInTransaction(ctx context.Context, changeLog *model.UserAccountChangeLog, tx *gorm.DB) error {
 	ret := _m.Called(ctx, changeLog, tx)
@@ -35,6 +65,66 @@ func (_m *UserAccountChangeLogDAO) CreateChangeLogInTransaction(ctx context.Cont
 	return r0
 }
 
+// GetChangeLogByID provides a mock function with given fields: ctx, id
+func (_m *UserAccountChangeLogDAO) GetChangeLogByID(ctx context.Context, id int) (*model.UserAccountChangeLog, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetChangeLogByID")
+	}
+
+	var r0 *model.UserAccountChangeLog
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (*model.UserAccountChangeLog, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) *model.UserAccountChangeLog); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UserAccountChangeLog)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetChangeLogByIdempotentKey provides a mock function with given fields: ctx, idempotentKey, opType
+func (_m *UserAccountChangeLogDAO) GetChangeLogByIdempotentKey(ctx context.Context, idempotentKey string, opType int) (*model.UserAccountChangeLog, error) {
+	ret := _m.Called(ctx, idempotentKey, opType)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetChangeLogByIdempotentKey")
+	}
+
+	var r0 *model.UserAccountChangeLog
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) (*model.UserAccountChangeLog, error)); ok {
+		return rf(ctx, idempotentKey, opType)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) *model.UserAccountChangeLog); ok {
+		r0 = rf(ctx, idempotentKey, opType)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UserAccountChangeLog)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, idempotentKey, opType)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // QueryChangeLogs provides a mock function with given fields: ctx, query
 func (_m *UserAccountChangeLogDAO) QueryChangeLogs(ctx context.Context, query *model.UserAccountChangeLogQuery) ([]*model.UserAccountChangeLog, error) {
 	ret := _m.Called(ctx, query)
@@ -65,6 +155,149 @@ func (_m *UserAccountChangeLogDAO) QueryChangeLogs(ctx context.Context, query *m
 	return r0, r1
 }
 
+// QueryChangeLogsByAccount provides a mock function with given fields: ctx, accountId, afterID, limit
+func (_m *UserAccountChangeLogDAO) QueryChangeLogsByAccount(ctx context.Context, accountId int, afterID int, limit int) ([]*model.UserAccountChangeLog, error) {
+	ret := _m.Called(ctx, accountId, afterID, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for QueryChangeLogsByAccount")
+	}
+
+	var r0 []*model.UserAccountChangeLog
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, int) ([]*model.UserAccountChangeLog, error)); ok {
+		return rf(ctx, accountId, afterID, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, int) []*model.UserAccountChangeLog); ok {
+		r0 = rf(ctx, accountId, afterID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.UserAccountChangeLog)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int, int) error); ok {
+		r1 = rf(ctx, accountId, afterID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryChangeLogsByIdempotentKeys provides a mock function with given fields: ctx, idempotentKeys, opType
+func (_m *UserAccountChangeLogDAO) QueryChangeLogsByIdempotentKeys(ctx context.Context, idempotentKeys []string, opType int) ([]*model.UserAccountChangeLog, error) {
+	ret := _m.Called(ctx, idempotentKeys, opType)
+
+	if len(ret) == 0 {
+		panic("no return value specified for QueryChangeLogsByIdempotentKeys")
+	}
+
+	var r0 []*model.UserAccountChangeLog
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string, int) ([]*model.UserAccountChangeLog, error)); ok {
+		return rf(ctx, idempotentKeys, opType)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []string, int) []*model.UserAccountChangeLog); ok {
+		r0 = rf(ctx, idempotentKeys, opType)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.UserAccountChangeLog)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []string, int) error); ok {
+		r1 = rf(ctx, idempotentKeys, opType)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SumAmountsByAccountsInTransaction provides a mock function with given fields: ctx, accountIds, tx
+func (_m *UserAccountChangeLogDAO) SumAmountsByAccountsInTransaction(ctx context.Context, accountIds []int, tx *gorm.DB) ([]*model.ChangeLogAmountSum, error) {
+	ret := _m.Called(ctx, accountIds, tx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SumAmountsByAccountsInTransaction")
+	}
+
+	var r0 []*model.ChangeLogAmountSum
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int, *gorm.DB) ([]*model.ChangeLogAmountSum, error)); ok {
+		return rf(ctx, accountIds, tx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int, *gorm.DB) []*model.ChangeLogAmountSum); ok {
+		r0 = rf(ctx, accountIds, tx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.ChangeLogAmountSum)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int, *gorm.DB) error); ok {
+		r1 = rf(ctx, accountIds, tx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SumChangeLogsInTransaction provides a mock function with given fields: ctx, accountId, opType, since, tx
+func (_m *UserAccountChangeLogDAO) SumChangeLogsInTransaction(ctx context.Context, accountId int, opType int, since time.Time, tx *gorm.DB) (int, int, error) {
+	ret := _m.Called(ctx, accountId, opType, since, tx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SumChangeLogsInTransaction")
+	}
+
+	var r0 int
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, time.Time, *gorm.DB) (int, int, error)); ok {
+		return rf(ctx, accountId, opType, since, tx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, time.Time, *gorm.DB) int); ok {
+		r0 = rf(ctx, accountId, opType, since, tx)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int, time.Time, *gorm.DB) int); ok {
+		r1 = rf(ctx, accountId, opType, since, tx)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, int, int, time.Time, *gorm.DB) error); ok {
+		r2 = rf(ctx, accountId, opType, since, tx)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// UpdateBalanceSnapshot provides a mock function with given fields: ctx, changeLog
+func (_m *UserAccountChangeLogDAO) UpdateBalanceSnapshot(ctx context.Context, changeLog *model.UserAccountChangeLog) error {
+	ret := _m.Called(ctx, changeLog)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateBalanceSnapshot")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.UserAccountChangeLog) error); ok {
+		r0 = rf(ctx, changeLog)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewUserAccountChangeLogDAO creates a new instance of UserAccountChangeLogDAO. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewUserAccountChangeLogDAO(t interface {
@@ -10,6 +10,8 @@ import (
 	mock "github.com/stretchr/testify/mock"
 
 	model "github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+
+	time "time"
 )
 
 // UserAccountDao is an autogenerated mock type for the UserAccountDao type
@@ -53,6 +55,94 @@ func (_m *UserAccountDao) CreateUserAccount(ctx context.Context, userAccount *mo
 	return r0
 }
 
+// FreezeBalanceInTransaction provides a mock function with given fields: ctx, userID, amount, oldAmount, tx
+func (_m *UserAccountDao) FreezeBalanceInTransaction(ctx context.Context, userID int, amount int, oldAmount int, tx *gorm.DB) (int, error) {
+	ret := _m.Called(ctx, userID, amount, oldAmount, tx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FreezeBalanceInTransaction")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, int, *gorm.DB) (int, error)); ok {
+		return rf(ctx, userID, amount, oldAmount, tx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, int, *gorm.DB) int); ok {
+		r0 = rf(ctx, userID, amount, oldAmount, tx)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int, int, *gorm.DB) error); ok {
+		r1 = rf(ctx, userID, amount, oldAmount, tx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserAccountByAccountNo provides a mock function with given fields: ctx, accountNo
+func (_m *UserAccountDao) GetUserAccountByAccountNo(ctx context.Context, accountNo string) (*model.UserAccount, error) {
+	ret := _m.Called(ctx, accountNo)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserAccountByAccountNo")
+	}
+
+	var r0 *model.UserAccount
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.UserAccount, error)); ok {
+		return rf(ctx, accountNo)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.UserAccount); ok {
+		r0 = rf(ctx, accountNo)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UserAccount)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, accountNo)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserAccountByID provides a mock function with given fields: ctx, id
+func (_m *UserAccountDao) GetUserAccountByID(ctx context.Context, id int) (*model.UserAccount, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserAccountByID")
+	}
+
+	var r0 *model.UserAccount
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (*model.UserAccount, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) *model.UserAccount); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UserAccount)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetUserAccountByUserID provides a mock function with given fields: ctx, userID
 func (_m *UserAccountDao) GetUserAccountByUserID(ctx context.Context, userID int) (*model.UserAccount, error) {
 	ret := _m.Called(ctx, userID)
@@ -83,6 +173,142 @@ func (_m *UserAccountDao) GetUserAccountByUserID(ctx context.Context, userID int
 	return r0, r1
 }
 
+// LockUserAccountInTransaction provides a mock function with given fields: ctx, userID, tx
+func (_m *UserAccountDao) LockUserAccountInTransaction(ctx context.Context, userID int, tx *gorm.DB) (*model.UserAccount, error) {
+	ret := _m.Called(ctx, userID, tx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LockUserAccountInTransaction")
+	}
+
+	var r0 *model.UserAccount
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, *gorm.DB) (*model.UserAccount, error)); ok {
+		return rf(ctx, userID, tx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, *gorm.DB) *model.UserAccount); ok {
+		r0 = rf(ctx, userID, tx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UserAccount)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, *gorm.DB) error); ok {
+		r1 = rf(ctx, userID, tx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryUserAccounts provides a mock function with given fields: ctx, afterID, limit
+func (_m *UserAccountDao) QueryUserAccounts(ctx context.Context, afterID int, limit int) ([]*model.UserAccount, error) {
+	ret := _m.Called(ctx, afterID, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for QueryUserAccounts")
+	}
+
+	var r0 []*model.UserAccount
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) ([]*model.UserAccount, error)); ok {
+		return rf(ctx, afterID, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []*model.UserAccount); ok {
+		r0 = rf(ctx, afterID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.UserAccount)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) error); ok {
+		r1 = rf(ctx, afterID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryUserAccountsByUserIDs provides a mock function with given fields: ctx, userIDs
+func (_m *UserAccountDao) QueryUserAccountsByUserIDs(ctx context.Context, userIDs []int) ([]*model.UserAccount, error) {
+	ret := _m.Called(ctx, userIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for QueryUserAccountsByUserIDs")
+	}
+
+	var r0 []*model.UserAccount
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int) ([]*model.UserAccount, error)); ok {
+		return rf(ctx, userIDs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int) []*model.UserAccount); ok {
+		r0 = rf(ctx, userIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.UserAccount)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int) error); ok {
+		r1 = rf(ctx, userIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RefundBalanceInTransaction provides a mock function with given fields: ctx, userID, amount, oldAmount, tx
+func (_m *UserAccountDao) RefundBalanceInTransaction(ctx context.Context, userID int, amount int, oldAmount int, tx *gorm.DB) error {
+	ret := _m.Called(ctx, userID, amount, oldAmount, tx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RefundBalanceInTransaction")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, int, *gorm.DB) error); ok {
+		r0 = rf(ctx, userID, amount, oldAmount, tx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SettleFrozenBalanceInTransaction provides a mock function with given fields: ctx, userID, captureAmount, releaseAmount, tx
+func (_m *UserAccountDao) SettleFrozenBalanceInTransaction(ctx context.Context, userID int, captureAmount int, releaseAmount int, tx *gorm.DB) (int, error) {
+	ret := _m.Called(ctx, userID, captureAmount, releaseAmount, tx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SettleFrozenBalanceInTransaction")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, int, *gorm.DB) (int, error)); ok {
+		return rf(ctx, userID, captureAmount, releaseAmount, tx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, int, *gorm.DB) int); ok {
+		r0 = rf(ctx, userID, captureAmount, releaseAmount, tx)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int, int, *gorm.DB) error); ok {
+		r1 = rf(ctx, userID, captureAmount, releaseAmount, tx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SubtractBalanceInTransaction provides a mock function with given fields: ctx, userID, amount, oldAmount, tx
 func (_m *UserAccountDao) SubtractBalanceInTransaction(ctx context.Context, userID int, amount int, oldAmount int, tx *gorm.DB) (int, error) {
 	ret := _m.Called(ctx, userID, amount, oldAmount, tx)
@@ -111,6 +337,34 @@ func (_m *UserAccountDao) SubtractBalanceInTransaction(ctx context.Context, user
 	return r0, r1
 }
 
+// UpdateStatus provides a mock function with given fields: ctx, userID, status, changedAt
+func (_m *UserAccountDao) UpdateStatus(ctx context.Context, userID int, status int, changedAt time.Time) (int, error) {
+	ret := _m.Called(ctx, userID, status, changedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateStatus")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, time.Time) (int, error)); ok {
+		return rf(ctx, userID, status, changedAt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, time.Time) int); ok {
+		r0 = rf(ctx, userID, status, changedAt)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int, time.Time) error); ok {
+		r1 = rf(ctx, userID, status, changedAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewUserAccountDao creates a new instance of UserAccountDao. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewUserAccountDao(t interface {
@@ -0,0 +1,97 @@
+package dao
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"gorm.io/gorm"
+)
+
+type OutboxDao interface {
+	CreateEventInTransaction(ctx context.Context, event *model.OutboxEvent, tx *gorm.DB) error
+	QueryPendingEvents(ctx context.Context, limit int) ([]*model.OutboxEvent, error)
+	MarkEventsSent(ctx context.Context, ids []uint, sentAt time.Time) error
+	RecordPublishFailure(ctx context.Context, ids []uint, lastError string) error
+	CountPendingEvents(ctx context.Context) (int, error)
+}
+
+var (
+	outboxDaoImpl     OutboxDao
+	outboxDaoSyncOnce sync.Once
+)
+
+func GetOutboxDao() OutboxDao {
+	outboxDaoSyncOnce.Do(func() {
+		outboxDaoImpl = &OutboxDaoImpl{
+			db: repository.DB,
+		}
+	})
+	return outboxDaoImpl
+}
+
+type OutboxDaoImpl struct {
+	db *gorm.DB
+}
+
+// CreateEventInTransaction implements OutboxDao.
+func (o *OutboxDaoImpl) CreateEventInTransaction(ctx context.Context, event *model.OutboxEvent, tx *gorm.DB) error {
+	ret := tx.WithContext(ctx).Create(event)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to create %s outbox event for key %s: %v", event.EventType, event.EventKey, ret.Error)
+		return ret.Error
+	}
+	return nil
+}
+
+// QueryPendingEvents implements OutboxDao.
+// It returns up to limit pending events oldest first, the order they must be published in.
+func (o *OutboxDaoImpl) QueryPendingEvents(ctx context.Context, limit int) ([]*model.OutboxEvent, error) {
+	var events []*model.OutboxEvent
+	ret := o.db.WithContext(ctx).Where("status = ?", model.OutboxEventStatusPending).Order("id asc").Limit(limit).Find(&events)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to query pending outbox events: %v", ret.Error)
+		return nil, ret.Error
+	}
+	return events, nil
+}
+
+// MarkEventsSent implements OutboxDao.
+func (o *OutboxDaoImpl) MarkEventsSent(ctx context.Context, ids []uint, sentAt time.Time) error {
+	ret := o.db.WithContext(ctx).Model(&model.OutboxEvent{}).Where("id IN ?", ids).
+		Updates(map[string]interface{}{"status": model.OutboxEventStatusSent, "sent_at": sentAt})
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to mark %d outbox events sent: %v", len(ids), ret.Error)
+		return ret.Error
+	}
+	return nil
+}
+
+// RecordPublishFailure implements OutboxDao.
+// The events stay pending, to be published again by a later relay.
+func (o *OutboxDaoImpl) RecordPublishFailure(ctx context.Context, ids []uint, lastError string) error {
+	if len(lastError) > 255 {
+		lastError = lastError[:255]
+	}
+	ret := o.db.WithContext(ctx).Model(&model.OutboxEvent{}).Where("id IN ?", ids).
+		Updates(map[string]interface{}{"attempts": gorm.Expr("attempts + 1"), "last_error": lastError})
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to record publish failure of %d outbox events: %v", len(ids), ret.Error)
+		return ret.Error
+	}
+	return nil
+}
+
+// CountPendingEvents implements OutboxDao.
+func (o *OutboxDaoImpl) CountPendingEvents(ctx context.Context) (int, error) {
+	var count int64
+	ret := o.db.WithContext(ctx).Model(&model.OutboxEvent{}).Where("status = ?", model.OutboxEventStatusPending).Count(&count)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to count pending outbox events: %v", ret.Error)
+		return 0, ret.Error
+	}
+	return int(count), nil
+}
@@ -0,0 +1,95 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"gorm.io/gorm"
+)
+
+type PaymentHoldDao interface {
+	CreateHoldInTransaction(ctx context.Context, hold *model.PaymentHold, tx *gorm.DB) error
+	GetHoldByBizId(ctx context.Context, bizId string) (*model.PaymentHold, error)
+	UpdateHoldStatusInTransaction(ctx context.Context, hold *model.PaymentHold, oldStatus int, tx *gorm.DB) (int, error)
+	QueryExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*model.PaymentHold, error)
+}
+
+var (
+	paymentHoldDaoImpl     PaymentHoldDao
+	paymentHoldDaoSyncOnce sync.Once
+)
+
+func GetPaymentHoldDao() PaymentHoldDao {
+	paymentHoldDaoSyncOnce.Do(func() {
+		paymentHoldDaoImpl = &PaymentHoldDaoImpl{
+			db: repository.DB,
+		}
+	})
+	return paymentHoldDaoImpl
+}
+
+type PaymentHoldDaoImpl struct {
+	db *gorm.DB
+}
+
+// CreateHoldInTransaction implements PaymentHoldDao.
+func (p *PaymentHoldDaoImpl) CreateHoldInTransaction(ctx context.Context, hold *model.PaymentHold, tx *gorm.DB) error {
+	ret := tx.WithContext(ctx).Create(hold)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to create payment hold for user ID %d, biz ID %s: %v", hold.UserId, hold.BizId, ret.Error)
+		return ret.Error
+	}
+	return nil
+}
+
+// GetHoldByBizId implements PaymentHoldDao.
+func (p *PaymentHoldDaoImpl) GetHoldByBizId(ctx context.Context, bizId string) (*model.PaymentHold, error) {
+	var hold model.PaymentHold
+	ret := p.db.WithContext(ctx).Where("biz_id = ?", bizId).First(&hold)
+	if ret.Error != nil {
+		if errors.Is(ret.Error, gorm.ErrRecordNotFound) {
+			log.Logger.Warnf("Payment hold not found for biz ID %s", bizId)
+			return nil, nil
+		}
+		log.Logger.Errorf("Failed to get payment hold by biz ID %s: %v", bizId, ret.Error)
+		return nil, ret.Error
+	}
+	return &hold, nil
+}
+
+// UpdateHoldStatusInTransaction implements PaymentHoldDao.
+// The status and captured amount are only written while the hold is still in oldStatus.
+func (p *PaymentHoldDaoImpl) UpdateHoldStatusInTransaction(ctx context.Context, hold *model.PaymentHold, oldStatus int, tx *gorm.DB) (int, error) {
+	ret := tx.WithContext(ctx).Model(&model.PaymentHold{}).
+		Where("id = ? and status = ?", hold.ID, oldStatus).
+		Updates(map[string]interface{}{
+			"status":          hold.Status,
+			"captured_amount": hold.CapturedAmount,
+		})
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to update payment hold %d to status %d: %v", hold.ID, hold.Status, ret.Error)
+		return 0, ret.Error
+	}
+	return int(ret.RowsAffected), nil
+}
+
+// QueryExpiredHolds implements PaymentHoldDao.
+func (p *PaymentHoldDaoImpl) QueryExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*model.PaymentHold, error) {
+	var holds []*model.PaymentHold
+	if limit <= 0 || limit > repository.DefaultQueryLimit {
+		limit = repository.DefaultQueryLimit
+	}
+	ret := p.db.WithContext(ctx).
+		Where("status = ? and expires_at <= ?", model.HoldStatusAuthorized, now).
+		Order("expires_at").Limit(limit).Find(&holds)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to query expired payment holds: %v", ret.Error)
+		return nil, ret.Error
+	}
+	return holds, nil
+}
@@ -0,0 +1,43 @@
+package dao
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"gorm.io/gorm"
+)
+
+type ReconciliationDao interface {
+	CreateDiscrepancies(ctx context.Context, discrepancies []*model.ReconciliationDiscrepancy) error
+}
+
+var (
+	reconciliationDaoImpl     ReconciliationDao
+	reconciliationDaoSyncOnce sync.Once
+)
+
+func GetReconciliationDao() ReconciliationDao {
+	reconciliationDaoSyncOnce.Do(func() {
+		reconciliationDaoImpl = &ReconciliationDaoImpl{
+			db: repository.DB,
+		}
+	})
+	return reconciliationDaoImpl
+}
+
+type ReconciliationDaoImpl struct {
+	db *gorm.DB
+}
+
+// CreateDiscrepancies implements ReconciliationDao.
+func (r *ReconciliationDaoImpl) CreateDiscrepancies(ctx context.Context, discrepancies []*model.ReconciliationDiscrepancy) error {
+	ret := r.db.WithContext(ctx).CreateInBatches(discrepancies, repository.DefaultQueryLimit)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to create %d reconciliation discrepancies: %v", len(discrepancies), ret.Error)
+		return ret.Error
+	}
+	return nil
+}
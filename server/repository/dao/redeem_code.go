@@ -2,19 +2,40 @@ package dao
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"time"
 
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type RedeemCodeDao interface {
-	BatchInsert(ctx context.Context, redeemCodes []*model.RedeemCode) error
-	GetByCode(ctx context.Context, code string) (*model.RedeemCode, error)
+	CreateBatch(ctx context.Context, batch *model.RedeemCodeBatch, redeemCodes []*model.RedeemCode) error
+	GetBatch(ctx context.Context, batchId uint) (*model.RedeemCodeBatch, error)
+	InsertBatchChunk(ctx context.Context, batch *model.RedeemCodeBatch, redeemCodes []*model.RedeemCode) (int, error)
+	UpdateBatchStatus(ctx context.Context, batchId uint, status int, lastError string) error
+	QueryBatchesByStatus(ctx context.Context, status int, limit int) ([]*model.RedeemCodeBatch, error)
+	QueryBatches(ctx context.Context, query *model.RedeemCodeBatchQuery) ([]*model.RedeemCodeBatch, error)
+	QueryBatchProgress(ctx context.Context, batchIds []uint) ([]*model.RedeemCodeBatchProgress, error)
+	RevokeBatch(ctx context.Context, batchId uint, revokedAt time.Time) (int, error)
+	GetByCode(ctx context.Context, codeHash string) (*model.RedeemCode, error)
 	QueryRedeemCodes(ctx context.Context, query *model.RedeemCodeQuery) ([]*model.RedeemCode, error)
+	FindRedeemCodesInBatches(ctx context.Context, query *model.RedeemCodeQuery, batchSize int, fn func(redeemCodes []*model.RedeemCode) error) error
+	ClaimSealedCodes(ctx context.Context, ids []uint) ([]uint, error)
+	QueryUsedRedeemCodes(ctx context.Context, afterID uint, limit int) ([]*model.RedeemCode, error)
+	QueryPlaintextRedeemCodes(ctx context.Context, afterID uint, limit int) ([]*model.RedeemCode, error)
+	HashRedeemCode(ctx context.Context, redeemCode *model.RedeemCode) (bool, error)
+	SealPlaintextCode(ctx context.Context, redeemCodeId uint, sealedCode []byte) (bool, error)
 	UseRedeemCodeInTransaction(ctx context.Context, redeemCode *model.RedeemCode, tx *gorm.DB) (int, error)
+	RedeemPromoCodeInTransaction(ctx context.Context, redeemCode *model.RedeemCode, tx *gorm.DB) (int, error)
+	CountRedemptions(ctx context.Context, redeemCodeId uint, userId int) (int, error)
+	CountRedemptionsInTransaction(ctx context.Context, redeemCodeId uint, userId int, tx *gorm.DB) (int, error)
+	QueryRedemptions(ctx context.Context, redeemCodeId uint, limit int) ([]*model.RedeemCodeRedemption, error)
+	CreateRedemptionInTransaction(ctx context.Context, redemption *model.RedeemCodeRedemption, tx *gorm.DB) error
 }
 
 type RedeemCodeDaoImpl struct {
@@ -35,21 +56,168 @@ func GetRedeemCodeDao() RedeemCodeDao {
 	return redeemCodeDaoImpl
 }
 
-func (dao *RedeemCodeDaoImpl) BatchInsert(ctx context.Context, redeemCodes []*model.RedeemCode) error {
-	ret := dao.db.WithContext(ctx).Create(&redeemCodes)
+// CreateBatch creates the batch and its codes in one transaction, pointing every code at the batch.
+func (dao *RedeemCodeDaoImpl) CreateBatch(ctx context.Context, batch *model.RedeemCodeBatch, redeemCodes []*model.RedeemCode) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(batch).Error; err != nil {
+			log.Logger.Errorf("Failed to create redeem code batch %s: %v", batch.Name, err)
+			return err
+		}
+		// a generation job creates its batch empty
+		if len(redeemCodes) == 0 {
+			return nil
+		}
+		for _, redeemCode := range redeemCodes {
+			redeemCode.BatchId = batch.ID
+		}
+		ret := tx.Create(&redeemCodes)
+		if ret.Error != nil {
+			log.Logger.Errorf("Failed to batch insert redeem codes: %v", ret.Error)
+			return ret.Error
+		}
+		log.Logger.Infof("Successfully batch inserted %d redeem codes into batch %d", ret.RowsAffected, batch.ID)
+		return nil
+	})
+}
+
+func (dao *RedeemCodeDaoImpl) GetBatch(ctx context.Context, batchId uint) (*model.RedeemCodeBatch, error) {
+	var batch model.RedeemCodeBatch
+	ret := dao.db.WithContext(ctx).Where("id = ?", batchId).First(&batch)
+	if ret.Error != nil {
+		if errors.Is(ret.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		log.Logger.Errorf("Failed to get redeem code batch %d: %v", batchId, ret.Error)
+		return nil, ret.Error
+	}
+	return &batch, nil
+}
+
+// InsertBatchChunk inserts the codes into the generating batch, skipping codes that already exist, and adds
+// the number inserted to the generated count of the batch in the same transaction. The batch completes once
+// its count reaches its quantity. It returns gorm.ErrCheckConstraintViolated and inserts nothing when the
+// generated count moved on since batch was read, so a chunk is never counted twice.
+func (dao *RedeemCodeDaoImpl) InsertBatchChunk(ctx context.Context, batch *model.RedeemCodeBatch, redeemCodes []*model.RedeemCode) (int, error) {
+	var inserted int
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, redeemCode := range redeemCodes {
+			redeemCode.BatchId = batch.ID
+		}
+		ret := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&redeemCodes)
+		if ret.Error != nil {
+			return ret.Error
+		}
+		inserted = int(ret.RowsAffected)
+		status := model.RedeemCodeBatchStatusGenerating
+		if batch.GeneratedCount+inserted >= batch.Quantity {
+			status = model.RedeemCodeBatchStatusCompleted
+		}
+		ret = tx.Model(&model.RedeemCodeBatch{}).
+			Where("id = ? and status = ? and generated_count = ?", batch.ID, model.RedeemCodeBatchStatusGenerating, batch.GeneratedCount).
+			Updates(map[string]interface{}{"generated_count": batch.GeneratedCount + inserted, "status": status, "last_error": ""})
+		if ret.Error != nil {
+			return ret.Error
+		}
+		if ret.RowsAffected == 0 {
+			return gorm.ErrCheckConstraintViolated
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrCheckConstraintViolated) {
+			log.Logger.Errorf("Failed to insert %d redeem codes into batch %d: %v", len(redeemCodes), batch.ID, err)
+		}
+		return 0, err
+	}
+	return inserted, nil
+}
+
+func (dao *RedeemCodeDaoImpl) UpdateBatchStatus(ctx context.Context, batchId uint, status int, lastError string) error {
+	ret := dao.db.WithContext(ctx).Model(&model.RedeemCodeBatch{}).Where("id = ?", batchId).
+		Updates(map[string]interface{}{"status": status, "last_error": lastError})
 	if ret.Error != nil {
-		log.Logger.Errorf("Failed to batch insert redeem codes: %v", ret.Error)
+		log.Logger.Errorf("Failed to update status of redeem code batch %d: %v", batchId, ret.Error)
 		return ret.Error
 	}
-	log.Logger.Infof("Successfully batch inserted %d redeem codes", ret.RowsAffected)
 	return nil
 }
 
-func (dao *RedeemCodeDaoImpl) GetByCode(ctx context.Context, code string) (*model.RedeemCode, error) {
+// QueryBatchesByStatus returns up to limit batches in the status, oldest first.
+func (dao *RedeemCodeDaoImpl) QueryBatchesByStatus(ctx context.Context, status int, limit int) ([]*model.RedeemCodeBatch, error) {
+	var batches []*model.RedeemCodeBatch
+	ret := dao.db.WithContext(ctx).Where("status = ?", status).Order("id asc").Limit(limit).Find(&batches)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to query redeem code batches in status %d: %v", status, ret.Error)
+		return nil, ret.Error
+	}
+	return batches, nil
+}
+
+// QueryBatches returns the batches matching the query, newest first.
+func (dao *RedeemCodeDaoImpl) QueryBatches(ctx context.Context, query *model.RedeemCodeBatchQuery) ([]*model.RedeemCodeBatch, error) {
+	var batches []*model.RedeemCodeBatch
+	dbQuery := dao.db.WithContext(ctx).Model(&model.RedeemCodeBatch{})
+	if query.Campaign != "" {
+		dbQuery = dbQuery.Where("campaign = ?", query.Campaign)
+	}
+	if query.CreatorId > 0 {
+		dbQuery = dbQuery.Where("creator_id = ?", query.CreatorId)
+	}
+	if query.BeforeId > 0 {
+		dbQuery = dbQuery.Where("id < ?", query.BeforeId)
+	}
+	ret := dbQuery.Order("id desc").Limit(query.Limit).Find(&batches)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to query redeem code batches: %v", ret.Error)
+		return nil, ret.Error
+	}
+	return batches, nil
+}
+
+// QueryBatchProgress counts the used and revoked codes of each batch, batches without such codes are left out.
+func (dao *RedeemCodeDaoImpl) QueryBatchProgress(ctx context.Context, batchIds []uint) ([]*model.RedeemCodeBatchProgress, error) {
+	var progress []*model.RedeemCodeBatchProgress
+	ret := dao.db.WithContext(ctx).Model(&model.RedeemCode{}).
+		Select("batch_id, count(case when used_user_id != 0 then 1 end) as used, count(case when revoked_at is not null then 1 end) as revoked, "+
+			"coalesce(sum(redeemed_count), 0) as redemptions").
+		Where("batch_id in ? and (used_user_id != 0 or revoked_at is not null or redeemed_count > 0)", batchIds).
+		Group("batch_id").
+		Scan(&progress)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to query progress of %d redeem code batches: %v", len(batchIds), ret.Error)
+		return nil, ret.Error
+	}
+	return progress, nil
+}
+
+// RevokeBatch revokes every unused code of the batch and returns how many were revoked.
+// Codes already used or revoked are left untouched.
+func (dao *RedeemCodeDaoImpl) RevokeBatch(ctx context.Context, batchId uint, revokedAt time.Time) (int, error) {
+	var revoked int64
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ret := tx.Model(&model.RedeemCode{}).
+			Where("batch_id = ? and used_user_id = 0 and revoked_at is null", batchId).
+			Update("revoked_at", revokedAt)
+		if ret.Error != nil {
+			return ret.Error
+		}
+		revoked = ret.RowsAffected
+		return tx.Model(&model.RedeemCodeBatch{}).Where("id = ?", batchId).Update("revoked_at", revokedAt).Error
+	})
+	if err != nil {
+		log.Logger.Errorf("Failed to revoke redeem code batch %d: %v", batchId, err)
+		return 0, err
+	}
+	log.Logger.Infof("Revoked %d unused redeem codes of batch %d", revoked, batchId)
+	return int(revoked), nil
+}
+
+// GetByCode looks the code up by its keyed hash, codes are never looked up by plaintext.
+func (dao *RedeemCodeDaoImpl) GetByCode(ctx context.Context, codeHash string) (*model.RedeemCode, error) {
 	var redeemCode model.RedeemCode
-	ret := dao.db.WithContext(ctx).Where("code = ?", code).First(&redeemCode)
+	ret := dao.db.WithContext(ctx).Where("code_hash = ?", codeHash).First(&redeemCode)
 	if ret.Error != nil {
-		log.Logger.Errorf("Failed to get redeem code by code %s: %v", code, ret.Error)
+		log.Logger.Errorf("Failed to get redeem code by hash %s: %v", codeHash, ret.Error)
 		return nil, ret.Error
 	}
 	return &redeemCode, nil
@@ -57,15 +225,7 @@ func (dao *RedeemCodeDaoImpl) GetByCode(ctx context.Context, code string) (*mode
 
 func (dao *RedeemCodeDaoImpl) QueryRedeemCodes(ctx context.Context, query *model.RedeemCodeQuery) ([]*model.RedeemCode, error) {
 	var redeemCodes []*model.RedeemCode
-	dbQquery := dao.db.WithContext(ctx).Model(&model.RedeemCode{})
-	if query.Code != nil {
-		dbQquery = dbQquery.Where("code = ?", *query.Code)
-	}
-	if query.Used != nil && *query.Used {
-		dbQquery = dbQquery.Where("used_user_id != 0")
-	} else if query.Used != nil && !*query.Used {
-		dbQquery = dbQquery.Where("used_user_id = 0")
-	}
+	dbQquery := filterRedeemCodes(dao.db.WithContext(ctx).Model(&model.RedeemCode{}), query)
 	if query.Limit > 0 && query.Limit < repository.DefaultQueryLimit {
 		dbQquery = dbQquery.Limit(query.Limit)
 	} else {
@@ -79,12 +239,203 @@ func (dao *RedeemCodeDaoImpl) QueryRedeemCodes(ctx context.Context, query *model
 	return redeemCodes, nil
 }
 
+// FindRedeemCodesInBatches hands every code matching the query to fn, batchSize codes at a time in id order,
+// so that exports never hold more than one batch in memory. query.Limit is ignored. It stops at the first
+// error returned by fn.
+func (dao *RedeemCodeDaoImpl) FindRedeemCodesInBatches(ctx context.Context, query *model.RedeemCodeQuery, batchSize int, fn func(redeemCodes []*model.RedeemCode) error) error {
+	var redeemCodes []*model.RedeemCode
+	ret := filterRedeemCodes(dao.db.WithContext(ctx).Model(&model.RedeemCode{}), query).
+		FindInBatches(&redeemCodes, batchSize, func(tx *gorm.DB, batch int) error {
+			return fn(redeemCodes)
+		})
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to find redeem codes in batches: %v", ret.Error)
+		return ret.Error
+	}
+	return nil
+}
+
+func filterRedeemCodes(dbQquery *gorm.DB, query *model.RedeemCodeQuery) *gorm.DB {
+	if query.CodeHash != nil {
+		dbQquery = dbQquery.Where("code_hash = ?", *query.CodeHash)
+	}
+	if query.BatchId != nil {
+		dbQquery = dbQquery.Where("batch_id = ?", *query.BatchId)
+	}
+	if query.Used != nil && *query.Used {
+		dbQquery = dbQquery.Where("used_user_id != 0")
+	} else if query.Used != nil && !*query.Used {
+		dbQquery = dbQquery.Where("used_user_id = 0")
+	}
+	now := time.Now()
+	switch query.Status {
+	case model.RedeemCodeStatusActive:
+		dbQquery = dbQquery.Where("(valid_from is null or valid_from <= ?) and (valid_until is null or valid_until > ?)", now, now)
+	case model.RedeemCodeStatusExpired:
+		dbQquery = dbQquery.Where("valid_until <= ?", now)
+	}
+	return dbQquery
+}
+
+// ClaimSealedCodes drops the encrypted plaintext of those of the codes that still hold it and returns their ids.
+// Only the claimed codes may be revealed, so that concurrent exports never reveal the same code twice.
+func (dao *RedeemCodeDaoImpl) ClaimSealedCodes(ctx context.Context, ids []uint) ([]uint, error) {
+	var claimed []uint
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ret := tx.Model(&model.RedeemCode{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id in ? and sealed_code is not null", ids).Order("id asc").Pluck("id", &claimed)
+		if ret.Error != nil || len(claimed) == 0 {
+			return ret.Error
+		}
+		return tx.Model(&model.RedeemCode{}).Where("id in ?", claimed).Update("sealed_code", nil).Error
+	})
+	if err != nil {
+		log.Logger.Errorf("Failed to claim the plaintext of %d redeem codes: %v", len(ids), err)
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// QueryUsedRedeemCodes returns up to limit used redeem codes with an id greater than afterID, in id order.
+func (dao *RedeemCodeDaoImpl) QueryUsedRedeemCodes(ctx context.Context, afterID uint, limit int) ([]*model.RedeemCode, error) {
+	var redeemCodes []*model.RedeemCode
+	ret := dao.db.WithContext(ctx).Where("id > ? and used_user_id != 0", afterID).Order("id asc").Limit(limit).Find(&redeemCodes)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to query used redeem codes after ID %d: %v", afterID, ret.Error)
+		return nil, ret.Error
+	}
+	return redeemCodes, nil
+}
+
+// QueryPlaintextRedeemCodes returns up to limit codes with an id greater than afterID that are not hashed yet or
+// still hold their plaintext, in id order.
+func (dao *RedeemCodeDaoImpl) QueryPlaintextRedeemCodes(ctx context.Context, afterID uint, limit int) ([]*model.RedeemCode, error) {
+	var redeemCodes []*model.RedeemCode
+	ret := dao.db.WithContext(ctx).Where("id > ? and (code is not null or code_hash is null or code_hash = '')", afterID).Order("id asc").Limit(limit).Find(&redeemCodes)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to query plaintext redeem codes after ID %d: %v", afterID, ret.Error)
+		return nil, ret.Error
+	}
+	return redeemCodes, nil
+}
+
+// HashRedeemCode stores redeemCode.CodeHash and redeemCode.CodeSuffix in place of the plaintext redeemCode.Code,
+// and rewrites the idempotent keys of the top-ups of the code that still hold the plaintext, in one transaction.
+// It returns false when the code was hashed in the meantime.
+func (dao *RedeemCodeDaoImpl) HashRedeemCode(ctx context.Context, redeemCode *model.RedeemCode) (bool, error) {
+	plaintext := *redeemCode.Code
+	hashed := false
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ret := tx.Model(&model.RedeemCode{}).
+			Where("id = ? and (code_hash is null or code_hash = '')", redeemCode.ID).
+			Updates(map[string]interface{}{"code": nil, "code_hash": redeemCode.CodeHash, "code_suffix": redeemCode.CodeSuffix})
+		if ret.Error != nil {
+			return ret.Error
+		}
+		if ret.RowsAffected == 0 {
+			return nil
+		}
+		hashed = true
+		if !redeemCode.IsPromo() {
+			return tx.Model(&model.UserAccountChangeLog{}).
+				Where("idempotent_key = ? and op_type = ?", plaintext, model.OpTypeTopUp).
+				Update("idempotent_key", redeemCode.TopUpIdempotentKey(0, 0)).Error
+		}
+		var redemptions []*model.RedeemCodeRedemption
+		if err := tx.Where("redeem_code_id = ?", redeemCode.ID).Find(&redemptions).Error; err != nil {
+			return err
+		}
+		for _, redemption := range redemptions {
+			err := tx.Model(&model.UserAccountChangeLog{}).
+				Where("id = ? and idempotent_key = ?", redemption.ChangeLogId, plaintext).
+				Update("idempotent_key", redeemCode.TopUpIdempotentKey(redemption.UserId, redemption.Seq)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Logger.Errorf("Failed to hash redeem code %d: %v", redeemCode.ID, err)
+		return false, err
+	}
+	return hashed, nil
+}
+
+// SealPlaintextCode stores sealedCode in place of the plaintext of a hashed code that was not exported yet.
+// It returns false when the plaintext was dropped in the meantime.
+func (dao *RedeemCodeDaoImpl) SealPlaintextCode(ctx context.Context, redeemCodeId uint, sealedCode []byte) (bool, error) {
+	ret := dao.db.WithContext(ctx).Model(&model.RedeemCode{}).
+		Where("id = ? and code is not null and code_hash != ''", redeemCodeId).
+		Updates(map[string]interface{}{"code": nil, "sealed_code": sealedCode})
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to seal the plaintext of redeem code %d: %v", redeemCodeId, ret.Error)
+		return false, ret.Error
+	}
+	return ret.RowsAffected > 0, nil
+}
+
+// UseRedeemCodeInTransaction marks the code used by redeemCode.UsedUserId. It returns 0 rows when the code
+// was used or revoked in the meantime.
 func (dao *RedeemCodeDaoImpl) UseRedeemCodeInTransaction(ctx context.Context, redeemCode *model.RedeemCode, tx *gorm.DB) (int, error) {
-	ret := tx.WithContext(ctx).Model(redeemCode).Where("used_user_id=0").Save(redeemCode)
+	ret := tx.WithContext(ctx).Model(redeemCode).Where("used_user_id = 0 and revoked_at is null").Update("used_user_id", redeemCode.UsedUserId)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to update redeem code %d: %v", redeemCode.ID, ret.Error)
+		return 0, ret.Error
+	}
+	log.Logger.Infof("Successfully updated redeem code %d", redeemCode.ID)
+	return int(ret.RowsAffected), nil
+}
+
+// RedeemPromoCodeInTransaction counts one more redemption of the promo code. It returns 0 rows when the code
+// reached its global cap or was revoked in the meantime, the row stays locked until tx ends.
+func (dao *RedeemCodeDaoImpl) RedeemPromoCodeInTransaction(ctx context.Context, redeemCode *model.RedeemCode, tx *gorm.DB) (int, error) {
+	ret := tx.WithContext(ctx).Model(redeemCode).
+		Where("revoked_at is null and (max_redemptions = 0 or redeemed_count < max_redemptions)").
+		Update("redeemed_count", gorm.Expr("redeemed_count + 1"))
 	if ret.Error != nil {
-		log.Logger.Errorf("Failed to update redeem code %s: %v", redeemCode.Code, ret.Error)
+		log.Logger.Errorf("Failed to redeem promo code %d: %v", redeemCode.ID, ret.Error)
 		return 0, ret.Error
 	}
-	log.Logger.Infof("Successfully updated redeem code %s", redeemCode.Code)
 	return int(ret.RowsAffected), nil
 }
+
+// CountRedemptions counts the redemptions of the promo code by the user.
+func (dao *RedeemCodeDaoImpl) CountRedemptions(ctx context.Context, redeemCodeId uint, userId int) (int, error) {
+	return dao.CountRedemptionsInTransaction(ctx, redeemCodeId, userId, dao.db)
+}
+
+// CountRedemptionsInTransaction counts the redemptions of the promo code by the user.
+func (dao *RedeemCodeDaoImpl) CountRedemptionsInTransaction(ctx context.Context, redeemCodeId uint, userId int, tx *gorm.DB) (int, error) {
+	var count int64
+	ret := tx.WithContext(ctx).Model(&model.RedeemCodeRedemption{}).Where("redeem_code_id = ? and user_id = ?", redeemCodeId, userId).Count(&count)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to count redemptions of redeem code %d by user %d: %v", redeemCodeId, userId, ret.Error)
+		return 0, ret.Error
+	}
+	return int(count), nil
+}
+
+// QueryRedemptions returns the latest limit redemptions of the promo code, newest first.
+func (dao *RedeemCodeDaoImpl) QueryRedemptions(ctx context.Context, redeemCodeId uint, limit int) ([]*model.RedeemCodeRedemption, error) {
+	var redemptions []*model.RedeemCodeRedemption
+	ret := dao.db.WithContext(ctx).Where("redeem_code_id = ?", redeemCodeId).Order("id desc").Limit(limit).Find(&redemptions)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to query redemptions of redeem code %d: %v", redeemCodeId, ret.Error)
+		return nil, ret.Error
+	}
+	return redemptions, nil
+}
+
+func (dao *RedeemCodeDaoImpl) CreateRedemptionInTransaction(ctx context.Context, redemption *model.RedeemCodeRedemption, tx *gorm.DB) error {
+	ret := tx.WithContext(ctx).Create(redemption)
+	if ret.Error != nil {
+		if errors.Is(ret.Error, gorm.ErrDuplicatedKey) {
+			log.Logger.Warnf("Redemption %d of redeem code %d by user %d already exists", redemption.Seq, redemption.RedeemCodeId, redemption.UserId)
+		} else {
+			log.Logger.Errorf("Failed to create redemption of redeem code %d by user %d: %v", redemption.RedeemCodeId, redemption.UserId, ret.Error)
+		}
+		return ret.Error
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package dao
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"gorm.io/gorm"
+)
+
+type RedeemCodeLockoutDao interface {
+	CreateAudit(ctx context.Context, audit *model.RedeemCodeLockoutAudit) error
+}
+
+var (
+	redeemCodeLockoutDaoImpl     RedeemCodeLockoutDao
+	redeemCodeLockoutDaoSyncOnce sync.Once
+)
+
+func GetRedeemCodeLockoutDao() RedeemCodeLockoutDao {
+	redeemCodeLockoutDaoSyncOnce.Do(func() {
+		redeemCodeLockoutDaoImpl = &RedeemCodeLockoutDaoImpl{
+			db: repository.DB,
+		}
+	})
+	return redeemCodeLockoutDaoImpl
+}
+
+type RedeemCodeLockoutDaoImpl struct {
+	db *gorm.DB
+}
+
+// CreateAudit implements RedeemCodeLockoutDao.
+func (r *RedeemCodeLockoutDaoImpl) CreateAudit(ctx context.Context, audit *model.RedeemCodeLockoutAudit) error {
+	ret := r.db.WithContext(ctx).Create(audit)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to create redeem code lockout audit for %s %s: %v", audit.Subject, audit.SubjectKey, ret.Error)
+		return ret.Error
+	}
+	return nil
+}
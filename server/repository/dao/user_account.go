@@ -4,20 +4,34 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserAccountDao interface {
 	CreateUserAccount(ctx context.Context, userAccount *model.UserAccount) error
 	GetUserAccountByUserID(ctx context.Context, userID int) (*model.UserAccount, error)
+	GetUserAccountByID(ctx context.Context, id int) (*model.UserAccount, error)
+	GetUserAccountByAccountNo(ctx context.Context, accountNo string) (*model.UserAccount, error)
+	LockUserAccountInTransaction(ctx context.Context, userID int, tx *gorm.DB) (*model.UserAccount, error)
+	QueryUserAccounts(ctx context.Context, afterID int, limit int) ([]*model.UserAccount, error)
+	QueryUserAccountsByUserIDs(ctx context.Context, userIDs []int) ([]*model.UserAccount, error)
 	AddBalanceInTransaction(ctx context.Context, userID int, amount int, oldAmount int, tx *gorm.DB) error
+	RefundBalanceInTransaction(ctx context.Context, userID int, amount int, oldAmount int, tx *gorm.DB) error
 	SubtractBalanceInTransaction(ctx context.Context, userID int, amount int, oldAmount int, tx *gorm.DB) (int, error)
+	FreezeBalanceInTransaction(ctx context.Context, userID int, amount int, oldAmount int, tx *gorm.DB) (int, error)
+	SettleFrozenBalanceInTransaction(ctx context.Context, userID int, captureAmount int, releaseAmount int, tx *gorm.DB) (int, error)
+	UpdateStatus(ctx context.Context, userID int, status int, changedAt time.Time) (int, error)
 }
 
+// ErrAccountNotActive is returned by balance updates that require an active account when the account is not.
+var ErrAccountNotActive = errors.New("user account is not active")
+
 var (
 	userAccountDaoImpl     UserAccountDao
 	userAccountDaoSyncOnce sync.Once
@@ -65,36 +79,195 @@ func (u *UserAccountDaoImpl) GetUserAccountByUserID(ctx context.Context, userID
 	return &userAccount, nil
 }
 
-// AddBalance implements UserAccountDao.
+// GetUserAccountByID implements UserAccountDao.
+func (u *UserAccountDaoImpl) GetUserAccountByID(ctx context.Context, id int) (*model.UserAccount, error) {
+	var userAccount model.UserAccount
+	ret := u.db.WithContext(ctx).Where("id = ?", id).First(&userAccount)
+	if ret.Error != nil {
+		if errors.Is(ret.Error, gorm.ErrRecordNotFound) {
+			log.Logger.Warnf("User account not found for account ID %d", id)
+			return nil, nil
+		}
+		log.Logger.Errorf("Failed to get user account by account ID %d: %v", id, ret.Error)
+		return nil, ret.Error
+	}
+	return &userAccount, nil
+}
+
+// GetUserAccountByAccountNo implements UserAccountDao.
+func (u *UserAccountDaoImpl) GetUserAccountByAccountNo(ctx context.Context, accountNo string) (*model.UserAccount, error) {
+	var userAccount model.UserAccount
+	ret := u.db.WithContext(ctx).Where("account_no = ?", accountNo).First(&userAccount)
+	if ret.Error != nil {
+		if errors.Is(ret.Error, gorm.ErrRecordNotFound) {
+			log.Logger.Warnf("User account not found for account no %s", accountNo)
+			return nil, nil
+		}
+		log.Logger.Errorf("Failed to get user account by account no %s: %v", accountNo, ret.Error)
+		return nil, ret.Error
+	}
+	return &userAccount, nil
+}
+
+// QueryUserAccounts implements UserAccountDao.
+// It returns up to limit accounts with an id greater than afterID, in id order.
+func (u *UserAccountDaoImpl) QueryUserAccounts(ctx context.Context, afterID int, limit int) ([]*model.UserAccount, error) {
+	var userAccounts []*model.UserAccount
+	ret := u.db.WithContext(ctx).Where("id > ?", afterID).Order("id asc").Limit(limit).Find(&userAccounts)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to query user accounts after ID %d: %v", afterID, ret.Error)
+		return nil, ret.Error
+	}
+	return userAccounts, nil
+}
+
+// QueryUserAccountsByUserIDs implements UserAccountDao.
+func (u *UserAccountDaoImpl) QueryUserAccountsByUserIDs(ctx context.Context, userIDs []int) ([]*model.UserAccount, error) {
+	var userAccounts []*model.UserAccount
+	ret := u.db.WithContext(ctx).Where("user_id in ?", userIDs).Find(&userAccounts)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to query user accounts of %d users: %v", len(userIDs), ret.Error)
+		return nil, ret.Error
+	}
+	return userAccounts, nil
+}
+
+// LockUserAccountInTransaction implements UserAccountDao.
+// It reads the account with SELECT ... FOR UPDATE so the row stays locked until the transaction ends.
+func (u *UserAccountDaoImpl) LockUserAccountInTransaction(ctx context.Context, userID int, tx *gorm.DB) (*model.UserAccount, error) {
+	var userAccount model.UserAccount
+	ret := tx.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&userAccount)
+	if ret.Error != nil {
+		if errors.Is(ret.Error, gorm.ErrRecordNotFound) {
+			log.Logger.Warnf("User account not found for user ID %d", userID)
+			return nil, nil
+		}
+		log.Logger.Errorf("Failed to lock user account for user ID %d: %v", userID, ret.Error)
+		return nil, ret.Error
+	}
+	return &userAccount, nil
+}
+
+// AddBalance implements UserAccountDao. It only credits an active account.
 func (u *UserAccountDaoImpl) AddBalanceInTransaction(ctx context.Context, userID int, amount int, oldAmount int, tx *gorm.DB) error {
 	ret := tx.WithContext(ctx).Model(&model.UserAccount{}).
-		Where("user_id = ? and balance=?", userID, oldAmount).
+		Where("user_id = ? and balance=? and status = ?", userID, oldAmount, model.UserAccountStatusActive).
 		Update("balance", gorm.Expr("balance + ?", amount))
 	if ret.Error != nil {
 		log.Logger.Errorf("Failed to add balance for user ID %d: %v", userID, ret.Error)
 		return ret.Error
 	}
 	if ret.RowsAffected == 0 {
-		log.Logger.Warnf("No user account found to add balance for user ID %d", userID)
-		return gorm.ErrCheckConstraintViolated
+		log.Logger.Warnf("No active user account found to add balance for user ID %d", userID)
+		return u.balanceUpdateMissed(ctx, userID, tx)
 	}
 	log.Logger.Infof("Successfully added %d to user ID %d", amount, userID)
 	return nil
 }
 
-// SubtractBalance implements UserAccountDao.
-func (u *UserAccountDaoImpl) SubtractBalanceInTransaction(ctx context.Context, userID int, amount int, oldAmount int, tx *gorm.DB) (int, error) {
+// RefundBalanceInTransaction implements UserAccountDao. Unlike AddBalanceInTransaction it credits accounts that
+// are frozen or closed too, a refund of an earlier payment always goes through.
+func (u *UserAccountDaoImpl) RefundBalanceInTransaction(ctx context.Context, userID int, amount int, oldAmount int, tx *gorm.DB) error {
 	ret := tx.WithContext(ctx).Model(&model.UserAccount{}).
 		Where("user_id = ? and balance=?", userID, oldAmount).
+		Update("balance", gorm.Expr("balance + ?", amount))
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to refund balance for user ID %d: %v", userID, ret.Error)
+		return ret.Error
+	}
+	if ret.RowsAffected == 0 {
+		log.Logger.Warnf("No user account found to refund balance for user ID %d", userID)
+		return gorm.ErrCheckConstraintViolated
+	}
+	log.Logger.Infof("Successfully refunded %d to user ID %d", amount, userID)
+	return nil
+}
+
+// SubtractBalance implements UserAccountDao. It only debits an active account.
+func (u *UserAccountDaoImpl) SubtractBalanceInTransaction(ctx context.Context, userID int, amount int, oldAmount int, tx *gorm.DB) (int, error) {
+	ret := tx.WithContext(ctx).Model(&model.UserAccount{}).
+		Where("user_id = ? and balance=? and status = ?", userID, oldAmount, model.UserAccountStatusActive).
 		Update("balance", gorm.Expr("balance - ?", amount))
 	if ret.Error != nil {
 		log.Logger.Errorf("Failed to subtract balance for user ID %d: %v", userID, ret.Error)
 		return 0, ret.Error
 	}
 	if ret.RowsAffected == 0 {
-		log.Logger.Warnf("No user account found to subtract balance for user ID %d", userID)
-		return 0, gorm.ErrCheckConstraintViolated
+		log.Logger.Warnf("No active user account found to subtract balance for user ID %d", userID)
+		return 0, u.balanceUpdateMissed(ctx, userID, tx)
 	}
 	log.Logger.Infof("Successfully subtracted %d from user ID %d", amount, userID)
 	return int(ret.RowsAffected), nil
 }
+
+// balanceUpdateMissed tells why a compare-and-swap of an active account updated no row: ErrAccountNotActive if the
+// account is no longer active, which retrying cannot fix, otherwise gorm.ErrCheckConstraintViolated.
+// The status is read with a locking read, which sees the latest committed status rather than the snapshot of tx.
+func (u *UserAccountDaoImpl) balanceUpdateMissed(ctx context.Context, userID int, tx *gorm.DB) error {
+	var statuses []int
+	ret := tx.WithContext(ctx).Model(&model.UserAccount{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userID).Pluck("status", &statuses)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to read the status of user ID %d: %v", userID, ret.Error)
+		return ret.Error
+	}
+	if len(statuses) > 0 && statuses[0] != model.UserAccountStatusActive {
+		return ErrAccountNotActive
+	}
+	return gorm.ErrCheckConstraintViolated
+}
+
+// FreezeBalanceInTransaction implements UserAccountDao. It only freezes the balance of an active account.
+func (u *UserAccountDaoImpl) FreezeBalanceInTransaction(ctx context.Context, userID int, amount int, oldAmount int, tx *gorm.DB) (int, error) {
+	ret := tx.WithContext(ctx).Model(&model.UserAccount{}).
+		Where("user_id = ? and balance=? and status = ?", userID, oldAmount, model.UserAccountStatusActive).
+		Updates(map[string]interface{}{
+			"balance":        gorm.Expr("balance - ?", amount),
+			"frozen_balance": gorm.Expr("frozen_balance + ?", amount),
+		})
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to freeze balance for user ID %d: %v", userID, ret.Error)
+		return 0, ret.Error
+	}
+	if ret.RowsAffected == 0 {
+		log.Logger.Warnf("No active user account found to freeze balance for user ID %d", userID)
+		return 0, u.balanceUpdateMissed(ctx, userID, tx)
+	}
+	log.Logger.Infof("Successfully froze %d for user ID %d", amount, userID)
+	return int(ret.RowsAffected), nil
+}
+
+// SettleFrozenBalanceInTransaction implements UserAccountDao.
+// The captured part leaves the frozen balance for good, the released part goes back to the balance.
+func (u *UserAccountDaoImpl) SettleFrozenBalanceInTransaction(ctx context.Context, userID int, captureAmount int, releaseAmount int, tx *gorm.DB) (int, error) {
+	ret := tx.WithContext(ctx).Model(&model.UserAccount{}).
+		Where("user_id = ? and frozen_balance >= ?", userID, captureAmount+releaseAmount).
+		Updates(map[string]interface{}{
+			"balance":        gorm.Expr("balance + ?", releaseAmount),
+			"frozen_balance": gorm.Expr("frozen_balance - ?", captureAmount+releaseAmount),
+		})
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to settle frozen balance for user ID %d: %v", userID, ret.Error)
+		return 0, ret.Error
+	}
+	if ret.RowsAffected == 0 {
+		log.Logger.Warnf("No user account found to settle frozen balance for user ID %d", userID)
+		return 0, gorm.ErrCheckConstraintViolated
+	}
+	log.Logger.Infof("Successfully settled frozen balance for user ID %d, captured %d, released %d", userID, captureAmount, releaseAmount)
+	return int(ret.RowsAffected), nil
+}
+
+// UpdateStatus implements UserAccountDao.
+// It moves the status as of changedAt, so only when the status was last changed before. A closed account keeps its
+// status, it returns 0 for it as for a status changed at or after changedAt.
+func (u *UserAccountDaoImpl) UpdateStatus(ctx context.Context, userID int, status int, changedAt time.Time) (int, error) {
+	ret := u.db.WithContext(ctx).Model(&model.UserAccount{}).
+		Where("user_id = ? AND status <> ? AND (status_changed_at IS NULL OR status_changed_at < ?)", userID, model.UserAccountStatusClosed, changedAt).
+		Updates(map[string]interface{}{"status": status, "status_changed_at": changedAt})
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to update status of user account for user ID %d: %v", userID, ret.Error)
+		return 0, ret.Error
+	}
+	return int(ret.RowsAffected), nil
+}
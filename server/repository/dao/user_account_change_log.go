@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
@@ -14,6 +15,14 @@ import (
 type UserAccountChangeLogDAO interface {
 	CreateChangeLogInTransaction(ctx context.Context, changeLog *model.UserAccountChangeLog, tx *gorm.DB) error
 	QueryChangeLogs(ctx context.Context, query *model.UserAccountChangeLogQuery) ([]*model.UserAccountChangeLog, error)
+	GetChangeLogByID(ctx context.Context, id int) (*model.UserAccountChangeLog, error)
+	GetChangeLogByIdempotentKey(ctx context.Context, idempotentKey string, opType int) (*model.UserAccountChangeLog, error)
+	AddRefundedAmountInTransaction(ctx context.Context, id int, amount int, tx *gorm.DB) (int, error)
+	QueryChangeLogsByAccount(ctx context.Context, accountId int, afterID int, limit int) ([]*model.UserAccountChangeLog, error)
+	UpdateBalanceSnapshot(ctx context.Context, changeLog *model.UserAccountChangeLog) error
+	SumChangeLogsInTransaction(ctx context.Context, accountId int, opType int, since time.Time, tx *gorm.DB) (int, int, error)
+	SumAmountsByAccountsInTransaction(ctx context.Context, accountIds []int, tx *gorm.DB) ([]*model.ChangeLogAmountSum, error)
+	QueryChangeLogsByIdempotentKeys(ctx context.Context, idempotentKeys []string, opType int) ([]*model.UserAccountChangeLog, error)
 }
 
 var (
@@ -48,6 +57,8 @@ func (u *UserAccountChangeLogDAOImpl) CreateChangeLogInTransaction(ctx context.C
 	return nil
 }
 
+// QueryChangeLogs implements UserAccountChangeLogDAO.
+// It returns the matching change logs newest first.
 func (u *UserAccountChangeLogDAOImpl) QueryChangeLogs(ctx context.Context, query *model.UserAccountChangeLogQuery) ([]*model.UserAccountChangeLog, error) {
 	var changeLogs []*model.UserAccountChangeLog
 	dbQuery := u.db.WithContext(ctx).Model(&model.UserAccountChangeLog{})
@@ -57,10 +68,144 @@ func (u *UserAccountChangeLogDAOImpl) QueryChangeLogs(ctx context.Context, query
 	if query.IdempotentKey != nil {
 		dbQuery = dbQuery.Where("idempotent_key = ?", *query.IdempotentKey)
 	}
-	ret := dbQuery.Order("id desc").Limit(repository.DefaultQueryLimit).Find(&changeLogs)
+	if query.OpType != 0 {
+		dbQuery = dbQuery.Where("op_type = ?", query.OpType)
+	}
+	if query.StartTime != nil {
+		dbQuery = dbQuery.Where("created_at >= ?", *query.StartTime)
+	}
+	if query.EndTime != nil {
+		dbQuery = dbQuery.Where("created_at < ?", *query.EndTime)
+	}
+	if query.BeforeId > 0 {
+		dbQuery = dbQuery.Where("id < ?", query.BeforeId)
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = repository.DefaultQueryLimit
+	}
+	ret := dbQuery.Order("id desc").Limit(limit).Find(&changeLogs)
 	if ret.Error != nil {
 		log.Logger.Errorf("Failed to query user account change logs: %v", ret.Error)
 		return nil, ret.Error
 	}
 	return changeLogs, nil
 }
+
+// GetChangeLogByID implements UserAccountChangeLogDAO.
+func (u *UserAccountChangeLogDAOImpl) GetChangeLogByID(ctx context.Context, id int) (*model.UserAccountChangeLog, error) {
+	var changeLog model.UserAccountChangeLog
+	ret := u.db.WithContext(ctx).Where("id = ?", id).First(&changeLog)
+	if ret.Error != nil {
+		if errors.Is(ret.Error, gorm.ErrRecordNotFound) {
+			log.Logger.Warnf("User account change log not found for ID %d", id)
+			return nil, nil
+		}
+		log.Logger.Errorf("Failed to get user account change log by ID %d: %v", id, ret.Error)
+		return nil, ret.Error
+	}
+	return &changeLog, nil
+}
+
+// GetChangeLogByIdempotentKey implements UserAccountChangeLogDAO.
+func (u *UserAccountChangeLogDAOImpl) GetChangeLogByIdempotentKey(ctx context.Context, idempotentKey string, opType int) (*model.UserAccountChangeLog, error) {
+	var changeLog model.UserAccountChangeLog
+	ret := u.db.WithContext(ctx).Where("idempotent_key = ? and op_type = ?", idempotentKey, opType).First(&changeLog)
+	if ret.Error != nil {
+		if errors.Is(ret.Error, gorm.ErrRecordNotFound) {
+			log.Logger.Warnf("User account change log not found for biz ID %s, op type %d", idempotentKey, opType)
+			return nil, nil
+		}
+		log.Logger.Errorf("Failed to get user account change log by biz ID %s, op type %d: %v", idempotentKey, opType, ret.Error)
+		return nil, ret.Error
+	}
+	return &changeLog, nil
+}
+
+// AddRefundedAmountInTransaction implements UserAccountChangeLogDAO.
+// The update only applies while the total refunded amount stays within the paid amount.
+func (u *UserAccountChangeLogDAOImpl) AddRefundedAmountInTransaction(ctx context.Context, id int, amount int, tx *gorm.DB) (int, error) {
+	ret := tx.WithContext(ctx).Model(&model.UserAccountChangeLog{}).
+		Where("id = ? and refunded_amount + ? <= amount", id, amount).
+		Update("refunded_amount", gorm.Expr("refunded_amount + ?", amount))
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to add refunded amount for change log ID %d: %v", id, ret.Error)
+		return 0, ret.Error
+	}
+	if ret.RowsAffected == 0 {
+		log.Logger.Warnf("Refunded amount exceeds paid amount for change log ID %d, amount %d", id, amount)
+	}
+	return int(ret.RowsAffected), nil
+}
+
+// QueryChangeLogsByAccount implements UserAccountChangeLogDAO.
+// It returns up to limit change logs of the account with an id greater than afterID, oldest first.
+func (u *UserAccountChangeLogDAOImpl) QueryChangeLogsByAccount(ctx context.Context, accountId int, afterID int, limit int) ([]*model.UserAccountChangeLog, error) {
+	var changeLogs []*model.UserAccountChangeLog
+	ret := u.db.WithContext(ctx).Where("account_id = ? and id > ?", accountId, afterID).Order("id asc").Limit(limit).Find(&changeLogs)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to query change logs of account ID %d after ID %d: %v", accountId, afterID, ret.Error)
+		return nil, ret.Error
+	}
+	return changeLogs, nil
+}
+
+// UpdateBalanceSnapshot implements UserAccountChangeLogDAO.
+func (u *UserAccountChangeLogDAOImpl) UpdateBalanceSnapshot(ctx context.Context, changeLog *model.UserAccountChangeLog) error {
+	ret := u.db.WithContext(ctx).Model(&model.UserAccountChangeLog{}).
+		Where("id = ?", changeLog.ID).
+		Updates(map[string]interface{}{
+			"balance_before": changeLog.BalanceBefore,
+			"balance_after":  changeLog.BalanceAfter,
+		})
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to update balance snapshot of change log ID %d: %v", changeLog.ID, ret.Error)
+		return ret.Error
+	}
+	return nil
+}
+
+// SumChangeLogsInTransaction implements UserAccountChangeLogDAO.
+// It returns the count and the total amount of the account's change logs of opType created since the given time.
+func (u *UserAccountChangeLogDAOImpl) SumChangeLogsInTransaction(ctx context.Context, accountId int, opType int, since time.Time, tx *gorm.DB) (int, int, error) {
+	var result struct {
+		Count  int
+		Amount int
+	}
+	ret := tx.WithContext(ctx).Model(&model.UserAccountChangeLog{}).
+		Select("count(*) as count, coalesce(sum(amount), 0) as amount").
+		Where("account_id = ? and op_type = ? and created_at >= ?", accountId, opType, since).
+		Scan(&result)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to sum change logs of account ID %d, op type %d: %v", accountId, opType, ret.Error)
+		return 0, 0, ret.Error
+	}
+	return result.Count, result.Amount, nil
+}
+
+// SumAmountsByAccountsInTransaction implements UserAccountChangeLogDAO.
+// It returns the total amount of every op type on each of the accounts.
+func (u *UserAccountChangeLogDAOImpl) SumAmountsByAccountsInTransaction(ctx context.Context, accountIds []int, tx *gorm.DB) ([]*model.ChangeLogAmountSum, error) {
+	var sums []*model.ChangeLogAmountSum
+	ret := tx.WithContext(ctx).Model(&model.UserAccountChangeLog{}).
+		Select("account_id, op_type, sum(amount) as amount").
+		Where("account_id in ?", accountIds).
+		Group("account_id, op_type").
+		Scan(&sums)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to sum change logs of %d accounts: %v", len(accountIds), ret.Error)
+		return nil, ret.Error
+	}
+	return sums, nil
+}
+
+// QueryChangeLogsByIdempotentKeys implements UserAccountChangeLogDAO.
+func (u *UserAccountChangeLogDAOImpl) QueryChangeLogsByIdempotentKeys(ctx context.Context, idempotentKeys []string, opType int) ([]*model.UserAccountChangeLog, error) {
+	var changeLogs []*model.UserAccountChangeLog
+	ret := u.db.WithContext(ctx).Where("idempotent_key in ? and op_type = ?", idempotentKeys, opType).Find(&changeLogs)
+	if ret.Error != nil {
+		log.Logger.Errorf("Failed to query change logs of %d biz IDs, op type %d: %v", len(idempotentKeys), opType, ret.Error)
+		return nil, ret.Error
+	}
+	return changeLogs, nil
+}
@@ -37,6 +37,7 @@ func Init() {
 		&gorm.Config{
 			PrepareStmt:            true,
 			SkipDefaultTransaction: true,
+			TranslateError:         true,
 		},
 	)
 	if err != nil {
@@ -0,0 +1,62 @@
+package model
+
+import "time"
+
+// Ledger account types, asset and expense accounts grow with debits, the others with credits.
+const (
+	LedgerAccountTypeAsset     = 1
+	LedgerAccountTypeLiability = 2
+	LedgerAccountTypeEquity    = 3
+	LedgerAccountTypeRevenue   = 4
+	LedgerAccountTypeExpense   = 5
+)
+
+type LedgerAccount struct {
+	ID            int       `gorm:"primaryKey"`
+	Code          string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Type          int       `gorm:"not null"`                 // 1: asset, 2: liability, 3: equity, 4: revenue, 5: expense
+	UserAccountId int       `gorm:"index;not null;default:0"` // owner of a customer wallet, 0 for system accounts
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+}
+
+func (l *LedgerAccount) TableName() string {
+	return "ledger_accounts"
+}
+
+// DebitNormal reports whether debits increase the balance of the account.
+func (l *LedgerAccount) DebitNormal() bool {
+	return l.Type == LedgerAccountTypeAsset || l.Type == LedgerAccountTypeExpense
+}
+
+// JournalEntry groups the lines of one money movement, its lines always balance.
+type JournalEntry struct {
+	ID          int       `gorm:"primaryKey"`
+	OpType      int       `gorm:"not null"`                 // op type of the change log, 0 for an opening balance
+	ChangeLogId int       `gorm:"index;not null;default:0"` // user account change log the entry was posted for
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+func (j *JournalEntry) TableName() string {
+	return "journal_entries"
+}
+
+// JournalLine debits or credits one ledger account, exactly one of Debit and Credit is set.
+type JournalLine struct {
+	ID              int       `gorm:"primaryKey"`
+	EntryId         int       `gorm:"index;not null"`
+	LedgerAccountId int       `gorm:"index;not null"`
+	Debit           int       `gorm:"not null;default:0"`
+	Credit          int       `gorm:"not null;default:0"`
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+}
+
+func (j *JournalLine) TableName() string {
+	return "journal_lines"
+}
+
+// LedgerAccountSum totals the lines of the wallet ledger account of a user account.
+type LedgerAccountSum struct {
+	UserAccountId int
+	Debit         int
+	Credit        int
+}
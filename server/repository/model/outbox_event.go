@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+const (
+	OutboxEventStatusPending = 1
+	OutboxEventStatusSent    = 2
+)
+
+// OutboxEvent is an event written in the transaction of the change it announces and published to Kafka
+// afterwards by the outbox relay, so that an event goes out if and only if its change was committed.
+type OutboxEvent struct {
+	ID        uint       `gorm:"primaryKey"`
+	EventType string     `gorm:"type:varchar(64);not null"` // also the topic it is published to
+	EventKey  string     `gorm:"type:varchar(64);not null"` // message key, events of a key keep their order
+	Version   int        `gorm:"not null;default:1"`
+	Payload   string     `gorm:"type:text;not null"`                    // JSON data of the envelope
+	Status    int        `gorm:"index:status_idx;not null;default:1"`   // 1: pending, 2: sent
+	Attempts  int        `gorm:"not null;default:0"`                    // failed publish attempts
+	LastError string     `gorm:"type:varchar(255);not null;default:''"` // error of the latest failed attempt
+	SentAt    *time.Time // nil until sent
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+}
+
+func (o *OutboxEvent) TableName() string {
+	return "outbox_events"
+}
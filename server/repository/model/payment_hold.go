@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+const (
+	HoldStatusAuthorized = 1
+	HoldStatusCaptured   = 2
+	HoldStatusVoided     = 3
+	HoldStatusExpired    = 4
+)
+
+type PaymentHold struct {
+	ID             int       `gorm:"primaryKey"`
+	AccountId      int       `gorm:"index;not null"`
+	UserId         int       `gorm:"not null"`
+	BizId          string    `gorm:"type:varchar(32);uniqueIndex;not null"`
+	Amount         int       `gorm:"not null"`
+	CapturedAmount int       `gorm:"not null;default:0"`
+	Status         int       `gorm:"not null"` // 1: authorized, 2: captured, 3: voided, 4: expired
+	ExpiresAt      time.Time `gorm:"index;not null"`
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
+}
+
+func (p *PaymentHold) TableName() string {
+	return "payment_holds"
+}
+
+// IsActive reports whether the hold still reserves funds that can be captured or voided.
+func (p *PaymentHold) IsActive(now time.Time) bool {
+	return p.Status == HoldStatusAuthorized && now.Before(p.ExpiresAt)
+}
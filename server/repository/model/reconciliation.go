@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+const (
+	// user_accounts.balance differs from the replayed change logs
+	DiscrepancyKindChangeLogBalance = 1
+	// user_accounts.balance differs from the wallet ledger account
+	DiscrepancyKindLedgerBalance = 2
+	// a used redeem code does not have exactly one matching top-up change log
+	DiscrepancyKindRedeemCodeTopUp = 3
+)
+
+// DiscrepancyKindNames labels the discrepancy kinds in metrics and logs.
+var DiscrepancyKindNames = map[int]string{
+	DiscrepancyKindChangeLogBalance: "change_log_balance",
+	DiscrepancyKindLedgerBalance:    "ledger_balance",
+	DiscrepancyKindRedeemCodeTopUp:  "redeem_code_top_up",
+}
+
+type ReconciliationDiscrepancy struct {
+	ID           int       `gorm:"primaryKey"`
+	RunId        string    `gorm:"type:varchar(32);index;not null"`
+	Kind         int       `gorm:"not null"` // 1: change log balance, 2: ledger balance, 3: redeem code top-up
+	AccountId    int       `gorm:"index;not null;default:0"`
+	RedeemCodeId int       `gorm:"not null;default:0"`
+	Expected     int       `gorm:"not null;default:0"` // balance or amount the records add up to
+	Actual       int       `gorm:"not null;default:0"` // balance or amount found
+	Detail       string    `gorm:"type:varchar(255);not null;default:''"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+func (r *ReconciliationDiscrepancy) TableName() string {
+	return "reconciliation_discrepancies"
+}
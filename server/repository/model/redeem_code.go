@@ -1,22 +1,170 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// redeemed once, by the user recorded in UsedUserId
+	RedeemCodeTypeSingleUse = 1
+	// redeemed by many users, each redemption recorded in RedeemCodeRedemption
+	RedeemCodeTypePromo = 2
+)
 
 type RedeemCode struct {
-	ID         uint      `gorm:"primaryKey"`
-	Code       string    `gorm:"uniqueIndex;not null"`
-	Amount     int       `gorm:"not null"`
-	UsedUserId int       `gorm:"not null;default:0"`
-	CreatedAt  time.Time `gorm:"autoCreateTime"`
-	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+	ID             uint       `gorm:"primaryKey"`
+	Code           *string    `gorm:"type:varchar(32);uniqueIndex"` // plaintext of a code stored before codes were hashed, nil once hashed
+	CodeHash       string     `gorm:"type:char(64);uniqueIndex"`    // keyed hash the code is looked up by, empty until hashed
+	SealedCode     []byte     `gorm:"type:varbinary(64)"`           // encrypted plaintext of a job generated code until its first export
+	CodeSuffix     string     `gorm:"type:varchar(8);not null;default:''"`
+	Type           int        `gorm:"not null;default:1"` // 1: single-use, 2: promo
+	Amount         int        `gorm:"not null"`
+	UsedUserId     int        `gorm:"not null;default:0"`       // single-use codes only
+	MaxRedemptions int        `gorm:"not null;default:0"`       // promo codes only, global cap, 0 for no cap
+	PerUserLimit   int        `gorm:"not null;default:0"`       // promo codes only, redemptions per user, 0 for no limit
+	RedeemedCount  int        `gorm:"not null;default:0"`       // promo codes only
+	BatchId        uint       `gorm:"index;not null;default:0"` // 0 for codes generated before batches
+	RevokedAt      *time.Time // nil unless the code was revoked before being used
+	ValidFrom      *time.Time // nil means valid from creation
+	ValidUntil     *time.Time `gorm:"index"` // exclusive, nil means the code never expires
+	CreatedAt      time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime"`
 }
 
+const (
+	// within the validity window
+	RedeemCodeStatusActive = "active"
+	// past valid until
+	RedeemCodeStatusExpired = "expired"
+	// before valid from
+	RedeemCodeStatusPending = "pending"
+	RedeemCodeStatusUsed    = "used"
+	RedeemCodeStatusRevoked = "revoked"
+)
+
 type RedeemCodeQuery struct {
-	Code  *string
-	Used  *bool
-	Limit int
+	CodeHash *string
+	BatchId  *uint
+	Used     *bool
+	Status   string // RedeemCodeStatusActive or RedeemCodeStatusExpired, empty matches every code
+	Limit    int
 }
 
 func (r *RedeemCode) TableName() string {
 	return "redeem_codes"
 }
+
+// NotYetValid reports whether the validity window of the code has not opened at now.
+func (r *RedeemCode) NotYetValid(now time.Time) bool {
+	return r.ValidFrom != nil && now.Before(*r.ValidFrom)
+}
+
+// Expired reports whether the validity window of the code has closed at now.
+func (r *RedeemCode) Expired(now time.Time) bool {
+	return r.ValidUntil != nil && !now.Before(*r.ValidUntil)
+}
+
+// IsPromo reports whether the code can be redeemed by many users.
+func (r *RedeemCode) IsPromo() bool {
+	return r.Type == RedeemCodeTypePromo
+}
+
+// Exhausted reports whether a promo code has reached its global cap.
+func (r *RedeemCode) Exhausted() bool {
+	return r.IsPromo() && r.MaxRedemptions > 0 && r.RedeemedCount >= r.MaxRedemptions
+}
+
+// TopUpIdempotentKey returns the idempotent key of the top-up change log redeeming the code. It names the code
+// by id so that change logs never hold the plaintext, seq numbers the redemptions of a promo code by the user.
+func (r *RedeemCode) TopUpIdempotentKey(userId int, seq int) string {
+	if r.IsPromo() {
+		return fmt.Sprintf("rc_%d_%d_%d", r.ID, userId, seq)
+	}
+	return fmt.Sprintf("rc_%d", r.ID)
+}
+
+// StatusAt returns the status of the code at now, a used or revoked code keeps that status once its window closes.
+// A promo code counts as used once exhausted.
+func (r *RedeemCode) StatusAt(now time.Time) string {
+	switch {
+	case r.UsedUserId != 0 || r.Exhausted():
+		return RedeemCodeStatusUsed
+	case r.RevokedAt != nil:
+		return RedeemCodeStatusRevoked
+	case r.Expired(now):
+		return RedeemCodeStatusExpired
+	case r.NotYetValid(now):
+		return RedeemCodeStatusPending
+	default:
+		return RedeemCodeStatusActive
+	}
+}
+
+// Redeem code batch statuses, only batches generated by a job go through generating.
+const (
+	RedeemCodeBatchStatusGenerating = 1
+	RedeemCodeBatchStatusCompleted  = 2
+	RedeemCodeBatchStatusFailed     = 3
+)
+
+var RedeemCodeBatchStatusNames = map[int]string{
+	RedeemCodeBatchStatusGenerating: "generating",
+	RedeemCodeBatchStatusCompleted:  "completed",
+	RedeemCodeBatchStatusFailed:     "failed",
+}
+
+// RedeemCodeBatch records one run of GenerateRedeemCodes or one generation job, every code generated by it
+// points back to the batch.
+type RedeemCodeBatch struct {
+	ID             uint       `gorm:"primaryKey"`
+	Name           string     `gorm:"type:varchar(64);not null"`
+	Campaign       string     `gorm:"type:varchar(64);index;not null;default:''"`
+	CreatorId      int        `gorm:"index;not null"` // merchant user who generated the batch
+	Amount         int        `gorm:"not null"`
+	Quantity       int        `gorm:"not null"`
+	Status         int        `gorm:"index;not null;default:2"` // 1: generating, 2: completed, 3: failed
+	GeneratedCount int        `gorm:"not null;default:0"`       // codes inserted so far, a job resumes from here
+	LastError      string     `gorm:"type:varchar(255);not null;default:''"`
+	ValidFrom      *time.Time // validity window shared by the codes of the batch
+	ValidUntil     *time.Time
+	RevokedAt      *time.Time // last time the unused codes of the batch were revoked
+	CreatedAt      time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime"`
+}
+
+func (r *RedeemCodeBatch) TableName() string {
+	return "redeem_code_batches"
+}
+
+type RedeemCodeBatchQuery struct {
+	Campaign  string // empty matches every campaign
+	CreatorId int    // 0 matches every creator
+	BeforeId  uint   // only batches with a smaller id, 0 for the newest
+	Limit     int
+}
+
+// RedeemCodeBatchProgress counts the used and revoked codes of a batch, and the redemptions of its promo codes.
+type RedeemCodeBatchProgress struct {
+	BatchId     uint
+	Used        int
+	Revoked     int
+	Redemptions int
+}
+
+// RedeemCodeRedemption records one redemption of a promo code. Seq numbers the redemptions of the code by the
+// user from 1, the unique index on it stops concurrent top-ups from going past the per-user limit.
+type RedeemCodeRedemption struct {
+	ID           int       `gorm:"primaryKey"`
+	RedeemCodeId uint      `gorm:"uniqueIndex:redemption_uniq;not null"`
+	UserId       int       `gorm:"uniqueIndex:redemption_uniq;not null"`
+	Seq          int       `gorm:"uniqueIndex:redemption_uniq;not null"`
+	AccountId    int       `gorm:"not null"`
+	ChangeLogId  int       `gorm:"not null"` // top-up change log of the redemption
+	Amount       int       `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+func (r *RedeemCodeRedemption) TableName() string {
+	return "redeem_code_redemptions"
+}
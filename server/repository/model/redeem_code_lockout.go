@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+const (
+	RedeemCodeLockoutActionLocked  = 1
+	RedeemCodeLockoutActionCleared = 2
+)
+
+// RedeemCodeLockoutAudit records a user or an ip being locked out of redeem code top-ups, or the lockout
+// being cleared by an operator. The lockouts themselves live in a lockout.Store.
+type RedeemCodeLockoutAudit struct {
+	ID          uint       `gorm:"primaryKey"`
+	Action      int        `gorm:"not null"`                                    // 1: locked, 2: cleared
+	Subject     string     `gorm:"type:varchar(8);index:subject_idx;not null"`  // user or ip
+	SubjectKey  string     `gorm:"type:varchar(64);index:subject_idx;not null"` // user id or ip address
+	Lockouts    int        `gorm:"not null;default:0"`                          // lockouts of the subject so far, 0 when cleared
+	LockedUntil *time.Time // nil when cleared
+	OperatorId  int        `gorm:"not null;default:0"` // cleared only
+	CreatedAt   time.Time  `gorm:"autoCreateTime"`
+}
+
+func (r *RedeemCodeLockoutAudit) TableName() string {
+	return "redeem_code_lockout_audits"
+}
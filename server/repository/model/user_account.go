@@ -2,13 +2,30 @@ package model
 
 import "time"
 
+const (
+	UserAccountStatusActive = 1
+	UserAccountStatusFrozen = 2 // reversible, e.g. the user was banned
+	UserAccountStatusClosed = 3 // final, the user was deleted
+)
+
+var userAccountStatusNames = map[int]string{
+	UserAccountStatusActive: "active",
+	UserAccountStatusFrozen: "frozen",
+	UserAccountStatusClosed: "closed",
+}
+
 type UserAccount struct {
-	ID        int       `gorm:"primaryKey"`
-	UserId    int       `gorm:"uniqueIndex;not null"`
-	AccountNo string    `gorm:"uniqueIndex;not null"`
-	Balance   int       `gorm:"not null;default:0"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	ID            int    `gorm:"primaryKey"`
+	UserId        int    `gorm:"uniqueIndex;not null"`
+	AccountNo     string `gorm:"uniqueIndex;not null"`
+	Balance       int    `gorm:"not null;default:0"` // cache of the wallet ledger account, see ledger.Wallet
+	FrozenBalance int    `gorm:"not null;default:0"` // reserved by authorized payment holds
+	Status        int    `gorm:"not null;default:1"` // 1: active, 2: frozen, 3: closed
+	// time of the user event the status was last moved by, nil until the first one. Events arrive out of order,
+	// older ones are dropped.
+	StatusChangedAt *time.Time
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
 }
 
 func (u *UserAccount) TableName() string {
@@ -21,3 +38,15 @@ func (u *UserAccount) GetHiddenAccountNo() string {
 	}
 	return u.AccountNo[0:4] + "****" + u.AccountNo[len(u.AccountNo)-4:]
 }
+
+// IsActive reports whether the account may pay, be topped up and send or receive transfers.
+func (u *UserAccount) IsActive() bool {
+	return u.Status == UserAccountStatusActive
+}
+
+func (u *UserAccount) StatusName() string {
+	if name, ok := userAccountStatusNames[u.Status]; ok {
+		return name
+	}
+	return "unknown"
+}
@@ -1,27 +1,77 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 const (
 	OpTypeTopUp   = 1
 	OpTypePayment = 2
+	OpTypeRefund  = 3
+	// payment hold lifecycle
+	OpTypeAuthorize = 4
+	OpTypeCapture   = 5
+	OpTypeVoid      = 6
+	// peer-to-peer transfer, the two sides share one idempotent key
+	OpTypeTransferOut = 7
+	OpTypeTransferIn  = 8
 )
 
 type UserAccountChangeLog struct {
-	ID            int       `gorm:"primaryKey"`
-	AccountId     int       `gorm:"index;not null"`
-	OpType        int       `gorm:"not null"` // 1: top-up, 2: payment
-	Amount        int       `gorm:"not null"`
-	CreatedAt     time.Time `gorm:"autoCreateTime"`
-	IdempotentKey string    `gorm:"type:varchar"` // JSON string for extra info
+	ID             int       `gorm:"primaryKey"`
+	AccountId      int       `gorm:"index;not null"`
+	OpType         int       `gorm:"not null"` // 1: top-up, 2: payment, 3: refund, 4: authorize, 5: capture, 6: void, 7: transfer-out, 8: transfer-in
+	Amount         int       `gorm:"not null"`
+	RefundedAmount int       `gorm:"not null;default:0"`       // total refunded so far, only used by payments
+	OriginLogId    int       `gorm:"index;not null;default:0"` // payment a refund belongs to, transfer-out a transfer-in belongs to
+	BalanceBefore  int       `gorm:"not null;default:0"`       // available balance before the change
+	BalanceAfter   int       `gorm:"not null;default:0"`       // available balance after the change
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+	IdempotentKey  string    `gorm:"type:varchar"` // JSON string for extra info
 }
 
 func (u *UserAccountChangeLog) TableName() string {
 	return "user_account_change_logs"
 }
 
+// GetPayOrderId returns the external order id of the change log, in the form of accountId_idempotentKey_id.
+func (u *UserAccountChangeLog) GetPayOrderId() string {
+	return fmt.Sprintf("%d_%s_%d", u.AccountId, u.IdempotentKey, u.ID)
+}
+
+// BalanceDelta returns how the change log moves the available balance, frozen money is not part of it.
+func (u *UserAccountChangeLog) BalanceDelta() int {
+	switch u.OpType {
+	case OpTypeTopUp, OpTypeRefund, OpTypeVoid, OpTypeTransferIn:
+		return u.Amount
+	case OpTypePayment, OpTypeAuthorize, OpTypeTransferOut:
+		return -u.Amount
+	default:
+		// a capture takes money that was already frozen
+		return 0
+	}
+}
+
+// SetBalance records the available balance around the change, before being the balance the change was applied to.
+func (u *UserAccountChangeLog) SetBalance(before int) {
+	u.BalanceBefore = before
+	u.BalanceAfter = before + u.BalanceDelta()
+}
+
 type UserAccountChangeLogQuery struct {
 	AccountId     *int
-	OpType        int
+	OpType        int // 0 matches every op type
 	IdempotentKey *string
+	StartTime     *time.Time
+	EndTime       *time.Time // exclusive
+	BeforeId      int        // keyset cursor, only change logs with a smaller id match when set
+	Limit         int        // defaults to repository.DefaultQueryLimit
+}
+
+// ChangeLogAmountSum is the total amount of one op type on one account.
+type ChangeLogAmountSum struct {
+	AccountId int
+	OpType    int
+	Amount    int
 }
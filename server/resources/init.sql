@@ -1,13 +1,61 @@
 CREATE TABLE `redeem_codes` (
   `id` bigint unsigned NOT NULL AUTO_INCREMENT,
-  `code` varchar(16) NOT NULL DEFAULT '',
+  `code` varchar(32) NULL DEFAULT NULL COMMENT 'plaintext of codes stored before hashing, null once hashed',
+  `code_hash` char(64) NULL DEFAULT NULL COMMENT 'HMAC-SHA256 of the code',
+  `sealed_code` varbinary(64) NULL DEFAULT NULL COMMENT 'encrypted plaintext until first export, job generated codes only',
+  `code_suffix` varchar(8) NOT NULL DEFAULT '',
+  `type` tinyint NOT NULL DEFAULT '1' COMMENT '1: single-use, 2: promo',
   `amount` int NOT NULL DEFAULT '0',
   `used_user_id` int NOT NULL DEFAULT '0' COMMENT 'top-up userId',
+  `max_redemptions` int NOT NULL DEFAULT '0' COMMENT 'promo only, 0 for no cap',
+  `per_user_limit` int NOT NULL DEFAULT '0' COMMENT 'promo only, 0 for no limit',
+  `redeemed_count` int NOT NULL DEFAULT '0' COMMENT 'promo only',
+  `batch_id` bigint unsigned NOT NULL DEFAULT '0' COMMENT '0 for codes generated before batches',
+  `revoked_at` datetime NULL DEFAULT NULL,
+  `valid_from` datetime NULL DEFAULT NULL COMMENT 'valid from creation if null',
+  `valid_until` datetime NULL DEFAULT NULL COMMENT 'exclusive, never expires if null',
   `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
   `updated_at` datetime DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   PRIMARY KEY (`id`),
   UNIQUE KEY `code_uniq` (`code`),
-  KEY `used_idx` (`used_user_id`)
+  UNIQUE KEY `code_hash_uniq` (`code_hash`),
+  KEY `used_idx` (`used_user_id`),
+  KEY `batch_idx` (`batch_id`),
+  KEY `valid_until_idx` (`valid_until`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `redeem_code_redemptions` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `redeem_code_id` bigint unsigned NOT NULL,
+  `user_id` int NOT NULL,
+  `seq` int NOT NULL COMMENT 'nth redemption of the code by the user',
+  `account_id` int NOT NULL,
+  `change_log_id` int NOT NULL COMMENT 'top-up change log',
+  `amount` int NOT NULL,
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `redemption_uniq` (`redeem_code_id`,`user_id`,`seq`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `redeem_code_batches` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) NOT NULL DEFAULT '',
+  `campaign` varchar(64) NOT NULL DEFAULT '',
+  `creator_id` int NOT NULL COMMENT 'merchant userId',
+  `amount` int NOT NULL,
+  `quantity` int NOT NULL,
+  `status` tinyint NOT NULL DEFAULT '2' COMMENT '1: generating, 2: completed, 3: failed',
+  `generated_count` int NOT NULL DEFAULT '0' COMMENT 'codes inserted so far by the generation job',
+  `last_error` varchar(255) NOT NULL DEFAULT '',
+  `valid_from` datetime NULL DEFAULT NULL,
+  `valid_until` datetime NULL DEFAULT NULL,
+  `revoked_at` datetime NULL DEFAULT NULL COMMENT 'last bulk revoke',
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` datetime DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `campaign_idx` (`campaign`),
+  KEY `creator_idx` (`creator_id`),
+  KEY `status_idx` (`status`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 
 CREATE TABLE `user_accounts` (
@@ -15,20 +63,121 @@ CREATE TABLE `user_accounts` (
   `user_id` int NOT NULL DEFAULT '0',
   `account_no` varchar(32) NOT NULL DEFAULT '',
   `balance` int NOT NULL DEFAULT '0',
+  `frozen_balance` int NOT NULL DEFAULT '0' COMMENT 'reserved by authorized payment holds',
+  `status` tinyint NOT NULL DEFAULT '1' COMMENT '1:active 2:frozen 3:closed',
+  `status_changed_at` datetime NULL DEFAULT NULL COMMENT 'time of the user event that last moved the status',
   `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
   `updated_at` datetime DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   PRIMARY KEY (`id`),
-  UNIQUE KEY `user_uniq` (`user_id`)
+  UNIQUE KEY `user_uniq` (`user_id`),
+  UNIQUE KEY `account_no_uniq` (`account_no`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 
 CREATE TABLE `user_account_change_logs` (
   `id` bigint unsigned NOT NULL AUTO_INCREMENT,
   `account_id` int NOT NULL DEFAULT '0',
-  `op_type` tinyint NOT NULL DEFAULT '0' COMMENT '1:top-up 2:deduct',
+  `op_type` tinyint NOT NULL DEFAULT '0' COMMENT '1:top-up 2:deduct 3:refund 4:authorize 5:capture 6:void 7:transfer-out 8:transfer-in',
   `amount` int NOT NULL DEFAULT '0',
+  `refunded_amount` int NOT NULL DEFAULT '0' COMMENT 'total refunded amount of a payment',
+  `origin_log_id` int NOT NULL DEFAULT '0' COMMENT 'payment change log id of a refund, transfer-out change log id of a transfer-in',
+  `balance_before` int NOT NULL DEFAULT '0' COMMENT 'available balance before the change',
+  `balance_after` int NOT NULL DEFAULT '0' COMMENT 'available balance after the change',
   `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
   `idempotent_key` varchar(32) NOT NULL DEFAULT '',
   PRIMARY KEY (`id`),
-  UNIQUE KEY `idempotent_key_uniq` (`idempotent_key`),
+  UNIQUE KEY `idempotent_key_uniq` (`idempotent_key`,`op_type`),
+  KEY `account_idx` (`account_id`),
+  KEY `account_op_type_idx` (`account_id`,`op_type`,`id`),
+  KEY `origin_log_idx` (`origin_log_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `payment_holds` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `account_id` int NOT NULL DEFAULT '0',
+  `user_id` int NOT NULL DEFAULT '0',
+  `biz_id` varchar(32) NOT NULL DEFAULT '',
+  `amount` int NOT NULL DEFAULT '0',
+  `captured_amount` int NOT NULL DEFAULT '0',
+  `status` tinyint NOT NULL DEFAULT '0' COMMENT '1:authorized 2:captured 3:voided 4:expired',
+  `expires_at` datetime NOT NULL,
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` datetime DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `biz_id_uniq` (`biz_id`),
+  KEY `account_idx` (`account_id`),
+  KEY `status_expires_idx` (`status`,`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+CREATE TABLE `ledger_accounts` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `code` varchar(64) NOT NULL DEFAULT '' COMMENT 'wallet:<user account id> for customer wallets',
+  `type` tinyint NOT NULL DEFAULT '0' COMMENT '1:asset 2:liability 3:equity 4:revenue 5:expense',
+  `user_account_id` int NOT NULL DEFAULT '0' COMMENT 'owner of a customer wallet, 0 for system accounts',
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `code_uniq` (`code`),
+  KEY `user_account_idx` (`user_account_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `journal_entries` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `op_type` tinyint NOT NULL DEFAULT '0' COMMENT 'op type of the change log, 0:opening balance',
+  `change_log_id` int NOT NULL DEFAULT '0',
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `change_log_idx` (`change_log_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `journal_lines` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `entry_id` int NOT NULL DEFAULT '0',
+  `ledger_account_id` int NOT NULL DEFAULT '0',
+  `debit` int NOT NULL DEFAULT '0',
+  `credit` int NOT NULL DEFAULT '0',
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `entry_idx` (`entry_id`),
+  KEY `ledger_account_idx` (`ledger_account_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `reconciliation_discrepancies` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `run_id` varchar(32) NOT NULL DEFAULT '',
+  `kind` tinyint NOT NULL DEFAULT '0' COMMENT '1:change log balance 2:ledger balance 3:redeem code top-up',
+  `account_id` int NOT NULL DEFAULT '0',
+  `redeem_code_id` int NOT NULL DEFAULT '0',
+  `expected` int NOT NULL DEFAULT '0' COMMENT 'balance or amount the records add up to',
+  `actual` int NOT NULL DEFAULT '0' COMMENT 'balance or amount found',
+  `detail` varchar(255) NOT NULL DEFAULT '',
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `run_idx` (`run_id`),
   KEY `account_idx` (`account_id`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
\ No newline at end of file
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `redeem_code_lockout_audits` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `action` tinyint NOT NULL DEFAULT '0' COMMENT '1:locked 2:cleared',
+  `subject` varchar(8) NOT NULL DEFAULT '' COMMENT 'user or ip',
+  `subject_key` varchar(64) NOT NULL DEFAULT '' COMMENT 'user id or ip address',
+  `lockouts` int NOT NULL DEFAULT '0' COMMENT 'lockouts of the subject so far, 0 when cleared',
+  `locked_until` datetime DEFAULT NULL,
+  `operator_id` int NOT NULL DEFAULT '0' COMMENT 'user clearing the lockout',
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `subject_idx` (`subject`, `subject_key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `outbox_events` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `event_type` varchar(64) NOT NULL DEFAULT '' COMMENT 'also the topic it is published to',
+  `event_key` varchar(64) NOT NULL DEFAULT '' COMMENT 'message key',
+  `version` int NOT NULL DEFAULT '1',
+  `payload` text NOT NULL COMMENT 'JSON data of the envelope',
+  `status` tinyint NOT NULL DEFAULT '1' COMMENT '1:pending 2:sent',
+  `attempts` int NOT NULL DEFAULT '0' COMMENT 'failed publish attempts',
+  `last_error` varchar(255) NOT NULL DEFAULT '',
+  `sent_at` datetime DEFAULT NULL,
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `status_idx` (`status`, `id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+)
+
+// BackfillBalanceSnapshots implements UserAccountService.
+// It replays the change logs of every account in id order from a zero balance and writes the
+// balance before and after each change, skipping rows that already hold the right values.
+// It returns the number of change logs updated.
+func (u *UserAccountServiceImpl) BackfillBalanceSnapshots(ctx context.Context) (int, error) {
+	updated := 0
+	lastAccountId := 0
+	for {
+		accounts, err := u.userAccountDao.QueryUserAccounts(ctx, lastAccountId, repository.DefaultQueryLimit)
+		if err != nil {
+			return updated, err
+		}
+		for _, account := range accounts {
+			n, err := u.backfillAccountBalanceSnapshots(ctx, account)
+			updated += n
+			if err != nil {
+				return updated, err
+			}
+		}
+		if len(accounts) < repository.DefaultQueryLimit {
+			break
+		}
+		lastAccountId = accounts[len(accounts)-1].ID
+	}
+	log.Logger.Infof("Backfilled balance snapshots of %d change logs", updated)
+	return updated, nil
+}
+
+func (u *UserAccountServiceImpl) backfillAccountBalanceSnapshots(ctx context.Context, account *model.UserAccount) (int, error) {
+	updated := 0
+	balance := 0
+	lastLogId := 0
+	for {
+		changeLogs, err := u.userAccountChangeLogDao.QueryChangeLogsByAccount(ctx, account.ID, lastLogId, repository.DefaultQueryLimit)
+		if err != nil {
+			return updated, err
+		}
+		for _, changeLog := range changeLogs {
+			before, after := changeLog.BalanceBefore, changeLog.BalanceAfter
+			changeLog.SetBalance(balance)
+			balance = changeLog.BalanceAfter
+			if changeLog.BalanceBefore == before && changeLog.BalanceAfter == after {
+				continue
+			}
+			if err = u.userAccountChangeLogDao.UpdateBalanceSnapshot(ctx, changeLog); err != nil {
+				return updated, err
+			}
+			updated++
+		}
+		if len(changeLogs) < repository.DefaultQueryLimit {
+			break
+		}
+		lastLogId = changeLogs[len(changeLogs)-1].ID
+	}
+	if balance != account.Balance {
+		log.Logger.Warnf("Replayed balance %d of account ID %d does not match the stored balance %d", balance, account.ID, account.Balance)
+	}
+	return updated, nil
+}
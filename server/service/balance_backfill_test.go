@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao/mocks"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+)
+
+func TestBackfillBalanceSnapshots(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+
+	userAccountDao := new(mocks.UserAccountDao)
+	userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+	service := &UserAccountServiceImpl{
+		userAccountDao:          userAccountDao,
+		userAccountChangeLogDao: userAccountChangeLogDao,
+	}
+
+	account := &model.UserAccount{ID: 1, UserId: 1, Balance: 60}
+	changeLogs := []*model.UserAccountChangeLog{
+		{ID: 1, AccountId: 1, OpType: model.OpTypeTopUp, Amount: 100, BalanceBefore: 0, BalanceAfter: 100},
+		{ID: 2, AccountId: 1, OpType: model.OpTypePayment, Amount: 30},
+		{ID: 3, AccountId: 1, OpType: model.OpTypeAuthorize, Amount: 20},
+		{ID: 4, AccountId: 1, OpType: model.OpTypeCapture, Amount: 10},
+		{ID: 5, AccountId: 1, OpType: model.OpTypeVoid, Amount: 10},
+	}
+	userAccountDao.On("QueryUserAccounts", ctx, 0, repository.DefaultQueryLimit).Return([]*model.UserAccount{account}, nil).Once()
+	userAccountChangeLogDao.On("QueryChangeLogsByAccount", ctx, 1, 0, repository.DefaultQueryLimit).Return(changeLogs, nil).Once()
+	userAccountChangeLogDao.On("UpdateBalanceSnapshot", ctx, mock.Anything).Return(nil).Times(4)
+
+	updated, err := service.BackfillBalanceSnapshots(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, updated)
+	expected := [][2]int{{0, 100}, {100, 70}, {70, 50}, {50, 50}, {50, 60}}
+	for i, changeLog := range changeLogs {
+		assert.Equal(t, expected[i][0], changeLog.BalanceBefore, "balance before of change log %d", changeLog.ID)
+		assert.Equal(t, expected[i][1], changeLog.BalanceAfter, "balance after of change log %d", changeLog.ID)
+	}
+	userAccountChangeLogDao.AssertExpectations(t)
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/metrics"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"gorm.io/gorm"
+)
+
+const (
+	BalanceUpdateModeOptimistic  = "optimistic"
+	BalanceUpdateModePessimistic = "pessimistic"
+
+	defaultBalanceUpdateRetryBackoff = 20 * time.Millisecond
+)
+
+// balanceUpdateFunc does the work of one balance update attempt inside tx, against the given account snapshot.
+// A compare-and-swap conflict must be returned as gorm.ErrCheckConstraintViolated so the attempt is retried.
+type balanceUpdateFunc func(tx *gorm.DB, account *model.UserAccount) error
+
+// balanceUpdatePolicy decides how balance updates deal with concurrent writers.
+// The zero value runs a single optimistic attempt.
+type balanceUpdatePolicy struct {
+	pessimistic  bool
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+func newBalanceUpdatePolicy(conf *config.PaymentConfig) balanceUpdatePolicy {
+	policy := balanceUpdatePolicy{retryBackoff: defaultBalanceUpdateRetryBackoff}
+	if conf == nil {
+		return policy
+	}
+	policy.pessimistic = conf.BalanceUpdateMode == BalanceUpdateModePessimistic
+	if conf.BalanceUpdateMaxRetries > 0 {
+		policy.maxRetries = conf.BalanceUpdateMaxRetries
+	}
+	if conf.BalanceUpdateRetryBackoffMs > 0 {
+		policy.retryBackoff = time.Duration(conf.BalanceUpdateRetryBackoffMs) * time.Millisecond
+	}
+	return policy
+}
+
+// run executes fn in a transaction. In optimistic mode a conflicting attempt is rolled back,
+// the account is re-read and the whole transaction retried up to maxRetries times with jittered backoff.
+// In pessimistic mode fn gets the account locked with SELECT ... FOR UPDATE, so it does not conflict.
+func (p balanceUpdatePolicy) run(ctx context.Context, op string, userAccountDao dao.UserAccountDao, txBeginner repository.TxBeginner, account *model.UserAccount, fn balanceUpdateFunc) error {
+	if p.pessimistic {
+		return txBeginner.Transaction(func(tx *gorm.DB) error {
+			locked, err := userAccountDao.LockUserAccountInTransaction(ctx, account.UserId, tx)
+			if err != nil {
+				return err
+			}
+			if locked == nil {
+				return &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_EXIST), Message: "user account not found"}
+			}
+			return fn(tx, locked)
+		})
+	}
+	for attempt := 0; ; attempt++ {
+		err := txBeginner.Transaction(func(tx *gorm.DB) error {
+			return fn(tx, account)
+		})
+		if errors.Is(err, dao.ErrAccountNotActive) {
+			// frozen or closed since the account was read, a retry would fail the same way
+			log.Logger.Warnf("Balance update %s for user ID %d hit an account that is no longer active", op, account.UserId)
+			return &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_ACTIVE), Message: "pay account is not active", Err: err}
+		}
+		if !errors.Is(err, gorm.ErrCheckConstraintViolated) {
+			return err
+		}
+		metrics.BalanceUpdateConflictsTotal.WithLabelValues(op).Inc()
+		if attempt >= p.maxRetries {
+			metrics.BalanceUpdateRetryExhaustedTotal.WithLabelValues(op).Inc()
+			log.Logger.Errorf("Balance update %s for user ID %d still conflicting after %d attempts", op, account.UserId, attempt+1)
+			return &bizerror.BizError{Code: int(paymentpb.RespCode_BALANCE_UPDATE_CONFLICT), Message: "balance was updated concurrently, please retry", Err: err}
+		}
+		log.Logger.Warnf("Balance update %s for user ID %d conflicted, retrying (attempt %d)", op, account.UserId, attempt+1)
+		if err = p.sleep(ctx, attempt); err != nil {
+			return err
+		}
+		metrics.BalanceUpdateRetriesTotal.WithLabelValues(op).Inc()
+		refreshed, err := userAccountDao.GetUserAccountByUserID(ctx, account.UserId)
+		if err != nil {
+			return err
+		}
+		if refreshed == nil {
+			return &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_EXIST), Message: "user account not found"}
+		}
+		account = refreshed
+	}
+}
+
+// sleep waits an exponential backoff with equal jitter, so racing writers do not retry in lockstep.
+func (p balanceUpdatePolicy) sleep(ctx context.Context, attempt int) error {
+	backoff := p.retryBackoff << attempt
+	if backoff <= 0 {
+		return nil
+	}
+	wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao/mocks"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"gorm.io/gorm"
+)
+
+func TestNewBalanceUpdatePolicy(t *testing.T) {
+	policy := newBalanceUpdatePolicy(nil)
+	assert.False(t, policy.pessimistic)
+	assert.Equal(t, 0, policy.maxRetries)
+	assert.Equal(t, defaultBalanceUpdateRetryBackoff, policy.retryBackoff)
+
+	policy = newBalanceUpdatePolicy(&config.PaymentConfig{
+		BalanceUpdateMode:           BalanceUpdateModePessimistic,
+		BalanceUpdateMaxRetries:     3,
+		BalanceUpdateRetryBackoffMs: 5,
+	})
+	assert.True(t, policy.pessimistic)
+	assert.Equal(t, 3, policy.maxRetries)
+	assert.Equal(t, 5*time.Millisecond, policy.retryBackoff)
+}
+
+func TestPayOrderBalanceConflict(t *testing.T) {
+	ctx := context.Background()
+	userId := 1
+	bizId := "test-biz-id"
+	amount := 100
+	initEnv()
+
+	t.Run("should re-read the account and retry on conflict", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+			balanceUpdatePolicy:     balanceUpdatePolicy{maxRetries: 2, retryBackoff: time.Millisecond},
+		}
+
+		stale := &model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}
+		fresh := &model.UserAccount{ID: 1, UserId: userId, Balance: 150, Status: model.UserAccountStatusActive}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(stale, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Twice()
+		userAccountDao.On("SubtractBalanceInTransaction", ctx, userId, amount, stale.Balance, mock.Anything).Return(0, gorm.ErrCheckConstraintViolated).Once()
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(fresh, nil).Once()
+		userAccountDao.On("SubtractBalanceInTransaction", ctx, userId, amount, fresh.Balance, mock.Anything).Return(1, nil).Once()
+
+		changeLog, err := service.PayOrder(ctx, userId, bizId, amount)
+		assert.NoError(t, err)
+		assert.NotNil(t, changeLog)
+		userAccountDao.AssertExpectations(t)
+	})
+
+	t.Run("should fail with insufficient balance after re-read", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+			balanceUpdatePolicy:     balanceUpdatePolicy{maxRetries: 2, retryBackoff: time.Millisecond},
+		}
+
+		stale := &model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}
+		fresh := &model.UserAccount{ID: 1, UserId: userId, Balance: 50, Status: model.UserAccountStatusActive}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(stale, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+		userAccountDao.On("SubtractBalanceInTransaction", ctx, userId, amount, stale.Balance, mock.Anything).Return(0, gorm.ErrCheckConstraintViolated).Once()
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(fresh, nil).Once()
+
+		_, err := service.PayOrder(ctx, userId, bizId, amount)
+		assert.Equal(t, paymentpb.RespCode_INSUFFICIENT_BALANCE, bizerror.RespCodeOf(err))
+	})
+
+	t.Run("should give up after max retries", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+			balanceUpdatePolicy:     balanceUpdatePolicy{maxRetries: 1, retryBackoff: time.Millisecond},
+		}
+
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Twice()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Twice()
+		userAccountDao.On("SubtractBalanceInTransaction", ctx, userId, amount, userAccount.Balance, mock.Anything).Return(0, gorm.ErrCheckConstraintViolated).Twice()
+
+		_, err := service.PayOrder(ctx, userId, bizId, amount)
+		assert.Equal(t, paymentpb.RespCode_BALANCE_UPDATE_CONFLICT, bizerror.RespCodeOf(err))
+		userAccountDao.AssertExpectations(t)
+	})
+
+	t.Run("should lock the account in pessimistic mode", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+			balanceUpdatePolicy:     balanceUpdatePolicy{pessimistic: true},
+		}
+
+		stale := &model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}
+		locked := &model.UserAccount{ID: 1, UserId: userId, Balance: 180, Status: model.UserAccountStatusActive}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(stale, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
+		userAccountDao.On("LockUserAccountInTransaction", ctx, userId, mock.Anything).Return(locked, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+		userAccountDao.On("SubtractBalanceInTransaction", ctx, userId, amount, locked.Balance, mock.Anything).Return(1, nil).Once()
+
+		changeLog, err := service.PayOrder(ctx, userId, bizId, amount)
+		assert.NoError(t, err)
+		assert.NotNil(t, changeLog)
+		userAccountDao.AssertExpectations(t)
+	})
+}
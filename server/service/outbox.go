@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/event"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/metrics"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"gorm.io/gorm"
+)
+
+type OutboxService interface {
+	// RelayOutbox publishes the pending outbox events oldest first and marks them sent, stopping at the first
+	// batch that fails to publish so that events of a key never overtake each other. It returns how many
+	// events were sent and publishes the remaining backlog.
+	RelayOutbox(ctx context.Context) (int, error)
+}
+
+var (
+	outboxServiceInstance OutboxService
+	outboxServiceOnce     sync.Once
+)
+
+func GetOutboxService() OutboxService {
+	outboxServiceOnce.Do(func() {
+		outboxServiceInstance = &OutboxServiceImpl{
+			outboxDao: dao.GetOutboxDao(),
+			publisher: event.GetPublisher(),
+			batchSize: outboxRelayBatchSize(config.Config.PaymentConfig),
+		}
+	})
+	return outboxServiceInstance
+}
+
+type OutboxServiceImpl struct {
+	outboxDao dao.OutboxDao
+	publisher event.Publisher
+	batchSize int
+}
+
+const defaultOutboxRelayBatchSize = 100
+
+func outboxRelayBatchSize(conf *config.PaymentConfig) int {
+	if conf == nil || conf.OutboxRelayBatchSize <= 0 {
+		return defaultOutboxRelayBatchSize
+	}
+	return conf.OutboxRelayBatchSize
+}
+
+// createOutboxEventInTransaction writes an event to the outbox within tx, it is published only if tx commits.
+func createOutboxEventInTransaction(ctx context.Context, outboxDao dao.OutboxDao, eventType string, version int, key string, data any, tx *gorm.DB) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Logger.Errorf("Failed to marshal %s event for key %s: %v", eventType, key, err)
+		return err
+	}
+	return outboxDao.CreateEventInTransaction(ctx, &model.OutboxEvent{
+		EventType: eventType,
+		EventKey:  key,
+		Version:   version,
+		Payload:   string(payload),
+		Status:    model.OutboxEventStatusPending,
+	}, tx)
+}
+
+// outboxMessage wraps the outbox event in its envelope. The event id is derived from the outbox row, so that
+// a republished event keeps its id.
+func outboxMessage(outboxEvent *model.OutboxEvent) (event.Message, error) {
+	value, err := json.Marshal(&event.Envelope{
+		EventId:    fmt.Sprintf("payment-outbox-%d", outboxEvent.ID),
+		EventType:  outboxEvent.EventType,
+		Version:    outboxEvent.Version,
+		OccurredAt: outboxEvent.CreatedAt.Unix(),
+		Data:       json.RawMessage(outboxEvent.Payload),
+	})
+	if err != nil {
+		return event.Message{}, err
+	}
+	return event.Message{Topic: outboxEvent.EventType, Key: outboxEvent.EventKey, Value: value}, nil
+}
+
+// RelayOutbox implements OutboxService.
+// An event published but not marked sent, e.g. when the process dies in between, is published again by the
+// next relay, consumers see it twice under the same event id.
+func (o *OutboxServiceImpl) RelayOutbox(ctx context.Context) (int, error) {
+	defer o.publishBacklog(ctx)
+	sent := 0
+	for {
+		outboxEvents, err := o.outboxDao.QueryPendingEvents(ctx, o.batchSize)
+		if err != nil {
+			return sent, err
+		}
+		if len(outboxEvents) == 0 {
+			return sent, nil
+		}
+		msgs := make([]event.Message, len(outboxEvents))
+		ids := make([]uint, len(outboxEvents))
+		for i, outboxEvent := range outboxEvents {
+			if msgs[i], err = outboxMessage(outboxEvent); err != nil {
+				log.Logger.Errorf("Failed to build message of outbox event %d: %v", outboxEvent.ID, err)
+				return sent, err
+			}
+			ids[i] = outboxEvent.ID
+		}
+		if err = o.publisher.Publish(ctx, msgs...); err != nil {
+			log.Logger.Errorf("Failed to publish %d outbox events from event %d: %v", len(ids), ids[0], err)
+			_ = o.outboxDao.RecordPublishFailure(ctx, ids, err.Error())
+			return sent, err
+		}
+		if err = o.outboxDao.MarkEventsSent(ctx, ids, time.Now()); err != nil {
+			return sent, err
+		}
+		sent += len(ids)
+		log.Logger.Infof("Published %d outbox events up to event %d", len(ids), ids[len(ids)-1])
+		if len(outboxEvents) < o.batchSize {
+			return sent, nil
+		}
+	}
+}
+
+func (o *OutboxServiceImpl) publishBacklog(ctx context.Context) {
+	backlog, err := o.outboxDao.CountPendingEvents(ctx)
+	if err != nil {
+		return
+	}
+	metrics.OutboxBacklog.Set(float64(backlog))
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/event"
+	eventmocks "github.com/sw5005-sus/ceramicraft-payment-mservice/server/event/mocks"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao/mocks"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+)
+
+func TestRelayOutbox(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Unix(1700000000, 0)
+	newEvents := func(ids ...uint) []*model.OutboxEvent {
+		outboxEvents := make([]*model.OutboxEvent, len(ids))
+		for i, id := range ids {
+			outboxEvents[i] = &model.OutboxEvent{
+				ID:        id,
+				EventType: event.TypePaymentSucceeded,
+				EventKey:  "1",
+				Version:   1,
+				Payload:   `{"biz_id":"order-1"}`,
+				Status:    model.OutboxEventStatusPending,
+				CreatedAt: createdAt,
+			}
+		}
+		return outboxEvents
+	}
+	initEnv()
+
+	t.Run("should publish pending events in envelopes and mark them sent", func(t *testing.T) {
+		outboxDao := new(mocks.OutboxDao)
+		publisher := new(eventmocks.Publisher)
+		service := &OutboxServiceImpl{outboxDao: outboxDao, publisher: publisher, batchSize: 10}
+
+		outboxDao.On("QueryPendingEvents", ctx, 10).Return(newEvents(4, 5), nil).Once()
+		publisher.On("Publish", ctx, mock.MatchedBy(func(msgs []event.Message) bool {
+			if len(msgs) != 2 || msgs[0].Topic != event.TypePaymentSucceeded || msgs[0].Key != "1" {
+				return false
+			}
+			var envelope event.Envelope
+			return json.Unmarshal(msgs[0].Value, &envelope) == nil && envelope.EventId == "payment-outbox-4" &&
+				envelope.EventType == event.TypePaymentSucceeded && envelope.Version == 1 &&
+				envelope.OccurredAt == createdAt.Unix() && string(envelope.Data) == `{"biz_id":"order-1"}`
+		})).Return(nil).Once()
+		outboxDao.On("MarkEventsSent", ctx, []uint{4, 5}, mock.Anything).Return(nil).Once()
+		outboxDao.On("CountPendingEvents", ctx).Return(0, nil).Once()
+
+		sent, err := service.RelayOutbox(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, sent)
+		outboxDao.AssertExpectations(t)
+		publisher.AssertExpectations(t)
+	})
+
+	t.Run("should keep relaying while batches are full", func(t *testing.T) {
+		outboxDao := new(mocks.OutboxDao)
+		publisher := new(eventmocks.Publisher)
+		service := &OutboxServiceImpl{outboxDao: outboxDao, publisher: publisher, batchSize: 2}
+
+		outboxDao.On("QueryPendingEvents", ctx, 2).Return(newEvents(1, 2), nil).Once()
+		outboxDao.On("QueryPendingEvents", ctx, 2).Return(newEvents(3), nil).Once()
+		publisher.On("Publish", ctx, mock.Anything).Return(nil).Twice()
+		outboxDao.On("MarkEventsSent", ctx, mock.Anything, mock.Anything).Return(nil).Twice()
+		outboxDao.On("CountPendingEvents", ctx).Return(0, nil).Once()
+
+		sent, err := service.RelayOutbox(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, sent)
+		outboxDao.AssertExpectations(t)
+	})
+
+	t.Run("should leave events pending when publishing fails", func(t *testing.T) {
+		outboxDao := new(mocks.OutboxDao)
+		publisher := new(eventmocks.Publisher)
+		service := &OutboxServiceImpl{outboxDao: outboxDao, publisher: publisher, batchSize: 10}
+
+		outboxDao.On("QueryPendingEvents", ctx, 10).Return(newEvents(4, 5), nil).Once()
+		publisher.On("Publish", ctx, mock.Anything).Return(assert.AnError).Once()
+		outboxDao.On("RecordPublishFailure", ctx, []uint{4, 5}, assert.AnError.Error()).Return(nil).Once()
+		outboxDao.On("CountPendingEvents", ctx).Return(2, nil).Once()
+
+		sent, err := service.RelayOutbox(ctx)
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.Equal(t, 0, sent)
+		outboxDao.AssertExpectations(t)
+		outboxDao.AssertNotCalled(t, "MarkEventsSent", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should do nothing without pending events", func(t *testing.T) {
+		outboxDao := new(mocks.OutboxDao)
+		publisher := new(eventmocks.Publisher)
+		service := &OutboxServiceImpl{outboxDao: outboxDao, publisher: publisher, batchSize: 10}
+
+		outboxDao.On("QueryPendingEvents", ctx, 10).Return(nil, nil).Once()
+		outboxDao.On("CountPendingEvents", ctx).Return(0, nil).Once()
+
+		sent, err := service.RelayOutbox(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, sent)
+		publisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/ledger"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"gorm.io/gorm"
+)
+
+type PaymentHoldService interface {
+	// AuthorizePayment freezes amount of the balance of the user under bizId. A replayed bizId returns the original
+	// hold along with a DUPLICATE_REQUEST error.
+	AuthorizePayment(ctx context.Context, userId int, bizId string, amount int, expireIn time.Duration) (*model.PaymentHold, error)
+	CapturePayment(ctx context.Context, bizId string, amount *int) (*model.PaymentHold, *model.UserAccountChangeLog, error)
+	VoidPayment(ctx context.Context, bizId string) (*model.PaymentHold, error)
+	ReleaseExpiredHolds(ctx context.Context) (int, error)
+}
+
+var (
+	paymentHoldServiceInstance PaymentHoldService
+	paymentHoldServiceOnce     sync.Once
+)
+
+func GetPaymentHoldService() PaymentHoldService {
+	paymentHoldServiceOnce.Do(func() {
+		paymentHoldServiceInstance = &PaymentHoldServiceImpl{
+			userAccountDao:          dao.GetUserAccountDao(),
+			userAccountChangeLogDao: dao.GetUserAccountChangeLogDAO(),
+			paymentHoldDao:          dao.GetPaymentHoldDao(),
+			ledger:                  ledger.GetLedger(),
+			txBeginner:              repository.DB,
+			balanceUpdatePolicy:     newBalanceUpdatePolicy(config.Config.PaymentConfig),
+		}
+	})
+	return paymentHoldServiceInstance
+}
+
+type PaymentHoldServiceImpl struct {
+	userAccountDao          dao.UserAccountDao
+	userAccountChangeLogDao dao.UserAccountChangeLogDAO
+	paymentHoldDao          dao.PaymentHoldDao
+	ledger                  ledger.Ledger
+	txBeginner              repository.TxBeginner
+	balanceUpdatePolicy     balanceUpdatePolicy
+}
+
+// AuthorizePayment implements PaymentHoldService.
+// It moves the amount from the balance into the frozen balance until the hold is captured, voided or expired.
+func (p *PaymentHoldServiceImpl) AuthorizePayment(ctx context.Context, userId int, bizId string, amount int, expireIn time.Duration) (*model.PaymentHold, error) {
+	account, err := p.userAccountDao.GetUserAccountByUserID(ctx, userId)
+	if err != nil {
+		log.Logger.Errorf("Failed to get user account for user ID %d: %v", userId, err)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get user account", Err: err}
+	}
+	if account == nil {
+		log.Logger.Warnf("User account not found for user ID %d", userId)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_EXIST), Message: "user account not found"}
+	}
+	// a replay must be answered before the balance check, the original hold already froze the money
+	original, err := p.checkAuthorizeReplay(ctx, account, bizId, amount)
+	if original != nil || err != nil {
+		return original, err
+	}
+	if err = checkAccountActive(account); err != nil {
+		return nil, err
+	}
+	if account.Balance < amount {
+		log.Logger.Warnf("Insufficient balance for user ID %d: balance %d, required %d", userId, account.Balance, amount)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_INSUFFICIENT_BALANCE), Message: "insufficient balance"}
+	}
+	var hold *model.PaymentHold
+	err = p.balanceUpdatePolicy.run(ctx, "authorize", p.userAccountDao, p.txBeginner, account, func(tx *gorm.DB, account *model.UserAccount) error {
+		if err := checkAccountActive(account); err != nil {
+			return err
+		}
+		if account.Balance < amount {
+			log.Logger.Warnf("Insufficient balance for user ID %d: balance %d, required %d", userId, account.Balance, amount)
+			return &bizerror.BizError{Code: int(paymentpb.RespCode_INSUFFICIENT_BALANCE), Message: "insufficient balance"}
+		}
+		now := time.Now()
+		hold = &model.PaymentHold{
+			AccountId: account.ID,
+			UserId:    userId,
+			BizId:     bizId,
+			Amount:    amount,
+			Status:    model.HoldStatusAuthorized,
+			ExpiresAt: now.Add(expireIn),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		changeLog := &model.UserAccountChangeLog{
+			AccountId:     account.ID,
+			OpType:        model.OpTypeAuthorize,
+			Amount:        amount,
+			IdempotentKey: bizId,
+			CreatedAt:     now,
+		}
+		changeLog.SetBalance(account.Balance)
+		err := p.paymentHoldDao.CreateHoldInTransaction(ctx, hold, tx)
+		if err != nil {
+			return err
+		}
+		err = p.userAccountChangeLogDao.CreateChangeLogInTransaction(ctx, changeLog, tx)
+		if err != nil {
+			log.Logger.Errorf("Failed to create authorize change log for user ID %d: %v", userId, err)
+			return err
+		}
+		entry := ledger.NewEntry(model.OpTypeAuthorize, changeLog.ID).
+			Debit(ledger.Wallet(account), amount).
+			Credit(ledger.PaymentHolds, amount)
+		if _, err = p.ledger.PostInTransaction(ctx, entry, tx); err != nil {
+			log.Logger.Errorf("Failed to post authorization to the ledger for user ID %d: %v", userId, err)
+			return err
+		}
+		_, err = p.userAccountDao.FreezeBalanceInTransaction(ctx, userId, amount, account.Balance, tx)
+		if err != nil {
+			log.Logger.Errorf("Failed to freeze balance for user ID %d: %v", userId, err)
+			return err
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// a concurrent request with the same bizId won the race
+		original, err := p.checkAuthorizeReplay(ctx, account, bizId, amount)
+		if original != nil || err != nil {
+			return original, err
+		}
+	}
+	if err != nil {
+		log.Logger.Errorf("Authorize transaction failed for user ID %d: %v", userId, err)
+		if bizErr, ok := err.(*bizerror.BizError); ok {
+			return nil, bizErr
+		}
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "transaction failed", Err: err}
+	}
+	log.Logger.Infof("Successfully authorized payment for user ID %d, amount %d, biz ID %s", userId, amount, bizId)
+	return hold, nil
+}
+
+// checkAuthorizeReplay looks for an earlier hold with the same bizId.
+// A faithful replay returns the original hold along with a DUPLICATE_REQUEST error,
+// a replay with a different user or amount is rejected with IDEMPOTENCY_KEY_CONFLICT.
+func (p *PaymentHoldServiceImpl) checkAuthorizeReplay(ctx context.Context, account *model.UserAccount, bizId string, amount int) (*model.PaymentHold, error) {
+	original, err := p.paymentHoldDao.GetHoldByBizId(ctx, bizId)
+	if err != nil {
+		log.Logger.Errorf("Failed to get payment hold for biz ID %s: %v", bizId, err)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get payment hold", Err: err}
+	}
+	if original == nil {
+		return nil, nil
+	}
+	if original.AccountId != account.ID || original.Amount != amount {
+		log.Logger.Warnf("Conflicting replay for biz ID %s: original account %d amount %d, got account %d amount %d", bizId, original.AccountId, original.Amount, account.ID, amount)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_IDEMPOTENCY_KEY_CONFLICT), Message: "bizId already used by a different payment hold"}
+	}
+	log.Logger.Infof("Duplicate authorize request for biz ID %s, returning payment hold %d", bizId, original.ID)
+	return original, &bizerror.BizError{Code: int(paymentpb.RespCode_DUPLICATE_REQUEST), Message: "duplicate request"}
+}
+
+// CapturePayment implements PaymentHoldService.
+// A nil amount captures the whole hold; for a partial capture the remainder is released back to the balance.
+func (p *PaymentHoldServiceImpl) CapturePayment(ctx context.Context, bizId string, amount *int) (*model.PaymentHold, *model.UserAccountChangeLog, error) {
+	hold, err := p.getActiveHold(ctx, bizId)
+	if err != nil {
+		return nil, nil, err
+	}
+	captureAmount := hold.Amount
+	if amount != nil {
+		captureAmount = *amount
+	}
+	if captureAmount > hold.Amount {
+		log.Logger.Warnf("Capture amount exceeded for biz ID %s: held %d, required %d", bizId, hold.Amount, captureAmount)
+		return nil, nil, &bizerror.BizError{Code: int(paymentpb.RespCode_CAPTURE_AMOUNT_EXCEEDED), Message: "capture amount exceeds held amount"}
+	}
+	hold.Status = model.HoldStatusCaptured
+	hold.CapturedAmount = captureAmount
+	now := time.Now()
+	changeLog := &model.UserAccountChangeLog{
+		AccountId:     hold.AccountId,
+		OpType:        model.OpTypeCapture,
+		Amount:        captureAmount,
+		IdempotentKey: bizId,
+		CreatedAt:     now,
+	}
+	changeLogs := []*model.UserAccountChangeLog{changeLog}
+	if captureAmount < hold.Amount {
+		// the remainder goes back to the balance, recorded as a void so the change logs add up to the balance
+		changeLogs = append(changeLogs, &model.UserAccountChangeLog{
+			AccountId:     hold.AccountId,
+			OpType:        model.OpTypeVoid,
+			Amount:        hold.Amount - captureAmount,
+			IdempotentKey: bizId,
+			CreatedAt:     now,
+		})
+	}
+	err = p.settleHold(ctx, hold, changeLogs...)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Logger.Infof("Successfully captured payment for user ID %d, amount %d, biz ID %s", hold.UserId, captureAmount, bizId)
+	return hold, changeLog, nil
+}
+
+// VoidPayment implements PaymentHoldService.
+func (p *PaymentHoldServiceImpl) VoidPayment(ctx context.Context, bizId string) (*model.PaymentHold, error) {
+	hold, err := p.getActiveHold(ctx, bizId)
+	if err != nil {
+		return nil, err
+	}
+	err = p.releaseHold(ctx, hold, model.HoldStatusVoided)
+	if err != nil {
+		return nil, err
+	}
+	log.Logger.Infof("Successfully voided payment for user ID %d, amount %d, biz ID %s", hold.UserId, hold.Amount, bizId)
+	return hold, nil
+}
+
+// ReleaseExpiredHolds implements PaymentHoldService.
+// It returns the number of holds released; failures are logged and retried on the next run.
+func (p *PaymentHoldServiceImpl) ReleaseExpiredHolds(ctx context.Context) (int, error) {
+	holds, err := p.paymentHoldDao.QueryExpiredHolds(ctx, time.Now(), repository.DefaultQueryLimit)
+	if err != nil {
+		log.Logger.Errorf("Failed to query expired payment holds: %v", err)
+		return 0, err
+	}
+	released := 0
+	for _, hold := range holds {
+		err = p.releaseHold(ctx, hold, model.HoldStatusExpired)
+		if err != nil {
+			log.Logger.Errorf("Failed to release expired payment hold for biz ID %s: %v", hold.BizId, err)
+			continue
+		}
+		released++
+	}
+	if released > 0 {
+		log.Logger.Infof("Released %d expired payment holds", released)
+	}
+	return released, nil
+}
+
+func (p *PaymentHoldServiceImpl) getActiveHold(ctx context.Context, bizId string) (*model.PaymentHold, error) {
+	hold, err := p.paymentHoldDao.GetHoldByBizId(ctx, bizId)
+	if err != nil {
+		log.Logger.Errorf("Failed to get payment hold for biz ID %s: %v", bizId, err)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get payment hold", Err: err}
+	}
+	if hold == nil {
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_HOLD_NOT_EXIST), Message: "payment hold not found"}
+	}
+	if !hold.IsActive(time.Now()) {
+		log.Logger.Warnf("Payment hold for biz ID %s is not active, status %d, expires at %v", bizId, hold.Status, hold.ExpiresAt)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_HOLD_NOT_ACTIVE), Message: "payment hold is not active"}
+	}
+	return hold, nil
+}
+
+// releaseHold gives the whole held amount back to the balance, recording it as a void.
+func (p *PaymentHoldServiceImpl) releaseHold(ctx context.Context, hold *model.PaymentHold, status int) error {
+	hold.Status = status
+	changeLog := &model.UserAccountChangeLog{
+		AccountId:     hold.AccountId,
+		OpType:        model.OpTypeVoid,
+		Amount:        hold.Amount,
+		IdempotentKey: hold.BizId,
+		CreatedAt:     time.Now(),
+	}
+	return p.settleHold(ctx, hold, changeLog)
+}
+
+// settleHold closes the hold with capture and void change logs, captured money leaves the frozen balance
+// for good and voided money goes back to the balance.
+func (p *PaymentHoldServiceImpl) settleHold(ctx context.Context, hold *model.PaymentHold, changeLogs ...*model.UserAccountChangeLog) error {
+	err := p.txBeginner.Transaction(func(tx *gorm.DB) error {
+		rowsAffected, err := p.paymentHoldDao.UpdateHoldStatusInTransaction(ctx, hold, model.HoldStatusAuthorized, tx)
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			log.Logger.Warnf("Payment hold for biz ID %s was settled concurrently", hold.BizId)
+			return &bizerror.BizError{Code: int(paymentpb.RespCode_HOLD_NOT_ACTIVE), Message: "payment hold is not active"}
+		}
+		// settling is not a compare-and-swap, the lock keeps the recorded balance exact
+		account, err := p.userAccountDao.LockUserAccountInTransaction(ctx, hold.UserId, tx)
+		if err != nil {
+			return err
+		}
+		if account == nil {
+			return &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_EXIST), Message: "user account not found"}
+		}
+		captureAmount, releaseAmount := 0, 0
+		balance := account.Balance
+		for _, changeLog := range changeLogs {
+			changeLog.SetBalance(balance)
+			balance = changeLog.BalanceAfter
+			err = p.userAccountChangeLogDao.CreateChangeLogInTransaction(ctx, changeLog, tx)
+			if err != nil {
+				log.Logger.Errorf("Failed to create change log for payment hold %s: %v", hold.BizId, err)
+				return err
+			}
+			// the captured part is revenue, the released part goes back to the wallet
+			entry := ledger.NewEntry(changeLog.OpType, changeLog.ID).Debit(ledger.PaymentHolds, changeLog.Amount)
+			if changeLog.OpType == model.OpTypeCapture {
+				captureAmount += changeLog.Amount
+				entry.Credit(ledger.MerchantRevenue, changeLog.Amount)
+			} else {
+				releaseAmount += changeLog.Amount
+				entry.Credit(ledger.Wallet(account), changeLog.Amount)
+			}
+			if _, err = p.ledger.PostInTransaction(ctx, entry, tx); err != nil {
+				log.Logger.Errorf("Failed to post payment hold %s to the ledger: %v", hold.BizId, err)
+				return err
+			}
+		}
+		_, err = p.userAccountDao.SettleFrozenBalanceInTransaction(ctx, hold.UserId, captureAmount, releaseAmount, tx)
+		if err != nil {
+			log.Logger.Errorf("Failed to settle frozen balance for user ID %d: %v", hold.UserId, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		log.Logger.Errorf("Settle transaction failed for payment hold %s: %v", hold.BizId, err)
+		if bizErr, ok := err.(*bizerror.BizError); ok {
+			return bizErr
+		}
+		return &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "transaction failed", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao/mocks"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"gorm.io/gorm"
+)
+
+func TestGetPaymentHoldService(t *testing.T) {
+	service1 := GetPaymentHoldService()
+	service2 := GetPaymentHoldService()
+	if service1 != service2 {
+		t.Errorf("Expected the same instance of PaymentHoldService, got different instances")
+	}
+}
+
+func TestAuthorizePayment(t *testing.T) {
+	ctx := context.Background()
+	userId := 1
+	bizId := "test-biz-id"
+	initEnv()
+
+	t.Run("should freeze balance and create hold", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		paymentHoldDao := new(mocks.PaymentHoldDao)
+		service := &PaymentHoldServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			paymentHoldDao:          paymentHoldDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+		}
+
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		paymentHoldDao.On("GetHoldByBizId", ctx, bizId).Return(nil, nil).Once()
+		paymentHoldDao.On("CreateHoldInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.MatchedBy(func(l *model.UserAccountChangeLog) bool {
+			return l.OpType == model.OpTypeAuthorize && l.Amount == 150 && l.IdempotentKey == bizId
+		}), mock.Anything).Return(nil).Once()
+		userAccountDao.On("FreezeBalanceInTransaction", ctx, userId, 150, userAccount.Balance, mock.Anything).Return(1, nil).Once()
+
+		hold, err := service.AuthorizePayment(ctx, userId, bizId, 150, time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, model.HoldStatusAuthorized, hold.Status)
+		assert.True(t, hold.IsActive(time.Now()))
+	})
+
+	t.Run("should return error if insufficient balance", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		paymentHoldDao := new(mocks.PaymentHoldDao)
+		service := &PaymentHoldServiceImpl{
+			userAccountDao: userAccountDao,
+			paymentHoldDao: paymentHoldDao,
+		}
+
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(&model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}, nil).Once()
+		paymentHoldDao.On("GetHoldByBizId", ctx, bizId).Return(nil, nil).Once()
+
+		_, err := service.AuthorizePayment(ctx, userId, bizId, 150, time.Minute)
+		bizErr, ok := err.(*bizerror.BizError)
+		assert.True(t, ok)
+		assert.Equal(t, int(paymentpb.RespCode_INSUFFICIENT_BALANCE), bizErr.Code)
+	})
+
+	t.Run("should return the original hold of a replay after it froze the balance", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		paymentHoldDao := new(mocks.PaymentHoldDao)
+		service := &PaymentHoldServiceImpl{
+			userAccountDao: userAccountDao,
+			paymentHoldDao: paymentHoldDao,
+		}
+
+		original := &model.PaymentHold{ID: 3, AccountId: 1, UserId: userId, BizId: bizId, Amount: 150, Status: model.HoldStatusAuthorized}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(&model.UserAccount{ID: 1, UserId: userId, Balance: 50, FrozenBalance: 150, Status: model.UserAccountStatusActive}, nil).Once()
+		paymentHoldDao.On("GetHoldByBizId", ctx, bizId).Return(original, nil).Once()
+
+		hold, err := service.AuthorizePayment(ctx, userId, bizId, 150, time.Minute)
+		assert.Equal(t, paymentpb.RespCode_DUPLICATE_REQUEST, bizerror.RespCodeOf(err))
+		assert.Equal(t, original, hold)
+	})
+
+	t.Run("should reject a replay with a different amount", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		paymentHoldDao := new(mocks.PaymentHoldDao)
+		service := &PaymentHoldServiceImpl{
+			userAccountDao: userAccountDao,
+			paymentHoldDao: paymentHoldDao,
+		}
+
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(&model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}, nil).Once()
+		paymentHoldDao.On("GetHoldByBizId", ctx, bizId).Return(&model.PaymentHold{ID: 3, AccountId: 1, BizId: bizId, Amount: 100}, nil).Once()
+
+		hold, err := service.AuthorizePayment(ctx, userId, bizId, 150, time.Minute)
+		assert.Equal(t, paymentpb.RespCode_IDEMPOTENCY_KEY_CONFLICT, bizerror.RespCodeOf(err))
+		assert.Nil(t, hold)
+	})
+
+	t.Run("should return the hold of a concurrent request that won the race", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		paymentHoldDao := new(mocks.PaymentHoldDao)
+		service := &PaymentHoldServiceImpl{
+			userAccountDao: userAccountDao,
+			paymentHoldDao: paymentHoldDao,
+			txBeginner:     &fakeTx{DB: initMemDb(t)},
+			ledger:         newLedgerMock(),
+		}
+
+		winner := &model.PaymentHold{ID: 3, AccountId: 1, UserId: userId, BizId: bizId, Amount: 150, Status: model.HoldStatusAuthorized}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(&model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}, nil).Once()
+		paymentHoldDao.On("GetHoldByBizId", ctx, bizId).Return(nil, nil).Once()
+		paymentHoldDao.On("CreateHoldInTransaction", ctx, mock.Anything, mock.Anything).Return(gorm.ErrDuplicatedKey).Once()
+		paymentHoldDao.On("GetHoldByBizId", ctx, bizId).Return(winner, nil).Once()
+
+		hold, err := service.AuthorizePayment(ctx, userId, bizId, 150, time.Minute)
+		assert.Equal(t, paymentpb.RespCode_DUPLICATE_REQUEST, bizerror.RespCodeOf(err))
+		assert.Equal(t, winner, hold)
+		paymentHoldDao.AssertExpectations(t)
+	})
+}
+
+func TestCapturePayment(t *testing.T) {
+	ctx := context.Background()
+	userId := 1
+	bizId := "test-biz-id"
+	initEnv()
+
+	newHold := func() *model.PaymentHold {
+		return &model.PaymentHold{ID: 3, AccountId: 1, UserId: userId, BizId: bizId, Amount: 150, Status: model.HoldStatusAuthorized, ExpiresAt: time.Now().Add(time.Minute)}
+	}
+
+	t.Run("should capture part of the hold and release the rest", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		paymentHoldDao := new(mocks.PaymentHoldDao)
+		service := &PaymentHoldServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			paymentHoldDao:          paymentHoldDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+		}
+
+		amount := 100
+		paymentHoldDao.On("GetHoldByBizId", ctx, bizId).Return(newHold(), nil).Once()
+		paymentHoldDao.On("UpdateHoldStatusInTransaction", ctx, mock.Anything, model.HoldStatusAuthorized, mock.Anything).Return(1, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.MatchedBy(func(l *model.UserAccountChangeLog) bool {
+			return l.OpType == model.OpTypeCapture
+		}), mock.Anything).Return(nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.MatchedBy(func(l *model.UserAccountChangeLog) bool {
+			return l.OpType == model.OpTypeVoid && l.Amount == 50 && l.BalanceBefore == 20 && l.BalanceAfter == 70
+		}), mock.Anything).Return(nil).Once()
+		userAccountDao.On("LockUserAccountInTransaction", ctx, userId, mock.Anything).Return(&model.UserAccount{ID: 1, UserId: userId, Balance: 20, Status: model.UserAccountStatusActive}, nil).Once()
+		userAccountDao.On("SettleFrozenBalanceInTransaction", ctx, userId, 100, 50, mock.Anything).Return(1, nil).Once()
+
+		hold, changeLog, err := service.CapturePayment(ctx, bizId, &amount)
+		assert.NoError(t, err)
+		assert.Equal(t, model.HoldStatusCaptured, hold.Status)
+		assert.Equal(t, 100, hold.CapturedAmount)
+		assert.Equal(t, model.OpTypeCapture, changeLog.OpType)
+		assert.Equal(t, 100, changeLog.Amount)
+		assert.Equal(t, 20, changeLog.BalanceBefore)
+		assert.Equal(t, 20, changeLog.BalanceAfter)
+		userAccountChangeLogDao.AssertExpectations(t)
+	})
+
+	t.Run("should capture the whole hold by default", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		paymentHoldDao := new(mocks.PaymentHoldDao)
+		service := &PaymentHoldServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			paymentHoldDao:          paymentHoldDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+		}
+
+		paymentHoldDao.On("GetHoldByBizId", ctx, bizId).Return(newHold(), nil).Once()
+		paymentHoldDao.On("UpdateHoldStatusInTransaction", ctx, mock.Anything, model.HoldStatusAuthorized, mock.Anything).Return(1, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+		userAccountDao.On("LockUserAccountInTransaction", ctx, userId, mock.Anything).Return(&model.UserAccount{ID: 1, UserId: userId, Balance: 20, Status: model.UserAccountStatusActive}, nil).Once()
+		userAccountDao.On("SettleFrozenBalanceInTransaction", ctx, userId, 150, 0, mock.Anything).Return(1, nil).Once()
+
+		hold, _, err := service.CapturePayment(ctx, bizId, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 150, hold.CapturedAmount)
+	})
+
+	t.Run("should return error if capture exceeds hold", func(t *testing.T) {
+		paymentHoldDao := new(mocks.PaymentHoldDao)
+		service := &PaymentHoldServiceImpl{
+			paymentHoldDao: paymentHoldDao,
+		}
+
+		amount := 151
+		paymentHoldDao.On("GetHoldByBizId", ctx, bizId).Return(newHold(), nil).Once()
+
+		_, _, err := service.CapturePayment(ctx, bizId, &amount)
+		bizErr, ok := err.(*bizerror.BizError)
+		assert.True(t, ok)
+		assert.Equal(t, int(paymentpb.RespCode_CAPTURE_AMOUNT_EXCEEDED), bizErr.Code)
+	})
+
+	t.Run("should return error if hold expired", func(t *testing.T) {
+		paymentHoldDao := new(mocks.PaymentHoldDao)
+		service := &PaymentHoldServiceImpl{
+			paymentHoldDao: paymentHoldDao,
+		}
+
+		hold := newHold()
+		hold.ExpiresAt = time.Now().Add(-time.Second)
+		paymentHoldDao.On("GetHoldByBizId", ctx, bizId).Return(hold, nil).Once()
+
+		_, _, err := service.CapturePayment(ctx, bizId, nil)
+		bizErr, ok := err.(*bizerror.BizError)
+		assert.True(t, ok)
+		assert.Equal(t, int(paymentpb.RespCode_HOLD_NOT_ACTIVE), bizErr.Code)
+	})
+
+	t.Run("should return error if hold settled concurrently", func(t *testing.T) {
+		paymentHoldDao := new(mocks.PaymentHoldDao)
+		service := &PaymentHoldServiceImpl{
+			paymentHoldDao: paymentHoldDao,
+			txBeginner:     &fakeTx{DB: initMemDb(t)},
+			ledger:         newLedgerMock(),
+		}
+
+		paymentHoldDao.On("GetHoldByBizId", ctx, bizId).Return(newHold(), nil).Once()
+		paymentHoldDao.On("UpdateHoldStatusInTransaction", ctx, mock.Anything, model.HoldStatusAuthorized, mock.Anything).Return(0, nil).Once()
+
+		_, _, err := service.CapturePayment(ctx, bizId, nil)
+		bizErr, ok := err.(*bizerror.BizError)
+		assert.True(t, ok)
+		assert.Equal(t, int(paymentpb.RespCode_HOLD_NOT_ACTIVE), bizErr.Code)
+	})
+}
+
+func TestVoidPayment(t *testing.T) {
+	ctx := context.Background()
+	userId := 1
+	bizId := "test-biz-id"
+	initEnv()
+
+	t.Run("should release the whole hold", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		paymentHoldDao := new(mocks.PaymentHoldDao)
+		service := &PaymentHoldServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			paymentHoldDao:          paymentHoldDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+		}
+
+		hold := &model.PaymentHold{ID: 3, AccountId: 1, UserId: userId, BizId: bizId, Amount: 150, Status: model.HoldStatusAuthorized, ExpiresAt: time.Now().Add(time.Minute)}
+		paymentHoldDao.On("GetHoldByBizId", ctx, bizId).Return(hold, nil).Once()
+		paymentHoldDao.On("UpdateHoldStatusInTransaction", ctx, mock.Anything, model.HoldStatusAuthorized, mock.Anything).Return(1, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.MatchedBy(func(l *model.UserAccountChangeLog) bool {
+			return l.OpType == model.OpTypeVoid && l.Amount == 150 && l.BalanceBefore == 20 && l.BalanceAfter == 170
+		}), mock.Anything).Return(nil).Once()
+		userAccountDao.On("LockUserAccountInTransaction", ctx, userId, mock.Anything).Return(&model.UserAccount{ID: 1, UserId: userId, Balance: 20, Status: model.UserAccountStatusActive}, nil).Once()
+		userAccountDao.On("SettleFrozenBalanceInTransaction", ctx, userId, 0, 150, mock.Anything).Return(1, nil).Once()
+
+		voided, err := service.VoidPayment(ctx, bizId)
+		assert.NoError(t, err)
+		assert.Equal(t, model.HoldStatusVoided, voided.Status)
+	})
+
+	t.Run("should return error if hold not found", func(t *testing.T) {
+		paymentHoldDao := new(mocks.PaymentHoldDao)
+		service := &PaymentHoldServiceImpl{
+			paymentHoldDao: paymentHoldDao,
+		}
+
+		paymentHoldDao.On("GetHoldByBizId", ctx, bizId).Return(nil, nil).Once()
+
+		_, err := service.VoidPayment(ctx, bizId)
+		bizErr, ok := err.(*bizerror.BizError)
+		assert.True(t, ok)
+		assert.Equal(t, int(paymentpb.RespCode_HOLD_NOT_EXIST), bizErr.Code)
+	})
+}
+
+func TestReleaseExpiredHolds(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+
+	userAccountDao := new(mocks.UserAccountDao)
+	userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+	paymentHoldDao := new(mocks.PaymentHoldDao)
+	service := &PaymentHoldServiceImpl{
+		userAccountDao:          userAccountDao,
+		userAccountChangeLogDao: userAccountChangeLogDao,
+		paymentHoldDao:          paymentHoldDao,
+		txBeginner:              &fakeTx{DB: initMemDb(t)},
+		ledger:                  newLedgerMock(),
+	}
+
+	holds := []*model.PaymentHold{
+		{ID: 1, AccountId: 1, UserId: 1, BizId: "biz-1", Amount: 10, Status: model.HoldStatusAuthorized},
+		{ID: 2, AccountId: 2, UserId: 2, BizId: "biz-2", Amount: 20, Status: model.HoldStatusAuthorized},
+	}
+	paymentHoldDao.On("QueryExpiredHolds", ctx, mock.Anything, mock.Anything).Return(holds, nil).Once()
+	paymentHoldDao.On("UpdateHoldStatusInTransaction", ctx, holds[0], model.HoldStatusAuthorized, mock.Anything).Return(1, nil).Once()
+	paymentHoldDao.On("UpdateHoldStatusInTransaction", ctx, holds[1], model.HoldStatusAuthorized, mock.Anything).Return(0, nil).Once()
+	userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+	userAccountDao.On("LockUserAccountInTransaction", ctx, 1, mock.Anything).Return(&model.UserAccount{ID: 1, UserId: 1, Balance: 20, Status: model.UserAccountStatusActive}, nil).Once()
+	userAccountDao.On("SettleFrozenBalanceInTransaction", ctx, 1, 0, 10, mock.Anything).Return(1, nil).Once()
+
+	released, err := service.ReleaseExpiredHolds(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, released)
+	assert.Equal(t, model.HoldStatusExpired, holds[0].Status)
+}
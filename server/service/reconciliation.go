@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/metrics"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/utils"
+	"gorm.io/gorm"
+)
+
+type ReconciliationService interface {
+	// Reconcile checks every account balance and every used redeem code, stores the discrepancies
+	// found under a new run id and publishes their count per kind.
+	Reconcile(ctx context.Context) (*ReconciliationReport, error)
+}
+
+type ReconciliationReport struct {
+	RunId              string
+	AccountsChecked    int
+	RedeemCodesChecked int
+	Discrepancies      []*model.ReconciliationDiscrepancy
+}
+
+var (
+	reconciliationServiceInstance ReconciliationService
+	reconciliationServiceOnce     sync.Once
+)
+
+func GetReconciliationService() ReconciliationService {
+	reconciliationServiceOnce.Do(func() {
+		reconciliationServiceInstance = &ReconciliationServiceImpl{
+			userAccountDao:          dao.GetUserAccountDao(),
+			userAccountChangeLogDao: dao.GetUserAccountChangeLogDAO(),
+			redeemCodeDao:           dao.GetRedeemCodeDao(),
+			ledgerDao:               dao.GetLedgerDao(),
+			reconciliationDao:       dao.GetReconciliationDao(),
+			txBeginner:              repository.DB,
+		}
+	})
+	return reconciliationServiceInstance
+}
+
+type ReconciliationServiceImpl struct {
+	userAccountDao          dao.UserAccountDao
+	userAccountChangeLogDao dao.UserAccountChangeLogDAO
+	redeemCodeDao           dao.RedeemCodeDao
+	ledgerDao               dao.LedgerDao
+	reconciliationDao       dao.ReconciliationDao
+	txBeginner              repository.TxBeginner
+}
+
+const reconciliationRunIdRandomSize = 6
+
+// Reconcile implements ReconciliationService.
+func (r *ReconciliationServiceImpl) Reconcile(ctx context.Context) (*ReconciliationReport, error) {
+	report := &ReconciliationReport{RunId: time.Now().Format("20060102150405") + utils.GenRedeemCode(reconciliationRunIdRandomSize)}
+	if err := r.reconcileAccounts(ctx, report); err != nil {
+		log.Logger.Errorf("Reconciliation %s failed to check account balances: %v", report.RunId, err)
+		return nil, err
+	}
+	if err := r.reconcileRedeemCodes(ctx, report); err != nil {
+		log.Logger.Errorf("Reconciliation %s failed to check redeem codes: %v", report.RunId, err)
+		return nil, err
+	}
+	counts := make(map[int]int, len(model.DiscrepancyKindNames))
+	for _, discrepancy := range report.Discrepancies {
+		discrepancy.RunId = report.RunId
+		counts[discrepancy.Kind]++
+		log.Logger.Warnf("Reconciliation %s found discrepancy: %s", report.RunId, discrepancy.Detail)
+	}
+	if len(report.Discrepancies) > 0 {
+		if err := r.reconciliationDao.CreateDiscrepancies(ctx, report.Discrepancies); err != nil {
+			return nil, err
+		}
+	}
+	for kind, name := range model.DiscrepancyKindNames {
+		metrics.ReconciliationDiscrepancies.WithLabelValues(name).Set(float64(counts[kind]))
+	}
+	log.Logger.Infof("Reconciliation %s checked %d accounts and %d redeem codes, found %d discrepancies",
+		report.RunId, report.AccountsChecked, report.RedeemCodesChecked, len(report.Discrepancies))
+	return report, nil
+}
+
+// reconcileAccounts compares every balance with its change logs and its wallet. Accounts are read
+// without locks, so a mismatch is only reported if it is still there with the account locked.
+func (r *ReconciliationServiceImpl) reconcileAccounts(ctx context.Context, report *ReconciliationReport) error {
+	lastAccountId := 0
+	for {
+		accounts, err := r.userAccountDao.QueryUserAccounts(ctx, lastAccountId, repository.DefaultQueryLimit)
+		if err != nil {
+			return err
+		}
+		if len(accounts) == 0 {
+			break
+		}
+		var suspects []*model.ReconciliationDiscrepancy
+		err = r.txBeginner.Transaction(func(tx *gorm.DB) error {
+			suspects, err = r.checkBalancesInTransaction(ctx, accounts, tx)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		rechecked := make(map[int]bool)
+		for _, suspect := range suspects {
+			if rechecked[suspect.AccountId] {
+				continue
+			}
+			rechecked[suspect.AccountId] = true
+			confirmed, err := r.recheckBalance(ctx, accounts, suspect.AccountId)
+			if err != nil {
+				return err
+			}
+			report.Discrepancies = append(report.Discrepancies, confirmed...)
+		}
+		report.AccountsChecked += len(accounts)
+		if len(accounts) < repository.DefaultQueryLimit {
+			break
+		}
+		lastAccountId = accounts[len(accounts)-1].ID
+	}
+	return nil
+}
+
+func (r *ReconciliationServiceImpl) recheckBalance(ctx context.Context, accounts []*model.UserAccount, accountId int) ([]*model.ReconciliationDiscrepancy, error) {
+	var userId int
+	for _, account := range accounts {
+		if account.ID == accountId {
+			userId = account.UserId
+		}
+	}
+	var confirmed []*model.ReconciliationDiscrepancy
+	err := r.txBeginner.Transaction(func(tx *gorm.DB) error {
+		account, err := r.userAccountDao.LockUserAccountInTransaction(ctx, userId, tx)
+		if err != nil || account == nil {
+			return err
+		}
+		confirmed, err = r.checkBalancesInTransaction(ctx, []*model.UserAccount{account}, tx)
+		return err
+	})
+	return confirmed, err
+}
+
+func (r *ReconciliationServiceImpl) checkBalancesInTransaction(ctx context.Context, accounts []*model.UserAccount, tx *gorm.DB) ([]*model.ReconciliationDiscrepancy, error) {
+	accountIds := make([]int, len(accounts))
+	for i, account := range accounts {
+		accountIds[i] = account.ID
+	}
+	sums, err := r.userAccountChangeLogDao.SumAmountsByAccountsInTransaction(ctx, accountIds, tx)
+	if err != nil {
+		return nil, err
+	}
+	replayed := make(map[int]int, len(accounts))
+	for _, sum := range sums {
+		replayed[sum.AccountId] += (&model.UserAccountChangeLog{OpType: sum.OpType, Amount: sum.Amount}).BalanceDelta()
+	}
+	walletSums, err := r.ledgerDao.SumWalletLinesInTransaction(ctx, accountIds, tx)
+	if err != nil {
+		return nil, err
+	}
+	// wallets are liabilities, credits increase them
+	wallets := make(map[int]int, len(walletSums))
+	for _, sum := range walletSums {
+		wallets[sum.UserAccountId] = sum.Credit - sum.Debit
+	}
+	var discrepancies []*model.ReconciliationDiscrepancy
+	for _, account := range accounts {
+		if replayed[account.ID] != account.Balance {
+			discrepancies = append(discrepancies, &model.ReconciliationDiscrepancy{
+				Kind:      model.DiscrepancyKindChangeLogBalance,
+				AccountId: account.ID,
+				Expected:  replayed[account.ID],
+				Actual:    account.Balance,
+				Detail:    fmt.Sprintf("account %d has balance %d, its change logs add up to %d", account.ID, account.Balance, replayed[account.ID]),
+			})
+		}
+		// an account joins the ledger with its first posting
+		if wallet, ok := wallets[account.ID]; ok && wallet != account.Balance {
+			discrepancies = append(discrepancies, &model.ReconciliationDiscrepancy{
+				Kind:      model.DiscrepancyKindLedgerBalance,
+				AccountId: account.ID,
+				Expected:  wallet,
+				Actual:    account.Balance,
+				Detail:    fmt.Sprintf("account %d has balance %d, its wallet holds %d", account.ID, account.Balance, wallet),
+			})
+		}
+	}
+	return discrepancies, nil
+}
+
+// reconcileRedeemCodes checks that every used redeem code has exactly one top-up of its amount
+// on the account of the user who used it.
+func (r *ReconciliationServiceImpl) reconcileRedeemCodes(ctx context.Context, report *ReconciliationReport) error {
+	var lastCodeId uint
+	for {
+		redeemCodes, err := r.redeemCodeDao.QueryUsedRedeemCodes(ctx, lastCodeId, repository.DefaultQueryLimit)
+		if err != nil {
+			return err
+		}
+		if len(redeemCodes) == 0 {
+			break
+		}
+		discrepancies, err := r.checkRedeemCodes(ctx, redeemCodes)
+		if err != nil {
+			return err
+		}
+		report.Discrepancies = append(report.Discrepancies, discrepancies...)
+		report.RedeemCodesChecked += len(redeemCodes)
+		if len(redeemCodes) < repository.DefaultQueryLimit {
+			break
+		}
+		lastCodeId = redeemCodes[len(redeemCodes)-1].ID
+	}
+	return nil
+}
+
+func (r *ReconciliationServiceImpl) checkRedeemCodes(ctx context.Context, redeemCodes []*model.RedeemCode) ([]*model.ReconciliationDiscrepancy, error) {
+	keys := make([]string, len(redeemCodes))
+	userIds := make([]int, len(redeemCodes))
+	for i, redeemCode := range redeemCodes {
+		keys[i] = redeemCode.TopUpIdempotentKey(redeemCode.UsedUserId, 0)
+		userIds[i] = redeemCode.UsedUserId
+	}
+	topUpLogs, err := r.userAccountChangeLogDao.QueryChangeLogsByIdempotentKeys(ctx, keys, model.OpTypeTopUp)
+	if err != nil {
+		return nil, err
+	}
+	topUps := make(map[string][]*model.UserAccountChangeLog, len(topUpLogs))
+	for _, topUpLog := range topUpLogs {
+		topUps[topUpLog.IdempotentKey] = append(topUps[topUpLog.IdempotentKey], topUpLog)
+	}
+	accounts, err := r.userAccountDao.QueryUserAccountsByUserIDs(ctx, userIds)
+	if err != nil {
+		return nil, err
+	}
+	accountIds := make(map[int]int, len(accounts))
+	for _, account := range accounts {
+		accountIds[account.UserId] = account.ID
+	}
+	var discrepancies []*model.ReconciliationDiscrepancy
+	for i, redeemCode := range redeemCodes {
+		discrepancy := &model.ReconciliationDiscrepancy{
+			Kind:         model.DiscrepancyKindRedeemCodeTopUp,
+			AccountId:    accountIds[redeemCode.UsedUserId],
+			RedeemCodeId: int(redeemCode.ID),
+			Expected:     redeemCode.Amount,
+		}
+		matches := topUps[keys[i]]
+		for _, topUpLog := range matches {
+			discrepancy.Actual += topUpLog.Amount
+		}
+		switch {
+		case len(matches) != 1:
+			discrepancy.Detail = fmt.Sprintf("redeem code %d used by user %d has %d top-ups", redeemCode.ID, redeemCode.UsedUserId, len(matches))
+		case matches[0].Amount != redeemCode.Amount:
+			discrepancy.Detail = fmt.Sprintf("redeem code %d of amount %d topped up %d", redeemCode.ID, redeemCode.Amount, matches[0].Amount)
+		case matches[0].AccountId != accountIds[redeemCode.UsedUserId]:
+			discrepancy.AccountId = matches[0].AccountId
+			discrepancy.Detail = fmt.Sprintf("redeem code %d used by user %d topped up account %d", redeemCode.ID, redeemCode.UsedUserId, matches[0].AccountId)
+		default:
+			continue
+		}
+		discrepancies = append(discrepancies, discrepancy)
+	}
+	return discrepancies, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao/mocks"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+)
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+
+	type daos struct {
+		userAccountDao          *mocks.UserAccountDao
+		userAccountChangeLogDao *mocks.UserAccountChangeLogDAO
+		redeemCodeDao           *mocks.RedeemCodeDao
+		ledgerDao               *mocks.LedgerDao
+		reconciliationDao       *mocks.ReconciliationDao
+	}
+	newService := func(t *testing.T) (*ReconciliationServiceImpl, daos) {
+		d := daos{
+			userAccountDao:          new(mocks.UserAccountDao),
+			userAccountChangeLogDao: new(mocks.UserAccountChangeLogDAO),
+			redeemCodeDao:           new(mocks.RedeemCodeDao),
+			ledgerDao:               new(mocks.LedgerDao),
+			reconciliationDao:       new(mocks.ReconciliationDao),
+		}
+		return &ReconciliationServiceImpl{
+			userAccountDao:          d.userAccountDao,
+			userAccountChangeLogDao: d.userAccountChangeLogDao,
+			redeemCodeDao:           d.redeemCodeDao,
+			ledgerDao:               d.ledgerDao,
+			reconciliationDao:       d.reconciliationDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+		}, d
+	}
+	// account 1 topped up 100 and paid 30, account 2 has never been posted to the ledger
+	accounts := []*model.UserAccount{{ID: 1, UserId: 11, Balance: 70}, {ID: 2, UserId: 12, Balance: 0}}
+	sums := []*model.ChangeLogAmountSum{{AccountId: 1, OpType: model.OpTypeTopUp, Amount: 100}, {AccountId: 1, OpType: model.OpTypePayment, Amount: 30}}
+	wallets := []*model.LedgerAccountSum{{UserAccountId: 1, Debit: 30, Credit: 100}}
+
+	t.Run("should find nothing when records agree", func(t *testing.T) {
+		service, d := newService(t)
+		d.userAccountDao.On("QueryUserAccounts", ctx, 0, mock.Anything).Return(accounts, nil).Once()
+		d.userAccountChangeLogDao.On("SumAmountsByAccountsInTransaction", ctx, []int{1, 2}, mock.Anything).Return(sums, nil).Once()
+		d.ledgerDao.On("SumWalletLinesInTransaction", ctx, []int{1, 2}, mock.Anything).Return(wallets, nil).Once()
+		d.redeemCodeDao.On("QueryUsedRedeemCodes", ctx, uint(0), mock.Anything).Return([]*model.RedeemCode{{ID: 5, Amount: 100, UsedUserId: 11}}, nil).Once()
+		d.userAccountChangeLogDao.On("QueryChangeLogsByIdempotentKeys", ctx, []string{"rc_5"}, model.OpTypeTopUp).
+			Return([]*model.UserAccountChangeLog{{AccountId: 1, OpType: model.OpTypeTopUp, Amount: 100, IdempotentKey: "rc_5"}}, nil).Once()
+		d.userAccountDao.On("QueryUserAccountsByUserIDs", ctx, []int{11}).Return(accounts[:1], nil).Once()
+
+		report, err := service.Reconcile(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, report.AccountsChecked)
+		assert.Equal(t, 1, report.RedeemCodesChecked)
+		assert.Empty(t, report.Discrepancies)
+		d.reconciliationDao.AssertNotCalled(t, "CreateDiscrepancies", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should record balance mismatches still there with the account locked", func(t *testing.T) {
+		service, d := newService(t)
+		drifted := &model.UserAccount{ID: 1, UserId: 11, Balance: 90}
+		d.userAccountDao.On("QueryUserAccounts", ctx, 0, mock.Anything).Return([]*model.UserAccount{drifted}, nil).Once()
+		d.userAccountChangeLogDao.On("SumAmountsByAccountsInTransaction", ctx, []int{1}, mock.Anything).Return(sums, nil).Twice()
+		d.ledgerDao.On("SumWalletLinesInTransaction", ctx, []int{1}, mock.Anything).Return(wallets, nil).Twice()
+		d.userAccountDao.On("LockUserAccountInTransaction", ctx, 11, mock.Anything).Return(drifted, nil).Once()
+		d.redeemCodeDao.On("QueryUsedRedeemCodes", ctx, uint(0), mock.Anything).Return(nil, nil).Once()
+		d.reconciliationDao.On("CreateDiscrepancies", ctx, mock.MatchedBy(func(discrepancies []*model.ReconciliationDiscrepancy) bool {
+			return len(discrepancies) == 2 && discrepancies[0].RunId != "" &&
+				discrepancies[0].Kind == model.DiscrepancyKindChangeLogBalance && discrepancies[0].Expected == 70 && discrepancies[0].Actual == 90 &&
+				discrepancies[1].Kind == model.DiscrepancyKindLedgerBalance && discrepancies[1].Expected == 70
+		})).Return(nil).Once()
+
+		report, err := service.Reconcile(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, report.Discrepancies, 2)
+		d.reconciliationDao.AssertExpectations(t)
+	})
+
+	t.Run("should drop mismatches caused by concurrent updates", func(t *testing.T) {
+		service, d := newService(t)
+		stale := &model.UserAccount{ID: 1, UserId: 11, Balance: 100}
+		d.userAccountDao.On("QueryUserAccounts", ctx, 0, mock.Anything).Return([]*model.UserAccount{stale}, nil).Once()
+		d.userAccountChangeLogDao.On("SumAmountsByAccountsInTransaction", ctx, []int{1}, mock.Anything).Return(sums, nil).Twice()
+		d.ledgerDao.On("SumWalletLinesInTransaction", ctx, []int{1}, mock.Anything).Return(wallets, nil).Twice()
+		d.userAccountDao.On("LockUserAccountInTransaction", ctx, 11, mock.Anything).Return(&model.UserAccount{ID: 1, UserId: 11, Balance: 70}, nil).Once()
+		d.redeemCodeDao.On("QueryUsedRedeemCodes", ctx, uint(0), mock.Anything).Return(nil, nil).Once()
+
+		report, err := service.Reconcile(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, report.Discrepancies)
+	})
+
+	t.Run("should flag used redeem codes without a matching top-up", func(t *testing.T) {
+		service, d := newService(t)
+		d.userAccountDao.On("QueryUserAccounts", ctx, 0, mock.Anything).Return(nil, nil).Once()
+		redeemCodes := []*model.RedeemCode{
+			{ID: 5, Amount: 100, UsedUserId: 11},
+			{ID: 6, Amount: 50, UsedUserId: 11},
+			{ID: 7, Amount: 20, UsedUserId: 12},
+		}
+		d.redeemCodeDao.On("QueryUsedRedeemCodes", ctx, uint(0), mock.Anything).Return(redeemCodes, nil).Once()
+		d.userAccountChangeLogDao.On("QueryChangeLogsByIdempotentKeys", ctx, []string{"rc_5", "rc_6", "rc_7"}, model.OpTypeTopUp).
+			Return([]*model.UserAccountChangeLog{
+				{AccountId: 1, OpType: model.OpTypeTopUp, Amount: 100, IdempotentKey: "rc_5"},
+				{AccountId: 1, OpType: model.OpTypeTopUp, Amount: 20, IdempotentKey: "rc_7"},
+			}, nil).Once()
+		d.userAccountDao.On("QueryUserAccountsByUserIDs", ctx, []int{11, 11, 12}).Return(accounts, nil).Once()
+		d.reconciliationDao.On("CreateDiscrepancies", ctx, mock.Anything).Return(nil).Once()
+
+		report, err := service.Reconcile(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, report.Discrepancies, 2)
+		// code 6 was never topped up, code 7 went to the account of another user
+		assert.Equal(t, 6, report.Discrepancies[0].RedeemCodeId)
+		assert.Equal(t, 0, report.Discrepancies[0].Actual)
+		assert.Equal(t, 7, report.Discrepancies[1].RedeemCodeId)
+		assert.Equal(t, 1, report.Discrepancies[1].AccountId)
+	})
+}
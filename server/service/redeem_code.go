@@ -2,19 +2,53 @@ package service
 
 import (
 	"context"
+	"errors"
+	"io"
 	"sync"
 	"time"
 
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/http/data"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/utils"
+	"gorm.io/gorm"
 )
 
 type RedeemCodeService interface {
-	GenerateRedeemCodes(ctx context.Context, amount int, quantity int) error
+	// GenerateRedeemCodes creates the batch together with batch.Quantity codes worth batch.Amount, usable within
+	// the validity window of the batch. Only their hashes are stored, the result is the one place the codes appear.
+	GenerateRedeemCodes(ctx context.Context, batch *model.RedeemCodeBatch) (*data.RedeemCodeGenResult, error)
+	// CreatePromoCode creates the batch together with one promo code worth batch.Amount per redemption. The code is
+	// generated when empty, and only returned by this call.
+	CreatePromoCode(ctx context.Context, batch *model.RedeemCodeBatch, code string, maxRedemptions int, perUserLimit int) (*data.RedeemCodeVO, error)
 	QueryRedeemCodes(ctx context.Context, query *data.RedeemCodeQuery) ([]*data.RedeemCodeVO, error)
+	// GetRedeemCodeDetails returns everything known about the code for support staff: its batch, who used it and
+	// when, or the latest redemptions of a promo code.
+	GetRedeemCodeDetails(ctx context.Context, code string) (*data.RedeemCodeDetailVO, error)
+	// ExportRedeemCodes streams every code matching the query to w as CSV or JSON Lines, one chunk at a time,
+	// and returns how many codes were written. Codes generated by a job are written in plaintext by the first
+	// export that includes them, later exports only carry their suffix.
+	ExportRedeemCodes(ctx context.Context, query *data.RedeemCodeExportQuery, w io.Writer) (int, error)
+	// QueryRedeemCodeBatches lists batches newest first with their redemption progress.
+	QueryRedeemCodeBatches(ctx context.Context, query *data.RedeemCodeBatchQuery) (*data.RedeemCodeBatchPage, error)
+	// RevokeRedeemCodeBatch revokes every unused code of the batch and returns how many were revoked.
+	RevokeRedeemCodeBatch(ctx context.Context, batchId uint) (int, error)
+	// StartGenerationJob creates the batch empty and generates its codes in the background, chunk by chunk.
+	// The id of the batch doubles as the id of the job.
+	StartGenerationJob(ctx context.Context, batch *model.RedeemCodeBatch) (*data.RedeemCodeGenJobVO, error)
+	GetGenerationJob(ctx context.Context, batchId uint) (*data.RedeemCodeGenJobVO, error)
+	// ResumeGenerationJobs carries on every generation job left unfinished, e.g. by a crash, and returns how many
+	// it completed.
+	ResumeGenerationJobs(ctx context.Context) (int, error)
+	// HashPlaintextRedeemCodes hashes every code stored in plaintext before codes were hashed, together with the
+	// idempotent keys of their top-ups, and encrypts the plaintext of job generated codes left by earlier versions.
+	// It returns how many codes it took the plaintext of.
+	HashPlaintextRedeemCodes(ctx context.Context) (int, error)
 }
 
 var (
@@ -25,56 +59,79 @@ var (
 func GetRedeemCodeService() RedeemCodeService {
 	redeemCodeServiceOnce.Do(func() {
 		redeemCodeServiceInstance = &RedeemCodeServiceImpl{
-			redeemCodeDao: dao.GetRedeemCodeDao(),
+			redeemCodeDao:           dao.GetRedeemCodeDao(),
+			userAccountChangeLogDao: dao.GetUserAccountChangeLogDAO(),
+			genJobLimit:             newGenJobLimit(config.Config.PaymentConfig),
+			codeHashSecret:          redeemCodeHashSecret(config.Config.PaymentConfig),
+			codeEncryptionKey:       redeemCodeEncryptionKey(config.Config.PaymentConfig),
+			codeFormat:              newRedeemCodeFormat(config.Config.PaymentConfig),
 		}
 	})
 	return redeemCodeServiceInstance
 }
 
 type RedeemCodeServiceImpl struct {
-	redeemCodeDao dao.RedeemCodeDao
+	redeemCodeDao           dao.RedeemCodeDao
+	userAccountChangeLogDao dao.UserAccountChangeLogDAO
+	genJobLimit             genJobLimit
+	codeHashSecret          []byte
+	codeEncryptionKey       []byte
+	codeFormat              *redeemCodeFormat
+	// ids of the batches a generation job of this process is working on
+	generating sync.Map
 }
 
-const redeemCodeSize = 16
+// redeemCodeHashSecret returns the key redeem codes are hashed with.
+func redeemCodeHashSecret(conf *config.PaymentConfig) []byte {
+	if conf == nil {
+		return nil
+	}
+	return []byte(conf.RedeemCodeHashSecret)
+}
+
+// redeemCodeEncryptionKey returns the key the codes of generation jobs are encrypted under until exported.
+func redeemCodeEncryptionKey(conf *config.PaymentConfig) []byte {
+	if conf == nil {
+		return nil
+	}
+	return []byte(conf.RedeemCodeEncryptionKey)
+}
 
 // GenerateRedeemCodes implements RedeemCodeService.
-func (r *RedeemCodeServiceImpl) GenerateRedeemCodes(ctx context.Context, amount int, quantity int) error {
-	toInsert := make([]*model.RedeemCode, quantity)
-	currentTime := time.Now()
-	codeSet := make(map[string]struct{})
-	for i := 0; i < quantity; i++ {
-		var code string
-		for {
-			code = utils.GenRedeemCode(redeemCodeSize)
-			if _, exists := codeSet[code]; !exists {
-				log.Logger.Infof("Generated redeem code: %s", code)
-				codeSet[code] = struct{}{}
-				break
-			}
-			log.Logger.Warnf("Duplicate redeem code generated: %s, regenerating...", code)
-		}
-		toInsert[i] = &model.RedeemCode{
-			Code:      code,
-			Amount:    amount,
-			CreatedAt: currentTime,
-			UpdatedAt: currentTime,
-		}
+func (r *RedeemCodeServiceImpl) GenerateRedeemCodes(ctx context.Context, batch *model.RedeemCodeBatch) (*data.RedeemCodeGenResult, error) {
+	toInsert, plaintexts := r.newBatchRedeemCodes(batch, batch.Quantity)
+	codes := make([]string, len(plaintexts))
+	for i, code := range plaintexts {
+		// handed out in the result, never stored
+		codes[i] = r.codeFormat.Display(code)
 	}
-	err := r.redeemCodeDao.BatchInsert(ctx, toInsert)
+	batch.Status = model.RedeemCodeBatchStatusCompleted
+	batch.GeneratedCount = batch.Quantity
+	batch.CreatedAt = time.Now()
+	err := r.redeemCodeDao.CreateBatch(ctx, batch, toInsert)
 	if err != nil {
 		log.Logger.Errorf("Failed to generate redeem codes: %v", err)
-		return err
+		return nil, err
 	}
-	log.Logger.Infof("Successfully generated %d redeem codes with amount %d each", quantity, amount)
-	return nil
+	log.Logger.Infof("Successfully generated batch %d of %d redeem codes with amount %d each", batch.ID, batch.Quantity, batch.Amount)
+	return &data.RedeemCodeGenResult{GenCount: len(codes), BatchId: batch.ID, Codes: codes}, nil
 }
 
 // QueryRedeemCodes implements RedeemCodeService.
 func (r *RedeemCodeServiceImpl) QueryRedeemCodes(ctx context.Context, query *data.RedeemCodeQuery) ([]*data.RedeemCodeVO, error) {
 	dbQuery := &model.RedeemCodeQuery{
-		Limit: query.Limit,
-		Code:  query.Code,
-		Used:  query.Used,
+		Limit:   query.Limit,
+		BatchId: query.BatchId,
+		Used:    query.Used,
+		Status:  query.Status,
+	}
+	if query.Code != nil {
+		code, err := r.codeFormat.Normalize(*query.Code)
+		if err != nil {
+			return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_MALFORMED), Message: "malformed redeem code", Err: err}
+		}
+		codeHash := utils.HashRedeemCode(r.codeHashSecret, code)
+		dbQuery.CodeHash = &codeHash
 	}
 	redeemCodes, err := r.redeemCodeDao.QueryRedeemCodes(ctx, dbQuery)
 	if err != nil {
@@ -83,14 +140,280 @@ func (r *RedeemCodeServiceImpl) QueryRedeemCodes(ctx context.Context, query *dat
 	}
 	result := make([]*data.RedeemCodeVO, len(redeemCodes))
 	for i, rc := range redeemCodes {
-		result[i] = &data.RedeemCodeVO{
-			Id:         int(rc.ID),
-			Code:       rc.Code,
-			Amount:     int(rc.Amount),
-			UsedUserId: rc.UsedUserId,
-			CreatedAt:  rc.CreatedAt.Unix(),
-			UpdatedAt:  rc.UpdatedAt.Unix(),
-		}
+		result[i] = toRedeemCodeVO(rc)
 	}
 	return result, nil
 }
+
+func toRedeemCodeVO(rc *model.RedeemCode) *data.RedeemCodeVO {
+	vo := &data.RedeemCodeVO{
+		Id:             int(rc.ID),
+		CodeSuffix:     rc.CodeSuffix,
+		Type:           rc.Type,
+		Amount:         int(rc.Amount),
+		UsedUserId:     rc.UsedUserId,
+		MaxRedemptions: rc.MaxRedemptions,
+		PerUserLimit:   rc.PerUserLimit,
+		RedeemedCount:  rc.RedeemedCount,
+		BatchId:        rc.BatchId,
+		Revoked:        rc.RevokedAt != nil,
+		CreatedAt:      rc.CreatedAt.Unix(),
+		UpdatedAt:      rc.UpdatedAt.Unix(),
+	}
+	if rc.ValidFrom != nil {
+		vo.ValidFrom = rc.ValidFrom.Unix()
+	}
+	if rc.ValidUntil != nil {
+		vo.ValidUntil = rc.ValidUntil.Unix()
+	}
+	return vo
+}
+
+// redeemCodeDetailRedemptions is how many of the latest redemptions of a promo code its details list.
+const redeemCodeDetailRedemptions = 20
+
+// GetRedeemCodeDetails implements RedeemCodeService.
+func (r *RedeemCodeServiceImpl) GetRedeemCodeDetails(ctx context.Context, code string) (*data.RedeemCodeDetailVO, error) {
+	code, err := r.codeFormat.Normalize(code)
+	if err != nil {
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_MALFORMED), Message: "malformed redeem code", Err: err}
+	}
+	rc, err := r.redeemCodeDao.GetByCode(ctx, utils.HashRedeemCode(r.codeHashSecret, code))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get redeem code", Err: err}
+	}
+	if rc == nil {
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_INVALID), Message: "invalid redeem code"}
+	}
+	details := &data.RedeemCodeDetailVO{RedeemCodeVO: *toRedeemCodeVO(rc), Status: rc.StatusAt(time.Now())}
+	if rc.RevokedAt != nil {
+		details.RevokedAt = rc.RevokedAt.Unix()
+	}
+	if rc.BatchId != 0 {
+		batch, err := r.redeemCodeDao.GetBatch(ctx, rc.BatchId)
+		if err != nil {
+			return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get redeem code batch", Err: err}
+		}
+		if batch != nil {
+			progress, err := r.redeemCodeDao.QueryBatchProgress(ctx, []uint{batch.ID})
+			if err != nil {
+				return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to query redeem code batch progress", Err: err}
+			}
+			var batchProgress *model.RedeemCodeBatchProgress
+			if len(progress) > 0 {
+				batchProgress = progress[0]
+			}
+			details.Batch = toRedeemCodeBatchVO(batch, batchProgress)
+		}
+	}
+	if rc.IsPromo() {
+		redemptions, err := r.redeemCodeDao.QueryRedemptions(ctx, rc.ID, redeemCodeDetailRedemptions)
+		if err != nil {
+			return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to query redemptions", Err: err}
+		}
+		details.Redemptions = make([]*data.RedeemCodeRedemptionVO, len(redemptions))
+		for i, redemption := range redemptions {
+			details.Redemptions[i] = &data.RedeemCodeRedemptionVO{
+				UserId:      redemption.UserId,
+				Seq:         redemption.Seq,
+				Amount:      redemption.Amount,
+				ChangeLogId: redemption.ChangeLogId,
+				RedeemedAt:  redemption.CreatedAt.Unix(),
+			}
+		}
+	} else if rc.UsedUserId != 0 {
+		// the code does not keep the time it was used, its top-up does
+		topUp, err := r.userAccountChangeLogDao.GetChangeLogByIdempotentKey(ctx, rc.TopUpIdempotentKey(rc.UsedUserId, 0), model.OpTypeTopUp)
+		if err != nil {
+			return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get top-up of redeem code", Err: err}
+		}
+		if topUp != nil {
+			details.UsedAt = topUp.CreatedAt.Unix()
+			details.TopUpChangeLogId = topUp.ID
+		}
+	}
+	return details, nil
+}
+
+// CreatePromoCode implements RedeemCodeService.
+func (r *RedeemCodeServiceImpl) CreatePromoCode(ctx context.Context, batch *model.RedeemCodeBatch, code string, maxRedemptions int, perUserLimit int) (*data.RedeemCodeVO, error) {
+	if code == "" {
+		code = r.codeFormat.generate(batch.Campaign)
+	} else {
+		// a code picked by hand gets a check character like any other
+		withCheck, err := r.codeFormat.FromBody(code)
+		if err != nil {
+			return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_BAD_REQUEST), Message: "code holds characters outside the redeem code alphabet", Err: err}
+		}
+		code = withCheck
+	}
+	currentTime := time.Now()
+	promoCode := &model.RedeemCode{
+		CodeHash:       utils.HashRedeemCode(r.codeHashSecret, code),
+		CodeSuffix:     utils.RedeemCodeSuffix(code),
+		Type:           model.RedeemCodeTypePromo,
+		Amount:         batch.Amount,
+		MaxRedemptions: maxRedemptions,
+		PerUserLimit:   perUserLimit,
+		ValidFrom:      batch.ValidFrom,
+		ValidUntil:     batch.ValidUntil,
+		CreatedAt:      currentTime,
+		UpdatedAt:      currentTime,
+	}
+	batch.Quantity = 1
+	batch.Status = model.RedeemCodeBatchStatusCompleted
+	batch.GeneratedCount = 1
+	batch.CreatedAt = currentTime
+	err := r.redeemCodeDao.CreateBatch(ctx, batch, []*model.RedeemCode{promoCode})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		log.Logger.Warnf("Promo code ending in %s already exists", promoCode.CodeSuffix)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_BAD_REQUEST), Message: "code already exists"}
+	}
+	if err != nil {
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to create promo code", Err: err}
+	}
+	log.Logger.Infof("Created promo code %d in batch %d, %d redemptions of %d, %d per user", promoCode.ID, batch.ID, maxRedemptions, batch.Amount, perUserLimit)
+	vo := toRedeemCodeVO(promoCode)
+	vo.Code = r.codeFormat.Display(code)
+	return vo, nil
+}
+
+// QueryRedeemCodeBatches implements RedeemCodeService.
+func (r *RedeemCodeServiceImpl) QueryRedeemCodeBatches(ctx context.Context, query *data.RedeemCodeBatchQuery) (*data.RedeemCodeBatchPage, error) {
+	beforeId, err := utils.DecodeCursor(query.Cursor)
+	if err != nil {
+		log.Logger.Warnf("Invalid redeem code batch cursor %s", query.Cursor)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_BAD_REQUEST), Message: "invalid cursor", Err: err}
+	}
+	pageSize := query.Limit
+	if pageSize <= 0 || pageSize > repository.DefaultQueryLimit {
+		pageSize = repository.DefaultQueryLimit
+	}
+	// one extra row tells whether there is a next page
+	batches, err := r.redeemCodeDao.QueryBatches(ctx, &model.RedeemCodeBatchQuery{
+		Campaign:  query.Campaign,
+		CreatorId: query.CreatorId,
+		BeforeId:  uint(beforeId),
+		Limit:     pageSize + 1,
+	})
+	if err != nil {
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to query redeem code batches", Err: err}
+	}
+	page := &data.RedeemCodeBatchPage{}
+	if len(batches) > pageSize {
+		batches = batches[:pageSize]
+		page.NextCursor = utils.EncodeCursor(int(batches[pageSize-1].ID))
+	}
+	page.Batches = make([]*data.RedeemCodeBatchVO, len(batches))
+	if len(batches) == 0 {
+		return page, nil
+	}
+	batchIds := make([]uint, len(batches))
+	for i, batch := range batches {
+		batchIds[i] = batch.ID
+	}
+	progress, err := r.redeemCodeDao.QueryBatchProgress(ctx, batchIds)
+	if err != nil {
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to query redeem code batch progress", Err: err}
+	}
+	progressByBatch := make(map[uint]*model.RedeemCodeBatchProgress, len(progress))
+	for _, p := range progress {
+		progressByBatch[p.BatchId] = p
+	}
+	for i, batch := range batches {
+		page.Batches[i] = toRedeemCodeBatchVO(batch, progressByBatch[batch.ID])
+	}
+	return page, nil
+}
+
+// toRedeemCodeBatchVO builds the VO of the batch, progress is nil for a batch without used or revoked codes.
+func toRedeemCodeBatchVO(batch *model.RedeemCodeBatch, progress *model.RedeemCodeBatchProgress) *data.RedeemCodeBatchVO {
+	vo := &data.RedeemCodeBatchVO{
+		Id:        batch.ID,
+		Name:      batch.Name,
+		Campaign:  batch.Campaign,
+		CreatorId: batch.CreatorId,
+		Amount:    batch.Amount,
+		Quantity:  batch.Quantity,
+		Unused:    batch.Quantity,
+		CreatedAt: batch.CreatedAt.Unix(),
+	}
+	if progress != nil {
+		vo.Used = progress.Used
+		vo.Revoked = progress.Revoked
+		vo.Redemptions = progress.Redemptions
+		vo.Unused = batch.Quantity - progress.Used - progress.Revoked
+	}
+	if batch.ValidFrom != nil {
+		vo.ValidFrom = batch.ValidFrom.Unix()
+	}
+	if batch.ValidUntil != nil {
+		vo.ValidUntil = batch.ValidUntil.Unix()
+	}
+	if batch.RevokedAt != nil {
+		vo.RevokedAt = batch.RevokedAt.Unix()
+	}
+	return vo
+}
+
+// RevokeRedeemCodeBatch implements RedeemCodeService.
+// Codes used before the revoke keep their top-ups, revoking a batch again only catches codes it missed.
+func (r *RedeemCodeServiceImpl) RevokeRedeemCodeBatch(ctx context.Context, batchId uint) (int, error) {
+	batch, err := r.redeemCodeDao.GetBatch(ctx, batchId)
+	if err != nil {
+		return 0, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get redeem code batch", Err: err}
+	}
+	if batch == nil {
+		log.Logger.Warnf("Redeem code batch %d not found", batchId)
+		return 0, &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_BATCH_NOT_EXIST), Message: "redeem code batch not found"}
+	}
+	revoked, err := r.redeemCodeDao.RevokeBatch(ctx, batchId, time.Now())
+	if err != nil {
+		return 0, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to revoke redeem code batch", Err: err}
+	}
+	return revoked, nil
+}
+
+// HashPlaintextRedeemCodes implements RedeemCodeService.
+func (r *RedeemCodeServiceImpl) HashPlaintextRedeemCodes(ctx context.Context) (int, error) {
+	hashed := 0
+	var lastCodeId uint
+	for {
+		redeemCodes, err := r.redeemCodeDao.QueryPlaintextRedeemCodes(ctx, lastCodeId, repository.DefaultQueryLimit)
+		if err != nil {
+			return hashed, err
+		}
+		for _, redeemCode := range redeemCodes {
+			var ok bool
+			switch {
+			case redeemCode.Code == nil:
+				log.Logger.Warnf("Redeem code %d has neither plaintext nor hash, skipped", redeemCode.ID)
+				continue
+			case redeemCode.CodeHash != "":
+				// a job generated code that was not exported yet, its plaintext is still needed by the export
+				sealedCode, err := utils.SealRedeemCode(r.codeEncryptionKey, *redeemCode.Code, redeemCode.CodeHash)
+				if err != nil {
+					return hashed, err
+				}
+				ok, err = r.redeemCodeDao.SealPlaintextCode(ctx, redeemCode.ID, sealedCode)
+				if err != nil {
+					return hashed, err
+				}
+			default:
+				redeemCode.CodeHash = utils.HashRedeemCode(r.codeHashSecret, *redeemCode.Code)
+				redeemCode.CodeSuffix = utils.RedeemCodeSuffix(*redeemCode.Code)
+				ok, err = r.redeemCodeDao.HashRedeemCode(ctx, redeemCode)
+				if err != nil {
+					return hashed, err
+				}
+			}
+			if ok {
+				hashed++
+			}
+		}
+		if len(redeemCodes) < repository.DefaultQueryLimit {
+			return hashed, nil
+		}
+		lastCodeId = redeemCodes[len(redeemCodes)-1].ID
+	}
+}
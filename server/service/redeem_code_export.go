@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/http/data"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/utils"
+)
+
+const (
+	RedeemCodeExportFormatCSV   = "csv"
+	RedeemCodeExportFormatJSONL = "jsonl"
+
+	// codes read from the database per round trip, the export never holds more in memory
+	redeemCodeExportChunkSize = 1000
+)
+
+var redeemCodeExportHeader = []string{"id", "code", "code_suffix", "amount", "status", "batch_id", "used", "valid_from", "valid_until", "revoked_at", "created_at", "updated_at"}
+
+// flusher is implemented by writers that buffer, such as http.ResponseWriter.
+type flusher interface {
+	Flush()
+}
+
+// redeemCodeExportWriter writes rows in one export format.
+type redeemCodeExportWriter interface {
+	Write(row *data.RedeemCodeExportRow) error
+	// Flush pushes the rows written so far to the underlying writer.
+	Flush() error
+}
+
+// ExportRedeemCodes implements RedeemCodeService.
+func (r *RedeemCodeServiceImpl) ExportRedeemCodes(ctx context.Context, query *data.RedeemCodeExportQuery, w io.Writer) (int, error) {
+	var exportWriter redeemCodeExportWriter
+	switch query.Format {
+	case RedeemCodeExportFormatCSV, "":
+		exportWriter = &redeemCodeCSVWriter{w: csv.NewWriter(w)}
+	case RedeemCodeExportFormatJSONL:
+		exportWriter = &redeemCodeJSONLWriter{encoder: json.NewEncoder(w)}
+	default:
+		return 0, &bizerror.BizError{Code: int(paymentpb.RespCode_BAD_REQUEST), Message: "unsupported export format " + query.Format}
+	}
+	dbQuery := &model.RedeemCodeQuery{
+		BatchId: query.BatchId,
+		Used:    query.Used,
+		Status:  query.Status,
+	}
+	exported, revealed := 0, 0
+	err := r.redeemCodeDao.FindRedeemCodesInBatches(ctx, dbQuery, redeemCodeExportChunkSize, func(redeemCodes []*model.RedeemCode) error {
+		now := time.Now()
+		rows := make([]*data.RedeemCodeExportRow, 0, len(redeemCodes))
+		var sealedIds []uint
+		for _, rc := range redeemCodes {
+			row, err := r.toRedeemCodeExportRow(rc, now)
+			if err != nil {
+				return err
+			}
+			if rc.SealedCode != nil {
+				sealedIds = append(sealedIds, rc.ID)
+			}
+			rows = append(rows, row)
+		}
+		// the plaintext is dropped before it is written, a code a concurrent export claimed first is not revealed here
+		if len(sealedIds) > 0 {
+			claimedIds, err := r.redeemCodeDao.ClaimSealedCodes(ctx, sealedIds)
+			if err != nil {
+				return err
+			}
+			claimed := make(map[uint]bool, len(claimedIds))
+			for _, id := range claimedIds {
+				claimed[id] = true
+			}
+			for _, row := range rows {
+				if !claimed[row.Id] {
+					row.Code = ""
+				}
+			}
+			revealed += len(claimedIds)
+		}
+		for _, row := range rows {
+			if err := exportWriter.Write(row); err != nil {
+				return err
+			}
+		}
+		if err := exportWriter.Flush(); err != nil {
+			return err
+		}
+		if f, ok := w.(flusher); ok {
+			f.Flush()
+		}
+		exported += len(redeemCodes)
+		return nil
+	})
+	if err != nil {
+		log.Logger.Errorf("Failed to export redeem codes after %d rows: %v", exported, err)
+		return exported, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to export redeem codes", Err: err}
+	}
+	// an empty export still gets its header
+	if err := exportWriter.Flush(); err != nil {
+		return exported, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to export redeem codes", Err: err}
+	}
+	log.Logger.Infof("Exported %d redeem codes as %s, %d revealed in plaintext", exported, query.Format, revealed)
+	return exported, nil
+}
+
+func (r *RedeemCodeServiceImpl) toRedeemCodeExportRow(rc *model.RedeemCode, now time.Time) (*data.RedeemCodeExportRow, error) {
+	row := &data.RedeemCodeExportRow{
+		Id:         rc.ID,
+		CodeSuffix: rc.CodeSuffix,
+		Amount:     rc.Amount,
+		Status:     rc.StatusAt(now),
+		BatchId:    rc.BatchId,
+		UsedUserId: rc.UsedUserId,
+		CreatedAt:  rc.CreatedAt.Unix(),
+		UpdatedAt:  rc.UpdatedAt.Unix(),
+	}
+	if rc.SealedCode != nil {
+		code, err := utils.OpenRedeemCode(r.codeEncryptionKey, rc.SealedCode, rc.CodeHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt redeem code %d: %w", rc.ID, err)
+		}
+		row.Code = r.codeFormat.Display(code)
+	}
+	if rc.ValidFrom != nil {
+		row.ValidFrom = rc.ValidFrom.Unix()
+	}
+	if rc.ValidUntil != nil {
+		row.ValidUntil = rc.ValidUntil.Unix()
+	}
+	if rc.RevokedAt != nil {
+		row.RevokedAt = rc.RevokedAt.Unix()
+	}
+	return row, nil
+}
+
+type redeemCodeCSVWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *redeemCodeCSVWriter) Write(row *data.RedeemCodeExportRow) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.w.Write([]string{
+		strconv.FormatUint(uint64(row.Id), 10),
+		row.Code,
+		row.CodeSuffix,
+		strconv.Itoa(row.Amount),
+		row.Status,
+		strconv.FormatUint(uint64(row.BatchId), 10),
+		strconv.Itoa(row.UsedUserId),
+		formatExportTime(row.ValidFrom),
+		formatExportTime(row.ValidUntil),
+		formatExportTime(row.RevokedAt),
+		formatExportTime(row.CreatedAt),
+		formatExportTime(row.UpdatedAt),
+	})
+}
+
+func (c *redeemCodeCSVWriter) Flush() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *redeemCodeCSVWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.w.Write(redeemCodeExportHeader)
+}
+
+// formatExportTime renders unix seconds as RFC 3339 for spreadsheets, 0 stays empty.
+func formatExportTime(unix int64) string {
+	if unix == 0 {
+		return ""
+	}
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+type redeemCodeJSONLWriter struct {
+	encoder *json.Encoder
+}
+
+// Write implements redeemCodeExportWriter, json.Encoder ends every row with a newline.
+func (j *redeemCodeJSONLWriter) Write(row *data.RedeemCodeExportRow) error {
+	return j.encoder.Encode(row)
+}
+
+func (j *redeemCodeJSONLWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/http/data"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao/mocks"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/utils"
+)
+
+func TestExportRedeemCodes(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+
+	batchId := uint(7)
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	revokedAt := createdAt.Add(time.Hour)
+	encryptionKey := []byte("encryption key")
+	sealedCode, err := utils.SealRedeemCode(encryptionKey, "CODE0001", "hash1")
+	assert.NoError(t, err)
+	// two chunks, the export must write both without collecting them first, plaintext in display form
+	chunks := [][]*model.RedeemCode{
+		{
+			{ID: 1, CodeHash: "hash1", SealedCode: sealedCode, CodeSuffix: "0001", Amount: 100, BatchId: batchId, CreatedAt: createdAt, UpdatedAt: createdAt},
+			{ID: 2, CodeSuffix: "0002", Amount: 100, BatchId: batchId, UsedUserId: 11, CreatedAt: createdAt, UpdatedAt: createdAt},
+		},
+		{
+			{ID: 3, CodeSuffix: "0003", Amount: 100, BatchId: batchId, RevokedAt: &revokedAt, CreatedAt: createdAt, UpdatedAt: revokedAt},
+		},
+	}
+	newService := func(chunks [][]*model.RedeemCode, err error) (*RedeemCodeServiceImpl, *mocks.RedeemCodeDao) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		// only code 1 still holds its plaintext
+		redeemCodeDao.On("ClaimSealedCodes", ctx, []uint{1}).Return([]uint{1}, nil).Maybe()
+		redeemCodeDao.On("FindRedeemCodesInBatches", ctx, &model.RedeemCodeQuery{BatchId: &batchId}, redeemCodeExportChunkSize, mock.Anything).
+			Run(func(args mock.Arguments) {
+				fn := args.Get(3).(func([]*model.RedeemCode) error)
+				for _, chunk := range chunks {
+					if fn(chunk) != nil {
+						return
+					}
+				}
+			}).Return(err).Once()
+		return &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao, codeFormat: newRedeemCodeFormat(nil), codeEncryptionKey: encryptionKey}, redeemCodeDao
+	}
+
+	t.Run("should write a csv row per code after the header and reveal plaintext once", func(t *testing.T) {
+		service, redeemCodeDao := newService(chunks, nil)
+		var buf bytes.Buffer
+
+		exported, err := service.ExportRedeemCodes(ctx, &data.RedeemCodeExportQuery{Format: RedeemCodeExportFormatCSV, BatchId: &batchId}, &buf)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, exported)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 4)
+		assert.Equal(t, strings.Join(redeemCodeExportHeader, ","), lines[0])
+		assert.Equal(t, "1,CODE-0001,0001,100,active,7,0,,,,2026-01-02T03:04:05Z,2026-01-02T03:04:05Z", lines[1])
+		assert.True(t, strings.HasPrefix(lines[2], "2,,0002,100,used,7,11,"))
+		assert.True(t, strings.HasPrefix(lines[3], "3,,0003,100,revoked,7,0,,,2026-01-02T04:04:05Z,"))
+		redeemCodeDao.AssertCalled(t, "ClaimSealedCodes", ctx, []uint{1})
+	})
+
+	t.Run("should write the header of an empty csv export", func(t *testing.T) {
+		service, _ := newService(nil, nil)
+		var buf bytes.Buffer
+
+		exported, err := service.ExportRedeemCodes(ctx, &data.RedeemCodeExportQuery{Format: RedeemCodeExportFormatCSV, BatchId: &batchId}, &buf)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, exported)
+		assert.Equal(t, strings.Join(redeemCodeExportHeader, ",")+"\n", buf.String())
+	})
+
+	t.Run("should write a json object per line", func(t *testing.T) {
+		service, _ := newService(chunks, nil)
+		var buf bytes.Buffer
+
+		exported, err := service.ExportRedeemCodes(ctx, &data.RedeemCodeExportQuery{Format: RedeemCodeExportFormatJSONL, BatchId: &batchId}, &buf)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, exported)
+		decoder := json.NewDecoder(&buf)
+		var rows []*data.RedeemCodeExportRow
+		for decoder.More() {
+			var row data.RedeemCodeExportRow
+			assert.NoError(t, decoder.Decode(&row))
+			rows = append(rows, &row)
+		}
+		assert.Len(t, rows, 3)
+		assert.Equal(t, "CODE-0001", rows[0].Code)
+		assert.Empty(t, rows[2].Code)
+		assert.Equal(t, "0003", rows[2].CodeSuffix)
+		assert.Equal(t, model.RedeemCodeStatusRevoked, rows[2].Status)
+		assert.Equal(t, revokedAt.Unix(), rows[2].RevokedAt)
+	})
+
+	t.Run("should not reveal a code a concurrent export claimed first", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		redeemCodeDao.On("FindRedeemCodesInBatches", ctx, mock.Anything, redeemCodeExportChunkSize, mock.Anything).
+			Run(func(args mock.Arguments) {
+				assert.NoError(t, args.Get(3).(func([]*model.RedeemCode) error)(chunks[0]))
+			}).Return(nil).Once()
+		redeemCodeDao.On("ClaimSealedCodes", ctx, []uint{1}).Return([]uint(nil), nil).Once()
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao, codeFormat: newRedeemCodeFormat(nil), codeEncryptionKey: encryptionKey}
+		var buf bytes.Buffer
+
+		exported, err := service.ExportRedeemCodes(ctx, &data.RedeemCodeExportQuery{Format: RedeemCodeExportFormatCSV, BatchId: &batchId}, &buf)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, exported)
+		assert.NotContains(t, buf.String(), "CODE-0001")
+		assert.Contains(t, buf.String(), "\n1,,0001,100,active,7,0,")
+	})
+
+	t.Run("should fail rather than write a code sealed for another row", func(t *testing.T) {
+		moved := []*model.RedeemCode{{ID: 4, CodeHash: "hash4", SealedCode: sealedCode, CodeSuffix: "0004", Amount: 100, BatchId: batchId}}
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		var fnErr error
+		redeemCodeDao.On("FindRedeemCodesInBatches", ctx, mock.Anything, redeemCodeExportChunkSize, mock.Anything).
+			Run(func(args mock.Arguments) {
+				fnErr = args.Get(3).(func([]*model.RedeemCode) error)(moved)
+			}).Return(nil).Once()
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao, codeFormat: newRedeemCodeFormat(nil), codeEncryptionKey: encryptionKey}
+		var buf bytes.Buffer
+
+		// the dao hands the error of the chunk back, the mock only records it
+		_, _ = service.ExportRedeemCodes(ctx, &data.RedeemCodeExportQuery{Format: RedeemCodeExportFormatCSV, BatchId: &batchId}, &buf)
+		assert.Error(t, fnErr)
+		assert.NotContains(t, buf.String(), "CODE-0001")
+		redeemCodeDao.AssertNotCalled(t, "ClaimSealedCodes", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should report the rows written before a failure", func(t *testing.T) {
+		service, _ := newService(chunks[:1], assert.AnError)
+		var buf bytes.Buffer
+
+		exported, err := service.ExportRedeemCodes(ctx, &data.RedeemCodeExportQuery{Format: RedeemCodeExportFormatCSV, BatchId: &batchId}, &buf)
+		assert.Equal(t, paymentpb.RespCode_UNKNOWN_ERROR, bizerror.RespCodeOf(err))
+		assert.Equal(t, 2, exported)
+	})
+}
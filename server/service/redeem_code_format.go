@@ -0,0 +1,51 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/utils"
+)
+
+// redeemCodeFormat is the configured format of redeem codes together with the prefixes of the campaigns.
+type redeemCodeFormat struct {
+	*utils.RedeemCodeFormat
+	prefixes map[string]string
+}
+
+// newRedeemCodeFormat panics on an invalid format, codes printed in it could never be redeemed.
+func newRedeemCodeFormat(conf *config.PaymentConfig) *redeemCodeFormat {
+	alphabet, length, groupSize := utils.DefaultRedeemCodeAlphabet, utils.DefaultRedeemCodeLength, utils.DefaultRedeemCodeGroupSize
+	var prefixes map[string]string
+	if conf != nil {
+		if conf.RedeemCodeAlphabet != "" {
+			alphabet = conf.RedeemCodeAlphabet
+		}
+		if conf.RedeemCodeLength > 0 {
+			length = conf.RedeemCodeLength
+		}
+		if conf.RedeemCodeGroupSize != 0 {
+			groupSize = conf.RedeemCodeGroupSize
+		}
+		prefixes = conf.RedeemCodeCampaignPrefixes
+	}
+	format, err := utils.NewRedeemCodeFormat(alphabet, length, groupSize)
+	if err != nil {
+		panic(fmt.Sprintf("invalid redeem code format: %v", err))
+	}
+	f := &redeemCodeFormat{RedeemCodeFormat: format, prefixes: make(map[string]string, len(prefixes))}
+	for campaign, prefix := range prefixes {
+		prefix = strings.ToUpper(prefix)
+		if err := format.CheckPrefix(prefix); err != nil {
+			panic(fmt.Sprintf("invalid redeem code prefix of campaign %s: %v", campaign, err))
+		}
+		f.prefixes[strings.ToLower(campaign)] = prefix
+	}
+	return f
+}
+
+// generate returns a new code in canonical form with the prefix of the campaign, if it has one.
+func (f *redeemCodeFormat) generate(campaign string) string {
+	return f.Generate(f.prefixes[strings.ToLower(campaign)])
+}
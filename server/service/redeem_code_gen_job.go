@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/http/data"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultGenJobChunkSize = 1000
+	defaultGenJobMaxCount  = 100000
+	// chunks in a row that may insert nothing because every code collided before the job fails
+	maxGenJobEmptyChunks = 5
+)
+
+// genJobLimit sizes redeem code generation jobs.
+type genJobLimit struct {
+	chunkSize int
+	maxCount  int
+}
+
+func newGenJobLimit(conf *config.PaymentConfig) genJobLimit {
+	limit := genJobLimit{chunkSize: defaultGenJobChunkSize, maxCount: defaultGenJobMaxCount}
+	if conf == nil {
+		return limit
+	}
+	if conf.RedeemCodeGenChunkSize > 0 {
+		limit.chunkSize = conf.RedeemCodeGenChunkSize
+	}
+	if conf.RedeemCodeGenMaxCount > 0 {
+		limit.maxCount = conf.RedeemCodeGenMaxCount
+	}
+	return limit
+}
+
+// StartGenerationJob implements RedeemCodeService.
+func (r *RedeemCodeServiceImpl) StartGenerationJob(ctx context.Context, batch *model.RedeemCodeBatch) (*data.RedeemCodeGenJobVO, error) {
+	if batch.Quantity > r.genJobLimit.maxCount {
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_BAD_REQUEST), Message: fmt.Sprintf("count must not exceed %d", r.genJobLimit.maxCount)}
+	}
+	batch.Status = model.RedeemCodeBatchStatusGenerating
+	batch.GeneratedCount = 0
+	batch.CreatedAt = time.Now()
+	if err := r.redeemCodeDao.CreateBatch(ctx, batch, nil); err != nil {
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to create redeem code batch", Err: err}
+	}
+	log.Logger.Infof("Started generation job for batch %d of %d redeem codes", batch.ID, batch.Quantity)
+	// the job outlives the request, a crash leaves it to ResumeGenerationJobs
+	go func(batchId uint) {
+		if err := r.runGenerationJob(context.Background(), batchId); err != nil {
+			log.Logger.Errorf("Generation job for batch %d stopped: %v", batchId, err)
+		}
+	}(batch.ID)
+	return toRedeemCodeGenJobVO(batch), nil
+}
+
+// GetGenerationJob implements RedeemCodeService.
+func (r *RedeemCodeServiceImpl) GetGenerationJob(ctx context.Context, batchId uint) (*data.RedeemCodeGenJobVO, error) {
+	batch, err := r.redeemCodeDao.GetBatch(ctx, batchId)
+	if err != nil {
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get redeem code batch", Err: err}
+	}
+	if batch == nil {
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_BATCH_NOT_EXIST), Message: "redeem code generation job not found"}
+	}
+	return toRedeemCodeGenJobVO(batch), nil
+}
+
+// ResumeGenerationJobs implements RedeemCodeService.
+func (r *RedeemCodeServiceImpl) ResumeGenerationJobs(ctx context.Context) (int, error) {
+	batches, err := r.redeemCodeDao.QueryBatchesByStatus(ctx, model.RedeemCodeBatchStatusGenerating, repository.DefaultQueryLimit)
+	if err != nil {
+		return 0, err
+	}
+	completed := 0
+	for _, batch := range batches {
+		if err := r.runGenerationJob(ctx, batch.ID); err != nil {
+			log.Logger.Errorf("Failed to resume generation job for batch %d: %v", batch.ID, err)
+			continue
+		}
+		completed++
+	}
+	return completed, nil
+}
+
+// runGenerationJob inserts the missing codes of the batch chunk by chunk until its generated count reaches its
+// quantity. Every chunk commits with the count, so the job picks up where it stopped. Codes that collide with
+// codes in the database are skipped and made up for by the next chunk.
+func (r *RedeemCodeServiceImpl) runGenerationJob(ctx context.Context, batchId uint) error {
+	if _, running := r.generating.LoadOrStore(batchId, struct{}{}); running {
+		log.Logger.Infof("Generation job for batch %d is already running", batchId)
+		return nil
+	}
+	defer r.generating.Delete(batchId)
+	emptyChunks := 0
+	for {
+		batch, err := r.redeemCodeDao.GetBatch(ctx, batchId)
+		if err != nil {
+			return err
+		}
+		if batch == nil || batch.Status != model.RedeemCodeBatchStatusGenerating {
+			return nil
+		}
+		remaining := batch.Quantity - batch.GeneratedCount
+		chunkSize := min(remaining, r.genJobLimit.chunkSize)
+		redeemCodes, err := r.sealedBatchRedeemCodes(batch, chunkSize)
+		if err != nil {
+			return err
+		}
+		inserted, err := r.redeemCodeDao.InsertBatchChunk(ctx, batch, redeemCodes)
+		if errors.Is(err, gorm.ErrCheckConstraintViolated) {
+			// another process moved the batch on, read it again
+			continue
+		}
+		if err != nil {
+			// left generating, ResumeGenerationJobs retries it
+			_ = r.redeemCodeDao.UpdateBatchStatus(ctx, batchId, model.RedeemCodeBatchStatusGenerating, truncateError(err))
+			return err
+		}
+		if inserted < chunkSize {
+			log.Logger.Warnf("Generation job for batch %d skipped %d colliding codes", batchId, chunkSize-inserted)
+		}
+		if inserted == 0 {
+			emptyChunks++
+			if emptyChunks >= maxGenJobEmptyChunks {
+				lastError := fmt.Sprintf("every code of %d chunks in a row collided with existing codes", emptyChunks)
+				if err := r.redeemCodeDao.UpdateBatchStatus(ctx, batchId, model.RedeemCodeBatchStatusFailed, lastError); err != nil {
+					return err
+				}
+				return errors.New(lastError)
+			}
+			continue
+		}
+		emptyChunks = 0
+		if inserted == remaining {
+			log.Logger.Infof("Generation job for batch %d completed, %d redeem codes", batchId, batch.Quantity)
+			return nil
+		}
+	}
+}
+
+// sealedBatchRedeemCodes generates size codes for a generation job, each keeping its plaintext encrypted in
+// SealedCode until the first export of the codes reveals it.
+func (r *RedeemCodeServiceImpl) sealedBatchRedeemCodes(batch *model.RedeemCodeBatch, size int) ([]*model.RedeemCode, error) {
+	redeemCodes, plaintexts := r.newBatchRedeemCodes(batch, size)
+	for i, redeemCode := range redeemCodes {
+		sealedCode, err := utils.SealRedeemCode(r.codeEncryptionKey, plaintexts[i], redeemCode.CodeHash)
+		if err != nil {
+			return nil, err
+		}
+		redeemCode.SealedCode = sealedCode
+	}
+	return redeemCodes, nil
+}
+
+// newBatchRedeemCodes generates size codes for the batch, unique among themselves, and returns them together with
+// their plaintexts in the same order. Only the hash of a code is set, the caller decides what becomes of the plaintext.
+func (r *RedeemCodeServiceImpl) newBatchRedeemCodes(batch *model.RedeemCodeBatch, size int) ([]*model.RedeemCode, []string) {
+	redeemCodes := make([]*model.RedeemCode, 0, size)
+	plaintexts := make([]string, 0, size)
+	codeSet := make(map[string]struct{}, size)
+	currentTime := time.Now()
+	for len(redeemCodes) < size {
+		code := r.codeFormat.generate(batch.Campaign)
+		if _, exists := codeSet[code]; exists {
+			continue
+		}
+		codeSet[code] = struct{}{}
+		plaintexts = append(plaintexts, code)
+		redeemCodes = append(redeemCodes, &model.RedeemCode{
+			CodeHash:   utils.HashRedeemCode(r.codeHashSecret, code),
+			CodeSuffix: utils.RedeemCodeSuffix(code),
+			Type:       model.RedeemCodeTypeSingleUse,
+			Amount:     batch.Amount,
+			ValidFrom:  batch.ValidFrom,
+			ValidUntil: batch.ValidUntil,
+			CreatedAt:  currentTime,
+			UpdatedAt:  currentTime,
+		})
+	}
+	return redeemCodes, plaintexts
+}
+
+// truncateError fits the message of err into RedeemCodeBatch.LastError.
+func truncateError(err error) string {
+	const maxLen = 255
+	msg := err.Error()
+	if len(msg) > maxLen {
+		return msg[:maxLen]
+	}
+	return msg
+}
+
+func toRedeemCodeGenJobVO(batch *model.RedeemCodeBatch) *data.RedeemCodeGenJobVO {
+	vo := &data.RedeemCodeGenJobVO{
+		JobId:     batch.ID,
+		Status:    model.RedeemCodeBatchStatusNames[batch.Status],
+		Quantity:  batch.Quantity,
+		Generated: batch.GeneratedCount,
+		LastError: batch.LastError,
+		CreatedAt: batch.CreatedAt.Unix(),
+		UpdatedAt: batch.UpdatedAt.Unix(),
+	}
+	if batch.Quantity > 0 {
+		vo.Progress = float64(batch.GeneratedCount) / float64(batch.Quantity)
+	}
+	return vo
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao/mocks"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/utils"
+	"gorm.io/gorm"
+)
+
+func TestRunGenerationJob(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+
+	encryptionKey := []byte("encryption key")
+	newService := func() (*RedeemCodeServiceImpl, *mocks.RedeemCodeDao) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		return &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao, codeFormat: newRedeemCodeFormat(nil), codeEncryptionKey: encryptionKey, genJobLimit: genJobLimit{chunkSize: 2, maxCount: 10}}, redeemCodeDao
+	}
+	batchAt := func(generated int) *model.RedeemCodeBatch {
+		return &model.RedeemCodeBatch{ID: 7, Amount: 100, Quantity: 5, Status: model.RedeemCodeBatchStatusGenerating, GeneratedCount: generated}
+	}
+	// every code of a chunk keeps its plaintext encrypted, never in the clear
+	chunkOf := func(size int) interface{} {
+		return mock.MatchedBy(func(codes []*model.RedeemCode) bool {
+			for _, code := range codes {
+				plaintext, err := utils.OpenRedeemCode(encryptionKey, code.SealedCode, code.CodeHash)
+				if err != nil || code.Code != nil || code.CodeHash != utils.HashRedeemCode(nil, plaintext) {
+					return false
+				}
+			}
+			return len(codes) == size
+		})
+	}
+
+	t.Run("should insert chunk by chunk from the generated count", func(t *testing.T) {
+		service, redeemCodeDao := newService()
+		// resumed after a crash with one chunk already in
+		redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(batchAt(2), nil).Once()
+		redeemCodeDao.On("InsertBatchChunk", ctx, batchAt(2), chunkOf(2)).Return(2, nil).Once()
+		redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(batchAt(4), nil).Once()
+		redeemCodeDao.On("InsertBatchChunk", ctx, batchAt(4), chunkOf(1)).Return(1, nil).Once()
+
+		assert.NoError(t, service.runGenerationJob(ctx, 7))
+		redeemCodeDao.AssertExpectations(t)
+	})
+
+	t.Run("should make up for codes colliding with existing ones", func(t *testing.T) {
+		service, redeemCodeDao := newService()
+		redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(batchAt(3), nil).Once()
+		redeemCodeDao.On("InsertBatchChunk", ctx, batchAt(3), chunkOf(2)).Return(1, nil).Once()
+		redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(batchAt(4), nil).Once()
+		redeemCodeDao.On("InsertBatchChunk", ctx, batchAt(4), chunkOf(1)).Return(1, nil).Once()
+
+		assert.NoError(t, service.runGenerationJob(ctx, 7))
+		redeemCodeDao.AssertExpectations(t)
+	})
+
+	t.Run("should read the batch again when another process moved it on", func(t *testing.T) {
+		service, redeemCodeDao := newService()
+		redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(batchAt(3), nil).Once()
+		redeemCodeDao.On("InsertBatchChunk", ctx, batchAt(3), chunkOf(2)).Return(0, gorm.ErrCheckConstraintViolated).Once()
+		completed := batchAt(5)
+		completed.Status = model.RedeemCodeBatchStatusCompleted
+		redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(completed, nil).Once()
+
+		assert.NoError(t, service.runGenerationJob(ctx, 7))
+		redeemCodeDao.AssertExpectations(t)
+	})
+
+	t.Run("should fail the job when chunks keep colliding", func(t *testing.T) {
+		service, redeemCodeDao := newService()
+		redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(batchAt(0), nil).Times(maxGenJobEmptyChunks)
+		redeemCodeDao.On("InsertBatchChunk", ctx, mock.Anything, mock.Anything).Return(0, nil).Times(maxGenJobEmptyChunks)
+		redeemCodeDao.On("UpdateBatchStatus", ctx, uint(7), model.RedeemCodeBatchStatusFailed, mock.Anything).Return(nil).Once()
+
+		assert.Error(t, service.runGenerationJob(ctx, 7))
+		redeemCodeDao.AssertExpectations(t)
+	})
+
+	t.Run("should insert nothing without an encryption key", func(t *testing.T) {
+		service, redeemCodeDao := newService()
+		service.codeEncryptionKey = nil
+		redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(batchAt(0), nil).Once()
+
+		assert.Error(t, service.runGenerationJob(ctx, 7))
+		redeemCodeDao.AssertNotCalled(t, "InsertBatchChunk", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should record the error and leave the job to be resumed", func(t *testing.T) {
+		service, redeemCodeDao := newService()
+		redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(batchAt(0), nil).Once()
+		redeemCodeDao.On("InsertBatchChunk", ctx, mock.Anything, mock.Anything).Return(0, assert.AnError).Once()
+		redeemCodeDao.On("UpdateBatchStatus", ctx, uint(7), model.RedeemCodeBatchStatusGenerating, assert.AnError.Error()).Return(nil).Once()
+
+		assert.ErrorIs(t, service.runGenerationJob(ctx, 7), assert.AnError)
+		redeemCodeDao.AssertExpectations(t)
+	})
+}
+
+func TestStartGenerationJob(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+
+	t.Run("should reject more codes than the configured maximum", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao, codeFormat: newRedeemCodeFormat(nil), genJobLimit: genJobLimit{chunkSize: 2, maxCount: 10}}
+
+		_, err := service.StartGenerationJob(ctx, &model.RedeemCodeBatch{Amount: 100, Quantity: 11})
+		assert.Equal(t, paymentpb.RespCode_BAD_REQUEST, bizerror.RespCodeOf(err))
+		redeemCodeDao.AssertNotCalled(t, "CreateBatch", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should create the batch empty in generating status", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao, codeFormat: newRedeemCodeFormat(nil), genJobLimit: genJobLimit{chunkSize: 2, maxCount: 10}}
+		redeemCodeDao.On("CreateBatch", ctx, mock.MatchedBy(func(batch *model.RedeemCodeBatch) bool {
+			return batch.Status == model.RedeemCodeBatchStatusGenerating && batch.GeneratedCount == 0
+		}), []*model.RedeemCode(nil)).Run(func(args mock.Arguments) {
+			args.Get(1).(*model.RedeemCodeBatch).ID = 7
+		}).Return(nil).Once()
+		// the background job finds nothing left to do
+		redeemCodeDao.On("GetBatch", mock.Anything, uint(7)).Return(nil, nil).Maybe()
+
+		job, err := service.StartGenerationJob(ctx, &model.RedeemCodeBatch{Amount: 100, Quantity: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, uint(7), job.JobId)
+		assert.Equal(t, "generating", job.Status)
+	})
+}
+
+func TestGetGenerationJob(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+
+	t.Run("should report the progress of the job", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao}
+		redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(&model.RedeemCodeBatch{ID: 7, Quantity: 8, GeneratedCount: 2, Status: model.RedeemCodeBatchStatusGenerating}, nil).Once()
+
+		job, err := service.GetGenerationJob(ctx, 7)
+		assert.NoError(t, err)
+		assert.Equal(t, 0.25, job.Progress)
+		assert.Equal(t, 2, job.Generated)
+	})
+
+	t.Run("should return error if job not found", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao}
+		redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(nil, nil).Once()
+
+		_, err := service.GetGenerationJob(ctx, 7)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_BATCH_NOT_EXIST, bizerror.RespCodeOf(err))
+	})
+}
+
+func TestResumeGenerationJobs(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+
+	redeemCodeDao := new(mocks.RedeemCodeDao)
+	service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao, codeFormat: newRedeemCodeFormat(nil), codeEncryptionKey: []byte("encryption key"), genJobLimit: genJobLimit{chunkSize: 10, maxCount: 10}}
+	unfinished := &model.RedeemCodeBatch{ID: 7, Amount: 100, Quantity: 5, Status: model.RedeemCodeBatchStatusGenerating, GeneratedCount: 3}
+	redeemCodeDao.On("QueryBatchesByStatus", ctx, model.RedeemCodeBatchStatusGenerating, mock.Anything).Return([]*model.RedeemCodeBatch{unfinished}, nil).Once()
+	redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(unfinished, nil).Once()
+	redeemCodeDao.On("InsertBatchChunk", ctx, unfinished, mock.Anything).Return(2, nil).Once()
+
+	completed, err := service.ResumeGenerationJobs(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, completed)
+}
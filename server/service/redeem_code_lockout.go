@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/lockout"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/metrics"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+)
+
+const (
+	RedeemCodeLockoutSubjectUser = "user"
+	RedeemCodeLockoutSubjectIP   = "ip"
+
+	defaultRedeemCodeBaseLockout = time.Minute
+	defaultRedeemCodeMaxLockout  = 24 * time.Hour
+	defaultRedeemCodeLockoutTTL  = 24 * time.Hour
+)
+
+// RedeemCodeLockoutService guards the customer endpoints taking a redeem code against guessing: a user or an ip
+// trying too many wrong codes is locked out, for longer each time it happens again.
+type RedeemCodeLockoutService interface {
+	// CheckAttempt returns a REDEEM_CODE_LOCKED BizError while the user or the ip is locked out.
+	CheckAttempt(ctx context.Context, userId int, ip string) error
+	// RecordTopUp counts a top-up failing on an invalid or malformed code against the user and the ip, locking
+	// out whichever runs out of attempts. A successful top-up gives the user its attempts back, the ip keeps
+	// its count as other users may share it.
+	RecordTopUp(ctx context.Context, userId int, ip string, topUpErr error)
+	// RecordValidation counts a validation failing on an invalid or malformed code like a top-up. A successful
+	// validation gives nothing back, the same valid code can be validated over and over.
+	RecordValidation(ctx context.Context, userId int, ip string, validateErr error)
+	// ClearLockout forgets the failures and lockouts of a user or an ip and reports whether there were any.
+	ClearLockout(ctx context.Context, subject string, key string, operatorId int) (bool, error)
+}
+
+var (
+	redeemCodeLockoutServiceInstance RedeemCodeLockoutService
+	redeemCodeLockoutServiceOnce     sync.Once
+)
+
+func GetRedeemCodeLockoutService() RedeemCodeLockoutService {
+	redeemCodeLockoutServiceOnce.Do(func() {
+		redeemCodeLockoutServiceInstance = &RedeemCodeLockoutServiceImpl{
+			redeemCodeLockoutDao: dao.GetRedeemCodeLockoutDao(),
+			store:                lockout.NewMemoryStore(),
+			policy:               newRedeemCodeLockoutPolicy(config.Config.PaymentConfig),
+		}
+	})
+	return redeemCodeLockoutServiceInstance
+}
+
+type RedeemCodeLockoutServiceImpl struct {
+	redeemCodeLockoutDao dao.RedeemCodeLockoutDao
+	store                lockout.Store
+	policy               redeemCodeLockoutPolicy
+}
+
+// redeemCodeLockoutPolicy decides when and for how long subjects are locked out.
+// The zero value never locks anyone out.
+type redeemCodeLockoutPolicy struct {
+	userMaxFailures int
+	ipMaxFailures   int
+	baseLockout     time.Duration
+	maxLockout      time.Duration
+	// failures and lockouts are forgotten after this long without a failure or a lockout running
+	ttl time.Duration
+	// users allowed to clear lockouts
+	operatorIds map[int]bool
+}
+
+func newRedeemCodeLockoutPolicy(conf *config.PaymentConfig) redeemCodeLockoutPolicy {
+	policy := redeemCodeLockoutPolicy{
+		baseLockout: defaultRedeemCodeBaseLockout,
+		maxLockout:  defaultRedeemCodeMaxLockout,
+		ttl:         defaultRedeemCodeLockoutTTL,
+	}
+	if conf == nil {
+		return policy
+	}
+	policy.userMaxFailures = conf.RedeemCodeLockoutUserMaxFailures
+	policy.ipMaxFailures = conf.RedeemCodeLockoutIPMaxFailures
+	if conf.RedeemCodeLockoutBaseSeconds > 0 {
+		policy.baseLockout = time.Duration(conf.RedeemCodeLockoutBaseSeconds) * time.Second
+	}
+	if conf.RedeemCodeLockoutMaxSeconds > 0 {
+		policy.maxLockout = time.Duration(conf.RedeemCodeLockoutMaxSeconds) * time.Second
+	}
+	if conf.RedeemCodeLockoutResetSeconds > 0 {
+		policy.ttl = time.Duration(conf.RedeemCodeLockoutResetSeconds) * time.Second
+	}
+	policy.operatorIds = make(map[int]bool, len(conf.RedeemCodeLockoutOperatorIds))
+	for _, id := range conf.RedeemCodeLockoutOperatorIds {
+		policy.operatorIds[id] = true
+	}
+	return policy
+}
+
+// lockoutDuration returns the length of the lockout following the given number of earlier lockouts.
+func (p redeemCodeLockoutPolicy) lockoutDuration(lockouts int) time.Duration {
+	d := p.baseLockout
+	for i := 0; i < lockouts && d < p.maxLockout; i++ {
+		d *= 2
+	}
+	return min(d, p.maxLockout)
+}
+
+func (p redeemCodeLockoutPolicy) maxFailures(subject string) int {
+	if subject == RedeemCodeLockoutSubjectIP {
+		return p.ipMaxFailures
+	}
+	return p.userMaxFailures
+}
+
+type redeemCodeLockoutSubject struct {
+	subject string
+	key     string
+}
+
+func (s redeemCodeLockoutSubject) storeKey() string {
+	return s.subject + ":" + s.key
+}
+
+func lockoutSubjects(userId int, ip string) []redeemCodeLockoutSubject {
+	subjects := []redeemCodeLockoutSubject{{RedeemCodeLockoutSubjectUser, strconv.Itoa(userId)}}
+	if ip != "" {
+		subjects = append(subjects, redeemCodeLockoutSubject{RedeemCodeLockoutSubjectIP, ip})
+	}
+	return subjects
+}
+
+// CheckAttempt implements RedeemCodeLockoutService.
+// A failing store lets the attempt through, guessing is still slowed down by the lockouts it records later.
+func (r *RedeemCodeLockoutServiceImpl) CheckAttempt(ctx context.Context, userId int, ip string) error {
+	now := time.Now()
+	var lockedUntil time.Time
+	for _, s := range lockoutSubjects(userId, ip) {
+		if r.policy.maxFailures(s.subject) <= 0 {
+			continue
+		}
+		state, err := r.store.Get(ctx, s.storeKey())
+		if err != nil {
+			log.Logger.Errorf("Failed to get redeem code lockout of %s %s: %v", s.subject, s.key, err)
+			continue
+		}
+		if state.LockedAt(now) && state.LockedUntil.After(lockedUntil) {
+			lockedUntil = state.LockedUntil
+		}
+	}
+	if lockedUntil.IsZero() {
+		return nil
+	}
+	log.Logger.Warnf("Redeem code attempt of user ID %d from %s rejected, locked out until %v", userId, ip, lockedUntil)
+	retryAfter := int(lockedUntil.Sub(now).Round(time.Second) / time.Second)
+	return &bizerror.BizError{
+		Code:    int(paymentpb.RespCode_REDEEM_CODE_LOCKED),
+		Message: fmt.Sprintf("too many failed redeem code attempts, retry in %d seconds", max(retryAfter, 1)),
+	}
+}
+
+// RecordTopUp implements RedeemCodeLockoutService.
+func (r *RedeemCodeLockoutServiceImpl) RecordTopUp(ctx context.Context, userId int, ip string, topUpErr error) {
+	switch bizerror.RespCodeOf(topUpErr) {
+	case paymentpb.RespCode_SUCCESS:
+		user := lockoutSubjects(userId, "")[0]
+		_, err := r.store.Update(ctx, user.storeKey(), func(state *lockout.State) {
+			state.Failures = 0
+		})
+		if err != nil {
+			log.Logger.Errorf("Failed to reset redeem code failures of user ID %d: %v", userId, err)
+		}
+	case paymentpb.RespCode_REDEEM_CODE_INVALID, paymentpb.RespCode_REDEEM_CODE_MALFORMED:
+		r.recordFailures(ctx, userId, ip)
+	}
+}
+
+// RecordValidation implements RedeemCodeLockoutService.
+func (r *RedeemCodeLockoutServiceImpl) RecordValidation(ctx context.Context, userId int, ip string, validateErr error) {
+	switch bizerror.RespCodeOf(validateErr) {
+	case paymentpb.RespCode_REDEEM_CODE_INVALID, paymentpb.RespCode_REDEEM_CODE_MALFORMED:
+		r.recordFailures(ctx, userId, ip)
+	}
+}
+
+func (r *RedeemCodeLockoutServiceImpl) recordFailures(ctx context.Context, userId int, ip string) {
+	for _, s := range lockoutSubjects(userId, ip) {
+		r.recordFailure(ctx, s)
+	}
+}
+
+func (r *RedeemCodeLockoutServiceImpl) recordFailure(ctx context.Context, s redeemCodeLockoutSubject) {
+	maxFailures := r.policy.maxFailures(s.subject)
+	if maxFailures <= 0 {
+		return
+	}
+	now := time.Now()
+	locked := false
+	state, err := r.store.Update(ctx, s.storeKey(), func(state *lockout.State) {
+		state.Failures++
+		if state.Failures >= maxFailures {
+			state.LockedUntil = now.Add(r.policy.lockoutDuration(state.Lockouts))
+			state.Lockouts++
+			state.Failures = 0
+			locked = true
+		}
+		state.ExpiresAt = now.Add(r.policy.ttl)
+		if state.LockedAt(now) {
+			state.ExpiresAt = state.LockedUntil.Add(r.policy.ttl)
+		}
+	})
+	if err != nil {
+		log.Logger.Errorf("Failed to record redeem code failure of %s %s: %v", s.subject, s.key, err)
+		return
+	}
+	if !locked {
+		return
+	}
+	metrics.RedeemCodeLockoutsTotal.WithLabelValues(s.subject).Inc()
+	log.Logger.Warnf("Locked out %s %s of redeem code top-ups until %v, lockout %d", s.subject, s.key, state.LockedUntil, state.Lockouts)
+	// the lockout is in force already, a lost audit record is logged rather than undoing it
+	_ = r.redeemCodeLockoutDao.CreateAudit(ctx, &model.RedeemCodeLockoutAudit{
+		Action:      model.RedeemCodeLockoutActionLocked,
+		Subject:     s.subject,
+		SubjectKey:  s.key,
+		Lockouts:    state.Lockouts,
+		LockedUntil: &state.LockedUntil,
+	})
+}
+
+// ClearLockout implements RedeemCodeLockoutService.
+func (r *RedeemCodeLockoutServiceImpl) ClearLockout(ctx context.Context, subject string, key string, operatorId int) (bool, error) {
+	if !r.policy.operatorIds[operatorId] {
+		log.Logger.Warnf("User ID %d is not allowed to clear redeem code lockouts", operatorId)
+		return false, &bizerror.BizError{Code: int(paymentpb.RespCode_PERMISSION_DENIED), Message: "only merchants and admins may clear lockouts"}
+	}
+	if subject != RedeemCodeLockoutSubjectUser && subject != RedeemCodeLockoutSubjectIP {
+		return false, &bizerror.BizError{Code: int(paymentpb.RespCode_BAD_REQUEST), Message: "subject must be user or ip"}
+	}
+	s := redeemCodeLockoutSubject{subject, key}
+	cleared, err := r.store.Delete(ctx, s.storeKey())
+	if err != nil {
+		log.Logger.Errorf("Failed to clear redeem code lockout of %s %s: %v", subject, key, err)
+		return false, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to clear lockout", Err: err}
+	}
+	if !cleared {
+		return false, nil
+	}
+	log.Logger.Infof("Redeem code lockout of %s %s cleared by user ID %d", subject, key, operatorId)
+	_ = r.redeemCodeLockoutDao.CreateAudit(ctx, &model.RedeemCodeLockoutAudit{
+		Action:     model.RedeemCodeLockoutActionCleared,
+		Subject:    subject,
+		SubjectKey: key,
+		OperatorId: operatorId,
+	})
+	return true, nil
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/lockout"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao/mocks"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+)
+
+func TestRedeemCodeLockoutPolicy(t *testing.T) {
+	t.Run("should let only the configured operators clear lockouts", func(t *testing.T) {
+		policy := newRedeemCodeLockoutPolicy(&config.PaymentConfig{RedeemCodeLockoutOperatorIds: []int{9}})
+		assert.True(t, policy.operatorIds[9])
+		assert.False(t, policy.operatorIds[1])
+	})
+
+	t.Run("should never lock out without config", func(t *testing.T) {
+		policy := newRedeemCodeLockoutPolicy(nil)
+		assert.Equal(t, 0, policy.maxFailures(RedeemCodeLockoutSubjectUser))
+		assert.Equal(t, 0, policy.maxFailures(RedeemCodeLockoutSubjectIP))
+	})
+
+	t.Run("should double each lockout up to the max", func(t *testing.T) {
+		policy := newRedeemCodeLockoutPolicy(&config.PaymentConfig{
+			RedeemCodeLockoutUserMaxFailures: 5,
+			RedeemCodeLockoutIPMaxFailures:   20,
+			RedeemCodeLockoutBaseSeconds:     60,
+			RedeemCodeLockoutMaxSeconds:      300,
+		})
+		assert.Equal(t, 5, policy.maxFailures(RedeemCodeLockoutSubjectUser))
+		assert.Equal(t, 20, policy.maxFailures(RedeemCodeLockoutSubjectIP))
+		assert.Equal(t, time.Minute, policy.lockoutDuration(0))
+		assert.Equal(t, 2*time.Minute, policy.lockoutDuration(1))
+		assert.Equal(t, 4*time.Minute, policy.lockoutDuration(2))
+		assert.Equal(t, 5*time.Minute, policy.lockoutDuration(3))
+		assert.Equal(t, 5*time.Minute, policy.lockoutDuration(100))
+	})
+}
+
+func TestRedeemCodeLockout(t *testing.T) {
+	ctx := context.Background()
+	userId := 1
+	ip := "203.0.113.7"
+	invalid := &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_INVALID), Message: "invalid redeem code"}
+	malformed := &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_MALFORMED), Message: "malformed redeem code"}
+	initEnv()
+
+	newService := func() (*RedeemCodeLockoutServiceImpl, *mocks.RedeemCodeLockoutDao, *lockout.MemoryStore) {
+		redeemCodeLockoutDao := new(mocks.RedeemCodeLockoutDao)
+		store := lockout.NewMemoryStore()
+		return &RedeemCodeLockoutServiceImpl{
+			redeemCodeLockoutDao: redeemCodeLockoutDao,
+			store:                store,
+			policy: redeemCodeLockoutPolicy{
+				userMaxFailures: 3,
+				ipMaxFailures:   5,
+				baseLockout:     time.Minute,
+				maxLockout:      time.Hour,
+				ttl:             time.Hour,
+				operatorIds:     map[int]bool{9: true},
+			},
+		}, redeemCodeLockoutDao, store
+	}
+	// ends the running lockout of key, keeping its count
+	expireLockout := func(store lockout.Store, key string) {
+		_, _ = store.Update(ctx, key, func(state *lockout.State) { state.LockedUntil = time.Now().Add(-time.Second) })
+	}
+
+	t.Run("should lock the user out after max failures and audit it", func(t *testing.T) {
+		service, redeemCodeLockoutDao, _ := newService()
+		redeemCodeLockoutDao.On("CreateAudit", ctx, mock.MatchedBy(func(audit *model.RedeemCodeLockoutAudit) bool {
+			return audit.Action == model.RedeemCodeLockoutActionLocked && audit.Subject == RedeemCodeLockoutSubjectUser &&
+				audit.SubjectKey == "1" && audit.Lockouts == 1 && audit.LockedUntil != nil
+		})).Return(nil).Once()
+
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, service.CheckAttempt(ctx, userId, ip))
+			service.RecordTopUp(ctx, userId, ip, invalid)
+		}
+		err := service.CheckAttempt(ctx, userId, ip)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_LOCKED, bizerror.RespCodeOf(err))
+		assert.Contains(t, err.Error(), "retry in 60 seconds")
+		// another user behind the same ip still has attempts left
+		assert.NoError(t, service.CheckAttempt(ctx, 2, ip))
+		redeemCodeLockoutDao.AssertExpectations(t)
+	})
+
+	t.Run("should lock out longer each time", func(t *testing.T) {
+		service, redeemCodeLockoutDao, store := newService()
+		redeemCodeLockoutDao.On("CreateAudit", ctx, mock.Anything).Return(nil)
+
+		for i := 0; i < 3; i++ {
+			service.RecordTopUp(ctx, userId, "", malformed)
+		}
+		expireLockout(store, "user:1")
+		assert.NoError(t, service.CheckAttempt(ctx, userId, ""))
+		for i := 0; i < 3; i++ {
+			service.RecordTopUp(ctx, userId, "", malformed)
+		}
+		state, _ := store.Get(ctx, "user:1")
+		assert.Equal(t, 2, state.Lockouts)
+		assert.WithinDuration(t, time.Now().Add(2*time.Minute), state.LockedUntil, time.Second)
+		assert.Contains(t, service.CheckAttempt(ctx, userId, "").Error(), "retry in 120 seconds")
+	})
+
+	t.Run("should lock the ip out across users", func(t *testing.T) {
+		service, redeemCodeLockoutDao, _ := newService()
+		redeemCodeLockoutDao.On("CreateAudit", ctx, mock.MatchedBy(func(audit *model.RedeemCodeLockoutAudit) bool {
+			return audit.Subject == RedeemCodeLockoutSubjectIP && audit.SubjectKey == ip
+		})).Return(nil).Once()
+
+		for i := 1; i <= 5; i++ {
+			service.RecordTopUp(ctx, 100+i, ip, invalid)
+		}
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_LOCKED, bizerror.RespCodeOf(service.CheckAttempt(ctx, 200, ip)))
+		assert.NoError(t, service.CheckAttempt(ctx, 200, "198.51.100.1"))
+		redeemCodeLockoutDao.AssertExpectations(t)
+	})
+
+	t.Run("should only count wrong codes and reset the user on success", func(t *testing.T) {
+		service, _, store := newService()
+
+		service.RecordTopUp(ctx, userId, ip, invalid)
+		service.RecordTopUp(ctx, userId, ip, invalid)
+		service.RecordTopUp(ctx, userId, ip, &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_USED), Message: "redeem code already used"})
+		service.RecordTopUp(ctx, userId, ip, assert.AnError)
+		user, _ := store.Get(ctx, "user:1")
+		assert.Equal(t, 2, user.Failures)
+
+		service.RecordTopUp(ctx, userId, ip, nil)
+		user, _ = store.Get(ctx, "user:1")
+		assert.Equal(t, 0, user.Failures)
+		ipState, _ := store.Get(ctx, "ip:"+ip)
+		assert.Equal(t, 2, ipState.Failures)
+	})
+
+	t.Run("should count failed validations without resetting on success", func(t *testing.T) {
+		service, _, store := newService()
+
+		service.RecordValidation(ctx, userId, ip, invalid)
+		service.RecordValidation(ctx, userId, ip, nil)
+		service.RecordValidation(ctx, userId, ip, malformed)
+		user, _ := store.Get(ctx, "user:1")
+		assert.Equal(t, 2, user.Failures)
+	})
+
+	t.Run("should not track a subject without a limit", func(t *testing.T) {
+		service, _, store := newService()
+		service.policy.ipMaxFailures = 0
+
+		service.RecordTopUp(ctx, userId, ip, invalid)
+		ipState, _ := store.Get(ctx, "ip:"+ip)
+		assert.Equal(t, lockout.State{}, ipState)
+	})
+
+	t.Run("should clear a lockout and audit the operator", func(t *testing.T) {
+		service, redeemCodeLockoutDao, _ := newService()
+		redeemCodeLockoutDao.On("CreateAudit", ctx, mock.MatchedBy(func(audit *model.RedeemCodeLockoutAudit) bool {
+			return audit.Action == model.RedeemCodeLockoutActionLocked
+		})).Return(nil).Once()
+		redeemCodeLockoutDao.On("CreateAudit", ctx, mock.MatchedBy(func(audit *model.RedeemCodeLockoutAudit) bool {
+			return audit.Action == model.RedeemCodeLockoutActionCleared && audit.SubjectKey == "1" && audit.OperatorId == 9
+		})).Return(nil).Once()
+		for i := 0; i < 3; i++ {
+			service.RecordTopUp(ctx, userId, "", invalid)
+		}
+
+		cleared, err := service.ClearLockout(ctx, RedeemCodeLockoutSubjectUser, "1", 9)
+		assert.NoError(t, err)
+		assert.True(t, cleared)
+		assert.NoError(t, service.CheckAttempt(ctx, userId, ""))
+		cleared, err = service.ClearLockout(ctx, RedeemCodeLockoutSubjectUser, "1", 9)
+		assert.NoError(t, err)
+		assert.False(t, cleared)
+		redeemCodeLockoutDao.AssertExpectations(t)
+	})
+
+	t.Run("should not let a customer clear a lockout", func(t *testing.T) {
+		service, redeemCodeLockoutDao, _ := newService()
+		redeemCodeLockoutDao.On("CreateAudit", ctx, mock.Anything).Return(nil).Once()
+		for i := 0; i < 3; i++ {
+			service.RecordTopUp(ctx, userId, "", invalid)
+		}
+
+		cleared, err := service.ClearLockout(ctx, RedeemCodeLockoutSubjectUser, "1", userId)
+		assert.Equal(t, paymentpb.RespCode_PERMISSION_DENIED, bizerror.RespCodeOf(err))
+		assert.False(t, cleared)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_LOCKED, bizerror.RespCodeOf(service.CheckAttempt(ctx, userId, "")))
+	})
+
+	t.Run("should reject an unknown subject", func(t *testing.T) {
+		service, _, _ := newService()
+		_, err := service.ClearLockout(ctx, "account", "1", 9)
+		assert.Equal(t, paymentpb.RespCode_BAD_REQUEST, bizerror.RespCodeOf(err))
+	})
+}
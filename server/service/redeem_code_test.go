@@ -7,11 +7,15 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/http/data"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao/mocks"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/utils"
+	"gorm.io/gorm"
 )
 
 func initEnv() {
@@ -35,19 +39,62 @@ func TestGetRedeemCodeService(t *testing.T) {
 func TestGenerateRedeemCodes(t *testing.T) {
 	initEnv()
 	redeemCodeDao := new(mocks.RedeemCodeDao)
+	secret := []byte("secret")
+	format := newRedeemCodeFormat(&config.PaymentConfig{RedeemCodeCampaignPrefixes: map[string]string{"spring-sale": "spr"}})
 	service := &RedeemCodeServiceImpl{
-		redeemCodeDao: redeemCodeDao,
+		redeemCodeDao:  redeemCodeDao,
+		codeHashSecret: secret,
+		codeFormat:     format,
 	}
 	ctx := context.Background()
 	amount := 100
 	quantity := 5
-	redeemCodeDao.On("BatchInsert", ctx, mock.Anything).Return(nil)
-	err := service.GenerateRedeemCodes(ctx, amount, quantity)
+	var stored []*model.RedeemCode
+	redeemCodeDao.On("CreateBatch", ctx, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.RedeemCodeBatch).ID = 7
+		stored = args.Get(2).([]*model.RedeemCode)
+	}).Return(nil)
+	result, err := service.GenerateRedeemCodes(ctx, &model.RedeemCodeBatch{Name: "spring", Campaign: "spring-sale", Amount: amount, Quantity: quantity})
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
-	redeemCodeDao.AssertNumberOfCalls(t, "BatchInsert", 1)
-	// Additional checks can be added here to verify the generated codes
+	assert.Equal(t, uint(7), result.BatchId)
+	assert.Equal(t, quantity, result.GenCount)
+	redeemCodeDao.AssertNumberOfCalls(t, "CreateBatch", 1)
+	// only the hashes are stored, the plaintext is in the result alone
+	assert.Len(t, result.Codes, quantity)
+	for i, rc := range stored {
+		// SPR-XXXX-XXXX-XXXX-XXXX, the campaign prefix, 15 random characters and the check character
+		assert.Regexp(t, `^SPR(-[2-9A-HJKMNP-Z]{4}){4}$`, result.Codes[i])
+		code, err := format.Normalize(result.Codes[i])
+		assert.NoError(t, err)
+		assert.Nil(t, rc.Code)
+		assert.Equal(t, utils.HashRedeemCode(secret, code), rc.CodeHash)
+		assert.Equal(t, utils.RedeemCodeSuffix(code), rc.CodeSuffix)
+	}
+}
+
+func TestGenerateRedeemCodesWithValidityWindow(t *testing.T) {
+	initEnv()
+	redeemCodeDao := new(mocks.RedeemCodeDao)
+	service := &RedeemCodeServiceImpl{
+		redeemCodeDao: redeemCodeDao,
+		codeFormat:    newRedeemCodeFormat(nil),
+	}
+	ctx := context.Background()
+	validFrom := time.Now().Add(time.Hour)
+	validUntil := validFrom.Add(24 * time.Hour)
+	redeemCodeDao.On("CreateBatch", ctx, mock.Anything, mock.MatchedBy(func(codes []*model.RedeemCode) bool {
+		for _, code := range codes {
+			if !code.ValidFrom.Equal(validFrom) || !code.ValidUntil.Equal(validUntil) {
+				return false
+			}
+		}
+		return len(codes) == 3
+	})).Return(nil)
+	_, err := service.GenerateRedeemCodes(ctx, &model.RedeemCodeBatch{Amount: 100, Quantity: 3, ValidFrom: &validFrom, ValidUntil: &validUntil})
+	assert.NoError(t, err)
+	redeemCodeDao.AssertExpectations(t)
 }
 
 func TestGenerateRedeemCodesErr(t *testing.T) {
@@ -55,12 +102,13 @@ func TestGenerateRedeemCodesErr(t *testing.T) {
 	redeemCodeDao := new(mocks.RedeemCodeDao)
 	service := &RedeemCodeServiceImpl{
 		redeemCodeDao: redeemCodeDao,
+		codeFormat:    newRedeemCodeFormat(nil),
 	}
 	ctx := context.Background()
 	amount := 100
 	quantity := 10
-	redeemCodeDao.On("BatchInsert", ctx, mock.Anything).Return(assert.AnError)
-	err := service.GenerateRedeemCodes(ctx, amount, quantity)
+	redeemCodeDao.On("CreateBatch", ctx, mock.Anything, mock.Anything).Return(assert.AnError)
+	_, err := service.GenerateRedeemCodes(ctx, &model.RedeemCodeBatch{Amount: amount, Quantity: quantity})
 	if err != assert.AnError {
 		t.Errorf("Expected error, got %v", err)
 	}
@@ -69,11 +117,14 @@ func TestGenerateRedeemCodesErr(t *testing.T) {
 func TestQueryRedeemCodes(t *testing.T) {
 	initEnv()
 	redeemCodeDao := new(mocks.RedeemCodeDao)
+	secret := []byte("secret")
 	service := &RedeemCodeServiceImpl{
-		redeemCodeDao: redeemCodeDao,
+		redeemCodeDao:  redeemCodeDao,
+		codeHashSecret: secret,
+		codeFormat:     newRedeemCodeFormat(nil),
 	}
 	ctx := context.Background()
-	code := "test-code"
+	code := "1234567890123456"
 	used := false
 	query := &data.RedeemCodeQuery{
 		Limit: 10,
@@ -81,9 +132,12 @@ func TestQueryRedeemCodes(t *testing.T) {
 		Used:  &used,
 	}
 	expectedRedeemCodes := []*model.RedeemCode{
-		{ID: 1, Code: "test-code", Amount: 100, UsedUserId: 0, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: 1, CodeSuffix: "code", Amount: 100, UsedUserId: 0, CreatedAt: time.Now(), UpdatedAt: time.Now()},
 	}
-	redeemCodeDao.On("QueryRedeemCodes", ctx, mock.Anything).Return(expectedRedeemCodes, nil)
+	codeHash := utils.HashRedeemCode(secret, code)
+	redeemCodeDao.On("QueryRedeemCodes", ctx, mock.MatchedBy(func(q *model.RedeemCodeQuery) bool {
+		return q.CodeHash != nil && *q.CodeHash == codeHash
+	})).Return(expectedRedeemCodes, nil)
 
 	result, err := service.QueryRedeemCodes(ctx, query)
 	if err != nil {
@@ -93,22 +147,93 @@ func TestQueryRedeemCodes(t *testing.T) {
 		t.Errorf("Expected %d redeem codes, got %d", len(expectedRedeemCodes), len(result))
 	}
 	for i, rc := range result {
-		if rc.Code != expectedRedeemCodes[i].Code {
-			t.Errorf("Expected code %s, got %s", expectedRedeemCodes[i].Code, rc.Code)
+		if rc.CodeSuffix != expectedRedeemCodes[i].CodeSuffix || rc.Code != "" {
+			t.Errorf("Expected code suffix %s only, got %s and %s", expectedRedeemCodes[i].CodeSuffix, rc.CodeSuffix, rc.Code)
 		}
 	}
 
 	redeemCodeDao.AssertNumberOfCalls(t, "QueryRedeemCodes", 1)
 }
 
+func TestGetRedeemCodeDetails(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+	format := newRedeemCodeFormat(nil)
+	code := format.Generate("")
+	codeHash := utils.HashRedeemCode(nil, code)
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	usedAt := createdAt.Add(time.Hour)
+	newService := func() (*RedeemCodeServiceImpl, *mocks.RedeemCodeDao, *mocks.UserAccountChangeLogDAO) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		return &RedeemCodeServiceImpl{
+			redeemCodeDao:           redeemCodeDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			codeFormat:              format,
+		}, redeemCodeDao, userAccountChangeLogDao
+	}
+
+	t.Run("should return the batch and the top-up of a used code", func(t *testing.T) {
+		service, redeemCodeDao, userAccountChangeLogDao := newService()
+		rc := &model.RedeemCode{ID: 5, CodeSuffix: utils.RedeemCodeSuffix(code), Type: model.RedeemCodeTypeSingleUse, Amount: 100, UsedUserId: 11, BatchId: 7, CreatedAt: createdAt}
+		batch := &model.RedeemCodeBatch{ID: 7, Name: "print run 1", Campaign: "spring-sale", Amount: 100, Quantity: 10, CreatedAt: createdAt}
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(rc, nil).Once()
+		redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(batch, nil).Once()
+		redeemCodeDao.On("QueryBatchProgress", ctx, []uint{7}).Return([]*model.RedeemCodeBatchProgress{{BatchId: 7, Used: 3, Revoked: 1}}, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, "rc_5", model.OpTypeTopUp).
+			Return(&model.UserAccountChangeLog{ID: 42, CreatedAt: usedAt}, nil).Once()
+
+		details, err := service.GetRedeemCodeDetails(ctx, format.Display(code))
+		assert.NoError(t, err)
+		assert.Equal(t, model.RedeemCodeStatusUsed, details.Status)
+		assert.Equal(t, 11, details.UsedUserId)
+		assert.Equal(t, usedAt.Unix(), details.UsedAt)
+		assert.Equal(t, 42, details.TopUpChangeLogId)
+		assert.Equal(t, "spring-sale", details.Batch.Campaign)
+		assert.Equal(t, 6, details.Batch.Unused)
+		assert.Empty(t, details.Code)
+	})
+
+	t.Run("should list the latest redemptions of a promo code", func(t *testing.T) {
+		service, redeemCodeDao, userAccountChangeLogDao := newService()
+		rc := &model.RedeemCode{ID: 3, Type: model.RedeemCodeTypePromo, Amount: 20, MaxRedemptions: 500, RedeemedCount: 2, CreatedAt: createdAt}
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(rc, nil).Once()
+		redeemCodeDao.On("QueryRedemptions", ctx, uint(3), redeemCodeDetailRedemptions).Return([]*model.RedeemCodeRedemption{
+			{ID: 2, RedeemCodeId: 3, UserId: 12, Seq: 1, ChangeLogId: 44, Amount: 20, CreatedAt: usedAt},
+			{ID: 1, RedeemCodeId: 3, UserId: 11, Seq: 1, ChangeLogId: 43, Amount: 20, CreatedAt: createdAt},
+		}, nil).Once()
+
+		details, err := service.GetRedeemCodeDetails(ctx, code)
+		assert.NoError(t, err)
+		assert.Equal(t, model.RedeemCodeStatusActive, details.Status)
+		assert.Nil(t, details.Batch)
+		assert.Len(t, details.Redemptions, 2)
+		assert.Equal(t, 12, details.Redemptions[0].UserId)
+		assert.Equal(t, usedAt.Unix(), details.Redemptions[0].RedeemedAt)
+		userAccountChangeLogDao.AssertNotCalled(t, "GetChangeLogByIdempotentKey", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should reject unknown and malformed codes", func(t *testing.T) {
+		service, redeemCodeDao, _ := newService()
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(nil, gorm.ErrRecordNotFound).Once()
+
+		_, err := service.GetRedeemCodeDetails(ctx, code)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_INVALID, bizerror.RespCodeOf(err))
+		_, err = service.GetRedeemCodeDetails(ctx, "not-a-code")
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_MALFORMED, bizerror.RespCodeOf(err))
+		redeemCodeDao.AssertNumberOfCalls(t, "GetByCode", 1)
+	})
+}
+
 func TestQueryRedeemCodesErr(t *testing.T) {
 	initEnv()
 	redeemCodeDao := new(mocks.RedeemCodeDao)
 	service := &RedeemCodeServiceImpl{
 		redeemCodeDao: redeemCodeDao,
+		codeFormat:    newRedeemCodeFormat(nil),
 	}
 	ctx := context.Background()
-	code := "test-code"
+	code := "1234567890123456"
 	used := false
 	query := &data.RedeemCodeQuery{
 		Limit: 10,
@@ -127,3 +252,177 @@ func TestQueryRedeemCodesErr(t *testing.T) {
 
 	redeemCodeDao.AssertNumberOfCalls(t, "QueryRedeemCodes", 1)
 }
+
+func TestQueryRedeemCodeBatches(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+
+	t.Run("should report the progress of each batch and the next cursor", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao}
+		batches := []*model.RedeemCodeBatch{
+			{ID: 9, Name: "flyers", Campaign: "spring", Amount: 100, Quantity: 10, CreatedAt: time.Now()},
+			{ID: 8, Name: "posters", Campaign: "spring", Amount: 50, Quantity: 5, CreatedAt: time.Now()},
+			{ID: 7, Name: "stickers", Campaign: "spring", Amount: 20, Quantity: 5, CreatedAt: time.Now()},
+		}
+		redeemCodeDao.On("QueryBatches", ctx, &model.RedeemCodeBatchQuery{Campaign: "spring", Limit: 3}).Return(batches, nil).Once()
+		redeemCodeDao.On("QueryBatchProgress", ctx, []uint{9, 8}).Return([]*model.RedeemCodeBatchProgress{{BatchId: 9, Used: 3, Revoked: 2}}, nil).Once()
+
+		page, err := service.QueryRedeemCodeBatches(ctx, &data.RedeemCodeBatchQuery{Campaign: "spring", Limit: 2})
+		assert.NoError(t, err)
+		assert.Len(t, page.Batches, 2)
+		assert.Equal(t, 3, page.Batches[0].Used)
+		assert.Equal(t, 2, page.Batches[0].Revoked)
+		assert.Equal(t, 5, page.Batches[0].Unused)
+		assert.Equal(t, 5, page.Batches[1].Unused)
+		assert.NotEmpty(t, page.NextCursor)
+	})
+
+	t.Run("should reject an invalid cursor", func(t *testing.T) {
+		service := &RedeemCodeServiceImpl{redeemCodeDao: new(mocks.RedeemCodeDao)}
+
+		_, err := service.QueryRedeemCodeBatches(ctx, &data.RedeemCodeBatchQuery{Cursor: "not-a-cursor", Limit: 20})
+		assert.Equal(t, paymentpb.RespCode_BAD_REQUEST, bizerror.RespCodeOf(err))
+	})
+}
+
+func TestRevokeRedeemCodeBatch(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+
+	t.Run("should revoke the unused codes of the batch", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao}
+		redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(&model.RedeemCodeBatch{ID: 7, Quantity: 10}, nil).Once()
+		redeemCodeDao.On("RevokeBatch", ctx, uint(7), mock.Anything).Return(6, nil).Once()
+
+		revoked, err := service.RevokeRedeemCodeBatch(ctx, 7)
+		assert.NoError(t, err)
+		assert.Equal(t, 6, revoked)
+	})
+
+	t.Run("should return error if batch not found", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao}
+		redeemCodeDao.On("GetBatch", ctx, uint(7)).Return(nil, nil).Once()
+
+		_, err := service.RevokeRedeemCodeBatch(ctx, 7)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_BATCH_NOT_EXIST, bizerror.RespCodeOf(err))
+		redeemCodeDao.AssertNotCalled(t, "RevokeBatch", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestCreatePromoCode(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+
+	format := newRedeemCodeFormat(nil)
+
+	t.Run("should create a promo code in a batch of its own", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao, codeFormat: format}
+		// the code picked by the merchant gets a check character
+		code := format.WithCheck("SUMMER25")
+		redeemCodeDao.On("CreateBatch", ctx, mock.MatchedBy(func(batch *model.RedeemCodeBatch) bool {
+			return batch.Quantity == 1 && batch.Campaign == "spring"
+		}), mock.MatchedBy(func(codes []*model.RedeemCode) bool {
+			return len(codes) == 1 && codes[0].Code == nil && codes[0].CodeHash == utils.HashRedeemCode(nil, code) &&
+				codes[0].CodeSuffix == utils.RedeemCodeSuffix(code) && codes[0].IsPromo() &&
+				codes[0].MaxRedemptions == 500 && codes[0].PerUserLimit == 1
+		})).Return(nil).Once()
+
+		promoCode, err := service.CreatePromoCode(ctx, &model.RedeemCodeBatch{Campaign: "spring", Amount: 20}, "summer25", 500, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, format.Display(code), promoCode.Code)
+		assert.Equal(t, model.RedeemCodeTypePromo, promoCode.Type)
+		redeemCodeDao.AssertExpectations(t)
+	})
+
+	t.Run("should generate the code when none is given", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao, codeFormat: format}
+		redeemCodeDao.On("CreateBatch", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+
+		promoCode, err := service.CreatePromoCode(ctx, &model.RedeemCodeBatch{Amount: 20}, "", 500, 1)
+		assert.NoError(t, err)
+		code, err := format.Normalize(promoCode.Code)
+		assert.NoError(t, err)
+		assert.Len(t, code, utils.DefaultRedeemCodeLength+1)
+	})
+
+	t.Run("should reject a code with characters outside the alphabet", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao, codeFormat: format}
+
+		// I and 0 are left out of the default alphabet
+		_, err := service.CreatePromoCode(ctx, &model.RedeemCodeBatch{Amount: 20}, "SPRING20", 500, 1)
+		assert.Equal(t, paymentpb.RespCode_BAD_REQUEST, bizerror.RespCodeOf(err))
+		redeemCodeDao.AssertNotCalled(t, "CreateBatch", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should reject a code that already exists", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao, codeFormat: format}
+		redeemCodeDao.On("CreateBatch", ctx, mock.Anything, mock.Anything).Return(gorm.ErrDuplicatedKey).Once()
+
+		_, err := service.CreatePromoCode(ctx, &model.RedeemCodeBatch{Amount: 20}, "SUMMER25", 500, 1)
+		assert.Equal(t, paymentpb.RespCode_BAD_REQUEST, bizerror.RespCodeOf(err))
+	})
+}
+
+func TestHashPlaintextRedeemCodes(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+	secret := []byte("secret")
+
+	t.Run("should hash every code still in plaintext", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao, codeHashSecret: secret}
+		code1, code2 := "1234567890123456", "6543210987654321"
+		redeemCodeDao.On("QueryPlaintextRedeemCodes", ctx, uint(0), mock.Anything).
+			Return([]*model.RedeemCode{{ID: 1, Code: &code1}, {ID: 2, Code: &code2}, {ID: 3}}, nil).Once()
+		redeemCodeDao.On("HashRedeemCode", ctx, mock.MatchedBy(func(rc *model.RedeemCode) bool {
+			return rc.ID == 1 && rc.CodeHash == utils.HashRedeemCode(secret, code1) && rc.CodeSuffix == "3456"
+		})).Return(true, nil).Once()
+		// hashed by another run in the meantime
+		redeemCodeDao.On("HashRedeemCode", ctx, mock.MatchedBy(func(rc *model.RedeemCode) bool {
+			return rc.ID == 2
+		})).Return(false, nil).Once()
+
+		hashed, err := service.HashPlaintextRedeemCodes(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, hashed)
+		redeemCodeDao.AssertExpectations(t)
+	})
+
+	t.Run("should encrypt the plaintext left on hashed job codes", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		encryptionKey := []byte("encryption key")
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao, codeHashSecret: secret, codeEncryptionKey: encryptionKey}
+		code := "SPR7KQ4XM2P9HTCR"
+		codeHash := utils.HashRedeemCode(secret, code)
+		redeemCodeDao.On("QueryPlaintextRedeemCodes", ctx, uint(0), mock.Anything).
+			Return([]*model.RedeemCode{{ID: 1, Code: &code, CodeHash: codeHash, CodeSuffix: "HTCR"}}, nil).Once()
+		redeemCodeDao.On("SealPlaintextCode", ctx, uint(1), mock.MatchedBy(func(sealedCode []byte) bool {
+			plaintext, err := utils.OpenRedeemCode(encryptionKey, sealedCode, codeHash)
+			return err == nil && plaintext == code
+		})).Return(true, nil).Once()
+
+		hashed, err := service.HashPlaintextRedeemCodes(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, hashed)
+		redeemCodeDao.AssertNotCalled(t, "HashRedeemCode", mock.Anything, mock.Anything)
+		redeemCodeDao.AssertExpectations(t)
+	})
+
+	t.Run("should stop at the first failure", func(t *testing.T) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &RedeemCodeServiceImpl{redeemCodeDao: redeemCodeDao, codeHashSecret: secret}
+		code1 := "1234567890123456"
+		redeemCodeDao.On("QueryPlaintextRedeemCodes", ctx, uint(0), mock.Anything).Return([]*model.RedeemCode{{ID: 1, Code: &code1}}, nil).Once()
+		redeemCodeDao.On("HashRedeemCode", ctx, mock.Anything).Return(false, assert.AnError).Once()
+
+		_, err := service.HashPlaintextRedeemCodes(ctx)
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
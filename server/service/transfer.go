@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/ledger"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"gorm.io/gorm"
+)
+
+// transferLimit caps what one account may send within a calendar day, zero fields are unlimited.
+type transferLimit struct {
+	dailyAmount int
+	dailyCount  int
+}
+
+func newTransferLimit(conf *config.PaymentConfig) transferLimit {
+	if conf == nil {
+		return transferLimit{}
+	}
+	return transferLimit{dailyAmount: conf.TransferDailyAmountLimit, dailyCount: conf.TransferDailyCountLimit}
+}
+
+// Transfer implements UserAccountService.
+// It moves amount from the user's account to the account numbered toAccountNo and returns the transfer-out
+// change log. A replayed transferId returns the original transfer-out change log with DUPLICATE_REQUEST.
+// Both accounts are locked in account id order whatever the balance update mode, so opposite transfers
+// between the same accounts cannot deadlock and the daily limit is checked against committed transfers.
+func (u *UserAccountServiceImpl) Transfer(ctx context.Context, fromUserId int, toAccountNo string, amount int, transferId string) (*model.UserAccountChangeLog, error) {
+	sender, err := u.userAccountDao.GetUserAccountByUserID(ctx, fromUserId)
+	if err != nil {
+		log.Logger.Errorf("Failed to get user account for user ID %d: %v", fromUserId, err)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get user account", Err: err}
+	}
+	if sender == nil {
+		log.Logger.Warnf("User account not found for user ID %d", fromUserId)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_EXIST), Message: "user account not found"}
+	}
+	recipient, err := u.userAccountDao.GetUserAccountByAccountNo(ctx, toAccountNo)
+	if err != nil {
+		log.Logger.Errorf("Failed to get user account for account no %s: %v", toAccountNo, err)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get recipient account", Err: err}
+	}
+	if recipient == nil {
+		log.Logger.Warnf("Recipient account not found for account no %s", toAccountNo)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_RECIPIENT_NOT_EXIST), Message: "recipient account not found"}
+	}
+	if recipient.ID == sender.ID {
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_BAD_REQUEST), Message: "cannot transfer to your own account"}
+	}
+	original, err := u.checkTransferReplay(ctx, sender, recipient, transferId, amount)
+	if original != nil || err != nil {
+		return original, err
+	}
+	if err = checkTransferAccountsActive(sender, recipient); err != nil {
+		return nil, err
+	}
+	if sender.Balance < amount {
+		log.Logger.Warnf("Insufficient balance for user ID %d: balance %d, required %d", fromUserId, sender.Balance, amount)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_INSUFFICIENT_BALANCE), Message: "insufficient balance"}
+	}
+	var outLog *model.UserAccountChangeLog
+	err = u.txBeginner.Transaction(func(tx *gorm.DB) error {
+		from, to, err := u.lockTransferAccounts(ctx, sender, recipient, tx)
+		if err != nil {
+			return err
+		}
+		if err = checkTransferAccountsActive(from, to); err != nil {
+			return err
+		}
+		if from.Balance < amount {
+			log.Logger.Warnf("Insufficient balance for user ID %d: balance %d, required %d", fromUserId, from.Balance, amount)
+			return &bizerror.BizError{Code: int(paymentpb.RespCode_INSUFFICIENT_BALANCE), Message: "insufficient balance"}
+		}
+		if err = u.checkTransferLimit(ctx, from, amount, tx); err != nil {
+			return err
+		}
+		now := time.Now()
+		outLog = &model.UserAccountChangeLog{
+			AccountId:     from.ID,
+			OpType:        model.OpTypeTransferOut,
+			Amount:        amount,
+			IdempotentKey: transferId,
+			CreatedAt:     now,
+		}
+		outLog.SetBalance(from.Balance)
+		if err = u.userAccountChangeLogDao.CreateChangeLogInTransaction(ctx, outLog, tx); err != nil {
+			log.Logger.Errorf("Failed to create transfer-out change log for user ID %d: %v", from.UserId, err)
+			return err
+		}
+		inLog := &model.UserAccountChangeLog{
+			AccountId:     to.ID,
+			OpType:        model.OpTypeTransferIn,
+			Amount:        amount,
+			OriginLogId:   outLog.ID,
+			IdempotentKey: transferId,
+			CreatedAt:     now,
+		}
+		inLog.SetBalance(to.Balance)
+		if err = u.userAccountChangeLogDao.CreateChangeLogInTransaction(ctx, inLog, tx); err != nil {
+			log.Logger.Errorf("Failed to create transfer-in change log for user ID %d: %v", to.UserId, err)
+			return err
+		}
+		entry := ledger.NewEntry(model.OpTypeTransferOut, outLog.ID).
+			Debit(ledger.Wallet(from), amount).
+			Credit(ledger.Wallet(to), amount)
+		if _, err = u.ledger.PostInTransaction(ctx, entry, tx); err != nil {
+			log.Logger.Errorf("Failed to post transfer to the ledger for user ID %d: %v", from.UserId, err)
+			return err
+		}
+		if _, err = u.userAccountDao.SubtractBalanceInTransaction(ctx, from.UserId, amount, from.Balance, tx); err != nil {
+			log.Logger.Errorf("Failed to subtract balance for user ID %d: %v", from.UserId, err)
+			return err
+		}
+		if err = u.userAccountDao.AddBalanceInTransaction(ctx, to.UserId, amount, to.Balance, tx); err != nil {
+			log.Logger.Errorf("Failed to add balance for user ID %d: %v", to.UserId, err)
+			return err
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// a concurrent request with the same transferId won the race
+		original, err := u.checkTransferReplay(ctx, sender, recipient, transferId, amount)
+		if original != nil || err != nil {
+			return original, err
+		}
+	}
+	if err != nil {
+		log.Logger.Errorf("Transfer transaction failed for user ID %d: %v", fromUserId, err)
+		if bizErr, ok := err.(*bizerror.BizError); ok {
+			return nil, bizErr
+		}
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "transaction failed", Err: err}
+	}
+	log.Logger.Infof("Successfully transferred %d from user ID %d to account no %s, transfer ID %s", amount, fromUserId, toAccountNo, transferId)
+	return outLog, nil
+}
+
+// lockTransferAccounts locks the sender and the recipient in account id order and returns their fresh rows.
+func (u *UserAccountServiceImpl) lockTransferAccounts(ctx context.Context, sender *model.UserAccount, recipient *model.UserAccount, tx *gorm.DB) (*model.UserAccount, *model.UserAccount, error) {
+	first, second := sender, recipient
+	if second.ID < first.ID {
+		first, second = second, first
+	}
+	locked := make(map[int]*model.UserAccount, 2)
+	for _, account := range []*model.UserAccount{first, second} {
+		lockedAccount, err := u.userAccountDao.LockUserAccountInTransaction(ctx, account.UserId, tx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if lockedAccount == nil {
+			return nil, nil, &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_EXIST), Message: "user account not found"}
+		}
+		locked[account.ID] = lockedAccount
+	}
+	return locked[sender.ID], locked[recipient.ID], nil
+}
+
+// checkTransferLimit rejects the transfer if it would take today's transfers of the account over the limits.
+func (u *UserAccountServiceImpl) checkTransferLimit(ctx context.Context, account *model.UserAccount, amount int, tx *gorm.DB) error {
+	if u.transferLimit.dailyAmount <= 0 && u.transferLimit.dailyCount <= 0 {
+		return nil
+	}
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	count, total, err := u.userAccountChangeLogDao.SumChangeLogsInTransaction(ctx, account.ID, model.OpTypeTransferOut, startOfDay, tx)
+	if err != nil {
+		return err
+	}
+	if u.transferLimit.dailyCount > 0 && count+1 > u.transferLimit.dailyCount {
+		log.Logger.Warnf("Daily transfer count limit reached for user ID %d: %d transfers today", account.UserId, count)
+		return &bizerror.BizError{Code: int(paymentpb.RespCode_TRANSFER_LIMIT_EXCEEDED), Message: "daily transfer count limit reached"}
+	}
+	if u.transferLimit.dailyAmount > 0 && total+amount > u.transferLimit.dailyAmount {
+		log.Logger.Warnf("Daily transfer amount limit exceeded for user ID %d: transferred %d today, required %d", account.UserId, total, amount)
+		return &bizerror.BizError{Code: int(paymentpb.RespCode_TRANSFER_LIMIT_EXCEEDED), Message: "daily transfer amount limit exceeded"}
+	}
+	return nil
+}
+
+// checkTransferReplay looks for an earlier transfer with the same transferId.
+// A faithful replay returns the original transfer-out change log along with a DUPLICATE_REQUEST error,
+// a replay with a different sender, recipient or amount is rejected with IDEMPOTENCY_KEY_CONFLICT.
+func (u *UserAccountServiceImpl) checkTransferReplay(ctx context.Context, sender *model.UserAccount, recipient *model.UserAccount, transferId string, amount int) (*model.UserAccountChangeLog, error) {
+	outLog, err := u.userAccountChangeLogDao.GetChangeLogByIdempotentKey(ctx, transferId, model.OpTypeTransferOut)
+	if err != nil {
+		log.Logger.Errorf("Failed to get change log for transfer ID %s: %v", transferId, err)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get change log", Err: err}
+	}
+	if outLog == nil {
+		return nil, nil
+	}
+	inLog, err := u.userAccountChangeLogDao.GetChangeLogByIdempotentKey(ctx, transferId, model.OpTypeTransferIn)
+	if err != nil {
+		log.Logger.Errorf("Failed to get change log for transfer ID %s: %v", transferId, err)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get change log", Err: err}
+	}
+	if outLog.AccountId != sender.ID || outLog.Amount != amount || inLog == nil || inLog.AccountId != recipient.ID {
+		log.Logger.Warnf("Conflicting replay for transfer ID %s: original account %d amount %d, got account %d amount %d", transferId, outLog.AccountId, outLog.Amount, sender.ID, amount)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_IDEMPOTENCY_KEY_CONFLICT), Message: "transferId already used by a different transfer"}
+	}
+	log.Logger.Infof("Duplicate transfer request for transfer ID %s", transferId)
+	return outLog, &bizerror.BizError{Code: int(paymentpb.RespCode_DUPLICATE_REQUEST), Message: "duplicate request"}
+}
+
+// checkTransferAccountsActive rejects a transfer from or to a frozen or closed account. The status of the
+// recipient is not revealed to the sender.
+func checkTransferAccountsActive(sender *model.UserAccount, recipient *model.UserAccount) error {
+	if err := checkAccountActive(sender); err != nil {
+		return err
+	}
+	if !recipient.IsActive() {
+		log.Logger.Warnf("Recipient account of user ID %d is %s", recipient.UserId, recipient.StatusName())
+		return &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_ACTIVE), Message: "recipient pay account cannot receive transfers"}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao/mocks"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+)
+
+func TestTransfer(t *testing.T) {
+	ctx := context.Background()
+	fromUserId := 2
+	toAccountNo := "100000000001"
+	transferId := "test-transfer-id"
+	amount := 30
+	initEnv()
+
+	newAccounts := func() (*model.UserAccount, *model.UserAccount) {
+		return &model.UserAccount{ID: 5, UserId: fromUserId, AccountNo: "100000000005", Balance: 100, Status: model.UserAccountStatusActive},
+			&model.UserAccount{ID: 1, UserId: 1, AccountNo: toAccountNo, Balance: 10, Status: model.UserAccountStatusActive}
+	}
+
+	t.Run("should move balance and write paired change logs", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+			transferLimit:           transferLimit{dailyAmount: 100, dailyCount: 3},
+		}
+
+		sender, recipient := newAccounts()
+		userAccountDao.On("GetUserAccountByUserID", ctx, fromUserId).Return(sender, nil).Once()
+		userAccountDao.On("GetUserAccountByAccountNo", ctx, toAccountNo).Return(recipient, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, transferId, model.OpTypeTransferOut).Return(nil, nil).Once()
+		// the recipient has the smaller account id and is locked first
+		lockRecipient := userAccountDao.On("LockUserAccountInTransaction", ctx, recipient.UserId, mock.Anything).Return(recipient, nil).Once()
+		userAccountDao.On("LockUserAccountInTransaction", ctx, fromUserId, mock.Anything).Return(sender, nil).Once().NotBefore(lockRecipient)
+		userAccountChangeLogDao.On("SumChangeLogsInTransaction", ctx, sender.ID, model.OpTypeTransferOut, mock.Anything, mock.Anything).Return(2, 60, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.MatchedBy(func(l *model.UserAccountChangeLog) bool {
+			return l.OpType == model.OpTypeTransferOut && l.AccountId == sender.ID && l.BalanceBefore == 100 && l.BalanceAfter == 70
+		}), mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(*model.UserAccountChangeLog).ID = 11
+		}).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.MatchedBy(func(l *model.UserAccountChangeLog) bool {
+			return l.OpType == model.OpTypeTransferIn && l.AccountId == recipient.ID && l.OriginLogId == 11 &&
+				l.IdempotentKey == transferId && l.BalanceBefore == 10 && l.BalanceAfter == 40
+		}), mock.Anything).Return(nil).Once()
+		userAccountDao.On("SubtractBalanceInTransaction", ctx, fromUserId, amount, sender.Balance, mock.Anything).Return(1, nil).Once()
+		userAccountDao.On("AddBalanceInTransaction", ctx, recipient.UserId, amount, recipient.Balance, mock.Anything).Return(nil).Once()
+
+		outLog, err := service.Transfer(ctx, fromUserId, toAccountNo, amount, transferId)
+		assert.NoError(t, err)
+		assert.Equal(t, 70, outLog.BalanceAfter)
+		userAccountDao.AssertExpectations(t)
+		userAccountChangeLogDao.AssertExpectations(t)
+	})
+
+	t.Run("should return error if recipient not found", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		service := &UserAccountServiceImpl{
+			userAccountDao: userAccountDao,
+		}
+
+		sender, _ := newAccounts()
+		userAccountDao.On("GetUserAccountByUserID", ctx, fromUserId).Return(sender, nil).Once()
+		userAccountDao.On("GetUserAccountByAccountNo", ctx, toAccountNo).Return(nil, nil).Once()
+
+		_, err := service.Transfer(ctx, fromUserId, toAccountNo, amount, transferId)
+		assert.Equal(t, paymentpb.RespCode_RECIPIENT_NOT_EXIST, bizerror.RespCodeOf(err))
+	})
+
+	t.Run("should reject transfer to own account", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		service := &UserAccountServiceImpl{
+			userAccountDao: userAccountDao,
+		}
+
+		sender, _ := newAccounts()
+		userAccountDao.On("GetUserAccountByUserID", ctx, fromUserId).Return(sender, nil).Once()
+		userAccountDao.On("GetUserAccountByAccountNo", ctx, sender.AccountNo).Return(sender, nil).Once()
+
+		_, err := service.Transfer(ctx, fromUserId, sender.AccountNo, amount, transferId)
+		assert.Equal(t, paymentpb.RespCode_BAD_REQUEST, bizerror.RespCodeOf(err))
+	})
+
+	t.Run("should reject transfer from or to an inactive account", func(t *testing.T) {
+		for _, frozenSide := range []string{"sender", "recipient"} {
+			userAccountDao := new(mocks.UserAccountDao)
+			userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+			service := &UserAccountServiceImpl{
+				userAccountDao:          userAccountDao,
+				userAccountChangeLogDao: userAccountChangeLogDao,
+			}
+
+			sender, recipient := newAccounts()
+			if frozenSide == "sender" {
+				sender.Status = model.UserAccountStatusFrozen
+			} else {
+				recipient.Status = model.UserAccountStatusClosed
+			}
+			userAccountDao.On("GetUserAccountByUserID", ctx, fromUserId).Return(sender, nil).Once()
+			userAccountDao.On("GetUserAccountByAccountNo", ctx, toAccountNo).Return(recipient, nil).Once()
+			userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, transferId, model.OpTypeTransferOut).Return(nil, nil).Once()
+
+			_, err := service.Transfer(ctx, fromUserId, toAccountNo, amount, transferId)
+			assert.Equal(t, paymentpb.RespCode_ACCOUNT_NOT_ACTIVE, bizerror.RespCodeOf(err), frozenSide)
+			userAccountDao.AssertNotCalled(t, "LockUserAccountInTransaction", mock.Anything, mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("should return error if daily limit exceeded", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+			transferLimit:           transferLimit{dailyAmount: 100},
+		}
+
+		sender, recipient := newAccounts()
+		userAccountDao.On("GetUserAccountByUserID", ctx, fromUserId).Return(sender, nil).Once()
+		userAccountDao.On("GetUserAccountByAccountNo", ctx, toAccountNo).Return(recipient, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, transferId, model.OpTypeTransferOut).Return(nil, nil).Once()
+		userAccountDao.On("LockUserAccountInTransaction", ctx, recipient.UserId, mock.Anything).Return(recipient, nil).Once()
+		userAccountDao.On("LockUserAccountInTransaction", ctx, fromUserId, mock.Anything).Return(sender, nil).Once()
+		userAccountChangeLogDao.On("SumChangeLogsInTransaction", ctx, sender.ID, model.OpTypeTransferOut, mock.Anything, mock.Anything).Return(1, 80, nil).Once()
+
+		_, err := service.Transfer(ctx, fromUserId, toAccountNo, amount, transferId)
+		assert.Equal(t, paymentpb.RespCode_TRANSFER_LIMIT_EXCEEDED, bizerror.RespCodeOf(err))
+	})
+
+	t.Run("should return original transfer on replay", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		sender, recipient := newAccounts()
+		original := &model.UserAccountChangeLog{ID: 11, AccountId: sender.ID, OpType: model.OpTypeTransferOut, Amount: amount, IdempotentKey: transferId}
+		userAccountDao.On("GetUserAccountByUserID", ctx, fromUserId).Return(sender, nil).Once()
+		userAccountDao.On("GetUserAccountByAccountNo", ctx, toAccountNo).Return(recipient, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, transferId, model.OpTypeTransferOut).Return(original, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, transferId, model.OpTypeTransferIn).Return(&model.UserAccountChangeLog{ID: 12, AccountId: recipient.ID}, nil).Once()
+
+		outLog, err := service.Transfer(ctx, fromUserId, toAccountNo, amount, transferId)
+		assert.Equal(t, paymentpb.RespCode_DUPLICATE_REQUEST, bizerror.RespCodeOf(err))
+		assert.Equal(t, original, outLog)
+	})
+
+	t.Run("should reject replay with a different amount", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		sender, recipient := newAccounts()
+		original := &model.UserAccountChangeLog{ID: 11, AccountId: sender.ID, OpType: model.OpTypeTransferOut, Amount: amount + 1, IdempotentKey: transferId}
+		userAccountDao.On("GetUserAccountByUserID", ctx, fromUserId).Return(sender, nil).Once()
+		userAccountDao.On("GetUserAccountByAccountNo", ctx, toAccountNo).Return(recipient, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, transferId, model.OpTypeTransferOut).Return(original, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, transferId, model.OpTypeTransferIn).Return(&model.UserAccountChangeLog{ID: 12, AccountId: recipient.ID}, nil).Once()
+
+		outLog, err := service.Transfer(ctx, fromUserId, toAccountNo, amount, transferId)
+		assert.Equal(t, paymentpb.RespCode_IDEMPOTENCY_KEY_CONFLICT, bizerror.RespCodeOf(err))
+		assert.Nil(t, outLog)
+	})
+}
@@ -2,12 +2,19 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/config"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/event"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/http/data"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/ledger"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/log"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao"
@@ -18,10 +25,29 @@ import (
 
 type UserAccountService interface {
 	CreateUserAccount(ctx context.Context, userId int) (*model.UserAccount, error)
+	// UpdateUserAccountStatus moves the account of the user to status as of changedAt, the time of the user event
+	// asking for it. A change older than the last one is dropped, the account is returned as it is. A closed account
+	// stays closed, moving it fails with ACCOUNT_NOT_ACTIVE.
+	UpdateUserAccountStatus(ctx context.Context, userId int, status int, changedAt time.Time) (*model.UserAccount, error)
 	GetUserAccountByUserID(ctx context.Context, userId int) (*model.UserAccount, error)
 	UserAccountTopUp(ctx context.Context, userId int, redeemCode string) (*model.UserAccount, *model.RedeemCode, error)
+	// ValidateRedeemCode tells the user what the code is worth and whether a top-up with it would go through,
+	// without redeeming it. Who used a code is never revealed.
+	ValidateRedeemCode(ctx context.Context, userId int, redeemCode string) (*data.RedeemCodeValidation, error)
+	// PayOrder returns the original change log together with a DUPLICATE_REQUEST BizError for a replayed bizId.
 	PayOrder(ctx context.Context, userId int, bizId string, amount int) (*model.UserAccountChangeLog, error)
-	GetUserPayHistory(ctx context.Context, query *paymentpb.PayOrderQueryRequest) ([]*model.UserAccountChangeLog, error)
+	// GetUserPayHistory returns a page of payments and the token of the next page, empty on the last page.
+	GetUserPayHistory(ctx context.Context, query *paymentpb.PayOrderQueryRequest) ([]*model.UserAccountChangeLog, string, error)
+	GetUserTransactions(ctx context.Context, userId int, query *data.UserPayTransactionQuery) (*data.UserPayTransactionPage, error)
+	BackfillBalanceSnapshots(ctx context.Context) (int, error)
+	// RefundOrder refunds req.Amount of a payment under req.RefundId. A replayed refund id returns the original
+	// refund and its pay order along with a DUPLICATE_REQUEST error.
+	RefundOrder(ctx context.Context, req *paymentpb.RefundOrderRequest) (*model.UserAccountChangeLog, *model.UserAccountChangeLog, error)
+	// RefundCancelledOrder refunds what is left of the payment of a cancelled order. The refund happens at most
+	// once per order, calling it again returns the refund made the first time. It returns nil when the payment
+	// was refunded in full already.
+	RefundCancelledOrder(ctx context.Context, bizId string) (*model.UserAccountChangeLog, error)
+	Transfer(ctx context.Context, fromUserId int, toAccountNo string, amount int, transferId string) (*model.UserAccountChangeLog, error)
 }
 
 var (
@@ -35,7 +61,13 @@ func GetUserAccountService() UserAccountService {
 			userAccountDao:          dao.GetUserAccountDao(),
 			userAccountChangeLogDao: dao.GetUserAccountChangeLogDAO(),
 			redeemCodeDao:           dao.GetRedeemCodeDao(),
+			outboxDao:               dao.GetOutboxDao(),
+			ledger:                  ledger.GetLedger(),
 			txBeginner:              repository.DB,
+			balanceUpdatePolicy:     newBalanceUpdatePolicy(config.Config.PaymentConfig),
+			transferLimit:           newTransferLimit(config.Config.PaymentConfig),
+			codeHashSecret:          redeemCodeHashSecret(config.Config.PaymentConfig),
+			codeFormat:              newRedeemCodeFormat(config.Config.PaymentConfig),
 		}
 	})
 	return userAccountServiceInstance
@@ -45,7 +77,13 @@ type UserAccountServiceImpl struct {
 	userAccountDao          dao.UserAccountDao
 	userAccountChangeLogDao dao.UserAccountChangeLogDAO
 	redeemCodeDao           dao.RedeemCodeDao
+	outboxDao               dao.OutboxDao
+	ledger                  ledger.Ledger
 	txBeginner              repository.TxBeginner
+	balanceUpdatePolicy     balanceUpdatePolicy
+	transferLimit           transferLimit
+	codeHashSecret          []byte
+	codeFormat              *redeemCodeFormat
 }
 
 const userAccountNoSize = 12
@@ -68,6 +106,7 @@ func (u *UserAccountServiceImpl) CreateUserAccount(ctx context.Context, userId i
 	account = &model.UserAccount{
 		UserId:    userId,
 		AccountNo: utils.GenRedeemCode(userAccountNoSize),
+		Status:    model.UserAccountStatusActive,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -80,6 +119,71 @@ func (u *UserAccountServiceImpl) CreateUserAccount(ctx context.Context, userId i
 	return account, nil
 }
 
+// UpdateUserAccountStatus implements UserAccountService.
+func (u *UserAccountServiceImpl) UpdateUserAccountStatus(ctx context.Context, userId int, status int, changedAt time.Time) (*model.UserAccount, error) {
+	if status != model.UserAccountStatusActive && status != model.UserAccountStatusFrozen && status != model.UserAccountStatusClosed {
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_BAD_REQUEST), Message: fmt.Sprintf("invalid account status %d", status)}
+	}
+	// stored to the second
+	changedAt = changedAt.Truncate(time.Second)
+	account, err := u.userAccountDao.GetUserAccountByUserID(ctx, userId)
+	if err != nil {
+		log.Logger.Errorf("Failed to get user account for user ID %d: %v", userId, err)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get user account", Err: err}
+	}
+	if account == nil {
+		log.Logger.Warnf("User account not found for user ID %d", userId)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_EXIST), Message: "user account not found"}
+	}
+	closed := &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_ACTIVE), Message: "pay account is closed"}
+	if account.Status == model.UserAccountStatusClosed {
+		if status == model.UserAccountStatusClosed {
+			return account, nil
+		}
+		log.Logger.Warnf("User account of user ID %d is closed, not moving it to status %d", userId, status)
+		return nil, closed
+	}
+	if account.StatusChangedAt != nil && !account.StatusChangedAt.Before(changedAt) {
+		log.Logger.Infof("User account of user ID %d changed status at %v, dropping status %d of %v", userId, *account.StatusChangedAt, status, changedAt)
+		return account, nil
+	}
+	// written even when the status stays, so that an older change arriving later is dropped
+	updated, err := u.userAccountDao.UpdateStatus(ctx, userId, status, changedAt)
+	if err != nil {
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to update account status", Err: err}
+	}
+	if updated == 0 {
+		// closed or moved by a newer event in the meantime
+		account, err = u.userAccountDao.GetUserAccountByUserID(ctx, userId)
+		if err != nil {
+			log.Logger.Errorf("Failed to get user account for user ID %d: %v", userId, err)
+			return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get user account", Err: err}
+		}
+		if account == nil || (account.Status == model.UserAccountStatusClosed && status != model.UserAccountStatusClosed) {
+			log.Logger.Warnf("User account of user ID %d was closed concurrently, not moving it to status %d", userId, status)
+			return nil, closed
+		}
+		log.Logger.Infof("User account of user ID %d was moved by a newer event concurrently, dropping status %d of %v", userId, status, changedAt)
+		return account, nil
+	}
+	previous := account.StatusName()
+	account.Status = status
+	account.StatusChangedAt = &changedAt
+	if previous != account.StatusName() {
+		log.Logger.Infof("User account of user ID %d moved from %s to %s", userId, previous, account.StatusName())
+	}
+	return account, nil
+}
+
+// checkAccountActive returns an ACCOUNT_NOT_ACTIVE BizError for a frozen or closed account.
+func checkAccountActive(account *model.UserAccount) error {
+	if account.IsActive() {
+		return nil
+	}
+	log.Logger.Warnf("User account of user ID %d is %s", account.UserId, account.StatusName())
+	return &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_ACTIVE), Message: "pay account is " + account.StatusName()}
+}
+
 // GetUserAccountByUserID implements UserAccountService.
 func (u *UserAccountServiceImpl) GetUserAccountByUserID(ctx context.Context, userId int) (*model.UserAccount, error) {
 	account, err := u.userAccountDao.GetUserAccountByUserID(ctx, userId)
@@ -105,23 +209,48 @@ func (u *UserAccountServiceImpl) PayOrder(ctx context.Context, userId int, bizId
 		log.Logger.Warnf("User account not found for user ID %d", userId)
 		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_EXIST), Message: "user account not found"}
 	}
+	// a replay must be answered before the balance check, the original payment already spent the money
+	original, err := u.checkPayOrderReplay(ctx, account, bizId, amount)
+	if original != nil || err != nil {
+		return original, err
+	}
+	if err = checkAccountActive(account); err != nil {
+		return nil, err
+	}
 	if account.Balance < amount {
 		log.Logger.Warnf("Insufficient balance for user ID %d: balance %d, required %d", userId, account.Balance, amount)
 		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_INSUFFICIENT_BALANCE), Message: "insufficient balance"}
 	}
-	changeLog := &model.UserAccountChangeLog{
-		AccountId:     account.ID,
-		OpType:        model.OpTypePayment,
-		Amount:        amount,
-		IdempotentKey: bizId,
-		CreatedAt:     time.Now(),
-	}
-	err = u.txBeginner.Transaction(func(tx *gorm.DB) error {
-		err = u.userAccountChangeLogDao.CreateChangeLogInTransaction(ctx, changeLog, tx)
+	var changeLog *model.UserAccountChangeLog
+	err = u.balanceUpdatePolicy.run(ctx, "pay_order", u.userAccountDao, u.txBeginner, account, func(tx *gorm.DB, account *model.UserAccount) error {
+		// the account may have been frozen and the balance changed since the first read when the attempt is retried
+		if err := checkAccountActive(account); err != nil {
+			return err
+		}
+		if account.Balance < amount {
+			log.Logger.Warnf("Insufficient balance for user ID %d: balance %d, required %d", userId, account.Balance, amount)
+			return &bizerror.BizError{Code: int(paymentpb.RespCode_INSUFFICIENT_BALANCE), Message: "insufficient balance"}
+		}
+		changeLog = &model.UserAccountChangeLog{
+			AccountId:     account.ID,
+			OpType:        model.OpTypePayment,
+			Amount:        amount,
+			IdempotentKey: bizId,
+			CreatedAt:     time.Now(),
+		}
+		changeLog.SetBalance(account.Balance)
+		err := u.userAccountChangeLogDao.CreateChangeLogInTransaction(ctx, changeLog, tx)
 		if err != nil {
 			log.Logger.Errorf("Failed to create user account change log for user ID %d: %v", userId, err)
 			return err
 		}
+		entry := ledger.NewEntry(model.OpTypePayment, changeLog.ID).
+			Debit(ledger.Wallet(account), amount).
+			Credit(ledger.MerchantRevenue, amount)
+		if _, err = u.ledger.PostInTransaction(ctx, entry, tx); err != nil {
+			log.Logger.Errorf("Failed to post payment to the ledger for user ID %d: %v", userId, err)
+			return err
+		}
 		rowsAffected, err := u.userAccountDao.SubtractBalanceInTransaction(ctx, userId, amount, account.Balance, tx)
 		if err != nil {
 			log.Logger.Errorf("Failed to subtract balance for user ID %d: %v", userId, err)
@@ -131,18 +260,60 @@ func (u *UserAccountServiceImpl) PayOrder(ctx context.Context, userId int, bizId
 			log.Logger.Errorf("No user account found to subtract balance for user ID %d", userId)
 			return &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to subtract balance"}
 		}
-		return nil
+		return createOutboxEventInTransaction(ctx, u.outboxDao, event.TypePaymentSucceeded, event.PaymentSucceededVersion, strconv.Itoa(userId), &event.PaymentSucceeded{
+			PayOrderId:   changeLog.GetPayOrderId(),
+			BizId:        bizId,
+			UserId:       userId,
+			AccountId:    account.ID,
+			Amount:       amount,
+			BalanceAfter: changeLog.BalanceAfter,
+			PaidAt:       changeLog.CreatedAt.Unix(),
+		}, tx)
 	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// a concurrent request with the same bizId won the race
+		original, err := u.checkPayOrderReplay(ctx, account, bizId, amount)
+		if original != nil || err != nil {
+			return original, err
+		}
+	}
 	if err != nil {
 		log.Logger.Errorf("Transaction failed for user ID %d: %v", userId, err)
+		if bizErr, ok := err.(*bizerror.BizError); ok {
+			return nil, bizErr
+		}
 		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "transaction failed", Err: err}
 	}
 	log.Logger.Infof("Successfully paid order for user ID %d, amount %d, biz ID %s", userId, amount, bizId)
 	return changeLog, nil
 }
 
+// checkPayOrderReplay looks for an earlier payment with the same bizId.
+// A faithful replay returns the original change log along with a DUPLICATE_REQUEST error,
+// a replay with a different user or amount is rejected with IDEMPOTENCY_KEY_CONFLICT.
+func (u *UserAccountServiceImpl) checkPayOrderReplay(ctx context.Context, account *model.UserAccount, bizId string, amount int) (*model.UserAccountChangeLog, error) {
+	original, err := u.userAccountChangeLogDao.GetChangeLogByIdempotentKey(ctx, bizId, model.OpTypePayment)
+	if err != nil {
+		log.Logger.Errorf("Failed to get change log for biz ID %s: %v", bizId, err)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get change log", Err: err}
+	}
+	if original == nil {
+		return nil, nil
+	}
+	if original.AccountId != account.ID || original.Amount != amount {
+		log.Logger.Warnf("Conflicting replay for biz ID %s: original account %d amount %d, got account %d amount %d", bizId, original.AccountId, original.Amount, account.ID, amount)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_IDEMPOTENCY_KEY_CONFLICT), Message: "bizId already used by a different payment"}
+	}
+	log.Logger.Infof("Duplicate pay order request for biz ID %s, returning pay order %s", bizId, original.GetPayOrderId())
+	return original, &bizerror.BizError{Code: int(paymentpb.RespCode_DUPLICATE_REQUEST), Message: "duplicate request"}
+}
+
 // UserAccountTopUp implements UserAccountService.
 func (u *UserAccountServiceImpl) UserAccountTopUp(ctx context.Context, userId int, redeemCode string) (*model.UserAccount, *model.RedeemCode, error) {
+	redeemCode, err := u.normalizeRedeemCode(userId, redeemCode)
+	if err != nil {
+		return nil, nil, err
+	}
 	account, err := u.userAccountDao.GetUserAccountByUserID(ctx, userId)
 	if err != nil {
 		log.Logger.Errorf("Failed to get user account for user ID %d: %v", userId, err)
@@ -150,86 +321,507 @@ func (u *UserAccountServiceImpl) UserAccountTopUp(ctx context.Context, userId in
 	}
 	if account == nil {
 		log.Logger.Warnf("User account not found for user ID %d", userId)
-		return nil, nil, fmt.Errorf("user account not found")
+		return nil, nil, &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_EXIST), Message: "user account not found"}
 	}
-	redeemCodeRecord, err := u.redeemCodeDao.GetByCode(ctx, redeemCode)
+	if err = checkAccountActive(account); err != nil {
+		return nil, nil, err
+	}
+	redeemCodeRecord, err := u.getRedeemCode(ctx, userId, redeemCode)
 	if err != nil {
-		log.Logger.Errorf("Failed to get redeem code %s: %v", redeemCode, err)
-		return nil, nil, fmt.Errorf("failed to get redeem code")
+		return nil, nil, err
 	}
-	if redeemCodeRecord == nil {
-		log.Logger.Warnf("Redeem code not found: %s", redeemCode)
-		return nil, nil, fmt.Errorf("invalid redeem code")
-	}
-	if redeemCodeRecord.UsedUserId != 0 {
-		log.Logger.Warnf("Redeem code already used: %s", redeemCode)
-		return nil, nil, fmt.Errorf("redeem code already used")
-	}
-	changeLog := &model.UserAccountChangeLog{
-		AccountId:     account.ID,
-		OpType:        model.OpTypeTopUp,
-		Amount:        redeemCodeRecord.Amount,
-		IdempotentKey: redeemCode,
-		CreatedAt:     time.Now(),
-	}
-	err = u.txBeginner.Transaction(func(tx *gorm.DB) error {
-		redeemCodeRecord.UsedUserId = userId
-		ret, err := u.redeemCodeDao.UseRedeemCodeInTransaction(ctx, redeemCodeRecord, tx)
-		if err != nil {
-			log.Logger.Errorf("Failed to mark redeem code %s as used: %v", redeemCode, err)
+	if err = checkRedeemable(redeemCodeRecord, time.Now()); err != nil {
+		return nil, nil, err
+	}
+	err = u.balanceUpdatePolicy.run(ctx, "top_up", u.userAccountDao, u.txBeginner, account, func(tx *gorm.DB, account *model.UserAccount) error {
+		if err := checkAccountActive(account); err != nil {
 			return err
 		}
-		if ret == 0 {
-			log.Logger.Errorf("Redeem code %s was already used by another user", redeemCode)
-			return fmt.Errorf("redeem code was already used")
+		changeLog := &model.UserAccountChangeLog{
+			AccountId:     account.ID,
+			OpType:        model.OpTypeTopUp,
+			Amount:        redeemCodeRecord.Amount,
+			IdempotentKey: redeemCodeRecord.TopUpIdempotentKey(userId, 0),
+			CreatedAt:     time.Now(),
+		}
+		changeLog.SetBalance(account.Balance)
+		var redemption *model.RedeemCodeRedemption
+		if redeemCodeRecord.IsPromo() {
+			if redemption, err = u.claimPromoCodeInTransaction(ctx, redeemCodeRecord, account, tx); err != nil {
+				return err
+			}
+			changeLog.IdempotentKey = redeemCodeRecord.TopUpIdempotentKey(userId, redemption.Seq)
+		} else {
+			redeemCodeRecord.UsedUserId = userId
+			ret, err := u.redeemCodeDao.UseRedeemCodeInTransaction(ctx, redeemCodeRecord, tx)
+			if err != nil {
+				log.Logger.Errorf("Failed to mark redeem code %d as used: %v", redeemCodeRecord.ID, err)
+				return err
+			}
+			if ret == 0 {
+				log.Logger.Errorf("Redeem code %d was already used by another user", redeemCodeRecord.ID)
+				return &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_USED), Message: "redeem code was already used"}
+			}
+			log.Logger.Infof("Redeem code %d marked as used by user ID %d", redeemCodeRecord.ID, userId)
 		}
-		log.Logger.Infof("Redeem code %s marked as used by user ID %d", redeemCode, userId)
 		err = u.userAccountChangeLogDao.CreateChangeLogInTransaction(ctx, changeLog, tx)
+		if redemption != nil && errors.Is(err, gorm.ErrDuplicatedKey) {
+			// the key carries the redemption number, a concurrent top-up by the same user committed it first
+			return gorm.ErrCheckConstraintViolated
+		}
 		if err != nil {
 			log.Logger.Errorf("Failed to create user account change log for user ID %d: %v", userId, err)
 			return err
 		}
-		log.Logger.Infof("User account change log created for user ID %d, amount %d, redeem code %s", userId, redeemCodeRecord.Amount, redeemCode)
+		log.Logger.Infof("User account change log created for user ID %d, amount %d, redeem code %d", userId, redeemCodeRecord.Amount, redeemCodeRecord.ID)
+		if redemption != nil {
+			redemption.ChangeLogId = changeLog.ID
+			err = u.redeemCodeDao.CreateRedemptionInTransaction(ctx, redemption, tx)
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				// a concurrent top-up by the same user took this redemption number, count again
+				return gorm.ErrCheckConstraintViolated
+			}
+			if err != nil {
+				return err
+			}
+		}
+		entry := ledger.NewEntry(model.OpTypeTopUp, changeLog.ID).
+			Debit(ledger.RedeemCodeLiability, redeemCodeRecord.Amount).
+			Credit(ledger.Wallet(account), redeemCodeRecord.Amount)
+		if _, err = u.ledger.PostInTransaction(ctx, entry, tx); err != nil {
+			log.Logger.Errorf("Failed to post top-up to the ledger for user ID %d: %v", userId, err)
+			return err
+		}
 		err = u.userAccountDao.AddBalanceInTransaction(ctx, userId, int(redeemCodeRecord.Amount), account.Balance, tx)
 		if err != nil {
 			log.Logger.Errorf("Failed to add balance for user ID %d: %v", userId, err)
 			return err
 		}
 		log.Logger.Infof("Balance added for user ID %d, amount %d", userId, redeemCodeRecord.Amount)
-		return nil
+		return createOutboxEventInTransaction(ctx, u.outboxDao, event.TypeAccountToppedUp, event.AccountToppedUpVersion, strconv.Itoa(userId), &event.AccountToppedUp{
+			UserId:       userId,
+			AccountId:    account.ID,
+			ChangeLogId:  changeLog.ID,
+			RedeemCodeId: redeemCodeRecord.ID,
+			Amount:       redeemCodeRecord.Amount,
+			BalanceAfter: changeLog.BalanceAfter,
+			ToppedUpAt:   changeLog.CreatedAt.Unix(),
+		}, tx)
 	})
 	if err != nil {
 		log.Logger.Errorf("Transaction failed for user ID %d: %v", userId, err)
-		return nil, nil, err
+		if bizErr, ok := err.(*bizerror.BizError); ok {
+			return nil, nil, bizErr
+		}
+		return nil, nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "transaction failed", Err: err}
+	}
+	if redeemCodeRecord.IsPromo() {
+		redeemCodeRecord.RedeemedCount++
 	}
-	log.Logger.Infof("Successfully topped up user account for user ID %d, amount %d, redeem code %s", userId, redeemCodeRecord.Amount, redeemCode)
+	log.Logger.Infof("Successfully topped up user account for user ID %d, amount %d, redeem code %d", userId, redeemCodeRecord.Amount, redeemCodeRecord.ID)
 	userAccount, err := u.userAccountDao.GetUserAccountByUserID(ctx, userId)
 	return userAccount, redeemCodeRecord, err
 }
 
-func (u *UserAccountServiceImpl) GetUserPayHistory(ctx context.Context, query *paymentpb.PayOrderQueryRequest) ([]*model.UserAccountChangeLog, error) {
+// normalizeRedeemCode turns the code typed by the user into canonical form, a typo fails on its check character
+// without a database round trip.
+func (u *UserAccountServiceImpl) normalizeRedeemCode(userId int, input string) (string, error) {
+	redeemCode, err := u.codeFormat.Normalize(input)
+	if err != nil {
+		log.Logger.Warnf("Malformed redeem code from user ID %d", userId)
+		return "", &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_MALFORMED), Message: "malformed redeem code", Err: err}
+	}
+	return redeemCode, nil
+}
+
+// getRedeemCode looks up a canonical code, returning REDEEM_CODE_INVALID when there is no such code.
+func (u *UserAccountServiceImpl) getRedeemCode(ctx context.Context, userId int, redeemCode string) (*model.RedeemCode, error) {
+	// only the suffix of the code is ever logged, a log line must not be enough to spend it
+	redeemCodeRecord, err := u.redeemCodeDao.GetByCode(ctx, utils.HashRedeemCode(u.codeHashSecret, redeemCode))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Logger.Errorf("Failed to get redeem code for user ID %d: %v", userId, err)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get redeem code", Err: err}
+	}
+	if redeemCodeRecord == nil {
+		log.Logger.Warnf("Redeem code ending in %s not found for user ID %d", utils.RedeemCodeSuffix(redeemCode), userId)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_INVALID), Message: "invalid redeem code"}
+	}
+	return redeemCodeRecord, nil
+}
+
+// checkRedeemable returns the BizError a top-up with the code fails with at now, nil if the code can be redeemed.
+// The per-user limit of promo codes is left to the caller.
+func checkRedeemable(redeemCode *model.RedeemCode, now time.Time) error {
+	if redeemCode.UsedUserId != 0 {
+		log.Logger.Warnf("Redeem code %d already used by user ID %d", redeemCode.ID, redeemCode.UsedUserId)
+		return &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_USED), Message: "redeem code already used"}
+	}
+	if redeemCode.Exhausted() {
+		log.Logger.Warnf("Promo code %d reached its cap of %d redemptions", redeemCode.ID, redeemCode.MaxRedemptions)
+		return &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_EXHAUSTED), Message: "redeem code has been fully redeemed"}
+	}
+	if redeemCode.RevokedAt != nil {
+		log.Logger.Warnf("Redeem code %d was revoked at %v", redeemCode.ID, *redeemCode.RevokedAt)
+		return &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_REVOKED), Message: "redeem code has been revoked"}
+	}
+	if redeemCode.NotYetValid(now) {
+		log.Logger.Warnf("Redeem code %d is not valid until %v", redeemCode.ID, *redeemCode.ValidFrom)
+		return &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_NOT_YET_VALID), Message: "redeem code is not valid yet"}
+	}
+	if redeemCode.Expired(now) {
+		log.Logger.Warnf("Redeem code %d expired at %v", redeemCode.ID, *redeemCode.ValidUntil)
+		return &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_EXPIRED), Message: "redeem code has expired"}
+	}
+	return nil
+}
+
+// ValidateRedeemCode implements UserAccountService.
+func (u *UserAccountServiceImpl) ValidateRedeemCode(ctx context.Context, userId int, redeemCode string) (*data.RedeemCodeValidation, error) {
+	redeemCode, err := u.normalizeRedeemCode(userId, redeemCode)
+	if err != nil {
+		return nil, err
+	}
+	redeemCodeRecord, err := u.getRedeemCode(ctx, userId, redeemCode)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	validation := &data.RedeemCodeValidation{
+		CodeSuffix: redeemCodeRecord.CodeSuffix,
+		Type:       redeemCodeRecord.Type,
+		Amount:     redeemCodeRecord.Amount,
+		Status:     redeemCodeRecord.StatusAt(now),
+	}
+	if redeemCodeRecord.ValidFrom != nil {
+		validation.ValidFrom = redeemCodeRecord.ValidFrom.Unix()
+	}
+	if redeemCodeRecord.ValidUntil != nil {
+		validation.ValidUntil = redeemCodeRecord.ValidUntil.Unix()
+	}
+	unavailable := checkRedeemable(redeemCodeRecord, now)
+	if unavailable == nil && redeemCodeRecord.IsPromo() && redeemCodeRecord.PerUserLimit > 0 {
+		redeemed, err := u.redeemCodeDao.CountRedemptions(ctx, redeemCodeRecord.ID, userId)
+		if err != nil {
+			return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to count redemptions", Err: err}
+		}
+		if redeemed >= redeemCodeRecord.PerUserLimit {
+			unavailable = &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_USER_LIMIT_REACHED), Message: "redeem code redemption limit reached for this user"}
+		}
+	}
+	validation.Redeemable = unavailable == nil
+	if unavailable != nil {
+		validation.Reason = bizerror.RespCodeOf(unavailable).String()
+	}
+	return validation, nil
+}
+
+// claimPromoCodeInTransaction checks the per-user limit of the promo code, counts one more redemption against
+// its global cap and returns the redemption to record once the top-up change log exists.
+func (u *UserAccountServiceImpl) claimPromoCodeInTransaction(ctx context.Context, redeemCode *model.RedeemCode, account *model.UserAccount, tx *gorm.DB) (*model.RedeemCodeRedemption, error) {
+	redeemed, err := u.redeemCodeDao.CountRedemptionsInTransaction(ctx, redeemCode.ID, account.UserId, tx)
+	if err != nil {
+		return nil, err
+	}
+	if redeemCode.PerUserLimit > 0 && redeemed >= redeemCode.PerUserLimit {
+		log.Logger.Warnf("User ID %d already redeemed promo code %d %d times", account.UserId, redeemCode.ID, redeemed)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_USER_LIMIT_REACHED), Message: "redeem code redemption limit reached for this user"}
+	}
+	ret, err := u.redeemCodeDao.RedeemPromoCodeInTransaction(ctx, redeemCode, tx)
+	if err != nil {
+		return nil, err
+	}
+	if ret == 0 {
+		log.Logger.Warnf("Promo code %d was fully redeemed or revoked concurrently", redeemCode.ID)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_REDEEM_CODE_EXHAUSTED), Message: "redeem code has been fully redeemed"}
+	}
+	return &model.RedeemCodeRedemption{
+		RedeemCodeId: redeemCode.ID,
+		UserId:       account.UserId,
+		Seq:          redeemed + 1,
+		AccountId:    account.ID,
+		Amount:       redeemCode.Amount,
+	}, nil
+}
+
+// GetUserPayHistory implements UserAccountService.
+func (u *UserAccountServiceImpl) GetUserPayHistory(ctx context.Context, query *paymentpb.PayOrderQueryRequest) ([]*model.UserAccountChangeLog, string, error) {
+	beforeId, err := utils.DecodeCursor(query.GetPageToken())
+	if err != nil {
+		log.Logger.Warnf("Invalid page token %s", query.GetPageToken())
+		return nil, "", &bizerror.BizError{Code: int(paymentpb.RespCode_BAD_REQUEST), Message: "invalid page token", Err: err}
+	}
 	var accountId *int
 	if query.UserId > 0 {
 		account, err := u.userAccountDao.GetUserAccountByUserID(ctx, int(query.UserId))
 		if err != nil {
 			log.Logger.Errorf("Failed to get user account for user ID %d: %v", query.UserId, err)
-			return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get user account", Err: err}
+			return nil, "", &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get user account", Err: err}
 		}
 		if account == nil {
 			log.Logger.Warnf("User account not found for user ID %d", query.UserId)
-			return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_EXIST), Message: "user account not found"}
+			return nil, "", &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_EXIST), Message: "user account not found"}
 		}
 		accountId = &account.ID
 	}
-	changeLogs, err := u.userAccountChangeLogDao.QueryChangeLogs(ctx,
-		&model.UserAccountChangeLogQuery{
-			AccountId:     accountId,
-			IdempotentKey: query.BizId,
-			OpType:        model.OpTypePayment,
-		})
+	dbQuery := &model.UserAccountChangeLogQuery{
+		AccountId:     accountId,
+		IdempotentKey: query.BizId,
+		OpType:        model.OpTypePayment,
+		BeforeId:      beforeId,
+	}
+	if query.StartTime != nil {
+		startTime := time.Unix(*query.StartTime, 0)
+		dbQuery.StartTime = &startTime
+	}
+	if query.EndTime != nil {
+		endTime := time.Unix(*query.EndTime, 0)
+		dbQuery.EndTime = &endTime
+	}
+	return u.queryChangeLogPage(ctx, dbQuery, int(query.GetQuerySize()))
+}
+
+// GetUserTransactions implements UserAccountService.
+// It lists the change logs on the user's account newest first, one page after the query cursor.
+func (u *UserAccountServiceImpl) GetUserTransactions(ctx context.Context, userId int, query *data.UserPayTransactionQuery) (*data.UserPayTransactionPage, error) {
+	beforeId, err := utils.DecodeCursor(query.Cursor)
+	if err != nil {
+		log.Logger.Warnf("Invalid transaction cursor %s for user ID %d", query.Cursor, userId)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_BAD_REQUEST), Message: "invalid cursor", Err: err}
+	}
+	account, err := u.userAccountDao.GetUserAccountByUserID(ctx, userId)
+	if err != nil {
+		log.Logger.Errorf("Failed to get user account for user ID %d: %v", userId, err)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get user account", Err: err}
+	}
+	if account == nil {
+		log.Logger.Warnf("User account not found for user ID %d", userId)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_EXIST), Message: "user account not found"}
+	}
+	dbQuery := &model.UserAccountChangeLogQuery{
+		AccountId: &account.ID,
+		OpType:    query.OpType,
+		BeforeId:  beforeId,
+	}
+	if query.StartTime > 0 {
+		startTime := time.Unix(query.StartTime, 0)
+		dbQuery.StartTime = &startTime
+	}
+	if query.EndTime > 0 {
+		endTime := time.Unix(query.EndTime, 0)
+		dbQuery.EndTime = &endTime
+	}
+	changeLogs, nextCursor, err := u.queryChangeLogPage(ctx, dbQuery, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+	page := &data.UserPayTransactionPage{NextCursor: nextCursor}
+	page.Transactions = make([]*data.UserPayTransaction, len(changeLogs))
+	for i, changeLog := range changeLogs {
+		page.Transactions[i] = &data.UserPayTransaction{
+			PayOrderId:    changeLog.GetPayOrderId(),
+			OpType:        changeLog.OpType,
+			Amount:        changeLog.Amount,
+			BalanceBefore: changeLog.BalanceBefore,
+			BalanceAfter:  changeLog.BalanceAfter,
+			BizId:         changeLog.IdempotentKey,
+			CreatedAt:     changeLog.CreatedAt.Unix(),
+		}
+	}
+	return page, nil
+}
+
+// queryChangeLogPage returns one page of at most pageSize change logs, newest first, and the cursor
+// of the next page, empty on the last page. pageSize is capped at repository.DefaultQueryLimit.
+func (u *UserAccountServiceImpl) queryChangeLogPage(ctx context.Context, query *model.UserAccountChangeLogQuery, pageSize int) ([]*model.UserAccountChangeLog, string, error) {
+	if pageSize <= 0 || pageSize > repository.DefaultQueryLimit {
+		pageSize = repository.DefaultQueryLimit
+	}
+	// one extra row tells whether there is a next page
+	query.Limit = pageSize + 1
+	changeLogs, err := u.userAccountChangeLogDao.QueryChangeLogs(ctx, query)
 	if err != nil {
 		log.Logger.Errorf("Failed to query user account change logs: %v", err)
-		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to query change logs", Err: err}
+		return nil, "", &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to query change logs", Err: err}
+	}
+	if len(changeLogs) <= pageSize {
+		return changeLogs, "", nil
+	}
+	changeLogs = changeLogs[:pageSize]
+	return changeLogs, utils.EncodeCursor(changeLogs[pageSize-1].ID), nil
+}
+
+// RefundOrder implements UserAccountService.
+// It returns the refund change log together with the refunded payment change log.
+func (u *UserAccountServiceImpl) RefundOrder(ctx context.Context, req *paymentpb.RefundOrderRequest) (*model.UserAccountChangeLog, *model.UserAccountChangeLog, error) {
+	payLog, err := u.getPaymentChangeLog(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	// a replay is answered before the amount check, which the refund it replays may now fail
+	original, err := u.checkRefundReplay(ctx, payLog, req)
+	if err != nil {
+		return original, payLog, err
+	}
+	if payLog.RefundedAmount+int(req.Amount) > payLog.Amount {
+		log.Logger.Warnf("Refund amount exceeded for pay order %s: paid %d, refunded %d, required %d", payLog.GetPayOrderId(), payLog.Amount, payLog.RefundedAmount, req.Amount)
+		return nil, nil, &bizerror.BizError{Code: int(paymentpb.RespCode_REFUND_AMOUNT_EXCEEDED), Message: "refund amount exceeds paid amount"}
+	}
+	account, err := u.userAccountDao.GetUserAccountByID(ctx, payLog.AccountId)
+	if err != nil {
+		log.Logger.Errorf("Failed to get user account for account ID %d: %v", payLog.AccountId, err)
+		return nil, nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get user account", Err: err}
+	}
+	if account == nil {
+		log.Logger.Warnf("User account not found for account ID %d", payLog.AccountId)
+		return nil, nil, &bizerror.BizError{Code: int(paymentpb.RespCode_ACCOUNT_NOT_EXIST), Message: "user account not found"}
+	}
+	var refundLog *model.UserAccountChangeLog
+	err = u.balanceUpdatePolicy.run(ctx, "refund", u.userAccountDao, u.txBeginner, account, func(tx *gorm.DB, account *model.UserAccount) error {
+		refundLog = &model.UserAccountChangeLog{
+			AccountId:     account.ID,
+			OpType:        model.OpTypeRefund,
+			Amount:        int(req.Amount),
+			OriginLogId:   payLog.ID,
+			IdempotentKey: req.RefundId,
+			CreatedAt:     time.Now(),
+		}
+		refundLog.SetBalance(account.Balance)
+		err := u.userAccountChangeLogDao.CreateChangeLogInTransaction(ctx, refundLog, tx)
+		if err != nil {
+			log.Logger.Errorf("Failed to create refund change log for user ID %d: %v", account.UserId, err)
+			return err
+		}
+		entry := ledger.NewEntry(model.OpTypeRefund, refundLog.ID).
+			Debit(ledger.Refunds, refundLog.Amount).
+			Credit(ledger.Wallet(account), refundLog.Amount)
+		if _, err = u.ledger.PostInTransaction(ctx, entry, tx); err != nil {
+			log.Logger.Errorf("Failed to post refund to the ledger for user ID %d: %v", account.UserId, err)
+			return err
+		}
+		rowsAffected, err := u.userAccountChangeLogDao.AddRefundedAmountInTransaction(ctx, payLog.ID, refundLog.Amount, tx)
+		if err != nil {
+			log.Logger.Errorf("Failed to add refunded amount for pay order %s: %v", payLog.GetPayOrderId(), err)
+			return err
+		}
+		if rowsAffected == 0 {
+			return &bizerror.BizError{Code: int(paymentpb.RespCode_REFUND_AMOUNT_EXCEEDED), Message: "refund amount exceeds paid amount"}
+		}
+		err = u.userAccountDao.RefundBalanceInTransaction(ctx, account.UserId, refundLog.Amount, account.Balance, tx)
+		if err != nil {
+			log.Logger.Errorf("Failed to add balance for user ID %d: %v", account.UserId, err)
+			return err
+		}
+		return createOutboxEventInTransaction(ctx, u.outboxDao, event.TypeRefundCompleted, event.RefundCompletedVersion, strconv.Itoa(account.UserId), &event.RefundCompleted{
+			RefundId:     req.RefundId,
+			PayOrderId:   payLog.GetPayOrderId(),
+			BizId:        payLog.IdempotentKey,
+			UserId:       account.UserId,
+			AccountId:    account.ID,
+			ChangeLogId:  refundLog.ID,
+			Amount:       refundLog.Amount,
+			BalanceAfter: refundLog.BalanceAfter,
+			RefundedAt:   refundLog.CreatedAt.Unix(),
+		}, tx)
+	})
+	if err != nil {
+		log.Logger.Errorf("Refund transaction failed for pay order %s: %v", payLog.GetPayOrderId(), err)
+		if bizErr, ok := err.(*bizerror.BizError); ok {
+			return nil, nil, bizErr
+		}
+		return nil, nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "transaction failed", Err: err}
+	}
+	payLog.RefundedAmount += refundLog.Amount
+	log.Logger.Infof("Successfully refunded pay order %s for user ID %d, amount %d, refund ID %s", payLog.GetPayOrderId(), account.UserId, refundLog.Amount, req.RefundId)
+	return refundLog, payLog, nil
+}
+
+// checkRefundReplay looks for an earlier refund with the same refundId.
+// A faithful replay returns the original refund along with a DUPLICATE_REQUEST error,
+// a replay against another pay order or with another amount is rejected with IDEMPOTENCY_KEY_CONFLICT.
+func (u *UserAccountServiceImpl) checkRefundReplay(ctx context.Context, payLog *model.UserAccountChangeLog, req *paymentpb.RefundOrderRequest) (*model.UserAccountChangeLog, error) {
+	original, err := u.userAccountChangeLogDao.GetChangeLogByIdempotentKey(ctx, req.RefundId, model.OpTypeRefund)
+	if err != nil {
+		log.Logger.Errorf("Failed to get change log for refund ID %s: %v", req.RefundId, err)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get change log", Err: err}
+	}
+	if original == nil {
+		return nil, nil
+	}
+	if original.OriginLogId != payLog.ID || original.Amount != int(req.Amount) {
+		log.Logger.Warnf("Conflicting replay for refund ID %s: original pay order %d amount %d, got pay order %d amount %d", req.RefundId, original.OriginLogId, original.Amount, payLog.ID, req.Amount)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_IDEMPOTENCY_KEY_CONFLICT), Message: "refund ID already used by a different refund"}
+	}
+	log.Logger.Infof("Duplicate refund request for refund ID %s, returning refund %s", req.RefundId, original.GetPayOrderId())
+	return original, &bizerror.BizError{Code: int(paymentpb.RespCode_DUPLICATE_REQUEST), Message: "duplicate request"}
+}
+
+// orderCancelledRefundIdFormat makes the refund id of a cancelled order from the id of its payment, one per order
+// so that the refund cannot happen twice. Unlike the biz id, the id of the payment always fits an idempotent key.
+const orderCancelledRefundIdFormat = "oc_%d"
+
+// RefundCancelledOrder implements UserAccountService.
+func (u *UserAccountServiceImpl) RefundCancelledOrder(ctx context.Context, bizId string) (*model.UserAccountChangeLog, error) {
+	req := &paymentpb.RefundOrderRequest{BizId: &bizId}
+	payLog, err := u.getPaymentChangeLog(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	req.RefundId = fmt.Sprintf(orderCancelledRefundIdFormat, payLog.ID)
+	refundLog, err := u.userAccountChangeLogDao.GetChangeLogByIdempotentKey(ctx, req.RefundId, model.OpTypeRefund)
+	if err != nil {
+		log.Logger.Errorf("Failed to get change log for refund ID %s: %v", req.RefundId, err)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get change log", Err: err}
+	}
+	if refundLog != nil {
+		log.Logger.Infof("Cancelled order %s already refunded by change log %d", bizId, refundLog.ID)
+		return refundLog, nil
+	}
+	remaining := payLog.Amount - payLog.RefundedAmount
+	if remaining <= 0 {
+		log.Logger.Infof("Cancelled order %s was refunded in full before, nothing left to refund", bizId)
+		return nil, nil
+	}
+	req.Amount = int32(remaining)
+	refundLog, _, err = u.RefundOrder(ctx, req)
+	if bizerror.RespCodeOf(err) == paymentpb.RespCode_DUPLICATE_REQUEST && refundLog != nil {
+		// a concurrent delivery of the cancellation refunded it first
+		return refundLog, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return refundLog, nil
+}
+
+// getPaymentChangeLog looks up the payment or capture to refund, by payOrderId if given, otherwise by bizId.
+func (u *UserAccountServiceImpl) getPaymentChangeLog(ctx context.Context, req *paymentpb.RefundOrderRequest) (*model.UserAccountChangeLog, error) {
+	var payLog *model.UserAccountChangeLog
+	var err error
+	if req.PayOrderId != nil {
+		idx := strings.LastIndex(*req.PayOrderId, "_")
+		id, convErr := strconv.Atoi((*req.PayOrderId)[idx+1:])
+		if idx < 0 || convErr != nil {
+			log.Logger.Warnf("Invalid pay order ID: %s", *req.PayOrderId)
+			return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_PAY_ORDER_NOT_EXIST), Message: "pay order not found"}
+		}
+		payLog, err = u.userAccountChangeLogDao.GetChangeLogByID(ctx, id)
+		if payLog != nil && payLog.GetPayOrderId() != *req.PayOrderId {
+			payLog = nil
+		}
+	} else {
+		payLog, err = u.userAccountChangeLogDao.GetChangeLogByIdempotentKey(ctx, req.GetBizId(), model.OpTypePayment)
+		if err == nil && payLog == nil {
+			// two-phase payments are refunded against their capture
+			payLog, err = u.userAccountChangeLogDao.GetChangeLogByIdempotentKey(ctx, req.GetBizId(), model.OpTypeCapture)
+		}
+	}
+	if err != nil {
+		log.Logger.Errorf("Failed to get pay order for refund ID %s: %v", req.RefundId, err)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_UNKNOWN_ERROR), Message: "failed to get pay order", Err: err}
+	}
+	if payLog == nil || (payLog.OpType != model.OpTypePayment && payLog.OpType != model.OpTypeCapture) {
+		log.Logger.Warnf("Pay order not found for refund ID %s", req.RefundId)
+		return nil, &bizerror.BizError{Code: int(paymentpb.RespCode_PAY_ORDER_NOT_EXIST), Message: "pay order not found"}
 	}
-	return changeLogs, nil
+	return payLog, nil
 }
@@ -3,12 +3,25 @@ package service
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	bizerror "github.com/sw5005-sus/ceramicraft-payment-mservice/common/biz_error"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/common/paymentpb"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/event"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/http/data"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/ledger"
+	ledgermocks "github.com/sw5005-sus/ceramicraft-payment-mservice/server/ledger/mocks"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/dao/mocks"
 	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/repository/model"
+	"github.com/sw5005-sus/ceramicraft-payment-mservice/server/utils"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -119,6 +132,98 @@ func TestCreateUserAccount(t *testing.T) {
 	})
 }
 
+func TestUpdateUserAccountStatus(t *testing.T) {
+	ctx := context.Background()
+	userId := 1
+	banned := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	unbanned := banned.Add(time.Hour)
+	initEnv()
+
+	t.Run("should freeze an active account", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		service := &UserAccountServiceImpl{userAccountDao: userAccountDao}
+
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(&model.UserAccount{ID: 1, UserId: userId, Status: model.UserAccountStatusActive}, nil).Once()
+		userAccountDao.On("UpdateStatus", ctx, userId, model.UserAccountStatusFrozen, banned).Return(1, nil).Once()
+
+		account, err := service.UpdateUserAccountStatus(ctx, userId, model.UserAccountStatusFrozen, banned)
+		assert.NoError(t, err)
+		assert.Equal(t, model.UserAccountStatusFrozen, account.Status)
+		assert.Equal(t, banned, *account.StatusChangedAt)
+		userAccountDao.AssertExpectations(t)
+	})
+
+	t.Run("should record the time of a change that keeps the status", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		service := &UserAccountServiceImpl{userAccountDao: userAccountDao}
+
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(&model.UserAccount{ID: 1, UserId: userId, Status: model.UserAccountStatusFrozen, StatusChangedAt: &banned}, nil).Once()
+		userAccountDao.On("UpdateStatus", ctx, userId, model.UserAccountStatusFrozen, unbanned).Return(1, nil).Once()
+
+		account, err := service.UpdateUserAccountStatus(ctx, userId, model.UserAccountStatusFrozen, unbanned)
+		assert.NoError(t, err)
+		assert.Equal(t, unbanned, *account.StatusChangedAt)
+		userAccountDao.AssertExpectations(t)
+	})
+
+	t.Run("should drop a deactivation older than the reactivation applied before it", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		service := &UserAccountServiceImpl{userAccountDao: userAccountDao}
+
+		// the reactivation of the user overtook the ban it lifts
+		reactivated := &model.UserAccount{ID: 1, UserId: userId, Status: model.UserAccountStatusActive, StatusChangedAt: &unbanned}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(reactivated, nil).Once()
+
+		account, err := service.UpdateUserAccountStatus(ctx, userId, model.UserAccountStatusFrozen, banned)
+		assert.NoError(t, err)
+		assert.Equal(t, model.UserAccountStatusActive, account.Status)
+		userAccountDao.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should drop a change overtaken by a newer one concurrently", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		service := &UserAccountServiceImpl{userAccountDao: userAccountDao}
+
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(&model.UserAccount{ID: 1, UserId: userId, Status: model.UserAccountStatusActive}, nil).Once()
+		userAccountDao.On("UpdateStatus", ctx, userId, model.UserAccountStatusFrozen, banned).Return(0, nil).Once()
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(&model.UserAccount{ID: 1, UserId: userId, Status: model.UserAccountStatusActive, StatusChangedAt: &unbanned}, nil).Once()
+
+		account, err := service.UpdateUserAccountStatus(ctx, userId, model.UserAccountStatusFrozen, banned)
+		assert.NoError(t, err)
+		assert.Equal(t, model.UserAccountStatusActive, account.Status)
+		userAccountDao.AssertExpectations(t)
+	})
+
+	t.Run("should never reopen a closed account", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		service := &UserAccountServiceImpl{userAccountDao: userAccountDao}
+
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(&model.UserAccount{ID: 1, UserId: userId, Status: model.UserAccountStatusClosed, StatusChangedAt: &banned}, nil).Once()
+
+		_, err := service.UpdateUserAccountStatus(ctx, userId, model.UserAccountStatusActive, unbanned)
+		assert.Equal(t, paymentpb.RespCode_ACCOUNT_NOT_ACTIVE, bizerror.RespCodeOf(err))
+		userAccountDao.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should fail when the account was closed concurrently", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		service := &UserAccountServiceImpl{userAccountDao: userAccountDao}
+
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(&model.UserAccount{ID: 1, UserId: userId, Status: model.UserAccountStatusFrozen}, nil).Once()
+		userAccountDao.On("UpdateStatus", ctx, userId, model.UserAccountStatusActive, unbanned).Return(0, nil).Once()
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(&model.UserAccount{ID: 1, UserId: userId, Status: model.UserAccountStatusClosed}, nil).Once()
+
+		_, err := service.UpdateUserAccountStatus(ctx, userId, model.UserAccountStatusActive, unbanned)
+		assert.Equal(t, paymentpb.RespCode_ACCOUNT_NOT_ACTIVE, bizerror.RespCodeOf(err))
+	})
+
+	t.Run("should reject an unknown status", func(t *testing.T) {
+		service := &UserAccountServiceImpl{}
+		_, err := service.UpdateUserAccountStatus(ctx, userId, 9, banned)
+		assert.Equal(t, paymentpb.RespCode_BAD_REQUEST, bizerror.RespCodeOf(err))
+	})
+}
+
 func TestGetUserAccountByUserID(t *testing.T) {
 	ctx := context.Background()
 	userId := 1
@@ -189,10 +294,13 @@ func TestPayOrder(t *testing.T) {
 			userAccountDao:          userAccountDao,
 			userAccountChangeLogDao: userAccountChangeLogDao,
 			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
 		}
 
-		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 200}
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}
 		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
 		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
 		userAccountDao.On("SubtractBalanceInTransaction", ctx, userId, amount, userAccount.Balance, mock.Anything).Return(1, nil).Once()
 
@@ -206,6 +314,159 @@ func TestPayOrder(t *testing.T) {
 		if changeLog != nil && (changeLog.Amount != amount || changeLog.OpType != model.OpTypePayment || changeLog.IdempotentKey != bizId) {
 			t.Errorf("Change log fields do not match expected values")
 		}
+		if changeLog != nil && (changeLog.BalanceBefore != 200 || changeLog.BalanceAfter != 100) {
+			t.Errorf("Expected balance 200 -> 100, got %d -> %d", changeLog.BalanceBefore, changeLog.BalanceAfter)
+		}
+	})
+
+	t.Run("should not retry a payment from an account frozen since it was read", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		db := initMemDb(t)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          &memUserAccountDao{UserAccountDao: userAccountDao},
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: db},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+			balanceUpdatePolicy:     balanceUpdatePolicy{maxRetries: 3, retryBackoff: time.Millisecond},
+		}
+
+		assert.NoError(t, db.Create(&model.UserAccount{ID: 1, UserId: userId, AccountNo: "6200000000000001", Balance: 200, Status: model.UserAccountStatusFrozen}).Error)
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(&model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+
+		changeLog, err := service.PayOrder(ctx, userId, bizId, amount)
+		assert.Equal(t, paymentpb.RespCode_ACCOUNT_NOT_ACTIVE, bizerror.RespCodeOf(err))
+		assert.Nil(t, changeLog)
+		userAccountDao.AssertNumberOfCalls(t, "GetUserAccountByUserID", 1)
+		var balance int
+		assert.NoError(t, db.Model(&model.UserAccount{}).Where("user_id = ?", userId).Pluck("balance", &balance).Error)
+		assert.Equal(t, 200, balance)
+	})
+
+	t.Run("should post the payment from the wallet to merchant revenue", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		ledgerMock := new(ledgermocks.Ledger)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  ledgerMock,
+			outboxDao:               newOutboxDaoMock(),
+		}
+
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(*model.UserAccountChangeLog).ID = 9
+		}).Once()
+		ledgerMock.On("PostInTransaction", ctx, mock.MatchedBy(func(entry *ledger.Entry) bool {
+			return entry.Validate() == nil && entry.ChangeLogId == 9 && len(entry.Lines) == 2 &&
+				entry.Lines[0].Account.Code == ledger.WalletCode(userAccount.ID) && entry.Lines[0].Debit == amount &&
+				entry.Lines[1].Account == ledger.MerchantRevenue && entry.Lines[1].Credit == amount
+		}), mock.Anything).Return(&model.JournalEntry{}, nil).Once()
+		userAccountDao.On("SubtractBalanceInTransaction", ctx, userId, amount, userAccount.Balance, mock.Anything).Return(1, nil).Once()
+
+		_, err := service.PayOrder(ctx, userId, bizId, amount)
+		assert.NoError(t, err)
+		ledgerMock.AssertExpectations(t)
+	})
+
+	t.Run("should write a payment-succeeded event in the transaction", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		outboxDao := new(mocks.OutboxDao)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               outboxDao,
+		}
+
+		userAccount := &model.UserAccount{ID: 3, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(*model.UserAccountChangeLog).ID = 9
+		}).Once()
+		userAccountDao.On("SubtractBalanceInTransaction", ctx, userId, amount, userAccount.Balance, mock.Anything).Return(1, nil).Once()
+		outboxDao.On("CreateEventInTransaction", ctx, mock.MatchedBy(func(outboxEvent *model.OutboxEvent) bool {
+			var paid event.PaymentSucceeded
+			return outboxEvent.EventType == event.TypePaymentSucceeded && outboxEvent.Version == event.PaymentSucceededVersion &&
+				outboxEvent.EventKey == "1" && outboxEvent.Status == model.OutboxEventStatusPending &&
+				json.Unmarshal([]byte(outboxEvent.Payload), &paid) == nil && paid.BizId == bizId && paid.AccountId == 3 &&
+				paid.Amount == amount && paid.BalanceAfter == 100 && paid.PayOrderId != ""
+		}), mock.Anything).Return(nil).Once()
+
+		_, err := service.PayOrder(ctx, userId, bizId, amount)
+		assert.NoError(t, err)
+		outboxDao.AssertExpectations(t)
+	})
+
+	t.Run("should fail the payment when the event cannot be written", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		outboxDao := new(mocks.OutboxDao)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               outboxDao,
+		}
+
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+		userAccountDao.On("SubtractBalanceInTransaction", ctx, userId, amount, userAccount.Balance, mock.Anything).Return(1, nil).Once()
+		outboxDao.On("CreateEventInTransaction", ctx, mock.Anything, mock.Anything).Return(assert.AnError).Once()
+
+		changeLog, err := service.PayOrder(ctx, userId, bizId, amount)
+		assert.Nil(t, changeLog)
+		assert.Equal(t, paymentpb.RespCode_UNKNOWN_ERROR, bizerror.RespCodeOf(err))
+	})
+
+	t.Run("should reject payment from a frozen account", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusFrozen}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
+
+		_, err := service.PayOrder(ctx, userId, bizId, amount)
+		assert.Equal(t, paymentpb.RespCode_ACCOUNT_NOT_ACTIVE, bizerror.RespCodeOf(err))
+		assert.Contains(t, err.Error(), "frozen")
+	})
+
+	t.Run("should reject payment when the account was frozen before the balance update", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			balanceUpdatePolicy:     balanceUpdatePolicy{pessimistic: true},
+		}
+
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
+		userAccountDao.On("LockUserAccountInTransaction", ctx, userId, mock.Anything).Return(&model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusFrozen}, nil).Once()
+
+		_, err := service.PayOrder(ctx, userId, bizId, amount)
+		assert.Equal(t, paymentpb.RespCode_ACCOUNT_NOT_ACTIVE, bizerror.RespCodeOf(err))
+		userAccountChangeLogDao.AssertNotCalled(t, "CreateChangeLogInTransaction", mock.Anything, mock.Anything, mock.Anything)
 	})
 
 	t.Run("should return error if user account not found", func(t *testing.T) {
@@ -224,12 +485,15 @@ func TestPayOrder(t *testing.T) {
 
 	t.Run("should return error if insufficient balance", func(t *testing.T) {
 		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
 		service := &UserAccountServiceImpl{
-			userAccountDao: userAccountDao,
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
 		}
 
-		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 50}
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 50, Status: model.UserAccountStatusActive}
 		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
 
 		_, err := service.PayOrder(ctx, userId, bizId, amount)
 		if err == nil {
@@ -244,10 +508,13 @@ func TestPayOrder(t *testing.T) {
 			userAccountDao:          userAccountDao,
 			userAccountChangeLogDao: userAccountChangeLogDao,
 			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
 		}
 
-		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 200}
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}
 		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
 		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
 		userAccountDao.On("SubtractBalanceInTransaction", ctx, userId, amount, userAccount.Balance, mock.Anything).Return(0, nil).Once() // Simulate failure
 
@@ -256,11 +523,101 @@ func TestPayOrder(t *testing.T) {
 			t.Errorf("Expected error from transaction failure, got nil")
 		}
 	})
+
+	t.Run("should return original pay order on replay", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		// the original payment already drained the balance
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 0, Status: model.UserAccountStatusActive}
+		original := &model.UserAccountChangeLog{ID: 7, AccountId: 1, OpType: model.OpTypePayment, Amount: amount, IdempotentKey: bizId}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(original, nil).Once()
+
+		changeLog, err := service.PayOrder(ctx, userId, bizId, amount)
+		bizErr, ok := err.(*bizerror.BizError)
+		assert.True(t, ok)
+		assert.Equal(t, int(paymentpb.RespCode_DUPLICATE_REQUEST), bizErr.Code)
+		assert.Equal(t, original, changeLog)
+	})
+
+	t.Run("should reject replay with a different amount", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}
+		original := &model.UserAccountChangeLog{ID: 7, AccountId: 1, OpType: model.OpTypePayment, Amount: amount + 1, IdempotentKey: bizId}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(original, nil).Once()
+
+		changeLog, err := service.PayOrder(ctx, userId, bizId, amount)
+		bizErr, ok := err.(*bizerror.BizError)
+		assert.True(t, ok)
+		assert.Equal(t, int(paymentpb.RespCode_IDEMPOTENCY_KEY_CONFLICT), bizErr.Code)
+		assert.Nil(t, changeLog)
+	})
+
+	t.Run("should reject replay from a different user", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		userAccount := &model.UserAccount{ID: 2, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}
+		original := &model.UserAccountChangeLog{ID: 7, AccountId: 1, OpType: model.OpTypePayment, Amount: amount, IdempotentKey: bizId}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(original, nil).Once()
+
+		_, err := service.PayOrder(ctx, userId, bizId, amount)
+		bizErr, ok := err.(*bizerror.BizError)
+		assert.True(t, ok)
+		assert.Equal(t, int(paymentpb.RespCode_IDEMPOTENCY_KEY_CONFLICT), bizErr.Code)
+	})
+
+	t.Run("should return original pay order when a concurrent replay wins", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+		}
+
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 200, Status: model.UserAccountStatusActive}
+		original := &model.UserAccountChangeLog{ID: 7, AccountId: 1, OpType: model.OpTypePayment, Amount: amount, IdempotentKey: bizId}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(gorm.ErrDuplicatedKey).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(original, nil).Once()
+
+		changeLog, err := service.PayOrder(ctx, userId, bizId, amount)
+		bizErr, ok := err.(*bizerror.BizError)
+		assert.True(t, ok)
+		assert.Equal(t, int(paymentpb.RespCode_DUPLICATE_REQUEST), bizErr.Code)
+		assert.Equal(t, original, changeLog)
+	})
 }
+
 func TestUserAccountTopUp(t *testing.T) {
 	ctx := context.Background()
 	userId := 1
-	redeemCode := "valid-redeem-code"
+	format := newRedeemCodeFormat(nil)
+	code := format.Generate("")
+	// as typed by a user, normalized before the lookup
+	redeemCode := strings.ToLower(format.Display(code))
+	codeHash := utils.HashRedeemCode(nil, code)
 	initEnv()
 
 	t.Run("should successfully top up user account", func(t *testing.T) {
@@ -272,14 +629,20 @@ func TestUserAccountTopUp(t *testing.T) {
 			redeemCodeDao:           redeemCodeDao,
 			userAccountChangeLogDao: userAccountChangeLogDao,
 			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+			codeFormat:              format,
 		}
 
-		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100}
-		redeemCodeRecord := &model.RedeemCode{Amount: 50}
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
+		redeemCodeRecord := &model.RedeemCode{ID: 5, Amount: 50}
 
 		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Twice()
-		redeemCodeDao.On("GetByCode", ctx, redeemCode).Return(redeemCodeRecord, nil).Once()
-		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(redeemCodeRecord, nil).Once()
+		// the change log names the code by id, never by plaintext
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.MatchedBy(func(changeLog *model.UserAccountChangeLog) bool {
+			return changeLog.IdempotentKey == "rc_5"
+		}), mock.Anything).Return(nil).Once()
 		userAccountDao.On("AddBalanceInTransaction", ctx, userId, int(redeemCodeRecord.Amount), userAccount.Balance, mock.Anything).Return(nil).Once()
 		redeemCodeDao.On("UseRedeemCodeInTransaction", ctx, mock.Anything, mock.Anything).Return(1, nil).Once()
 
@@ -295,12 +658,50 @@ func TestUserAccountTopUp(t *testing.T) {
 		}
 	})
 
+	t.Run("should write an account-topped-up event in the transaction", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		outboxDao := new(mocks.OutboxDao)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			redeemCodeDao:           redeemCodeDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               outboxDao,
+			codeFormat:              format,
+		}
+
+		userAccount := &model.UserAccount{ID: 3, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
+		redeemCodeRecord := &model.RedeemCode{ID: 5, Amount: 50}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Twice()
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(redeemCodeRecord, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(*model.UserAccountChangeLog).ID = 7
+		}).Once()
+		userAccountDao.On("AddBalanceInTransaction", ctx, userId, int(redeemCodeRecord.Amount), userAccount.Balance, mock.Anything).Return(nil).Once()
+		redeemCodeDao.On("UseRedeemCodeInTransaction", ctx, mock.Anything, mock.Anything).Return(1, nil).Once()
+		outboxDao.On("CreateEventInTransaction", ctx, mock.MatchedBy(func(outboxEvent *model.OutboxEvent) bool {
+			var toppedUp event.AccountToppedUp
+			return outboxEvent.EventType == event.TypeAccountToppedUp && outboxEvent.Version == event.AccountToppedUpVersion &&
+				outboxEvent.EventKey == "1" && json.Unmarshal([]byte(outboxEvent.Payload), &toppedUp) == nil &&
+				toppedUp.ChangeLogId == 7 && toppedUp.RedeemCodeId == 5 && toppedUp.AccountId == 3 &&
+				toppedUp.Amount == 50 && toppedUp.BalanceAfter == 150
+		}), mock.Anything).Return(nil).Once()
+
+		_, _, err := service.UserAccountTopUp(ctx, userId, redeemCode)
+		assert.NoError(t, err)
+		outboxDao.AssertExpectations(t)
+	})
+
 	t.Run("should return error if user account not found", func(t *testing.T) {
 		userAccountDao := new(mocks.UserAccountDao)
 		redeemCodeDao := new(mocks.RedeemCodeDao)
 		service := &UserAccountServiceImpl{
 			userAccountDao: userAccountDao,
 			redeemCodeDao:  redeemCodeDao,
+			codeFormat:     format,
 		}
 
 		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(nil, nil).Once()
@@ -309,6 +710,46 @@ func TestUserAccountTopUp(t *testing.T) {
 		if err == nil {
 			t.Errorf("Expected error for non-existing user account, got nil")
 		}
+		assert.Equal(t, paymentpb.RespCode_ACCOUNT_NOT_EXIST, bizerror.RespCodeOf(err))
+	})
+
+	t.Run("should reject top-up of a closed account before the code lookup", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &UserAccountServiceImpl{
+			userAccountDao: userAccountDao,
+			redeemCodeDao:  redeemCodeDao,
+			codeFormat:     format,
+		}
+
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(&model.UserAccount{ID: 1, UserId: userId, Status: model.UserAccountStatusClosed}, nil).Once()
+
+		_, _, err := service.UserAccountTopUp(ctx, userId, redeemCode)
+		assert.Equal(t, paymentpb.RespCode_ACCOUNT_NOT_ACTIVE, bizerror.RespCodeOf(err))
+		redeemCodeDao.AssertNotCalled(t, "GetByCode", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should reject a malformed code before any lookup", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &UserAccountServiceImpl{
+			userAccountDao: userAccountDao,
+			redeemCodeDao:  redeemCodeDao,
+			codeFormat:     format,
+		}
+		// one character off, the check character catches the typo
+		typo := []byte(code)
+		typo[0] = '2'
+		if code[0] == '2' {
+			typo[0] = '3'
+		}
+
+		for _, input := range []string{"valid-redeem-code", string(typo), ""} {
+			_, _, err := service.UserAccountTopUp(ctx, userId, input)
+			assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_MALFORMED, bizerror.RespCodeOf(err), input)
+		}
+		userAccountDao.AssertNotCalled(t, "GetUserAccountByUserID", mock.Anything, mock.Anything)
+		redeemCodeDao.AssertNotCalled(t, "GetByCode", mock.Anything, mock.Anything)
 	})
 
 	t.Run("should return error if redeem code not found", func(t *testing.T) {
@@ -317,16 +758,35 @@ func TestUserAccountTopUp(t *testing.T) {
 		service := &UserAccountServiceImpl{
 			userAccountDao: userAccountDao,
 			redeemCodeDao:  redeemCodeDao,
+			codeFormat:     format,
 		}
 
-		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100}
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
 		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
-		redeemCodeDao.On("GetByCode", ctx, redeemCode).Return(nil, nil).Once()
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(nil, nil).Once()
 
 		_, _, err := service.UserAccountTopUp(ctx, userId, redeemCode)
 		if err == nil {
 			t.Errorf("Expected error for non-existing redeem code, got nil")
 		}
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_INVALID, bizerror.RespCodeOf(err))
+	})
+
+	t.Run("should treat record not found as invalid redeem code", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &UserAccountServiceImpl{
+			userAccountDao: userAccountDao,
+			redeemCodeDao:  redeemCodeDao,
+			codeFormat:     format,
+		}
+
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(nil, gorm.ErrRecordNotFound).Once()
+
+		_, _, err := service.UserAccountTopUp(ctx, userId, redeemCode)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_INVALID, bizerror.RespCodeOf(err))
 	})
 
 	t.Run("should return error if redeem code already used", func(t *testing.T) {
@@ -335,54 +795,898 @@ func TestUserAccountTopUp(t *testing.T) {
 		service := &UserAccountServiceImpl{
 			userAccountDao: userAccountDao,
 			redeemCodeDao:  redeemCodeDao,
+			codeFormat:     format,
 		}
 
-		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100}
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
 		redeemCodeRecord := &model.RedeemCode{UsedUserId: userId}
 
 		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
-		redeemCodeDao.On("GetByCode", ctx, redeemCode).Return(redeemCodeRecord, nil).Once()
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(redeemCodeRecord, nil).Once()
 
 		_, _, err := service.UserAccountTopUp(ctx, userId, redeemCode)
 		if err == nil {
 			t.Errorf("Expected error for already used redeem code, got nil")
 		}
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_USED, bizerror.RespCodeOf(err))
 	})
 
-	t.Run("should return error if transaction fails", func(t *testing.T) {
+	t.Run("should return error if redeem code was revoked", func(t *testing.T) {
 		userAccountDao := new(mocks.UserAccountDao)
 		redeemCodeDao := new(mocks.RedeemCodeDao)
-		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
 		service := &UserAccountServiceImpl{
-			userAccountDao:          userAccountDao,
-			redeemCodeDao:           redeemCodeDao,
-			userAccountChangeLogDao: userAccountChangeLogDao,
-			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			userAccountDao: userAccountDao,
+			redeemCodeDao:  redeemCodeDao,
+			codeFormat:     format,
 		}
 
-		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100}
-		redeemCodeRecord := &model.RedeemCode{Amount: 50}
+		revokedAt := time.Now().Add(-time.Minute)
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
+		redeemCodeRecord := &model.RedeemCode{Amount: 50, RevokedAt: &revokedAt}
 
 		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
-		redeemCodeDao.On("GetByCode", ctx, redeemCode).Return(redeemCodeRecord, nil).Once()
-		redeemCodeDao.On("UseRedeemCodeInTransaction", ctx, mock.Anything, mock.Anything).Return(1, nil).Once()
-		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(assert.AnError).Once()
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(redeemCodeRecord, nil).Once()
 
 		_, _, err := service.UserAccountTopUp(ctx, userId, redeemCode)
-		if err == nil {
-			t.Errorf("Expected error from transaction failure, got nil")
-		}
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_REVOKED, bizerror.RespCodeOf(err))
 	})
-}
 
-type fakeTx struct{ *gorm.DB }
+	t.Run("should return error if redeem code is not valid yet", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &UserAccountServiceImpl{
+			userAccountDao: userAccountDao,
+			redeemCodeDao:  redeemCodeDao,
+			codeFormat:     format,
+		}
 
-func (f *fakeTx) Transaction(fn func(tx *gorm.DB) error, opts ...*sql.TxOptions) error {
-	return fn(f.DB) // Pass through the same DB instance
+		validFrom := time.Now().Add(time.Hour)
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
+		redeemCodeRecord := &model.RedeemCode{Amount: 50, ValidFrom: &validFrom}
+
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(redeemCodeRecord, nil).Once()
+
+		_, _, err := service.UserAccountTopUp(ctx, userId, redeemCode)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_NOT_YET_VALID, bizerror.RespCodeOf(err))
+		redeemCodeDao.AssertNotCalled(t, "UseRedeemCodeInTransaction", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should return error if redeem code has expired", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &UserAccountServiceImpl{
+			userAccountDao: userAccountDao,
+			redeemCodeDao:  redeemCodeDao,
+			codeFormat:     format,
+		}
+
+		validUntil := time.Now().Add(-time.Minute)
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
+		redeemCodeRecord := &model.RedeemCode{Amount: 50, ValidUntil: &validUntil}
+
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(redeemCodeRecord, nil).Once()
+
+		_, _, err := service.UserAccountTopUp(ctx, userId, redeemCode)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_EXPIRED, bizerror.RespCodeOf(err))
+		redeemCodeDao.AssertNotCalled(t, "UseRedeemCodeInTransaction", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should return error if redeem code used concurrently", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		service := &UserAccountServiceImpl{
+			userAccountDao: userAccountDao,
+			redeemCodeDao:  redeemCodeDao,
+			txBeginner:     &fakeTx{DB: initMemDb(t)},
+			ledger:         newLedgerMock(),
+			outboxDao:      newOutboxDaoMock(),
+			codeFormat:     format,
+		}
+
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
+		redeemCodeRecord := &model.RedeemCode{Amount: 50}
+
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(redeemCodeRecord, nil).Once()
+		redeemCodeDao.On("UseRedeemCodeInTransaction", ctx, mock.Anything, mock.Anything).Return(0, nil).Once()
+
+		_, _, err := service.UserAccountTopUp(ctx, userId, redeemCode)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_USED, bizerror.RespCodeOf(err))
+	})
+
+	t.Run("should return error if transaction fails", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			redeemCodeDao:           redeemCodeDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+			codeFormat:              format,
+		}
+
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
+		redeemCodeRecord := &model.RedeemCode{Amount: 50}
+
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(redeemCodeRecord, nil).Once()
+		redeemCodeDao.On("UseRedeemCodeInTransaction", ctx, mock.Anything, mock.Anything).Return(1, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(assert.AnError).Once()
+
+		_, _, err := service.UserAccountTopUp(ctx, userId, redeemCode)
+		if err == nil {
+			t.Errorf("Expected error from transaction failure, got nil")
+		}
+		assert.Equal(t, paymentpb.RespCode_UNKNOWN_ERROR, bizerror.RespCodeOf(err))
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
+
+func TestUserAccountTopUpPromoCode(t *testing.T) {
+	ctx := context.Background()
+	userId := 1
+	format := newRedeemCodeFormat(nil)
+	code := format.WithCheck("SUMMER25")
+	codeHash := utils.HashRedeemCode(nil, code)
+	initEnv()
+
+	type deps struct {
+		userAccountDao          *mocks.UserAccountDao
+		redeemCodeDao           *mocks.RedeemCodeDao
+		userAccountChangeLogDao *mocks.UserAccountChangeLogDAO
+	}
+	newService := func(t *testing.T) (*UserAccountServiceImpl, deps) {
+		d := deps{
+			userAccountDao:          new(mocks.UserAccountDao),
+			redeemCodeDao:           new(mocks.RedeemCodeDao),
+			userAccountChangeLogDao: new(mocks.UserAccountChangeLogDAO),
+		}
+		return &UserAccountServiceImpl{
+			userAccountDao:          d.userAccountDao,
+			redeemCodeDao:           d.redeemCodeDao,
+			userAccountChangeLogDao: d.userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+			balanceUpdatePolicy:     balanceUpdatePolicy{maxRetries: 1, retryBackoff: time.Millisecond},
+			codeFormat:              format,
+		}, d
+	}
+	newPromoCode := func() *model.RedeemCode {
+		return &model.RedeemCode{ID: 3, CodeHash: codeHash, CodeSuffix: utils.RedeemCodeSuffix(code), Type: model.RedeemCodeTypePromo, Amount: 20, MaxRedemptions: 500, PerUserLimit: 2, RedeemedCount: 10}
+	}
+
+	t.Run("should record the redemption against the top-up", func(t *testing.T) {
+		service, d := newService(t)
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
+		promoCode := newPromoCode()
+		d.userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Twice()
+		d.redeemCodeDao.On("GetByCode", ctx, codeHash).Return(promoCode, nil).Once()
+		d.redeemCodeDao.On("CountRedemptionsInTransaction", ctx, uint(3), userId, mock.Anything).Return(1, nil).Once()
+		d.redeemCodeDao.On("RedeemPromoCodeInTransaction", ctx, promoCode, mock.Anything).Return(1, nil).Once()
+		// keyed by the redemption, so other users and later redemptions of the code get keys of their own
+		d.userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.MatchedBy(func(changeLog *model.UserAccountChangeLog) bool {
+			return changeLog.IdempotentKey == "rc_3_1_2"
+		}), mock.Anything).Run(func(args mock.Arguments) {
+			args.Get(1).(*model.UserAccountChangeLog).ID = 42
+		}).Return(nil).Once()
+		d.redeemCodeDao.On("CreateRedemptionInTransaction", ctx, &model.RedeemCodeRedemption{
+			RedeemCodeId: 3, UserId: userId, Seq: 2, AccountId: 1, ChangeLogId: 42, Amount: 20,
+		}, mock.Anything).Return(nil).Once()
+		d.userAccountDao.On("AddBalanceInTransaction", ctx, userId, 20, 100, mock.Anything).Return(nil).Once()
+
+		_, redeemed, err := service.UserAccountTopUp(ctx, userId, code)
+		assert.NoError(t, err)
+		assert.Equal(t, 11, redeemed.RedeemedCount)
+		assert.Equal(t, 0, redeemed.UsedUserId)
+		d.redeemCodeDao.AssertExpectations(t)
+		d.redeemCodeDao.AssertNotCalled(t, "UseRedeemCodeInTransaction", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should let many users redeem the same promo code", func(t *testing.T) {
+		service, d := newService(t)
+		db := service.txBeginner.(*fakeTx).DB
+		// the unique index of init.sql, which AutoMigrate does not create
+		assert.NoError(t, db.Exec("CREATE UNIQUE INDEX idempotent_key_uniq ON user_account_change_logs (idempotent_key, op_type)").Error)
+		service.userAccountChangeLogDao = &dao.UserAccountChangeLogDAOImpl{}
+		for _, redeemer := range []int{1, 2} {
+			userAccount := &model.UserAccount{ID: redeemer, UserId: redeemer, Balance: 100, Status: model.UserAccountStatusActive}
+			d.userAccountDao.On("GetUserAccountByUserID", ctx, redeemer).Return(userAccount, nil).Twice()
+			d.userAccountDao.On("AddBalanceInTransaction", ctx, redeemer, 20, 100, mock.Anything).Return(nil).Once()
+			d.redeemCodeDao.On("GetByCode", ctx, codeHash).Return(newPromoCode(), nil).Once()
+			d.redeemCodeDao.On("CountRedemptionsInTransaction", ctx, uint(3), redeemer, mock.Anything).Return(0, nil).Once()
+		}
+		d.redeemCodeDao.On("RedeemPromoCodeInTransaction", ctx, mock.Anything, mock.Anything).Return(1, nil).Twice()
+		d.redeemCodeDao.On("CreateRedemptionInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Twice()
+
+		for _, redeemer := range []int{1, 2} {
+			_, _, err := service.UserAccountTopUp(ctx, redeemer, code)
+			assert.NoError(t, err)
+		}
+		var keys []string
+		assert.NoError(t, db.Model(&model.UserAccountChangeLog{}).Order("id").Pluck("idempotent_key", &keys).Error)
+		assert.Equal(t, []string{"rc_3_1_1", "rc_3_2_1"}, keys)
+	})
+
+	t.Run("should return error once the user reached the per-user limit", func(t *testing.T) {
+		service, d := newService(t)
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
+		d.userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		d.redeemCodeDao.On("GetByCode", ctx, codeHash).Return(newPromoCode(), nil).Once()
+		d.redeemCodeDao.On("CountRedemptionsInTransaction", ctx, uint(3), userId, mock.Anything).Return(2, nil).Once()
+
+		_, _, err := service.UserAccountTopUp(ctx, userId, code)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_USER_LIMIT_REACHED, bizerror.RespCodeOf(err))
+		d.redeemCodeDao.AssertNotCalled(t, "RedeemPromoCodeInTransaction", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should return error if the code is exhausted", func(t *testing.T) {
+		service, d := newService(t)
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
+		promoCode := newPromoCode()
+		promoCode.RedeemedCount = promoCode.MaxRedemptions
+		d.userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		d.redeemCodeDao.On("GetByCode", ctx, codeHash).Return(promoCode, nil).Once()
+
+		_, _, err := service.UserAccountTopUp(ctx, userId, code)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_EXHAUSTED, bizerror.RespCodeOf(err))
+	})
+
+	t.Run("should return error if the cap was reached concurrently", func(t *testing.T) {
+		service, d := newService(t)
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
+		d.userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		d.redeemCodeDao.On("GetByCode", ctx, codeHash).Return(newPromoCode(), nil).Once()
+		d.redeemCodeDao.On("CountRedemptionsInTransaction", ctx, uint(3), userId, mock.Anything).Return(0, nil).Once()
+		d.redeemCodeDao.On("RedeemPromoCodeInTransaction", ctx, mock.Anything, mock.Anything).Return(0, nil).Once()
+
+		_, _, err := service.UserAccountTopUp(ctx, userId, code)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_EXHAUSTED, bizerror.RespCodeOf(err))
+		d.userAccountChangeLogDao.AssertNotCalled(t, "CreateChangeLogInTransaction", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should count again when a concurrent top-up of the same user took the redemption", func(t *testing.T) {
+		service, d := newService(t)
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 100, Status: model.UserAccountStatusActive}
+		d.userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil)
+		d.redeemCodeDao.On("GetByCode", ctx, codeHash).Return(newPromoCode(), nil).Once()
+		d.redeemCodeDao.On("CountRedemptionsInTransaction", ctx, uint(3), userId, mock.Anything).Return(1, nil).Once()
+		d.redeemCodeDao.On("CountRedemptionsInTransaction", ctx, uint(3), userId, mock.Anything).Return(2, nil).Once()
+		d.redeemCodeDao.On("RedeemPromoCodeInTransaction", ctx, mock.Anything, mock.Anything).Return(1, nil).Once()
+		d.userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+		d.redeemCodeDao.On("CreateRedemptionInTransaction", ctx, mock.Anything, mock.Anything).Return(gorm.ErrDuplicatedKey).Once()
+
+		_, _, err := service.UserAccountTopUp(ctx, userId, code)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_USER_LIMIT_REACHED, bizerror.RespCodeOf(err))
+		d.redeemCodeDao.AssertNumberOfCalls(t, "CountRedemptionsInTransaction", 2)
+	})
+
+	t.Run("should count again when a concurrent top-up of the same user took the change log key", func(t *testing.T) {
+		service, d := newService(t)
+		db := service.txBeginner.(*fakeTx).DB
+		assert.NoError(t, db.Exec("CREATE UNIQUE INDEX idempotent_key_uniq ON user_account_change_logs (idempotent_key, op_type)").Error)
+		service.userAccountChangeLogDao = &dao.UserAccountChangeLogDAOImpl{}
+		// committed by the concurrent top-up after this one counted the redemptions
+		assert.NoError(t, db.Create(&model.UserAccountChangeLog{AccountId: 1, OpType: model.OpTypeTopUp, Amount: 20, IdempotentKey: "rc_3_1_2", CreatedAt: time.Now()}).Error)
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 120, Status: model.UserAccountStatusActive}
+		promoCode := newPromoCode()
+		promoCode.PerUserLimit = 3
+		d.userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil)
+		d.redeemCodeDao.On("GetByCode", ctx, codeHash).Return(promoCode, nil).Once()
+		d.redeemCodeDao.On("CountRedemptionsInTransaction", ctx, uint(3), userId, mock.Anything).Return(1, nil).Once()
+		d.redeemCodeDao.On("CountRedemptionsInTransaction", ctx, uint(3), userId, mock.Anything).Return(2, nil).Once()
+		d.redeemCodeDao.On("RedeemPromoCodeInTransaction", ctx, mock.Anything, mock.Anything).Return(1, nil).Twice()
+		d.redeemCodeDao.On("CreateRedemptionInTransaction", ctx, mock.MatchedBy(func(redemption *model.RedeemCodeRedemption) bool {
+			return redemption.Seq == 3
+		}), mock.Anything).Return(nil).Once()
+		d.userAccountDao.On("AddBalanceInTransaction", ctx, userId, 20, 120, mock.Anything).Return(nil).Once()
+
+		_, _, err := service.UserAccountTopUp(ctx, userId, code)
+		assert.NoError(t, err)
+		var keys []string
+		assert.NoError(t, db.Model(&model.UserAccountChangeLog{}).Order("id").Pluck("idempotent_key", &keys).Error)
+		assert.Equal(t, []string{"rc_3_1_2", "rc_3_1_3"}, keys)
+		d.redeemCodeDao.AssertExpectations(t)
+	})
+}
+
+func TestValidateRedeemCode(t *testing.T) {
+	ctx := context.Background()
+	userId := 1
+	format := newRedeemCodeFormat(nil)
+	code := format.Generate("")
+	codeHash := utils.HashRedeemCode(nil, code)
+	initEnv()
+
+	newService := func() (*UserAccountServiceImpl, *mocks.RedeemCodeDao) {
+		redeemCodeDao := new(mocks.RedeemCodeDao)
+		return &UserAccountServiceImpl{redeemCodeDao: redeemCodeDao, codeFormat: format}, redeemCodeDao
+	}
+
+	t.Run("should report an active code as redeemable", func(t *testing.T) {
+		service, redeemCodeDao := newService()
+		validUntil := time.Now().Add(time.Hour)
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(&model.RedeemCode{ID: 5, Amount: 50, Type: model.RedeemCodeTypeSingleUse, ValidUntil: &validUntil}, nil).Once()
+
+		validation, err := service.ValidateRedeemCode(ctx, userId, strings.ToLower(format.Display(code)))
+		assert.NoError(t, err)
+		assert.True(t, validation.Redeemable)
+		assert.Equal(t, 50, validation.Amount)
+		assert.Equal(t, model.RedeemCodeStatusActive, validation.Status)
+		assert.Equal(t, validUntil.Unix(), validation.ValidUntil)
+		assert.Empty(t, validation.Reason)
+	})
+
+	t.Run("should give the reason a used code cannot be redeemed", func(t *testing.T) {
+		service, redeemCodeDao := newService()
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(&model.RedeemCode{ID: 5, Amount: 50, UsedUserId: 11}, nil).Once()
+
+		validation, err := service.ValidateRedeemCode(ctx, userId, code)
+		assert.NoError(t, err)
+		assert.False(t, validation.Redeemable)
+		assert.Equal(t, model.RedeemCodeStatusUsed, validation.Status)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_USED.String(), validation.Reason)
+	})
+
+	t.Run("should check the per-user limit of a promo code", func(t *testing.T) {
+		service, redeemCodeDao := newService()
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(&model.RedeemCode{ID: 3, Amount: 20, Type: model.RedeemCodeTypePromo, PerUserLimit: 2}, nil).Twice()
+		redeemCodeDao.On("CountRedemptions", ctx, uint(3), userId).Return(1, nil).Once()
+		redeemCodeDao.On("CountRedemptions", ctx, uint(3), userId).Return(2, nil).Once()
+
+		validation, err := service.ValidateRedeemCode(ctx, userId, code)
+		assert.NoError(t, err)
+		assert.True(t, validation.Redeemable)
+		validation, err = service.ValidateRedeemCode(ctx, userId, code)
+		assert.NoError(t, err)
+		assert.False(t, validation.Redeemable)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_USER_LIMIT_REACHED.String(), validation.Reason)
+	})
+
+	t.Run("should reject unknown and malformed codes", func(t *testing.T) {
+		service, redeemCodeDao := newService()
+		redeemCodeDao.On("GetByCode", ctx, codeHash).Return(nil, gorm.ErrRecordNotFound).Once()
+
+		_, err := service.ValidateRedeemCode(ctx, userId, code)
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_INVALID, bizerror.RespCodeOf(err))
+		_, err = service.ValidateRedeemCode(ctx, userId, "valid-redeem-code")
+		assert.Equal(t, paymentpb.RespCode_REDEEM_CODE_MALFORMED, bizerror.RespCodeOf(err))
+		redeemCodeDao.AssertNumberOfCalls(t, "GetByCode", 1)
+	})
+}
+
+func TestGetUserPayHistory(t *testing.T) {
+	ctx := context.Background()
+	initEnv()
+
+	t.Run("should honour query size and return the next page token", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		querySize := int32(2)
+		pageToken := utils.EncodeCursor(20)
+		startTime, endTime := int64(1000), int64(2000)
+		userAccountDao.On("GetUserAccountByUserID", ctx, 1).Return(&model.UserAccount{ID: 7, UserId: 1}, nil).Once()
+		userAccountChangeLogDao.On("QueryChangeLogs", ctx, mock.MatchedBy(func(q *model.UserAccountChangeLogQuery) bool {
+			return *q.AccountId == 7 && q.OpType == model.OpTypePayment && q.BeforeId == 20 && q.Limit == 3 &&
+				q.StartTime.Unix() == startTime && q.EndTime.Unix() == endTime
+		})).Return([]*model.UserAccountChangeLog{{ID: 15}, {ID: 12}, {ID: 11}}, nil).Once()
+
+		changeLogs, nextPageToken, err := service.GetUserPayHistory(ctx, &paymentpb.PayOrderQueryRequest{
+			UserId:    1,
+			QuerySize: &querySize,
+			PageToken: &pageToken,
+			StartTime: &startTime,
+			EndTime:   &endTime,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, changeLogs, 2)
+		nextId, err := utils.DecodeCursor(nextPageToken)
+		assert.NoError(t, err)
+		assert.Equal(t, 12, nextId)
+	})
+
+	t.Run("should cap query size and return no token on the last page", func(t *testing.T) {
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		querySize := int32(1000)
+		bizId := "test-biz-id"
+		userAccountChangeLogDao.On("QueryChangeLogs", ctx, mock.MatchedBy(func(q *model.UserAccountChangeLogQuery) bool {
+			return q.AccountId == nil && *q.IdempotentKey == bizId && q.Limit == repository.DefaultQueryLimit+1
+		})).Return([]*model.UserAccountChangeLog{{ID: 3}}, nil).Once()
+
+		changeLogs, nextPageToken, err := service.GetUserPayHistory(ctx, &paymentpb.PayOrderQueryRequest{BizId: &bizId, QuerySize: &querySize})
+		assert.NoError(t, err)
+		assert.Len(t, changeLogs, 1)
+		assert.Empty(t, nextPageToken)
+	})
+
+	t.Run("should reject an invalid page token", func(t *testing.T) {
+		service := &UserAccountServiceImpl{}
+
+		pageToken := "bad-token"
+		_, _, err := service.GetUserPayHistory(ctx, &paymentpb.PayOrderQueryRequest{UserId: 1, PageToken: &pageToken})
+		assert.Equal(t, paymentpb.RespCode_BAD_REQUEST, bizerror.RespCodeOf(err))
+	})
+}
+
+func TestGetUserTransactions(t *testing.T) {
+	ctx := context.Background()
+	userId := 1
+	initEnv()
+
+	t.Run("should return a page with the next cursor", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		userAccount := &model.UserAccount{ID: 7, UserId: userId}
+		changeLogs := []*model.UserAccountChangeLog{
+			{ID: 9, AccountId: 7, OpType: model.OpTypePayment, Amount: 30, BalanceBefore: 100, BalanceAfter: 70},
+			{ID: 5, AccountId: 7, OpType: model.OpTypePayment, Amount: 10, BalanceBefore: 110, BalanceAfter: 100},
+			{ID: 4, AccountId: 7, OpType: model.OpTypePayment, Amount: 10, BalanceBefore: 120, BalanceAfter: 110},
+		}
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("QueryChangeLogs", ctx, mock.MatchedBy(func(q *model.UserAccountChangeLogQuery) bool {
+			return *q.AccountId == 7 && q.OpType == model.OpTypePayment && q.BeforeId == 10 && q.Limit == 3 &&
+				q.StartTime != nil && q.StartTime.Unix() == 1000 && q.EndTime == nil
+		})).Return(changeLogs, nil).Once()
+
+		page, err := service.GetUserTransactions(ctx, userId, &data.UserPayTransactionQuery{
+			OpType:    model.OpTypePayment,
+			StartTime: 1000,
+			Cursor:    utils.EncodeCursor(10),
+			Limit:     2,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, page.Transactions, 2)
+		assert.Equal(t, 70, page.Transactions[0].BalanceAfter)
+		nextId, err := utils.DecodeCursor(page.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, nextId)
+	})
+
+	t.Run("should return no cursor on the last page", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(&model.UserAccount{ID: 7, UserId: userId}, nil).Once()
+		userAccountChangeLogDao.On("QueryChangeLogs", ctx, mock.Anything).Return([]*model.UserAccountChangeLog{{ID: 1, AccountId: 7}}, nil).Once()
+
+		page, err := service.GetUserTransactions(ctx, userId, &data.UserPayTransactionQuery{Limit: 20})
+		assert.NoError(t, err)
+		assert.Len(t, page.Transactions, 1)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("should reject an invalid cursor", func(t *testing.T) {
+		service := &UserAccountServiceImpl{}
+
+		_, err := service.GetUserTransactions(ctx, userId, &data.UserPayTransactionQuery{Cursor: "not-a-cursor", Limit: 20})
+		assert.Equal(t, paymentpb.RespCode_BAD_REQUEST, bizerror.RespCodeOf(err))
+	})
+
+	t.Run("should return error if user account not found", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		service := &UserAccountServiceImpl{
+			userAccountDao: userAccountDao,
+		}
+
+		userAccountDao.On("GetUserAccountByUserID", ctx, userId).Return(nil, nil).Once()
+
+		_, err := service.GetUserTransactions(ctx, userId, &data.UserPayTransactionQuery{Limit: 20})
+		assert.Equal(t, paymentpb.RespCode_ACCOUNT_NOT_EXIST, bizerror.RespCodeOf(err))
+	})
+}
+
+func TestRefundOrder(t *testing.T) {
+	ctx := context.Background()
+	userId := 1
+	bizId := "test-biz-id"
+	refundId := "test-refund-id"
+	initEnv()
+
+	newPayLog := func() *model.UserAccountChangeLog {
+		return &model.UserAccountChangeLog{ID: 10, AccountId: 1, OpType: model.OpTypePayment, Amount: 100, RefundedAmount: 30, IdempotentKey: bizId}
+	}
+
+	t.Run("should successfully refund order by bizId", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+		}
+
+		payLog := newPayLog()
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 200}
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(payLog, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, refundId, model.OpTypeRefund).Return(nil, nil).Once()
+		userAccountDao.On("GetUserAccountByID", ctx, 1).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+		userAccountChangeLogDao.On("AddRefundedAmountInTransaction", ctx, payLog.ID, 50, mock.Anything).Return(1, nil).Once()
+		userAccountDao.On("RefundBalanceInTransaction", ctx, userId, 50, userAccount.Balance, mock.Anything).Return(nil).Once()
+
+		refundLog, refundedPayLog, err := service.RefundOrder(ctx, &paymentpb.RefundOrderRequest{BizId: &bizId, Amount: 50, RefundId: refundId})
+		assert.NoError(t, err)
+		assert.Equal(t, model.OpTypeRefund, refundLog.OpType)
+		assert.Equal(t, 50, refundLog.Amount)
+		assert.Equal(t, payLog.ID, refundLog.OriginLogId)
+		assert.Equal(t, 80, refundedPayLog.RefundedAmount)
+	})
+
+	t.Run("should refund to an account frozen since the payment", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		db := initMemDb(t)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          &memUserAccountDao{UserAccountDao: userAccountDao},
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: db},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+		}
+
+		payLog := newPayLog()
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, AccountNo: "6200000000000001", Balance: 200, Status: model.UserAccountStatusFrozen}
+		assert.NoError(t, db.Create(userAccount).Error)
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(payLog, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, refundId, model.OpTypeRefund).Return(nil, nil).Once()
+		userAccountDao.On("GetUserAccountByID", ctx, 1).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+		userAccountChangeLogDao.On("AddRefundedAmountInTransaction", ctx, payLog.ID, 50, mock.Anything).Return(1, nil).Once()
+
+		_, _, err := service.RefundOrder(ctx, &paymentpb.RefundOrderRequest{BizId: &bizId, Amount: 50, RefundId: refundId})
+		assert.NoError(t, err)
+		var balance int
+		assert.NoError(t, db.Model(&model.UserAccount{}).Where("user_id = ?", userId).Pluck("balance", &balance).Error)
+		assert.Equal(t, 250, balance)
+	})
+
+	t.Run("should successfully refund order by payOrderId", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+		}
+
+		payLog := newPayLog()
+		payOrderId := payLog.GetPayOrderId()
+		userAccount := &model.UserAccount{ID: 1, UserId: userId, Balance: 200}
+		userAccountChangeLogDao.On("GetChangeLogByID", ctx, payLog.ID).Return(payLog, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, refundId, model.OpTypeRefund).Return(nil, nil).Once()
+		userAccountDao.On("GetUserAccountByID", ctx, 1).Return(userAccount, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+		userAccountChangeLogDao.On("AddRefundedAmountInTransaction", ctx, payLog.ID, 70, mock.Anything).Return(1, nil).Once()
+		userAccountDao.On("RefundBalanceInTransaction", ctx, userId, 70, userAccount.Balance, mock.Anything).Return(nil).Once()
+
+		_, refundedPayLog, err := service.RefundOrder(ctx, &paymentpb.RefundOrderRequest{PayOrderId: &payOrderId, Amount: 70, RefundId: refundId})
+		assert.NoError(t, err)
+		assert.Equal(t, payLog.Amount, refundedPayLog.RefundedAmount)
+	})
+
+	t.Run("should return error if pay order not found", func(t *testing.T) {
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		payOrderId := "1_unknown_99"
+		userAccountChangeLogDao.On("GetChangeLogByID", ctx, 99).Return(nil, nil).Once()
+
+		_, _, err := service.RefundOrder(ctx, &paymentpb.RefundOrderRequest{PayOrderId: &payOrderId, Amount: 10, RefundId: refundId})
+		bizErr, ok := err.(*bizerror.BizError)
+		assert.True(t, ok)
+		assert.Equal(t, int(paymentpb.RespCode_PAY_ORDER_NOT_EXIST), bizErr.Code)
+	})
+
+	t.Run("should return error if refund exceeds paid amount", func(t *testing.T) {
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(newPayLog(), nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, refundId, model.OpTypeRefund).Return(nil, nil).Once()
+
+		_, _, err := service.RefundOrder(ctx, &paymentpb.RefundOrderRequest{BizId: &bizId, Amount: 71, RefundId: refundId})
+		bizErr, ok := err.(*bizerror.BizError)
+		assert.True(t, ok)
+		assert.Equal(t, int(paymentpb.RespCode_REFUND_AMOUNT_EXCEEDED), bizErr.Code)
+	})
+
+	t.Run("should return error if concurrent refunds exceed paid amount", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+		}
+
+		payLog := newPayLog()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(payLog, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, refundId, model.OpTypeRefund).Return(nil, nil).Once()
+		userAccountDao.On("GetUserAccountByID", ctx, 1).Return(&model.UserAccount{ID: 1, UserId: userId, Balance: 200}, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+		userAccountChangeLogDao.On("AddRefundedAmountInTransaction", ctx, payLog.ID, 50, mock.Anything).Return(0, nil).Once()
+
+		_, _, err := service.RefundOrder(ctx, &paymentpb.RefundOrderRequest{BizId: &bizId, Amount: 50, RefundId: refundId})
+		bizErr, ok := err.(*bizerror.BizError)
+		assert.True(t, ok)
+		assert.Equal(t, int(paymentpb.RespCode_REFUND_AMOUNT_EXCEEDED), bizErr.Code)
+		userAccountDao.AssertNotCalled(t, "RefundBalanceInTransaction", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should return the original refund of a replayed refund ID", func(t *testing.T) {
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		// the refund being replayed took what was left of the payment
+		payLog := newPayLog()
+		payLog.RefundedAmount = payLog.Amount
+		original := &model.UserAccountChangeLog{ID: 11, OpType: model.OpTypeRefund, Amount: 70, OriginLogId: payLog.ID, IdempotentKey: refundId}
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(payLog, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, refundId, model.OpTypeRefund).Return(original, nil).Once()
+
+		refundLog, refundedPayLog, err := service.RefundOrder(ctx, &paymentpb.RefundOrderRequest{BizId: &bizId, Amount: 70, RefundId: refundId})
+		assert.Equal(t, paymentpb.RespCode_DUPLICATE_REQUEST, bizerror.RespCodeOf(err))
+		assert.Equal(t, original, refundLog)
+		assert.Equal(t, payLog, refundedPayLog)
+		userAccountChangeLogDao.AssertNotCalled(t, "CreateChangeLogInTransaction", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should reject a refund ID used by a different refund", func(t *testing.T) {
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		original := &model.UserAccountChangeLog{ID: 11, OpType: model.OpTypeRefund, Amount: 20, OriginLogId: 10, IdempotentKey: refundId}
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(newPayLog(), nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, refundId, model.OpTypeRefund).Return(original, nil).Once()
+
+		refundLog, _, err := service.RefundOrder(ctx, &paymentpb.RefundOrderRequest{BizId: &bizId, Amount: 10, RefundId: refundId})
+		assert.Equal(t, paymentpb.RespCode_IDEMPOTENCY_KEY_CONFLICT, bizerror.RespCodeOf(err))
+		assert.Nil(t, refundLog)
+	})
+
+	t.Run("should write a refund-completed event in the transaction", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		outboxDao := new(mocks.OutboxDao)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               outboxDao,
+		}
+
+		payLog := newPayLog()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(payLog, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, refundId, model.OpTypeRefund).Return(nil, nil).Once()
+		userAccountDao.On("GetUserAccountByID", ctx, 1).Return(&model.UserAccount{ID: 1, UserId: userId, Balance: 200}, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(1).(*model.UserAccountChangeLog).ID = 12
+		}).Once()
+		userAccountChangeLogDao.On("AddRefundedAmountInTransaction", ctx, payLog.ID, 50, mock.Anything).Return(1, nil).Once()
+		userAccountDao.On("RefundBalanceInTransaction", ctx, userId, 50, 200, mock.Anything).Return(nil).Once()
+		outboxDao.On("CreateEventInTransaction", ctx, mock.MatchedBy(func(outboxEvent *model.OutboxEvent) bool {
+			var refunded event.RefundCompleted
+			return outboxEvent.EventType == event.TypeRefundCompleted && outboxEvent.EventKey == "1" &&
+				json.Unmarshal([]byte(outboxEvent.Payload), &refunded) == nil && refunded.RefundId == refundId &&
+				refunded.BizId == bizId && refunded.PayOrderId == payLog.GetPayOrderId() && refunded.ChangeLogId == 12 &&
+				refunded.Amount == 50 && refunded.BalanceAfter == 250
+		}), mock.Anything).Return(nil).Once()
+
+		_, _, err := service.RefundOrder(ctx, &paymentpb.RefundOrderRequest{BizId: &bizId, Amount: 50, RefundId: refundId})
+		assert.NoError(t, err)
+		outboxDao.AssertExpectations(t)
+	})
+}
+
+func TestRefundCancelledOrder(t *testing.T) {
+	ctx := context.Background()
+	userId := 1
+	bizId := "test-biz-id"
+	refundId := "oc_10"
+	initEnv()
+
+	t.Run("should refund what is left of the payment", func(t *testing.T) {
+		userAccountDao := new(mocks.UserAccountDao)
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: userAccountChangeLogDao,
+			txBeginner:              &fakeTx{DB: initMemDb(t)},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+		}
+
+		payLog := &model.UserAccountChangeLog{ID: 10, AccountId: 1, OpType: model.OpTypePayment, Amount: 100, RefundedAmount: 30, IdempotentKey: bizId}
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, refundId, model.OpTypeRefund).Return(nil, nil).Twice()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(payLog, nil).Twice()
+		userAccountDao.On("GetUserAccountByID", ctx, 1).Return(&model.UserAccount{ID: 1, UserId: userId, Balance: 200}, nil).Once()
+		userAccountChangeLogDao.On("CreateChangeLogInTransaction", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+		userAccountChangeLogDao.On("AddRefundedAmountInTransaction", ctx, payLog.ID, 70, mock.Anything).Return(1, nil).Once()
+		userAccountDao.On("RefundBalanceInTransaction", ctx, userId, 70, 200, mock.Anything).Return(nil).Once()
+
+		refundLog, err := service.RefundCancelledOrder(ctx, bizId)
+		assert.NoError(t, err)
+		assert.Equal(t, 70, refundLog.Amount)
+		assert.Equal(t, refundId, refundLog.IdempotentKey)
+		userAccountDao.AssertExpectations(t)
+	})
+
+	t.Run("should return the earlier refund of a redelivered cancellation", func(t *testing.T) {
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		payLog := &model.UserAccountChangeLog{ID: 10, AccountId: 1, OpType: model.OpTypePayment, Amount: 100, RefundedAmount: 100, IdempotentKey: bizId}
+		earlier := &model.UserAccountChangeLog{ID: 11, OpType: model.OpTypeRefund, Amount: 70, IdempotentKey: refundId}
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(payLog, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, refundId, model.OpTypeRefund).Return(earlier, nil).Once()
+
+		refundLog, err := service.RefundCancelledOrder(ctx, bizId)
+		assert.NoError(t, err)
+		assert.Equal(t, earlier, refundLog)
+		userAccountChangeLogDao.AssertNotCalled(t, "CreateChangeLogInTransaction", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should refund nothing when the payment was refunded in full", func(t *testing.T) {
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		payLog := &model.UserAccountChangeLog{ID: 10, AccountId: 1, OpType: model.OpTypePayment, Amount: 100, RefundedAmount: 100, IdempotentKey: bizId}
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, refundId, model.OpTypeRefund).Return(nil, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(payLog, nil).Once()
+
+		refundLog, err := service.RefundCancelledOrder(ctx, bizId)
+		assert.NoError(t, err)
+		assert.Nil(t, refundLog)
+	})
+
+	t.Run("should return error if the order was not paid", func(t *testing.T) {
+		userAccountChangeLogDao := new(mocks.UserAccountChangeLogDAO)
+		service := &UserAccountServiceImpl{
+			userAccountChangeLogDao: userAccountChangeLogDao,
+		}
+
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypePayment).Return(nil, nil).Once()
+		userAccountChangeLogDao.On("GetChangeLogByIdempotentKey", ctx, bizId, model.OpTypeCapture).Return(nil, nil).Once()
+
+		_, err := service.RefundCancelledOrder(ctx, bizId)
+		assert.Equal(t, paymentpb.RespCode_PAY_ORDER_NOT_EXIST, bizerror.RespCodeOf(err))
+		userAccountChangeLogDao.AssertNotCalled(t, "GetChangeLogByIdempotentKey", ctx, mock.Anything, model.OpTypeRefund)
+	})
+
+	t.Run("should fit the refund id of the longest biz id into an idempotent key", func(t *testing.T) {
+		memDB := initMemDb(t)
+		// the unique index of the change logs of the MySQL schema, which also caps idempotent keys at 32 characters
+		assert.NoError(t, memDB.Exec("CREATE UNIQUE INDEX idempotent_key_uniq ON user_account_change_logs (idempotent_key, op_type)").Error)
+		userAccountDao := new(mocks.UserAccountDao)
+		service := &UserAccountServiceImpl{
+			userAccountDao:          userAccountDao,
+			userAccountChangeLogDao: &memChangeLogDao{db: memDB},
+			txBeginner:              &fakeTx{DB: memDB},
+			ledger:                  newLedgerMock(),
+			outboxDao:               newOutboxDaoMock(),
+		}
+		// two orders whose biz ids only differ past the 16th character
+		longBizIds := []string{"order-2026-10-17-00000000-000001", "order-2026-10-17-00000000-000002"}
+		for _, longBizId := range longBizIds {
+			assert.Len(t, longBizId, 32)
+			payLog := &model.UserAccountChangeLog{AccountId: 1, OpType: model.OpTypePayment, Amount: 100, IdempotentKey: longBizId}
+			assert.NoError(t, memDB.Create(payLog).Error)
+		}
+		userAccountDao.On("GetUserAccountByID", mock.Anything, 1).Return(&model.UserAccount{ID: 1, UserId: userId, Balance: 200}, nil)
+		userAccountDao.On("RefundBalanceInTransaction", mock.Anything, userId, 100, mock.Anything, mock.Anything).Return(nil)
+
+		refundIds := make(map[string]struct{})
+		for _, longBizId := range longBizIds {
+			refundLog, err := service.RefundCancelledOrder(ctx, longBizId)
+			assert.NoError(t, err)
+			assert.LessOrEqual(t, len(refundLog.IdempotentKey), 32)
+			refundIds[refundLog.IdempotentKey] = struct{}{}
+
+			// a redelivered cancellation finds the refund under the same key
+			again, err := service.RefundCancelledOrder(ctx, longBizId)
+			assert.NoError(t, err)
+			assert.Equal(t, refundLog.ID, again.ID)
+		}
+		assert.Len(t, refundIds, 2)
+	})
+}
+
+type fakeTx struct{ *gorm.DB }
+
+// memChangeLogDao runs the change log dao against an in-memory database, the lookups it makes outside of a
+// transaction included.
+// memUserAccountDao runs the balance updates of the real dao against the memory database of the transaction.
+type memUserAccountDao struct {
+	*mocks.UserAccountDao
+	impl dao.UserAccountDaoImpl
+}
+
+func (m *memUserAccountDao) SubtractBalanceInTransaction(ctx context.Context, userID int, amount int, oldAmount int, tx *gorm.DB) (int, error) {
+	return m.impl.SubtractBalanceInTransaction(ctx, userID, amount, oldAmount, tx)
+}
+
+func (m *memUserAccountDao) RefundBalanceInTransaction(ctx context.Context, userID int, amount int, oldAmount int, tx *gorm.DB) error {
+	return m.impl.RefundBalanceInTransaction(ctx, userID, amount, oldAmount, tx)
+}
+
+type memChangeLogDao struct {
+	dao.UserAccountChangeLogDAOImpl
+	db *gorm.DB
+}
+
+func (m *memChangeLogDao) GetChangeLogByIdempotentKey(ctx context.Context, idempotentKey string, opType int) (*model.UserAccountChangeLog, error) {
+	var changeLog model.UserAccountChangeLog
+	err := m.db.WithContext(ctx).Where("idempotent_key = ? and op_type = ?", idempotentKey, opType).First(&changeLog).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &changeLog, nil
+}
+
+func (f *fakeTx) Transaction(fn func(tx *gorm.DB) error, opts ...*sql.TxOptions) error {
+	return fn(f.DB) // Pass through the same DB instance
+}
+
+// newLedgerMock returns a ledger that accepts every posting.
+func newLedgerMock() *ledgermocks.Ledger {
+	ledgerMock := new(ledgermocks.Ledger)
+	ledgerMock.On("PostInTransaction", mock.Anything, mock.Anything, mock.Anything).Return(&model.JournalEntry{}, nil).Maybe()
+	return ledgerMock
+}
+
+func newOutboxDaoMock() *mocks.OutboxDao {
+	outboxDao := new(mocks.OutboxDao)
+	outboxDao.On("CreateEventInTransaction", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	return outboxDao
 }
 
 func initMemDb(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
 	assert.NoError(t, err)
 	assert.NoError(t, db.AutoMigrate(&model.UserAccount{}, &model.UserAccountChangeLog{}, &model.RedeemCode{}))
 	return db
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type pageCursor struct {
+	LastId int `json:"last_id"`
+}
+
+// EncodeCursor turns the id of the last row of a page into an opaque page token.
+func EncodeCursor(lastId int) string {
+	b, _ := json.Marshal(&pageCursor{LastId: lastId})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor returns the last row id carried by a token made by EncodeCursor, an empty token decodes to 0.
+func DecodeCursor(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	var cursor pageCursor
+	if err = json.Unmarshal(b, &cursor); err != nil || cursor.LastId <= 0 {
+		return 0, ErrInvalidCursor
+	}
+	return cursor.LastId, nil
+}
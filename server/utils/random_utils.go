@@ -5,16 +5,29 @@ import "crypto/rand"
 const charset = "0123456789"
 
 func GenRedeemCode(length int) string {
-	code := make([]byte, length)
-	for i := range code {
-		b := make([]byte, 1)
-		_, err := rand.Read(b)
-		if err != nil {
-			// fallback: use '0' if random fails
-			code[i] = charset[0]
-			continue
+	return RandomString(charset, length)
+}
+
+// RandomString returns length characters drawn uniformly from alphabet, which holds at most 256 bytes.
+// Random bytes past the largest multiple of len(alphabet) are thrown away, so no character is favoured.
+func RandomString(alphabet string, length int) string {
+	limit := 256 - 256%len(alphabet)
+	code := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(code) < length {
+		if _, err := rand.Read(buf); err != nil {
+			// crypto/rand does not fail on supported platforms
+			panic(err)
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			code = append(code, alphabet[int(b)%len(alphabet)])
+			if len(code) == length {
+				break
+			}
 		}
-		code[i] = charset[int(b[0])%len(charset)]
 	}
 	return string(code)
 }
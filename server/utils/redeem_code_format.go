@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+const (
+	// no 0/O, 1/I/L, which are misread on print
+	DefaultRedeemCodeAlphabet  = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+	DefaultRedeemCodeLength    = 15
+	DefaultRedeemCodeGroupSize = 4
+
+	// codes generated before formats had check characters, 16 decimal digits
+	legacyRedeemCodeLength = 16
+	maxRedeemCodePrefixLen = 8
+	// canonical codes, check character included, fit redeem_codes.code
+	minRedeemCodeLen = 4
+	maxRedeemCodeLen = 32
+)
+
+var ErrMalformedRedeemCode = errors.New("malformed redeem code")
+
+// RedeemCodeFormat describes how redeem codes are generated and checked. A code is an optional campaign prefix,
+// length random characters of the alphabet and a Luhn mod N check character over everything before it. Codes
+// are stored and hashed in canonical form, upper case without dashes, and shown in dash separated groups.
+type RedeemCodeFormat struct {
+	alphabet  string
+	index     map[rune]int
+	length    int
+	groupSize int
+}
+
+// NewRedeemCodeFormat checks the alphabet, it must hold at least two distinct upper case letters or digits.
+// A groupSize of 0 or less shows codes without dashes.
+func NewRedeemCodeFormat(alphabet string, length int, groupSize int) (*RedeemCodeFormat, error) {
+	if len(alphabet) < 2 {
+		return nil, errors.New("redeem code alphabet needs at least 2 characters")
+	}
+	if length < minRedeemCodeLen || length+1+maxRedeemCodePrefixLen > maxRedeemCodeLen {
+		return nil, errors.New("redeem code length must be between 4 and 23")
+	}
+	index := make(map[rune]int, len(alphabet))
+	for i, c := range alphabet {
+		if c > unicode.MaxASCII || !(unicode.IsUpper(c) || unicode.IsDigit(c)) {
+			return nil, errors.New("redeem code alphabet may only hold upper case letters and digits")
+		}
+		if _, exists := index[c]; exists {
+			return nil, errors.New("redeem code alphabet holds " + string(c) + " twice")
+		}
+		index[c] = i
+	}
+	return &RedeemCodeFormat{alphabet: alphabet, index: index, length: length, groupSize: groupSize}, nil
+}
+
+// CheckPrefix reports whether prefix can start a code, up to 8 characters of the alphabet.
+func (f *RedeemCodeFormat) CheckPrefix(prefix string) error {
+	if len(prefix) > maxRedeemCodePrefixLen {
+		return errors.New("redeem code prefix is longer than 8 characters")
+	}
+	if !f.inAlphabet(prefix) {
+		return errors.New("redeem code prefix " + prefix + " holds characters outside the alphabet")
+	}
+	return nil
+}
+
+// Generate returns a new code in canonical form. prefix must pass CheckPrefix.
+func (f *RedeemCodeFormat) Generate(prefix string) string {
+	return f.WithCheck(prefix + RandomString(f.alphabet, f.length))
+}
+
+// FromBody turns a code picked by hand, such as a promo code, into a code of this format by appending the
+// check character. It returns ErrMalformedRedeemCode when the body holds characters outside the alphabet.
+func (f *RedeemCodeFormat) FromBody(body string) (string, error) {
+	body = canonicalRedeemCode(body)
+	if len(body) < minRedeemCodeLen-1 || len(body) >= maxRedeemCodeLen || !f.inAlphabet(body) {
+		return "", ErrMalformedRedeemCode
+	}
+	return f.WithCheck(body), nil
+}
+
+// WithCheck appends the check character to body, which must only hold characters of the alphabet.
+func (f *RedeemCodeFormat) WithCheck(body string) string {
+	return body + string(f.alphabet[f.checkIndex(body, false)])
+}
+
+// Normalize turns user input into canonical form, dropping dashes and spaces and upper casing it. It returns
+// ErrMalformedRedeemCode unless the result is a well-formed code of this format or a legacy code.
+func (f *RedeemCodeFormat) Normalize(input string) (string, error) {
+	code := canonicalRedeemCode(input)
+	if isLegacyRedeemCode(code) {
+		return code, nil
+	}
+	if len(code) < minRedeemCodeLen || len(code) > maxRedeemCodeLen || !f.inAlphabet(code) {
+		return "", ErrMalformedRedeemCode
+	}
+	if f.checkIndex(code, true) != 0 {
+		return "", ErrMalformedRedeemCode
+	}
+	return code, nil
+}
+
+// Display splits a canonical code into dash separated groups, counted from the end so that a prefix of any
+// length leads the first group.
+func (f *RedeemCodeFormat) Display(code string) string {
+	if f.groupSize <= 0 || isLegacyRedeemCode(code) {
+		return code
+	}
+	var b strings.Builder
+	for i, c := range code {
+		if i > 0 && (len(code)-i)%f.groupSize == 0 {
+			b.WriteByte('-')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// checkIndex runs Luhn mod N over code. Without the check character it returns the index of the character
+// to append, with it the sum of a well-formed code, which is 0.
+func (f *RedeemCodeFormat) checkIndex(code string, withCheck bool) int {
+	n := len(f.alphabet)
+	factor, sum := 2, 0
+	if withCheck {
+		factor = 1
+	}
+	for i := len(code) - 1; i >= 0; i-- {
+		addend := factor * f.index[rune(code[i])]
+		addend = addend/n + addend%n
+		sum += addend
+		factor = 3 - factor
+	}
+	if withCheck {
+		return sum % n
+	}
+	return (n - sum%n) % n
+}
+
+func (f *RedeemCodeFormat) inAlphabet(s string) bool {
+	for _, c := range s {
+		if _, ok := f.index[c]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func canonicalRedeemCode(input string) string {
+	return strings.Map(func(c rune) rune {
+		if c == '-' || unicode.IsSpace(c) {
+			return -1
+		}
+		return unicode.ToUpper(c)
+	}, input)
+}
+
+func isLegacyRedeemCode(code string) bool {
+	if len(code) != legacyRedeemCodeLength {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
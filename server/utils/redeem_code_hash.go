@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// RedeemCodeSuffixLen is how many trailing characters of a code are kept in clear to tell codes apart.
+const RedeemCodeSuffixLen = 4
+
+// HashRedeemCode returns the hex HMAC-SHA256 of the code keyed by secret, the form codes are looked up by.
+func HashRedeemCode(secret []byte, code string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RedeemCodeSuffix returns the last RedeemCodeSuffixLen characters of the code.
+func RedeemCodeSuffix(code string) string {
+	if len(code) <= RedeemCodeSuffixLen {
+		return code
+	}
+	return code[len(code)-RedeemCodeSuffixLen:]
+}
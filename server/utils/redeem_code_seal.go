@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+// SealRedeemCode encrypts the code with AES-256-GCM under a key derived from key, for codes whose plaintext has to
+// be kept until it is handed out. The hash of the code is bound to the result, so a sealed code only opens on its
+// own row. The nonce is prepended to the ciphertext.
+func SealRedeemCode(key []byte, code string, codeHash string) ([]byte, error) {
+	aead, err := newRedeemCodeAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(code)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, []byte(code), []byte(codeHash)), nil
+}
+
+// OpenRedeemCode returns the plaintext of a code sealed by SealRedeemCode with the same key and hash.
+func OpenRedeemCode(key []byte, sealed []byte, codeHash string) (string, error) {
+	aead, err := newRedeemCodeAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("sealed redeem code is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	code, err := aead.Open(nil, nonce, ciphertext, []byte(codeHash))
+	if err != nil {
+		return "", err
+	}
+	return string(code), nil
+}
+
+func newRedeemCodeAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, errors.New("redeem code encryption key is not set")
+	}
+	derived := sha256.Sum256(key)
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}